@@ -0,0 +1,270 @@
+// mock-oidc 本地调试用的模拟OIDC身份提供商
+//
+// 支持授权码模式和PKCE(S256)，授权页面可以填写要模拟的用户信息和组，不要在生产环境使用。
+//
+//	go run ./cmd/mock-oidc -addr :9000 -client-id emby-manager
+//
+// 对应的后端配置：
+//
+//	OIDC_ENABLED=true OIDC_ISSUER=http://localhost:9000 OIDC_CLIENT_ID=emby-manager
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mock-oidc"
+
+// authCode 已签发但尚未换取令牌的授权码
+type authCode struct {
+	clientID      string
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	claims        jwt.MapClaims
+	expiresAt     time.Time
+}
+
+type server struct {
+	issuer   string
+	clientID string
+	key      *rsa.PrivateKey
+
+	mutex sync.Mutex
+	codes map[string]*authCode
+}
+
+var authorizePage = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Mock OIDC</title></head>
+<body style="font-family:sans-serif;max-width:420px;margin:40px auto">
+<h2>Mock OIDC 登录</h2>
+<form method="post">
+  {{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{$v}}">{{end}}
+  <p><label>sub<br><input name="sub" value="mock-user-1" style="width:100%"></label></p>
+  <p><label>preferred_username<br><input name="preferred_username" value="mockuser" style="width:100%"></label></p>
+  <p><label>email<br><input name="email" value="mockuser@example.com" style="width:100%"></label></p>
+  <p><label><input type="checkbox" name="email_verified" value="true" checked> email_verified</label></p>
+  <p><label>name<br><input name="name" value="Mock User" style="width:100%"></label></p>
+  <p><label>groups（逗号分隔）<br><input name="groups" value="emby-users" style="width:100%"></label></p>
+  <button type="submit">登录</button>
+</form>
+</body></html>`))
+
+func main() {
+	addr := flag.String("addr", ":9000", "监听地址")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer地址，必须与后端oidc.issuer一致")
+	clientID := flag.String("client-id", "emby-manager", "允许的client_id")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal("生成签名密钥失败:", err)
+	}
+
+	s := &server{
+		issuer:   strings.TrimRight(*issuer, "/"),
+		clientID: *clientID,
+		key:      key,
+		codes:    make(map[string]*authCode),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+
+	log.Printf("模拟OIDC身份提供商已启动: %s (client_id=%s)", s.issuer, s.clientID)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func (s *server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.issuer + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"jwks_uri":                              s.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "profile", "email", "groups"},
+	})
+}
+
+func (s *server) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize GET显示登录表单，POST签发授权码并跳转回客户端
+func (s *server) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		params := map[string]string{}
+		for _, name := range []string{"client_id", "redirect_uri", "state", "nonce", "code_challenge", "code_challenge_method"} {
+			params[name] = r.Form.Get(name)
+		}
+		if params["client_id"] != s.clientID {
+			http.Error(w, "unknown client_id", http.StatusBadRequest)
+			return
+		}
+		if params["code_challenge"] == "" || params["code_challenge_method"] != "S256" {
+			http.Error(w, "PKCE (S256) is required", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		authorizePage.Execute(w, map[string]interface{}{"Params": params})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"sub":                r.Form.Get("sub"),
+		"preferred_username": r.Form.Get("preferred_username"),
+		"email":              r.Form.Get("email"),
+		"email_verified":     r.Form.Get("email_verified") == "true",
+		"name":               r.Form.Get("name"),
+	}
+	var groups []string
+	for _, g := range strings.Split(r.Form.Get("groups"), ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	claims["groups"] = groups
+
+	code := randomString()
+	s.mutex.Lock()
+	s.codes[code] = &authCode{
+		clientID:      r.Form.Get("client_id"),
+		redirectURI:   r.Form.Get("redirect_uri"),
+		codeChallenge: r.Form.Get("code_challenge"),
+		nonce:         r.Form.Get("nonce"),
+		claims:        claims,
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	s.mutex.Unlock()
+
+	redirect, err := url.Parse(r.Form.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	q := redirect.Query()
+	q.Set("code", code)
+	q.Set("state", r.Form.Get("state"))
+	redirect.RawQuery = q.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token 校验授权码、redirect_uri和PKCE后签发ID Token
+func (s *server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request", err.Error())
+		return
+	}
+	if r.Form.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+
+	clientID := r.Form.Get("client_id")
+	if id, _, ok := r.BasicAuth(); ok {
+		clientID = id
+	}
+
+	code := r.Form.Get("code")
+	s.mutex.Lock()
+	ac, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mutex.Unlock()
+
+	if !ok || time.Now().After(ac.expiresAt) {
+		tokenError(w, "invalid_grant", "unknown or expired code")
+		return
+	}
+	if clientID != ac.clientID || r.Form.Get("redirect_uri") != ac.redirectURI {
+		tokenError(w, "invalid_grant", "client_id or redirect_uri mismatch")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != ac.codeChallenge {
+		tokenError(w, "invalid_grant", "PKCE verification failed")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{}
+	for k, v := range ac.claims {
+		claims[k] = v
+	}
+	claims["iss"] = s.issuer
+	claims["aud"] = ac.clientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(5 * time.Minute).Unix()
+	if ac.nonce != "" {
+		claims["nonce"] = ac.nonce
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = keyID
+	signed, err := idToken.SignedString(s.key)
+	if err != nil {
+		tokenError(w, "server_error", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
+
+func tokenError(w http.ResponseWriter, code, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -3,10 +3,16 @@ package main
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/emby-client-go/backend/internal/config"
 	"github.com/emby-client-go/backend/internal/database"
 	"github.com/emby-client-go/backend/internal/handlers"
+	"github.com/emby-client-go/backend/internal/mail"
+	"github.com/emby-client-go/backend/internal/metrics"
+	"github.com/emby-client-go/backend/internal/scheduler"
+	"github.com/emby-client-go/backend/internal/secrets"
+	"github.com/emby-client-go/backend/internal/services"
 	"github.com/emby-client-go/backend/pkg/websocket"
 	"github.com/gin-gonic/gin"
 )
@@ -20,12 +26,44 @@ func main() {
 	// 初始化配置
 	config.Init()
 
+	// 初始化敏感数据加密密钥
+	// 派生密钥会随jwt.secret轮换而改变，导致已加密的数据无法解密，生产模式必须显式配置
+	enc := config.AppConfig.Encryption
+	if enc.Key == "" && config.AppConfig.Server.Mode == "release" {
+		log.Fatal("生产模式必须配置encryption.key（环境变量ENCRYPTION_KEY），可用 openssl rand -base64 32 生成")
+	}
+	if err := secrets.Init(enc.KeyID, enc.Key, enc.PreviousKeys, config.AppConfig.JWT.Secret); err != nil {
+		log.Fatal("加密密钥初始化失败:", err)
+	}
+	if enc.Key == "" {
+		log.Println("警告: 未配置encryption.key，使用由JWT密钥派生的加密密钥，修改jwt.secret后已加密的数据将无法解密")
+	}
+
+	// 初始化邮件发送
+	if err := mail.Init(config.AppConfig.Mail); err != nil {
+		log.Fatal("邮件配置错误:", err)
+	}
+	if config.AppConfig.Mail.Driver == "log" {
+		log.Println("警告: 邮件驱动为log，邮件只打印到日志，不会实际发送")
+	}
+
 	// 初始化数据库
 	if err := database.Init(); err != nil {
 		log.Fatal("数据库初始化失败:", err)
 	}
 	defer database.Close()
 
+	// 初始化系统权限和内置角色
+	if err := services.NewRBACService().EnsureDefaults(); err != nil {
+		log.Fatal("角色权限初始化失败:", err)
+	}
+
+	// 初始化令牌吊销列表
+	services.InitRevocationStore(config.AppConfig.Redis)
+
+	// 初始化IP登录失败计数器
+	services.InitLoginThrottle(config.AppConfig.Redis)
+
 	// 初始化WebSocket Hub
 	hub := websocket.NewHub()
 	go hub.Run()
@@ -33,18 +71,65 @@ func main() {
 
 	// 初始化WebSocket Manager
 	wsManager := websocket.NewManager(hub)
+	wsManager.SetStatusHandler(services.RecordWebSocketStatus)
 	log.Println("WebSocket Manager已初始化")
 
+	// 注册连接数和数据库连接池指标
+	if config.AppConfig.Metrics.Enabled {
+		sqlDB, err := database.DB.DB()
+		if err != nil {
+			log.Fatal("获取数据库实例失败:", err)
+		}
+		metrics.Register(hub, wsManager, sqlDB)
+		if config.AppConfig.Metrics.Token == "" {
+			log.Println("警告: 未配置metrics.token，/metrics 无需认证即可访问")
+		}
+	}
+
+	// 初始化同步任务服务
+	syncJobService := services.NewSyncJobService(hub)
+	if err := syncJobService.RecoverInterruptedJobs(); err != nil {
+		log.Printf("恢复同步任务失败: %v", err)
+	}
+
+	// 初始化后台任务调度器
+	sched := scheduler.New(config.AppConfig.Scheduler, syncJobService)
+	if config.AppConfig.Scheduler.Enabled {
+		sched.Start()
+		defer sched.Stop()
+	}
+
+	// 启动LDAP组同步
+	if ldapService := services.NewLDAPService(); ldapService.Enabled() {
+		interval, err := scheduler.ParseSchedule(config.AppConfig.LDAP.GroupSyncSchedule)
+		if err != nil {
+			log.Fatal("LDAP组同步配置错误:", err)
+		}
+		if interval > 0 {
+			stop := ldapService.StartGroupSync(interval)
+			defer stop()
+		}
+	}
+
+	// 启动告警评估
+	if interval := config.AppConfig.Alerting.EvaluateInterval; interval > 0 {
+		stop := services.NewAlertService().StartEvaluator(time.Duration(interval) * time.Second)
+		defer stop()
+	}
+
 	// 设置Gin模式
 	if config.AppConfig.Server.Mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// 创建Gin引擎
+	// 创建Gin引擎，只信任配置的反向代理传递的客户端IP
 	r := gin.Default()
+	if err := r.SetTrustedProxies(config.AppConfig.Server.TrustedProxies); err != nil {
+		log.Fatal("反向代理配置错误:", err)
+	}
 
 	// 设置路由
-	handlers.SetupRoutes(r, hub, wsManager)
+	handlers.SetupRoutes(r, hub, wsManager, sched, syncJobService)
 
 	// 启动服务器
 	addr := fmt.Sprintf("%s:%d", config.AppConfig.Server.Host, config.AppConfig.Server.Port)
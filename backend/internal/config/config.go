@@ -2,16 +2,28 @@ package config
 
 import (
 	"log"
+	"strings"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	Emby     EmbyConfig     `mapstructure:"emby"`
-	Log      LogConfig      `mapstructure:"log"`
+	Server        ServerConfig        `mapstructure:"server"`
+	Database      DatabaseConfig      `mapstructure:"database"`
+	Redis         RedisConfig         `mapstructure:"redis"`
+	JWT           JWTConfig           `mapstructure:"jwt"`
+	Emby          EmbyConfig          `mapstructure:"emby"`
+	Encryption    EncryptionConfig    `mapstructure:"encryption"`
+	OIDC          OIDCConfig          `mapstructure:"oidc"`
+	LDAP          LDAPConfig          `mapstructure:"ldap"`
+	Mail          MailConfig          `mapstructure:"mail"`
+	Account       AccountConfig       `mapstructure:"account"`
+	Security      SecurityConfig      `mapstructure:"security"`
+	Scheduler     SchedulerConfig     `mapstructure:"scheduler"`
+	ServerMetrics ServerMetricsConfig `mapstructure:"server_metrics"`
+	Metrics       MetricsConfig       `mapstructure:"metrics"`
+	Alerting      AlertingConfig      `mapstructure:"alerting"`
+	Log           LogConfig           `mapstructure:"log"`
 }
 
 type ServerConfig struct {
@@ -20,6 +32,9 @@ type ServerConfig struct {
 	Mode         string `mapstructure:"mode"`
 	ReadTimeout  int    `mapstructure:"read_timeout"`
 	WriteTimeout int    `mapstructure:"write_timeout"`
+	// TrustedProxies 信任的反向代理地址或网段，只有来自这些地址的请求才会读取X-Forwarded-For等请求头获取客户端IP
+	// 默认不信任任何代理，登录限流、审计和令牌记录的IP为直接连接的地址
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 type DatabaseConfig struct {
@@ -35,6 +50,7 @@ type DatabaseConfig struct {
 }
 
 type RedisConfig struct {
+	Enabled  bool   `mapstructure:"enabled"` // 启用后令牌吊销列表保存在Redis中，多副本共享
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
 	Password string `mapstructure:"password"`
@@ -42,16 +58,146 @@ type RedisConfig struct {
 }
 
 type JWTConfig struct {
-	Secret     string `mapstructure:"secret"`
-	ExpireTime int    `mapstructure:"expire_time"`
-	Issuer     string `mapstructure:"issuer"`
+	Secret            string `mapstructure:"secret"`
+	ExpireTime        int    `mapstructure:"expire_time"`         // 访问令牌有效期（秒）
+	RefreshExpireTime int    `mapstructure:"refresh_expire_time"` // 刷新令牌有效期（秒）
+	Issuer            string `mapstructure:"issuer"`
 }
 
 type EmbyConfig struct {
-	DefaultTimeout int  `mapstructure:"default_timeout"`
-	MaxRetryTimes  int  `mapstructure:"max_retry_times"`
-	EnableCache    bool `mapstructure:"enable_cache"`
-	CacheTTL       int  `mapstructure:"cache_ttl"`
+	DefaultTimeout int    `mapstructure:"default_timeout"`
+	MaxRetryTimes  int    `mapstructure:"max_retry_times"`
+	EnableCache    bool   `mapstructure:"enable_cache"`
+	CacheTTL       int    `mapstructure:"cache_ttl"`
+	AuthMode       string `mapstructure:"auth_mode"`   // header: 请求头认证, query: api_key查询参数
+	ClientName     string `mapstructure:"client_name"` // 上报给Emby的客户端名称
+	DeviceID       string `mapstructure:"device_id"`   // 上报给Emby的设备ID，留空时使用主机名
+}
+
+// EncryptionConfig 敏感数据加密配置
+// 生产模式必须设置key；调试模式未设置时使用由JWT密钥派生的默认主密钥，此时不能修改JWT密钥，否则已加密的数据无法解密
+// 轮换密钥时把旧密钥加入previous_keys，启动时会自动用新密钥重新加密
+type EncryptionConfig struct {
+	Key          string `mapstructure:"key"`           // base64编码的32字节主密钥
+	KeyID        string `mapstructure:"key_id"`        // 主密钥ID，写入密文用于轮换
+	PreviousKeys string `mapstructure:"previous_keys"` // 历史主密钥，格式 "ID:base64密钥,ID:base64密钥"
+}
+
+// OIDCConfig OIDC单点登录配置（授权码模式 + PKCE）
+type OIDCConfig struct {
+	Enabled              bool     `mapstructure:"enabled"`
+	ProviderName         string   `mapstructure:"provider_name"` // 登录页按钮上显示的名称
+	Issuer               string   `mapstructure:"issuer"`
+	ClientID             string   `mapstructure:"client_id"`
+	ClientSecret         string   `mapstructure:"client_secret"` // 公共客户端可留空，仅依赖PKCE
+	RedirectURL          string   `mapstructure:"redirect_url"`  // 回调地址，如 https://emby.example.com/api/auth/oidc/callback
+	FrontendURL          string   `mapstructure:"frontend_url"`  // 登录完成后浏览器跳转的前端地址
+	Scopes               []string `mapstructure:"scopes"`
+	GroupsClaim          string   `mapstructure:"groups_claim"`
+	RoleMapping          []string `mapstructure:"role_mapping"`           // 组到角色的映射，格式 "组名=角色"，按顺序取第一个匹配
+	DefaultRole          string   `mapstructure:"default_role"`           // 自动创建用户且没有匹配的组时使用的角色
+	AutoProvision        bool     `mapstructure:"auto_provision"`         // 首次登录时自动创建用户
+	RequireVerifiedEmail bool     `mapstructure:"require_verified_email"` // 按邮箱关联已有账户时要求email_verified为true
+}
+
+// LDAPConfig LDAP目录认证配置
+// 登录时先用服务账号按user_filter搜索用户，再用用户DN和密码绑定校验
+type LDAPConfig struct {
+	Enabled            bool     `mapstructure:"enabled"`
+	URL                string   `mapstructure:"url"`                  // 如 ldap://ldap.example.com:389 或 ldaps://ldap.example.com:636
+	StartTLS           bool     `mapstructure:"start_tls"`            // ldap://连接建立后升级为TLS
+	InsecureSkipVerify bool     `mapstructure:"insecure_skip_verify"` // 跳过证书校验，仅用于测试环境
+	BindDN             string   `mapstructure:"bind_dn"`              // 搜索用户使用的服务账号，留空时匿名搜索
+	BindPassword       string   `mapstructure:"bind_password"`
+	BaseDN             string   `mapstructure:"base_dn"`
+	UserFilter         string   `mapstructure:"user_filter"` // 用户搜索过滤器，{username}会被替换为转义后的登录名
+	UsernameAttribute  string   `mapstructure:"username_attribute"`
+	EmailAttribute     string   `mapstructure:"email_attribute"`
+	NameAttribute      string   `mapstructure:"name_attribute"`
+	GroupBaseDN        string   `mapstructure:"group_base_dn"` // 留空时从用户的memberOf属性读取组
+	GroupFilter        string   `mapstructure:"group_filter"`  // 组搜索过滤器，{dn}和{username}会被替换为用户DN和登录名
+	GroupNameAttribute string   `mapstructure:"group_name_attribute"`
+	RoleMapping        []string `mapstructure:"role_mapping"`        // 组到角色的映射，格式 "组名=角色"，组名可以是cn或完整DN
+	DefaultRole        string   `mapstructure:"default_role"`        // 没有匹配的组时使用的角色，配置了映射时同步会把移出组的用户降为该角色
+	AutoProvision      bool     `mapstructure:"auto_provision"`      // 首次登录时自动创建用户
+	GroupSyncSchedule  string   `mapstructure:"group_sync_schedule"` // 定期同步目录用户的组和状态，留空表示不同步
+	Timeout            int      `mapstructure:"timeout"`             // 连接超时（秒）
+}
+
+// MailConfig 邮件发送配置
+// driver为smtp时通过SMTP服务器发送；file把邮件写入file_dir目录，log只打印到日志，二者用于开发和测试
+type MailConfig struct {
+	Driver         string `mapstructure:"driver"` // smtp, file, log
+	From           string `mapstructure:"from"`   // 发件人，如 "Emby Manager <noreply@example.com>"
+	SMTPHost       string `mapstructure:"smtp_host"`
+	SMTPPort       int    `mapstructure:"smtp_port"`
+	SMTPUsername   string `mapstructure:"smtp_username"` // 留空时不认证
+	SMTPPassword   string `mapstructure:"smtp_password"`
+	SMTPEncryption string `mapstructure:"smtp_encryption"` // none, starttls, tls
+	FileDir        string `mapstructure:"file_dir"`
+	Timeout        int    `mapstructure:"timeout"` // 发送超时（秒）
+}
+
+// AccountConfig 账户找回和邮箱验证配置
+type AccountConfig struct {
+	FrontendURL              string `mapstructure:"frontend_url"`               // 邮件中链接指向的前端登录页，如 https://emby.example.com/login
+	PasswordResetTTL         int    `mapstructure:"password_reset_ttl"`         // 密码重置链接有效期（分钟）
+	EmailVerificationTTL     int    `mapstructure:"email_verification_ttl"`     // 邮箱验证链接有效期（小时）
+	RequireEmailVerification bool   `mapstructure:"require_email_verification"` // 邮箱验证通过后才能使用密码登录
+}
+
+// SecurityConfig 密码和登录锁定策略的默认值
+// 管理员在安全策略中修改过的项以数据库中的值为准，breached_password_file只能通过配置文件设置
+type SecurityConfig struct {
+	PasswordMinLength     int    `mapstructure:"password_min_length"`
+	PasswordRequireUpper  bool   `mapstructure:"password_require_upper"`
+	PasswordRequireLower  bool   `mapstructure:"password_require_lower"`
+	PasswordRequireDigit  bool   `mapstructure:"password_require_digit"`
+	PasswordRequireSymbol bool   `mapstructure:"password_require_symbol"`
+	PasswordCheckBreached bool   `mapstructure:"password_check_breached"` // 拒绝出现在泄露密码列表中的密码
+	BreachedPasswordFile  string `mapstructure:"breached_password_file"`  // 按摘要排序的SHA-1列表（兼容HIBP的 "摘要:次数" 格式），或不超过16MB的明文列表
+	PasswordHistory       int    `mapstructure:"password_history"`        // 不能与最近几次使用过的密码相同，0表示不限制
+	LockoutThreshold      int    `mapstructure:"lockout_threshold"`       // 连续失败多少次后锁定账户，0表示不锁定
+	LockoutMinutes        int    `mapstructure:"lockout_minutes"`         // 首次锁定时长（分钟），之后每次锁定翻倍
+	LockoutMaxMinutes     int    `mapstructure:"lockout_max_minutes"`     // 锁定时长上限（分钟）
+	IPMaxFailures         int    `mapstructure:"ip_max_failures"`         // 同一IP在时间窗口内允许的登录失败次数，0表示不限制
+	IPWindowMinutes       int    `mapstructure:"ip_window_minutes"`       // IP登录失败计数的时间窗口（分钟）
+}
+
+// SchedulerConfig 后台定时任务配置
+// 调度表达式支持 "@every 5m"、"@hourly"、"@daily" 或直接写时间间隔如 "10m"，留空表示禁用该任务
+type SchedulerConfig struct {
+	Enabled                bool    `mapstructure:"enabled"`
+	TickInterval           int     `mapstructure:"tick_interval"` // 调度检查周期（秒）
+	HealthCheckSchedule    string  `mapstructure:"health_check_schedule"`
+	DeviceSyncSchedule     string  `mapstructure:"device_sync_schedule"`
+	LibrarySyncSchedule    string  `mapstructure:"library_sync_schedule"`
+	SessionSyncSchedule    string  `mapstructure:"session_sync_schedule"`
+	MetricsRollupSchedule  string  `mapstructure:"metrics_rollup_schedule"` // 汇总可用率和延迟采样并清理过期数据
+	Jitter                 float64 `mapstructure:"jitter"`                    // 随机抖动占间隔的比例（0-1）
+	MaxConcurrentPerServer int     `mapstructure:"max_concurrent_per_server"` // 每个服务器同时运行的任务数上限，健康检查和会话同步不计入
+	JobTimeout             int     `mapstructure:"job_timeout"`               // 单个任务超时时间（秒）
+}
+
+// ServerMetricsConfig 服务器可用率和延迟时间序列的保留时长，0表示永久保留
+// 原始采样来自定时健康检查，按小时和按天汇总后分别保留；连接日志与原始采样保留相同天数
+type ServerMetricsConfig struct {
+	RawRetentionDays    int `mapstructure:"raw_retention_days"`
+	HourlyRetentionDays int `mapstructure:"hourly_retention_days"`
+	DailyRetentionDays  int `mapstructure:"daily_retention_days"`
+}
+
+// MetricsConfig Prometheus指标导出配置
+// 启用后在 /metrics 导出指标，设置token时抓取请求需要携带 "Authorization: Bearer <token>"
+type MetricsConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Token   string `mapstructure:"token"`
+}
+
+// AlertingConfig 告警配置，规则和通知渠道在管理接口中维护
+type AlertingConfig struct {
+	EvaluateInterval int `mapstructure:"evaluate_interval"` // 检查待触发告警和重复通知的周期（秒）
+	Timeout          int `mapstructure:"timeout"`           // 单个渠道发送通知的超时（秒）
 }
 
 type LogConfig struct {
@@ -71,6 +217,10 @@ func Init() {
 	// 设置默认值
 	setDefaults()
 
+	// 环境变量优先于配置文件，如 JWT_SECRET 覆盖 jwt.secret；空值视为未设置
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+
 	// 读取配置文件
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
@@ -95,6 +245,7 @@ func setDefaults() {
 	viper.SetDefault("server.mode", "debug")
 	viper.SetDefault("server.read_timeout", 30)
 	viper.SetDefault("server.write_timeout", 30)
+	viper.SetDefault("server.trusted_proxies", []string{})
 
 	// 数据库默认配置
 	viper.SetDefault("database.type", "sqlite")
@@ -108,6 +259,7 @@ func setDefaults() {
 	viper.SetDefault("database.max_open_conns", 100)
 
 	// Redis默认配置
+	viper.SetDefault("redis.enabled", false)
 	viper.SetDefault("redis.host", "localhost")
 	viper.SetDefault("redis.port", 6379)
 	viper.SetDefault("redis.password", "")
@@ -115,7 +267,8 @@ func setDefaults() {
 
 	// JWT默认配置
 	viper.SetDefault("jwt.secret", "emby_manager_secret_key_please_change_in_production")
-	viper.SetDefault("jwt.expire_time", 86400)
+	viper.SetDefault("jwt.expire_time", 900)
+	viper.SetDefault("jwt.refresh_expire_time", 604800)
 	viper.SetDefault("jwt.issuer", "emby-manager")
 
 	// Emby默认配置
@@ -123,6 +276,107 @@ func setDefaults() {
 	viper.SetDefault("emby.max_retry_times", 3)
 	viper.SetDefault("emby.enable_cache", true)
 	viper.SetDefault("emby.cache_ttl", 300)
+	viper.SetDefault("emby.auth_mode", "header")
+	viper.SetDefault("emby.client_name", "EmbyManager")
+	viper.SetDefault("emby.device_id", "")
+
+	// 加密默认配置
+	viper.SetDefault("encryption.key", "")
+	viper.SetDefault("encryption.key_id", "primary")
+	viper.SetDefault("encryption.previous_keys", "")
+
+	// OIDC默认配置
+	viper.SetDefault("oidc.enabled", false)
+	viper.SetDefault("oidc.provider_name", "SSO")
+	viper.SetDefault("oidc.issuer", "")
+	viper.SetDefault("oidc.client_id", "")
+	viper.SetDefault("oidc.client_secret", "")
+	viper.SetDefault("oidc.redirect_url", "http://localhost:8080/api/auth/oidc/callback")
+	viper.SetDefault("oidc.frontend_url", "/login")
+	viper.SetDefault("oidc.scopes", []string{"openid", "profile", "email"})
+	viper.SetDefault("oidc.groups_claim", "groups")
+	viper.SetDefault("oidc.role_mapping", []string{})
+	viper.SetDefault("oidc.default_role", "user")
+	viper.SetDefault("oidc.auto_provision", true)
+	viper.SetDefault("oidc.require_verified_email", true)
+
+	// LDAP默认配置
+	viper.SetDefault("ldap.enabled", false)
+	viper.SetDefault("ldap.url", "ldap://localhost:389")
+	viper.SetDefault("ldap.start_tls", false)
+	viper.SetDefault("ldap.insecure_skip_verify", false)
+	viper.SetDefault("ldap.bind_dn", "")
+	viper.SetDefault("ldap.bind_password", "")
+	viper.SetDefault("ldap.base_dn", "")
+	viper.SetDefault("ldap.user_filter", "(&(objectClass=person)(uid={username}))")
+	viper.SetDefault("ldap.username_attribute", "uid")
+	viper.SetDefault("ldap.email_attribute", "mail")
+	viper.SetDefault("ldap.name_attribute", "cn")
+	viper.SetDefault("ldap.group_base_dn", "")
+	viper.SetDefault("ldap.group_filter", "(&(objectClass=groupOfNames)(member={dn}))")
+	viper.SetDefault("ldap.group_name_attribute", "cn")
+	viper.SetDefault("ldap.role_mapping", []string{})
+	viper.SetDefault("ldap.default_role", "user")
+	viper.SetDefault("ldap.auto_provision", true)
+	viper.SetDefault("ldap.group_sync_schedule", "@every 1h")
+	viper.SetDefault("ldap.timeout", 10)
+
+	// 邮件默认配置
+	viper.SetDefault("mail.driver", "log")
+	viper.SetDefault("mail.from", "Emby Manager <noreply@localhost>")
+	viper.SetDefault("mail.smtp_host", "localhost")
+	viper.SetDefault("mail.smtp_port", 587)
+	viper.SetDefault("mail.smtp_username", "")
+	viper.SetDefault("mail.smtp_password", "")
+	viper.SetDefault("mail.smtp_encryption", "starttls")
+	viper.SetDefault("mail.file_dir", "./mail")
+	viper.SetDefault("mail.timeout", 10)
+
+	// 账户默认配置
+	viper.SetDefault("account.frontend_url", "http://localhost:3000/login")
+	viper.SetDefault("account.password_reset_ttl", 30)
+	viper.SetDefault("account.email_verification_ttl", 48)
+	viper.SetDefault("account.require_email_verification", false)
+
+	// 安全策略默认配置
+	viper.SetDefault("security.password_min_length", 8)
+	viper.SetDefault("security.password_require_upper", false)
+	viper.SetDefault("security.password_require_lower", false)
+	viper.SetDefault("security.password_require_digit", false)
+	viper.SetDefault("security.password_require_symbol", false)
+	viper.SetDefault("security.password_check_breached", true)
+	viper.SetDefault("security.breached_password_file", "")
+	viper.SetDefault("security.password_history", 0)
+	viper.SetDefault("security.lockout_threshold", 5)
+	viper.SetDefault("security.lockout_minutes", 15)
+	viper.SetDefault("security.lockout_max_minutes", 1440)
+	viper.SetDefault("security.ip_max_failures", 20)
+	viper.SetDefault("security.ip_window_minutes", 15)
+
+	// 定时任务默认配置
+	viper.SetDefault("scheduler.enabled", true)
+	viper.SetDefault("scheduler.tick_interval", 10)
+	viper.SetDefault("scheduler.health_check_schedule", "@every 5m")
+	viper.SetDefault("scheduler.device_sync_schedule", "@every 30m")
+	viper.SetDefault("scheduler.library_sync_schedule", "@every 6h")
+	viper.SetDefault("scheduler.session_sync_schedule", "@every 1m")
+	viper.SetDefault("scheduler.metrics_rollup_schedule", "@hourly")
+	viper.SetDefault("scheduler.jitter", 0.1)
+	viper.SetDefault("scheduler.max_concurrent_per_server", 1)
+	viper.SetDefault("scheduler.job_timeout", 1800)
+
+	// 服务器时间序列默认配置
+	viper.SetDefault("server_metrics.raw_retention_days", 7)
+	viper.SetDefault("server_metrics.hourly_retention_days", 90)
+	viper.SetDefault("server_metrics.daily_retention_days", 730)
+
+	// 监控指标默认配置
+	viper.SetDefault("metrics.enabled", false)
+	viper.SetDefault("metrics.token", "")
+
+	// 告警默认配置
+	viper.SetDefault("alerting.evaluate_interval", 30)
+	viper.SetDefault("alerting.timeout", 10)
 
 	// 日志默认配置
 	viper.SetDefault("log.level", "info")
@@ -7,6 +7,7 @@ import (
 
 	"github.com/emby-client-go/backend/internal/config"
 	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/internal/secrets"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/sqlite"
@@ -83,11 +84,28 @@ func Init() error {
 		return fmt.Errorf("数据表迁移失败: %v", err)
 	}
 
+	// 加密历史明文数据，并用当前主密钥重新加密
+	if err := encryptSecrets(); err != nil {
+		return fmt.Errorf("敏感数据加密迁移失败: %v", err)
+	}
+
 	return nil
 }
 
 func autoMigrate() error {
-	return DB.AutoMigrate(
+	// 用户与服务器的多对多关联使用自定义关联表，以保存访问角色
+	if err := DB.SetupJoinTable(&models.User{}, "EmbyServers", &models.UserEmbyServer{}); err != nil {
+		return err
+	}
+	if err := DB.SetupJoinTable(&models.EmbyServer{}, "Users", &models.UserEmbyServer{}); err != nil {
+		return err
+	}
+
+	// 引入邮箱验证之前的用户视为已验证
+	verifyExistingUsers := DB.Migrator().HasTable(&models.User{}) &&
+		!DB.Migrator().HasColumn(&models.User{}, "EmailVerified")
+
+	if err := DB.AutoMigrate(
 		&models.User{},
 		&models.EmbyServer{},
 		&models.MediaLibrary{},
@@ -96,8 +114,115 @@ func autoMigrate() error {
 		&models.PlaybackSession{},
 		&models.PlaybackRecord{},
 		&models.ConnectionLog{},
+		&models.ServerMetric{},
+		&models.ServerMetricRollup{},
 		&models.SystemConfig{},
-	)
+		&models.ScheduledJob{},
+		&models.SyncJob{},
+		&models.SyncLease{},
+		&models.UserEmbyServer{},
+		&models.Role{},
+		&models.Permission{},
+		&models.UserSession{},
+		&models.RevokedToken{},
+		&models.RecoveryCode{},
+		&models.UserIdentity{},
+		&models.OIDCLoginState{},
+		&models.APIToken{},
+		&models.AuditEvent{},
+		&models.PasswordHistory{},
+		&models.AlertChannel{},
+		&models.AlertRule{},
+		&models.Alert{},
+		&models.AlertSilence{},
+	); err != nil {
+		return err
+	}
+
+	if verifyExistingUsers {
+		if err := DB.Model(&models.User{}).Where("1 = 1").Update("email_verified", true).Error; err != nil {
+			return err
+		}
+	}
+
+	// 引入访问角色之前的关联均由创建服务器时写入，视为所有者
+	return DB.Model(&models.UserEmbyServer{}).
+		Where("role IS NULL OR role = ?", "").
+		Update("role", "owner").Error
+}
+
+// encryptedColumns 使用EncryptedString保存的列，按表分组
+// OIDC登录状态只保留几分钟，不需要迁移
+var encryptedColumns = []struct {
+	table   string
+	columns []string
+}{
+	{"emby_servers", []string{"api_key", "emby_password"}},
+	{"users", []string{"totp_secret"}},
+	{"alert_channels", []string{"secret"}},
+}
+
+// encryptSecrets 加密尚未加密的敏感列
+// 主密钥轮换或首次配置主密钥后，使用旧主密钥加密的数据会被重新加密
+func encryptSecrets() error {
+	for _, target := range encryptedColumns {
+		migrated, err := encryptColumns(target.table, target.columns)
+		if err != nil {
+			return err
+		}
+		if migrated > 0 {
+			log.Printf("已加密或重新加密 %s 表中 %d 条记录的敏感数据", target.table, migrated)
+		}
+	}
+	return nil
+}
+
+// encryptColumns 加密或重新加密一张表中的敏感列，返回更新的记录数
+func encryptColumns(table string, columns []string) (int, error) {
+	// 直接查询原始列值，包含已软删除的记录
+	var rows []map[string]interface{}
+	if err := DB.Table(table).Select(append([]string{"id"}, columns...)).Find(&rows).Error; err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, row := range rows {
+		updates := make(map[string]interface{})
+		for _, column := range columns {
+			value := rawString(row[column])
+			if value == "" {
+				continue
+			}
+			rewrapped, changed, err := secrets.Rewrap(value)
+			if err != nil {
+				return migrated, fmt.Errorf("%s 表记录 %v 的 %s 加密失败: %w", table, row["id"], column, err)
+			}
+			if changed {
+				updates[column] = rewrapped
+			}
+		}
+
+		if len(updates) == 0 {
+			continue
+		}
+		if err := DB.Table(table).Where("id = ?", row["id"]).UpdateColumns(updates).Error; err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// rawString 把驱动返回的列值转换为字符串
+func rawString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return ""
+	}
 }
 
 func Close() error {
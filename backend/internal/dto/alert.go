@@ -0,0 +1,48 @@
+package dto
+
+// AlertRuleRequest 创建或更新告警规则请求，更新时整体替换
+type AlertRuleRequest struct {
+	Name           string `json:"name" binding:"required,max=100"`
+	Event          string `json:"event" binding:"required,oneof=server_offline sync_failed websocket_failed"`
+	EmbyServerID   *uint  `json:"emby_server_id"`                      // 为空时适用于所有服务器
+	Duration       int    `json:"duration" binding:"min=0,max=604800"` // 条件持续多少秒后告警，0表示立即告警
+	Severity       string `json:"severity" binding:"required,oneof=info warning critical"`
+	RepeatInterval int    `json:"repeat_interval" binding:"omitempty,min=60,max=604800"` // 重复通知间隔（秒），0表示不重复
+	NotifyResolved bool   `json:"notify_resolved"`
+	Enabled        *bool  `json:"enabled"` // 默认启用
+	ChannelIDs     []uint `json:"channel_ids"`
+}
+
+// AlertChannelRequest 创建或更新通知渠道请求，更新时整体替换
+type AlertChannelRequest struct {
+	Name       string   `json:"name" binding:"required,max=100"`
+	Type       string   `json:"type" binding:"required,oneof=webhook slack email telegram"`
+	URL        string   `json:"url" binding:"omitempty,url"` // webhook和slack必填，telegram留空使用官方API地址
+	Secret     *string  `json:"secret"`                      // webhook签名密钥或telegram机器人令牌，更新时为null表示不修改
+	ChatID     string   `json:"chat_id" binding:"max=100"`   // telegram会话ID
+	Recipients []string `json:"recipients" binding:"omitempty,dive,email"`
+	Enabled    *bool    `json:"enabled"` // 默认启用
+}
+
+// AlertSilenceRequest 创建静默请求
+type AlertSilenceRequest struct {
+	AlertRuleID  *uint  `json:"alert_rule_id"`              // 为空时匹配所有规则
+	EmbyServerID *uint  `json:"emby_server_id"`             // 为空时匹配所有服务器
+	StartsAt     string `json:"starts_at"`                  // RFC3339时间，默认为当前时间
+	EndsAt       string `json:"ends_at" binding:"required"` // RFC3339时间
+	Reason       string `json:"reason" binding:"max=255"`
+}
+
+// AlertListRequest 分页查询告警请求
+type AlertListRequest struct {
+	Status       string `form:"status" binding:"omitempty,oneof=pending firing resolved"`
+	AlertRuleID  uint   `form:"rule_id"`
+	EmbyServerID uint   `form:"server_id"`
+	Page         int    `form:"page,default=1" binding:"min=1"`
+	PageSize     int    `form:"page_size,default=20" binding:"min=1,max=200"`
+}
+
+// AlertSilenceListRequest 查询静默请求
+type AlertSilenceListRequest struct {
+	Active bool `form:"active"` // 只返回未结束的静默
+}
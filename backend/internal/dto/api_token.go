@@ -0,0 +1,34 @@
+package dto
+
+// CreateAPITokenRequest 创建个人访问令牌请求
+type CreateAPITokenRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1"` // 权限名称，不能超出当前角色拥有的权限
+	// ExpiresInDays 有效天数，0表示永不过期
+	ExpiresInDays int `json:"expires_in_days" binding:"min=0,max=3650"`
+}
+
+// UpdateAPITokenRequest 修改个人访问令牌请求，字段为空时不修改
+type UpdateAPITokenRequest struct {
+	Name   *string  `json:"name" binding:"omitempty,min=1,max=100"`
+	Scopes []string `json:"scopes" binding:"omitempty,min=1"`
+}
+
+// APITokenResponse 个人访问令牌响应，不包含令牌明文
+type APITokenResponse struct {
+	ID         uint     `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  *string  `json:"expires_at"`
+	LastUsedAt *string  `json:"last_used_at"`
+	LastUsedIP string   `json:"last_used_ip"`
+	CreatedAt  string   `json:"created_at"`
+	Expired    bool     `json:"expired"`
+}
+
+// CreateAPITokenResponse 创建令牌响应，令牌明文只返回这一次
+type CreateAPITokenResponse struct {
+	APITokenResponse
+	Token string `json:"token"`
+}
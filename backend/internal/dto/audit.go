@@ -0,0 +1,50 @@
+package dto
+
+import "encoding/json"
+
+// AuditQueryRequest 审计事件筛选条件
+type AuditQueryRequest struct {
+	ActorID    uint   `form:"actor_id"`
+	Actor      string `form:"actor"`  // 操作者用户名
+	Action     string `form:"action"` // 以 "." 结尾时按前缀匹配，如 "server."
+	TargetType string `form:"target_type"`
+	TargetID   string `form:"target_id"`
+	IP         string `form:"ip"`
+	From       string `form:"from"` // RFC3339时间，包含
+	To         string `form:"to"`   // RFC3339时间，不包含
+}
+
+// AuditListRequest 分页查询审计事件请求
+type AuditListRequest struct {
+	AuditQueryRequest
+	Page     int `form:"page,default=1" binding:"min=1"`
+	PageSize int `form:"page_size,default=20" binding:"min=1,max=200"`
+}
+
+// AuditExportRequest 导出审计事件请求
+type AuditExportRequest struct {
+	AuditQueryRequest
+	Format string `form:"format,default=csv" binding:"oneof=csv json"`
+}
+
+// AuditChange 单个字段的变化
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEventResponse 审计事件
+type AuditEventResponse struct {
+	ID         uint                   `json:"id"`
+	ActorID    *uint                  `json:"actor_id"`
+	ActorName  string                 `json:"actor_name"`
+	Action     string                 `json:"action"`
+	TargetType string                 `json:"target_type"`
+	TargetID   string                 `json:"target_id"`
+	Before     json.RawMessage        `json:"before,omitempty"`
+	After      json.RawMessage        `json:"after,omitempty"`
+	Changes    map[string]AuditChange `json:"changes,omitempty"` // 操作前后发生变化的字段
+	IP         string                 `json:"ip"`
+	UserAgent  string                 `json:"user_agent"`
+	CreatedAt  string                 `json:"created_at"`
+}
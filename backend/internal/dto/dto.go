@@ -4,20 +4,73 @@ package dto
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	// DeviceName 客户端设备名称，显示在会话列表中
+	DeviceName string `json:"device_name" binding:"max=100"`
 }
 
-// RegisterRequest 注册请求
+// RegisterRequest 注册请求，密码规则由安全策略校验
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=20"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
 	Nickname string `json:"nickname"`
 }
 
 // ChangePasswordRequest 修改密码请求
 type ChangePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=6"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// SetAuthSourceRequest 设置用户认证来源请求
+type SetAuthSourceRequest struct {
+	AuthSource string `json:"auth_source" binding:"required,oneof=local ldap"`
+	// Password 目录用户改为本地账户时必须设置新密码
+	Password string `json:"password"`
+}
+
+// ForgotPasswordRequest 忘记密码请求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// RecoverPasswordRequest 通过邮件链接重置密码请求
+type RecoverPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// VerifyEmailRequest 邮箱验证请求
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationRequest 重新发送验证邮件请求
+type ResendVerificationRequest struct {
+	Username string `json:"username" binding:"required"` // 用户名或邮箱
+}
+
+// CreateUserRequest 管理员创建用户请求
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=20"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+	Nickname string `json:"nickname"`
+	Role     string `json:"role"` // 为空时使用普通用户角色
+}
+
+// UpdateUserRequest 管理员编辑用户请求，为空的字段不修改
+type UpdateUserRequest struct {
+	Username *string `json:"username" binding:"omitempty,min=3,max=20"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+	Nickname *string `json:"nickname"`
+	// EmailVerified 手动标记邮箱验证状态
+	EmailVerified *bool `json:"email_verified"`
+}
+
+// ResetPasswordRequest 管理员重置密码请求
+type ResetPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
 }
 
 // UserResponse 用户响应
@@ -30,12 +83,23 @@ type UserResponse struct {
 	Status    string `json:"status"`
 	LastLogin *string `json:"last_login"`
 	CreatedAt string `json:"created_at"`
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
+	AuthSource string `json:"auth_source"` // local: 本地密码, ldap: 目录认证
+	EmailVerified bool `json:"email_verified"`
 }
 
 // LoginResponse 登录响应
+// 需要两步验证时只返回TwoFactorToken，客户端提交验证码后才会获得访问令牌
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  UserResponse `json:"user"`
+	Token        string        `json:"token,omitempty"`
+	RefreshToken string        `json:"refresh_token,omitempty"`
+	ExpiresIn    int           `json:"expires_in,omitempty"` // 访问令牌有效期（秒）
+	User         *UserResponse `json:"user,omitempty"`
+
+	TwoFactorRequired      bool     `json:"two_factor_required,omitempty"`
+	TwoFactorSetupRequired bool     `json:"two_factor_setup_required,omitempty"` // 系统要求两步验证但尚未绑定
+	TwoFactorToken         string   `json:"two_factor_token,omitempty"`
+	RecoveryCodes          []string `json:"recovery_codes,omitempty"` // 登录时完成绑定返回的恢复码
 }
 
 // ApiResponse 通用API响应
@@ -62,10 +126,24 @@ type PageResponse struct {
 
 // RefreshTokenRequest 刷新令牌请求
 type RefreshTokenRequest struct {
-	Token string `json:"token" binding:"required"`
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
-// RefreshTokenResponse 刷新令牌响应
+// RefreshTokenResponse 刷新令牌响应，旧的刷新令牌随即失效
 type RefreshTokenResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // 访问令牌有效期（秒）
+}
+
+// SessionResponse 登录会话响应
+type SessionResponse struct {
+	ID         uint   `json:"id"`
+	DeviceName string `json:"device_name"`
+	UserAgent  string `json:"user_agent"`
+	IP         string `json:"ip"`
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at"`
+	ExpiresAt  string `json:"expires_at"`
+	Current    bool   `json:"current"` // 是否为发起请求的会话
 }
\ No newline at end of file
@@ -0,0 +1,13 @@
+package dto
+
+// OIDCConfigResponse 单点登录配置，供登录页决定是否显示单点登录按钮
+type OIDCConfigResponse struct {
+	Enabled      bool   `json:"enabled"`
+	ProviderName string `json:"provider_name"`
+}
+
+// OIDCExchangeRequest 使用回调返回的一次性登录码换取令牌
+type OIDCExchangeRequest struct {
+	Code       string `json:"code" binding:"required"`
+	DeviceName string `json:"device_name" binding:"max=100"`
+}
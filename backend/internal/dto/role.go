@@ -0,0 +1,19 @@
+package dto
+
+// CreateRoleRequest 创建角色请求
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required,min=2,max=50,alphanum"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"` // 权限名称，如 server.manage
+}
+
+// UpdateRoleRequest 更新角色请求
+type UpdateRoleRequest struct {
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions"` // 为空时不修改权限，传空数组清空权限
+}
+
+// AssignRoleRequest 设置用户角色请求
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
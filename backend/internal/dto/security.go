@@ -0,0 +1,42 @@
+package dto
+
+// SecurityPolicyRequest 更新安全策略请求，只更新传入的项
+type SecurityPolicyRequest struct {
+	RequireTwoFactor      *bool `json:"require_two_factor"`
+	PasswordMinLength     *int  `json:"password_min_length"`
+	PasswordRequireUpper  *bool `json:"password_require_upper"`
+	PasswordRequireLower  *bool `json:"password_require_lower"`
+	PasswordRequireDigit  *bool `json:"password_require_digit"`
+	PasswordRequireSymbol *bool `json:"password_require_symbol"`
+	PasswordCheckBreached *bool `json:"password_check_breached"`
+	PasswordHistory       *int  `json:"password_history"`
+	LockoutThreshold      *int  `json:"lockout_threshold"`
+	LockoutMinutes        *int  `json:"lockout_minutes"`
+	LockoutMaxMinutes     *int  `json:"lockout_max_minutes"`
+	IPMaxFailures         *int  `json:"ip_max_failures"`
+	IPWindowMinutes       *int  `json:"ip_window_minutes"`
+}
+
+// SecurityPolicyResponse 安全策略
+type SecurityPolicyResponse struct {
+	RequireTwoFactor bool `json:"require_two_factor"`
+	PasswordPolicyResponse
+	PasswordCheckBreached bool `json:"password_check_breached"`
+	// BreachedListConfigured 是否在配置文件中设置了泄露密码列表，未设置时不检查
+	BreachedListConfigured bool `json:"breached_list_configured"`
+	PasswordHistory        int  `json:"password_history"`
+	LockoutThreshold       int  `json:"lockout_threshold"`
+	LockoutMinutes         int  `json:"lockout_minutes"`
+	LockoutMaxMinutes      int  `json:"lockout_max_minutes"`
+	IPMaxFailures          int  `json:"ip_max_failures"`
+	IPWindowMinutes        int  `json:"ip_window_minutes"`
+}
+
+// PasswordPolicyResponse 密码规则，用于在注册和修改密码时提示用户
+type PasswordPolicyResponse struct {
+	MinLength     int  `json:"password_min_length"`
+	RequireUpper  bool `json:"password_require_upper"`
+	RequireLower  bool `json:"password_require_lower"`
+	RequireDigit  bool `json:"password_require_digit"`
+	RequireSymbol bool `json:"password_require_symbol"`
+}
@@ -4,30 +4,65 @@ package dto
 type CreateServerRequest struct {
 	Name        string `json:"name" binding:"required"`
 	URL         string `json:"url" binding:"required,url"`
-	APIKey      string `json:"api_key" binding:"required"`
+	APIKey      string `json:"api_key"`  // 与用户名密码二选一
+	Username    string `json:"username"` // Emby用户名，用于登录获取访问令牌
+	Password    string `json:"password"`
 	Description string `json:"description"`
+	Timeout     *int   `json:"timeout" binding:"omitempty,min=0"`                // 请求超时（秒），为空时使用全局配置
+	MaxRetries  *int   `json:"max_retries" binding:"omitempty,min=0"`            // 最大重试次数，为空时使用全局配置
+	CacheTTL    *int   `json:"cache_ttl" binding:"omitempty,min=0"`              // 缓存有效期（秒），为空时使用全局配置
+	AuthMode    string `json:"auth_mode" binding:"omitempty,oneof=header query"` // API密钥传递方式，为空时使用全局配置
 }
 
 // UpdateServerRequest 更新服务器请求
 type UpdateServerRequest struct {
-	Name        string `json:"name"`
-	URL         string `json:"url" binding:"omitempty,url"`
-	APIKey      string `json:"api_key"`
-	Description string `json:"description"`
+	Name        string  `json:"name"`
+	URL         string  `json:"url" binding:"omitempty,url"`
+	APIKey      string  `json:"api_key"`  // 设置后改为API密钥认证
+	Username    string  `json:"username"` // 与password一起设置时改为用户名密码认证
+	Password    string  `json:"password"`
+	Description string  `json:"description"`
+	Timeout     *int    `json:"timeout" binding:"omitempty,min=0"`                        // 传0恢复使用全局配置
+	MaxRetries  *int    `json:"max_retries"`                                              // 0表示不重试，传负数（如-1）恢复使用全局配置，为空时不修改
+	CacheTTL    *int    `json:"cache_ttl" binding:"omitempty,min=0"`                      // 传0恢复使用全局配置
+	AuthMode    *string `json:"auth_mode" binding:"omitempty,oneof=header query default"` // 传default恢复使用全局配置
 }
 
 // ServerResponse 服务器响应
 type ServerResponse struct {
-	ID          uint   `json:"id"`
-	Name        string `json:"name"`
-	URL         string `json:"url"`
-	Version     string `json:"version"`
-	OS          string `json:"os"`
-	Status      string `json:"status"`
-	LastCheck   string `json:"last_check"`
-	Description string `json:"description"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	ID                uint   `json:"id"`
+	Name              string `json:"name"`
+	URL               string `json:"url"`
+	Version           string `json:"version"`
+	OS                string `json:"os"`
+	Status            string `json:"status"`
+	LastCheck         string `json:"last_check"`
+	Description       string `json:"description"`
+	APIKeyFingerprint string `json:"api_key_fingerprint"` // API密钥的掩码指纹，不返回明文
+	AuthType          string `json:"auth_type"`
+	Username          string `json:"username"`
+	EmbyUserID        string `json:"emby_user_id"`
+	Timeout           *int   `json:"timeout"`
+	MaxRetries        *int   `json:"max_retries"`
+	CacheTTL          *int   `json:"cache_ttl"`
+	AuthMode          string `json:"auth_mode"`
+	CreatedAt         string `json:"created_at"`
+	UpdatedAt         string `json:"updated_at"`
+}
+
+// ShareServerRequest 共享服务器请求
+type ShareServerRequest struct {
+	UserID uint   `json:"user_id" binding:"required"`
+	Role   string `json:"role" binding:"required,oneof=owner operator viewer"`
+}
+
+// ServerShareResponse 服务器共享用户
+type ServerShareResponse struct {
+	UserID    uint   `json:"user_id"`
+	Username  string `json:"username"`
+	Nickname  string `json:"nickname"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
 }
 
 // TestConnectionResponse 测试连接响应
@@ -36,3 +71,85 @@ type TestConnectionResponse struct {
 	ResponseTime int64  `json:"response_time"` // 毫秒
 	Message      string `json:"message"`
 }
+
+// ConnectionLogListRequest 分页查询连接日志请求
+type ConnectionLogListRequest struct {
+	Page     int    `form:"page,default=1" binding:"min=1"`
+	PageSize int    `form:"page_size,default=20" binding:"min=1,max=200"`
+	Action   string `form:"action" binding:"omitempty,oneof=connect test health_check reconnect disconnect error"`
+	Status   string `form:"status" binding:"omitempty,oneof=success failed"`
+	Source   string `form:"source" binding:"omitempty,oneof=http websocket"`
+	From     string `form:"from"` // RFC3339时间，包含
+	To       string `form:"to"`   // RFC3339时间，不包含
+}
+
+// ConnectionLogResponse 连接日志
+type ConnectionLogResponse struct {
+	ID           uint   `json:"id"`
+	Action       string `json:"action"`
+	Source       string `json:"source"`
+	Status       string `json:"status"`
+	Message      string `json:"message"`
+	ResponseTime int    `json:"response_time"` // 毫秒
+	UserID       *uint  `json:"user_id"`       // 系统自动检测时为空
+	Username     string `json:"username"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// ConnectionStatsRequest 连接统计请求，未指定时统计最近24小时，时间范围最长31天
+type ConnectionStatsRequest struct {
+	From string `form:"from"` // RFC3339时间，包含
+	To   string `form:"to"`   // RFC3339时间，不包含
+}
+
+// ConnectionStatsResponse 连接统计
+type ConnectionStatsResponse struct {
+	From             string                `json:"from"`
+	To               string                `json:"to"`
+	Checks           int64                 `json:"checks"` // 创建、测试和健康检查的次数
+	SuccessfulChecks int64                 `json:"successful_checks"`
+	UptimePercent    *float64              `json:"uptime_percent"` // 没有检查记录时为空
+	Failures         int64                 `json:"failures"`       // 所有失败的日志数
+	Disconnects      int64                 `json:"disconnects"`    // WebSocket断开次数
+	Latency          *LatencyStatsResponse `json:"latency"`        // 没有成功的检查时为空
+}
+
+// LatencyStatsResponse 成功检查的响应时间分布，单位毫秒
+type LatencyStatsResponse struct {
+	Min int `json:"min"`
+	Avg int `json:"avg"`
+	P50 int `json:"p50"`
+	P90 int `json:"p90"`
+	P95 int `json:"p95"`
+	P99 int `json:"p99"`
+	Max int `json:"max"`
+}
+
+// ServerMetricsRequest 服务器时间序列请求
+type ServerMetricsRequest struct {
+	From string `form:"from"` // RFC3339时间，默认为结束时间前24小时
+	To   string `form:"to"`   // RFC3339时间，默认为当前时间
+	Step string `form:"step"` // 步长，如 5m、1h、1d，默认按时间范围选择
+}
+
+// ServerMetricsResponse 服务器可用率和延迟时间序列
+type ServerMetricsResponse struct {
+	ServerID   uint                  `json:"server_id"`
+	From       string                `json:"from"`
+	To         string                `json:"to"`
+	Step       int64                 `json:"step"`       // 秒
+	Resolution string                `json:"resolution"` // raw, hour, day
+	Summary    MetricPointResponse   `json:"summary"`    // 整个时间范围的汇总
+	Points     []MetricPointResponse `json:"points"`
+}
+
+// MetricPointResponse 一个时间段的可用率和延迟，没有采样时可用率和延迟为空
+type MetricPointResponse struct {
+	Time          string   `json:"time"`
+	Samples       int      `json:"samples"`
+	UpSamples     int      `json:"up_samples"`
+	UptimePercent *float64 `json:"uptime_percent"`
+	LatencyAvg    *int     `json:"latency_avg"` // 毫秒
+	LatencyMin    *int     `json:"latency_min"`
+	LatencyMax    *int     `json:"latency_max"`
+}
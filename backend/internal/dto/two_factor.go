@@ -0,0 +1,40 @@
+package dto
+
+// TwoFactorLoginRequest 登录第二步请求
+type TwoFactorLoginRequest struct {
+	TwoFactorToken string `json:"two_factor_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // TOTP验证码或恢复码
+}
+
+// TwoFactorSetupLoginRequest 登录时强制绑定两步验证的请求
+type TwoFactorSetupLoginRequest struct {
+	TwoFactorToken string `json:"two_factor_token" binding:"required"`
+}
+
+// TwoFactorEnableLoginRequest 登录时确认绑定两步验证的请求
+type TwoFactorEnableLoginRequest struct {
+	TwoFactorToken string `json:"two_factor_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// TwoFactorCodeRequest 提交验证码的请求
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorDisableRequest 关闭两步验证请求
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP验证码或恢复码
+}
+
+// TwoFactorSetupResponse 两步验证绑定信息
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"`           // base32密钥，供无法扫码时手动输入
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// URI，用于生成二维码
+}
+
+// RecoveryCodesResponse 恢复码响应，恢复码只在生成时返回一次
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/emby-client-go/backend/internal/dto"
+	"github.com/emby-client-go/backend/internal/middleware"
+	"github.com/emby-client-go/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AccountHandler 账户找回和邮箱验证处理器
+type AccountHandler struct {
+	accountService *services.AccountService
+	policyService  *services.SecurityPolicyService
+}
+
+// NewAccountHandler 创建账户找回和邮箱验证处理器
+func NewAccountHandler() *AccountHandler {
+	return &AccountHandler{
+		accountService: services.NewAccountService(),
+		policyService:  services.NewSecurityPolicyService(),
+	}
+}
+
+// GetPasswordPolicy 获取密码规则
+// @Summary 获取密码规则
+// @Description 获取当前的密码长度和字符类型要求，用于在注册和修改密码时提示用户
+// @Tags 用户认证
+// @Produce json
+// @Success 200 {object} dto.ApiResponse{data=dto.PasswordPolicyResponse}
+// @Router /auth/password-policy [get]
+func (h *AccountHandler) GetPasswordPolicy(c *gin.Context) {
+	policy, err := h.policyService.GetPolicy()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data:    toPasswordPolicyResponse(policy),
+	})
+}
+
+// ForgotPassword 忘记密码
+// @Summary 忘记密码
+// @Description 向邮箱发送密码重置链接；邮箱不存在时同样返回成功，避免泄露账户是否存在
+// @Tags 用户认证
+// @Accept json
+// @Produce json
+// @Param request body dto.ForgotPasswordRequest true "邮箱"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Router /auth/forgot-password [post]
+func (h *AccountHandler) ForgotPassword(c *gin.Context) {
+	var req dto.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.accountService.RequestPasswordReset(req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "如果该邮箱已注册，重置密码的链接将发送到该邮箱",
+	})
+}
+
+// ResetPassword 通过邮件链接重置密码
+// @Summary 通过邮件链接重置密码
+// @Description 使用重置邮件中的一次性令牌设置新密码，成功后解除账户锁定并吊销全部会话
+// @Tags 用户认证
+// @Accept json
+// @Produce json
+// @Param request body dto.RecoverPasswordRequest true "令牌和新密码"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse "目录用户不能重置密码"
+// @Router /auth/reset-password [post]
+func (h *AccountHandler) ResetPassword(c *gin.Context) {
+	var req dto.RecoverPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.accountService.ResetPassword(c.Request.Context(), middleware.AuditActor(c), req.Token, req.Password); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrDirectoryManagedUser) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "密码重置成功，请使用新密码登录",
+	})
+}
+
+// VerifyEmail 验证邮箱
+// @Summary 验证邮箱
+// @Description 使用验证邮件中的令牌完成邮箱验证
+// @Tags 用户认证
+// @Accept json
+// @Produce json
+// @Param request body dto.VerifyEmailRequest true "验证令牌"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Router /auth/verify-email [post]
+func (h *AccountHandler) VerifyEmail(c *gin.Context) {
+	var req dto.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if _, err := h.accountService.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "邮箱验证成功",
+	})
+}
+
+// ResendVerification 重新发送验证邮件
+// @Summary 重新发送验证邮件
+// @Description 按用户名或邮箱重新发送邮箱验证邮件；用户不存在或已验证时同样返回成功
+// @Tags 用户认证
+// @Accept json
+// @Produce json
+// @Param request body dto.ResendVerificationRequest true "用户名或邮箱"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Router /auth/resend-verification [post]
+func (h *AccountHandler) ResendVerification(c *gin.Context) {
+	var req dto.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.accountService.ResendVerification(req.Username); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "如果该账户的邮箱尚未验证，验证邮件将重新发送",
+	})
+}
@@ -0,0 +1,517 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/dto"
+	"github.com/emby-client-go/backend/internal/middleware"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AlertHandler 告警处理器
+type AlertHandler struct {
+	alertService *services.AlertService
+}
+
+// NewAlertHandler 创建告警处理器
+func NewAlertHandler() *AlertHandler {
+	return &AlertHandler{
+		alertService: services.NewAlertService(),
+	}
+}
+
+// GetAlerts 分页查询告警
+// @Summary 查询告警
+// @Description 按状态、规则和服务器分页查询告警，最新的在前（需要alerts.manage权限）
+// @Tags 告警管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param status query string false "状态" Enums(pending, firing, resolved)
+// @Param rule_id query int false "规则ID"
+// @Param server_id query int false "服务器ID"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Success 200 {object} dto.ApiResponse{data=dto.PageResponse}
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /alerts [get]
+func (h *AlertHandler) GetAlerts(c *gin.Context) {
+	var req dto.AlertListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	alerts, total, err := h.alertService.ListAlerts(req.Status, req.AlertRuleID, req.EmbyServerID, req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data: dto.PageResponse{
+			List:     alerts,
+			Total:    total,
+			Page:     req.Page,
+			PageSize: req.PageSize,
+		},
+	})
+}
+
+// GetRules 获取告警规则列表
+// @Summary 获取告警规则列表
+// @Description 获取所有告警规则及其通知渠道（需要alerts.manage权限）
+// @Tags 告警管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /alerts/rules [get]
+func (h *AlertHandler) GetRules(c *gin.Context) {
+	rules, err := h.alertService.ListRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data:    rules,
+	})
+}
+
+// CreateRule 创建告警规则
+// @Summary 创建告警规则
+// @Description 创建告警规则，条件持续指定时间后按级别通知所选渠道（需要alerts.manage权限）
+// @Tags 告警管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body dto.AlertRuleRequest true "规则信息"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 404 {object} dto.ApiResponse
+// @Router /alerts/rules [post]
+func (h *AlertHandler) CreateRule(c *gin.Context) {
+	var req dto.AlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	rule, err := h.alertService.CreateRule(req)
+	if err != nil {
+		c.JSON(alertErrorStatus(err), dto.ApiResponse{
+			Code:    alertErrorStatus(err),
+			Message: err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditTarget(c, rule.ID)
+	middleware.SetAuditDetails(c, rule)
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "规则创建成功",
+		Data:    rule,
+	})
+}
+
+// UpdateRule 更新告警规则
+// @Summary 更新告警规则
+// @Description 整体替换告警规则，停用规则或修改条件、服务器时结束未恢复的告警（需要alerts.manage权限）
+// @Tags 告警管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "规则ID"
+// @Param request body dto.AlertRuleRequest true "规则信息"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 404 {object} dto.ApiResponse
+// @Router /alerts/rules/{id} [put]
+func (h *AlertHandler) UpdateRule(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req dto.AlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	rule, err := h.alertService.UpdateRule(uint(id), req)
+	if err != nil {
+		c.JSON(alertErrorStatus(err), dto.ApiResponse{
+			Code:    alertErrorStatus(err),
+			Message: err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditDetails(c, rule)
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "更新成功",
+		Data:    rule,
+	})
+}
+
+// DeleteRule 删除告警规则
+// @Summary 删除告警规则
+// @Description 删除告警规则及其静默，未恢复的告警直接结束（需要alerts.manage权限）
+// @Tags 告警管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "规则ID"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 404 {object} dto.ApiResponse
+// @Router /alerts/rules/{id} [delete]
+func (h *AlertHandler) DeleteRule(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.alertService.DeleteRule(uint(id)); err != nil {
+		c.JSON(alertErrorStatus(err), dto.ApiResponse{
+			Code:    alertErrorStatus(err),
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "删除成功",
+	})
+}
+
+// GetChannels 获取通知渠道列表
+// @Summary 获取通知渠道列表
+// @Description 获取所有通知渠道，密钥只返回指纹（需要alerts.manage权限）
+// @Tags 告警管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /alerts/channels [get]
+func (h *AlertHandler) GetChannels(c *gin.Context) {
+	channels, err := h.alertService.ListChannels()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data:    channels,
+	})
+}
+
+// CreateChannel 创建通知渠道
+// @Summary 创建通知渠道
+// @Description 创建Webhook、Slack、邮件或Telegram通知渠道（需要alerts.manage权限）
+// @Tags 告警管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body dto.AlertChannelRequest true "渠道信息"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Router /alerts/channels [post]
+func (h *AlertHandler) CreateChannel(c *gin.Context) {
+	var req dto.AlertChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	channel, err := h.alertService.CreateChannel(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditTarget(c, channel.ID)
+	middleware.SetAuditDetails(c, channel)
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "渠道创建成功",
+		Data:    channel,
+	})
+}
+
+// UpdateChannel 更新通知渠道
+// @Summary 更新通知渠道
+// @Description 整体替换通知渠道配置，secret为null时保留原密钥（需要alerts.manage权限）
+// @Tags 告警管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "渠道ID"
+// @Param request body dto.AlertChannelRequest true "渠道信息"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 404 {object} dto.ApiResponse
+// @Router /alerts/channels/{id} [put]
+func (h *AlertHandler) UpdateChannel(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req dto.AlertChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	channel, err := h.alertService.UpdateChannel(uint(id), req)
+	if err != nil {
+		c.JSON(alertErrorStatus(err), dto.ApiResponse{
+			Code:    alertErrorStatus(err),
+			Message: err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditDetails(c, channel)
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "更新成功",
+		Data:    channel,
+	})
+}
+
+// DeleteChannel 删除通知渠道
+// @Summary 删除通知渠道
+// @Description 删除通知渠道并从引用它的规则中移除（需要alerts.manage权限）
+// @Tags 告警管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "渠道ID"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 404 {object} dto.ApiResponse
+// @Router /alerts/channels/{id} [delete]
+func (h *AlertHandler) DeleteChannel(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.alertService.DeleteChannel(uint(id)); err != nil {
+		c.JSON(alertErrorStatus(err), dto.ApiResponse{
+			Code:    alertErrorStatus(err),
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "删除成功",
+	})
+}
+
+// TestChannel 发送测试通知
+// @Summary 发送测试通知
+// @Description 通过通知渠道同步发送一条测试通知，停用的渠道也可以测试（需要alerts.manage权限）
+// @Tags 告警管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "渠道ID"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 404 {object} dto.ApiResponse
+// @Failure 502 {object} dto.ApiResponse
+// @Router /alerts/channels/{id}/test [post]
+func (h *AlertHandler) TestChannel(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.alertService.TestChannel(uint(id)); err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, services.ErrAlertChannelNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: "发送失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "测试通知已发送",
+	})
+}
+
+// GetSilences 获取静默列表
+// @Summary 获取静默列表
+// @Description 获取告警静默，静默期间匹配的告警不发送通知（需要alerts.manage权限）
+// @Tags 告警管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param active query bool false "只返回未结束的静默"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /alerts/silences [get]
+func (h *AlertHandler) GetSilences(c *gin.Context) {
+	var req dto.AlertSilenceListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	silences, err := h.alertService.ListSilences(req.Active)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data:    silences,
+	})
+}
+
+// CreateSilence 创建静默
+// @Summary 创建静默
+// @Description 在时间窗口内静默指定规则和服务器的告警通知，规则或服务器为空时匹配全部（需要alerts.manage权限）
+// @Tags 告警管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body dto.AlertSilenceRequest true "静默信息"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 404 {object} dto.ApiResponse
+// @Router /alerts/silences [post]
+func (h *AlertHandler) CreateSilence(c *gin.Context) {
+	var req dto.AlertSilenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	startsAt := time.Now()
+	if req.StartsAt != "" {
+		t, err := time.Parse(time.RFC3339, req.StartsAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, dto.ApiResponse{
+				Code:    400,
+				Message: "开始时间格式错误，应为RFC3339格式",
+			})
+			return
+		}
+		startsAt = t
+	}
+	endsAt, err := time.Parse(time.RFC3339, req.EndsAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "结束时间格式错误，应为RFC3339格式",
+		})
+		return
+	}
+
+	silence := &models.AlertSilence{
+		AlertRuleID:  req.AlertRuleID,
+		EmbyServerID: req.EmbyServerID,
+		StartsAt:     startsAt,
+		EndsAt:       endsAt,
+		Reason:       req.Reason,
+		CreatedBy:    c.GetUint("user_id"),
+	}
+	if err := h.alertService.CreateSilence(silence); err != nil {
+		c.JSON(alertErrorStatus(err), dto.ApiResponse{
+			Code:    alertErrorStatus(err),
+			Message: err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditTarget(c, silence.ID)
+	middleware.SetAuditDetails(c, silence)
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "静默创建成功",
+		Data:    silence,
+	})
+}
+
+// DeleteSilence 删除静默
+// @Summary 删除静默
+// @Description 提前结束静默，被抑制的告警通知会在下次评估时补发（需要alerts.manage权限）
+// @Tags 告警管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "静默ID"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 404 {object} dto.ApiResponse
+// @Router /alerts/silences/{id} [delete]
+func (h *AlertHandler) DeleteSilence(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.alertService.DeleteSilence(uint(id)); err != nil {
+		c.JSON(alertErrorStatus(err), dto.ApiResponse{
+			Code:    alertErrorStatus(err),
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "删除成功",
+	})
+}
+
+// alertErrorStatus 告警操作错误对应的HTTP状态码
+func alertErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrAlertRuleNotFound),
+		errors.Is(err, services.ErrAlertChannelNotFound),
+		errors.Is(err, services.ErrAlertSilenceNotFound),
+		errors.Is(err, services.ErrServerNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/dto"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// APITokenHandler 个人访问令牌处理器
+type APITokenHandler struct {
+	apiTokenService *services.APITokenService
+	userService     *services.UserService
+}
+
+// NewAPITokenHandler 创建个人访问令牌处理器
+func NewAPITokenHandler() *APITokenHandler {
+	return &APITokenHandler{
+		apiTokenService: services.NewAPITokenService(),
+		userService:     services.NewUserService(),
+	}
+}
+
+// GetTokens 获取个人访问令牌列表
+// @Summary 获取个人访问令牌
+// @Description 获取当前用户的全部个人访问令牌，不包含令牌明文
+// @Tags 访问令牌
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} dto.ApiResponse{data=[]dto.APITokenResponse}
+// @Router /user/tokens [get]
+func (h *APITokenHandler) GetTokens(c *gin.Context) {
+	tokens, err := h.apiTokenService.ListTokens(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	responses := make([]dto.APITokenResponse, 0, len(tokens))
+	for i := range tokens {
+		responses = append(responses, toAPITokenResponse(&tokens[i]))
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data:    responses,
+	})
+}
+
+// GetScopes 获取可授予的权限范围
+// @Summary 获取可授予的权限范围
+// @Description 获取当前用户可以授予个人访问令牌的权限，即当前角色拥有的权限
+// @Tags 访问令牌
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} dto.ApiResponse{data=[]models.Permission}
+// @Router /user/tokens/scopes [get]
+func (h *APITokenHandler) GetScopes(c *gin.Context) {
+	scopes, err := h.apiTokenService.AvailableScopes(c.GetString("role"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data:    scopes,
+	})
+}
+
+// CreateToken 创建个人访问令牌
+// @Summary 创建个人访问令牌
+// @Description 创建用于脚本调用的长期令牌，请求时使用 Authorization: Bearer <token>，令牌明文只在创建时返回一次
+// @Tags 访问令牌
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body dto.CreateAPITokenRequest true "令牌信息"
+// @Success 200 {object} dto.ApiResponse{data=dto.CreateAPITokenResponse}
+// @Failure 400 {object} dto.ApiResponse
+// @Router /user/tokens [post]
+func (h *APITokenHandler) CreateToken(c *gin.Context) {
+	var req dto.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	token, plaintext, err := h.apiTokenService.CreateToken(user, req.Name, req.Scopes, req.ExpiresInDays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "令牌已创建，请立即复制保存，之后将无法再次查看",
+		Data: dto.CreateAPITokenResponse{
+			APITokenResponse: toAPITokenResponse(token),
+			Token:            plaintext,
+		},
+	})
+}
+
+// UpdateToken 修改个人访问令牌
+// @Summary 修改个人访问令牌
+// @Description 修改令牌名称或权限范围
+// @Tags 访问令牌
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "令牌ID"
+// @Param request body dto.UpdateAPITokenRequest true "令牌信息"
+// @Success 200 {object} dto.ApiResponse{data=dto.APITokenResponse}
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 404 {object} dto.ApiResponse
+// @Router /user/tokens/{id} [put]
+func (h *APITokenHandler) UpdateToken(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req dto.UpdateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	token, err := h.apiTokenService.UpdateToken(user, uint(id), req.Name, req.Scopes)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrAPITokenNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "更新成功",
+		Data:    toAPITokenResponse(token),
+	})
+}
+
+// DeleteToken 删除个人访问令牌
+// @Summary 删除个人访问令牌
+// @Description 删除令牌，使用该令牌的脚本将立即无法访问
+// @Tags 访问令牌
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "令牌ID"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 404 {object} dto.ApiResponse
+// @Router /user/tokens/{id} [delete]
+func (h *APITokenHandler) DeleteToken(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.apiTokenService.DeleteToken(c.GetUint("user_id"), uint(id)); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrAPITokenNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "令牌已删除",
+	})
+}
+
+// currentUser 获取当前用户，失败时直接写入错误响应
+func (h *APITokenHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	user, err := h.userService.GetUserByID(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, dto.ApiResponse{
+			Code:    401,
+			Message: err.Error(),
+		})
+		return nil, false
+	}
+	return user, true
+}
+
+// toAPITokenResponse 转换为令牌响应
+func toAPITokenResponse(token *models.APIToken) dto.APITokenResponse {
+	response := dto.APITokenResponse{
+		ID:         token.ID,
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scopes:     token.Scopes,
+		LastUsedIP: token.LastUsedIP,
+		CreatedAt:  token.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if response.Scopes == nil {
+		response.Scopes = []string{}
+	}
+	if token.ExpiresAt != nil {
+		expiresAt := token.ExpiresAt.Format("2006-01-02 15:04:05")
+		response.ExpiresAt = &expiresAt
+		response.Expired = time.Now().After(*token.ExpiresAt)
+	}
+	if token.LastUsedAt != nil {
+		lastUsedAt := token.LastUsedAt.Format("2006-01-02 15:04:05")
+		response.LastUsedAt = &lastUsedAt
+	}
+	return response
+}
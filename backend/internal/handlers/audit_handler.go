@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/dto"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler 审计日志处理器
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+// NewAuditHandler 创建审计日志处理器
+func NewAuditHandler() *AuditHandler {
+	return &AuditHandler{
+		auditService: services.NewAuditService(),
+	}
+}
+
+// GetEvents 查询审计事件
+// @Summary 查询审计事件
+// @Description 按操作者、操作、对象、IP和时间范围分页查询审计事件，最新的在前（需要audit.view权限）
+// @Tags 审计日志
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Param actor_id query int false "操作者ID"
+// @Param actor query string false "操作者用户名"
+// @Param action query string false "操作，以.结尾时按前缀匹配，如 server."
+// @Param target_type query string false "对象类型，如 user、server"
+// @Param target_id query string false "对象ID"
+// @Param ip query string false "客户端IP"
+// @Param from query string false "开始时间（RFC3339，包含）"
+// @Param to query string false "结束时间（RFC3339，不包含）"
+// @Success 200 {object} dto.ApiResponse{data=dto.PageResponse}
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /audit [get]
+func (h *AuditHandler) GetEvents(c *gin.Context) {
+	var req dto.AuditListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+	query, err := toAuditQuery(req.AuditQueryRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	events, total, err := h.auditService.ListEvents(query, req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	responses := make([]dto.AuditEventResponse, 0, len(events))
+	for i := range events {
+		responses = append(responses, toAuditEventResponse(&events[i]))
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data: dto.PageResponse{
+			List:     responses,
+			Total:    total,
+			Page:     req.Page,
+			PageSize: req.PageSize,
+		},
+	})
+}
+
+// ExportEvents 导出审计事件
+// @Summary 导出审计事件
+// @Description 按与查询相同的条件导出审计事件，最新的在前，单次最多导出10万条（需要audit.view权限）
+// @Tags 审计日志
+// @Produce text/csv
+// @Produce json
+// @Security ApiKeyAuth
+// @Param format query string false "导出格式" Enums(csv, json) default(csv)
+// @Param actor_id query int false "操作者ID"
+// @Param actor query string false "操作者用户名"
+// @Param action query string false "操作，以.结尾时按前缀匹配，如 server."
+// @Param target_type query string false "对象类型，如 user、server"
+// @Param target_id query string false "对象ID"
+// @Param ip query string false "客户端IP"
+// @Param from query string false "开始时间（RFC3339，包含）"
+// @Param to query string false "结束时间（RFC3339，不包含）"
+// @Success 200 {file} file "审计事件文件"
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /audit/export [get]
+func (h *AuditHandler) ExportEvents(c *gin.Context) {
+	var req dto.AuditExportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+	query, err := toAuditQuery(req.AuditQueryRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.%s", time.Now().Format("20060102-150405"), req.Format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// 响应已经开始写入后无法再返回错误，导出中断时只记录日志
+	if req.Format == "json" {
+		err = h.exportJSON(c, query)
+	} else {
+		err = h.exportCSV(c, query)
+	}
+	if err != nil {
+		log.Printf("导出审计事件失败: %v", err)
+	}
+}
+
+// exportCSV 以CSV格式逐行写出审计事件
+func (h *AuditHandler) exportCSV(c *gin.Context, query services.AuditQuery) error {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	// 写入BOM，便于Excel识别UTF-8编码
+	c.Writer.WriteString("\xEF\xBB\xBF")
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"id", "created_at", "actor_id", "actor_name", "action", "target_type", "target_id", "ip", "user_agent", "before", "after"})
+	err := h.auditService.ExportEvents(query, func(event *models.AuditEvent) error {
+		actorID := ""
+		if event.ActorID != nil {
+			actorID = fmt.Sprint(*event.ActorID)
+		}
+		return w.Write([]string{
+			fmt.Sprint(event.ID),
+			event.CreatedAt.Format(time.RFC3339),
+			actorID,
+			event.ActorName,
+			event.Action,
+			event.TargetType,
+			event.TargetID,
+			event.IP,
+			event.UserAgent,
+			event.Before,
+			event.After,
+		})
+	})
+	w.Flush()
+	if err != nil {
+		return err
+	}
+	return w.Error()
+}
+
+// exportJSON 以JSON数组格式逐条写出审计事件
+func (h *AuditHandler) exportJSON(c *gin.Context, query services.AuditQuery) error {
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	c.Writer.WriteString("[")
+	first := true
+	err := h.auditService.ExportEvents(query, func(event *models.AuditEvent) error {
+		data, err := json.Marshal(toAuditEventResponse(event))
+		if err != nil {
+			return err
+		}
+		if !first {
+			c.Writer.WriteString(",\n")
+		}
+		first = false
+		_, err = c.Writer.Write(data)
+		return err
+	})
+	c.Writer.WriteString("]\n")
+	return err
+}
+
+// toAuditQuery 把请求参数转换为查询条件
+func toAuditQuery(req dto.AuditQueryRequest) (services.AuditQuery, error) {
+	from, to, err := parseTimeRange(req.From, req.To)
+	return services.AuditQuery{
+		ActorID:    req.ActorID,
+		Actor:      req.Actor,
+		Action:     req.Action,
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		IP:         req.IP,
+		From:       from,
+		To:         to,
+	}, err
+}
+
+// toAuditEventResponse 转换为审计事件响应
+func toAuditEventResponse(event *models.AuditEvent) dto.AuditEventResponse {
+	response := dto.AuditEventResponse{
+		ID:         event.ID,
+		ActorID:    event.ActorID,
+		ActorName:  event.ActorName,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+		CreatedAt:  event.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if event.Before != "" {
+		response.Before = json.RawMessage(event.Before)
+	}
+	if event.After != "" {
+		response.After = json.RawMessage(event.After)
+	}
+	if event.Before != "" && event.After != "" {
+		response.Changes = services.AuditDiff(event.Before, event.After)
+	}
+	return response
+}
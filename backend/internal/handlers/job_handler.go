@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// JobHandler 同步任务处理器
+type JobHandler struct {
+	syncJobService *services.SyncJobService
+}
+
+// NewJobHandler 创建同步任务处理器
+func NewJobHandler(syncJobService *services.SyncJobService) *JobHandler {
+	return &JobHandler{
+		syncJobService: syncJobService,
+	}
+}
+
+// GetJobs 获取同步任务列表
+// @Summary 获取同步任务列表
+// @Description 获取最近的同步任务，拥有jobs.manage权限的用户可查看所有用户的任务
+// @Tags Jobs
+// @Security BearerAuth
+// @Param limit query int false "数量，默认20"
+// @Success 200 {object} map[string]interface{} "任务列表"
+// @Failure 401 {object} map[string]interface{} "未授权"
+// @Failure 500 {object} map[string]interface{} "服务器错误"
+// @Router /api/jobs [get]
+func (h *JobHandler) GetJobs(c *gin.Context) {
+	limit := 20
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	userID := c.GetUint("user_id")
+	if hasPermission(c, services.PermJobsManage) {
+		userID = 0
+	}
+
+	jobs, err := h.syncJobService.ListJobs(userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    jobs,
+	})
+}
+
+// GetJob 获取同步任务详情
+// @Summary 获取同步任务详情
+// @Description 获取同步任务的状态、进度和错误列表，任务发起人、服务器操作员和拥有jobs.manage权限的用户可访问
+// @Tags Jobs
+// @Security BearerAuth
+// @Param id path int true "任务ID"
+// @Success 200 {object} map[string]interface{} "任务详情"
+// @Failure 400 {object} map[string]interface{} "请求错误"
+// @Failure 404 {object} map[string]interface{} "任务不存在"
+// @Router /api/jobs/:id [get]
+func (h *JobHandler) GetJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	job, err := h.syncJobService.GetJob(uint(id))
+	if err != nil || !canAccessJob(c, job) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    job,
+	})
+}
+
+// CancelJob 取消同步任务
+// @Summary 取消同步任务
+// @Description 取消等待中或运行中的同步任务
+// @Tags Jobs
+// @Security BearerAuth
+// @Param id path int true "任务ID"
+// @Success 200 {object} map[string]interface{} "取消成功"
+// @Failure 404 {object} map[string]interface{} "任务不存在"
+// @Failure 409 {object} map[string]interface{} "任务已结束"
+// @Router /api/jobs/:id/cancel [post]
+func (h *JobHandler) CancelJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	job, err := h.syncJobService.GetJob(uint(id))
+	if err != nil || !canAccessJob(c, job) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+
+	if _, err := h.syncJobService.CancelJob(job.ID); err != nil {
+		if errors.Is(err, services.ErrSyncJobFinished) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "任务取消中",
+	})
+}
+
+// canAccessJob 检查当前用户是否可以访问任务
+// 除发起人和拥有jobs.manage权限的用户外，服务器的操作员也可以访问该服务器的任务，
+// 因为服务器已在同步时发起同步会返回他人或调度器创建的任务
+func canAccessJob(c *gin.Context, job *models.SyncJob) bool {
+	userID := c.GetUint("user_id")
+	if userID == job.CreatedBy || hasPermission(c, services.PermJobsManage) {
+		return true
+	}
+	if job.EmbyServerID == 0 {
+		return false
+	}
+	return serverAccess.CheckAccess(userID, canManageAllServers(c), job.EmbyServerID, services.ServerRoleOperator) == nil
+}
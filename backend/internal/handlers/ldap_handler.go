@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/emby-client-go/backend/internal/dto"
+	"github.com/emby-client-go/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// LDAPHandler LDAP目录管理处理器
+type LDAPHandler struct {
+	ldapService *services.LDAPService
+}
+
+// NewLDAPHandler 创建LDAP目录管理处理器
+func NewLDAPHandler() *LDAPHandler {
+	return &LDAPHandler{
+		ldapService: services.NewLDAPService(),
+	}
+}
+
+// SyncGroups 立即同步目录用户
+// @Summary 同步LDAP目录用户
+// @Description 立即按目录中的组同步所有目录用户的角色，目录中已删除的用户会被禁用（需要system.manage权限）
+// @Tags 系统管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} dto.ApiResponse{data=services.LDAPSyncResult}
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 500 {object} dto.ApiResponse
+// @Router /system/ldap/sync [post]
+func (h *LDAPHandler) SyncGroups(c *gin.Context) {
+	result, err := h.ldapService.SyncGroups(c.Request.Context())
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrLDAPDisabled) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "同步完成",
+		Data:    result,
+	})
+}
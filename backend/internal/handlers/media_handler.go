@@ -1,22 +1,26 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/emby-client-go/backend/internal/middleware"
 	"github.com/emby-client-go/backend/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
 // MediaHandler 媒体库处理器
 type MediaHandler struct {
-	mediaService *services.MediaService
+	mediaService   *services.MediaService
+	syncJobService *services.SyncJobService
 }
 
 // NewMediaHandler 创建媒体库处理器
-func NewMediaHandler() *MediaHandler {
+func NewMediaHandler(syncJobService *services.SyncJobService) *MediaHandler {
 	return &MediaHandler{
-		mediaService: services.NewMediaService(),
+		mediaService:   services.NewMediaService(),
+		syncJobService: syncJobService,
 	}
 }
 
@@ -54,8 +58,13 @@ func (h *MediaHandler) GetMediaLibraries(c *gin.Context) {
 			"data":    libraries,
 		})
 	} else {
-		// 获取所有媒体库
-		libraries, err := h.mediaService.GetAllMediaLibraries()
+		// 获取当前用户可访问的所有媒体库
+		serverIDs, ok := accessibleServerIDs(c, services.ServerRoleViewer)
+		if !ok {
+			return
+		}
+
+		libraries, err := h.mediaService.GetAllMediaLibraries(serverIDs)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -103,12 +112,15 @@ func (h *MediaHandler) GetMediaLibrary(c *gin.Context) {
 
 // SyncMediaLibraries 同步服务器媒体库
 // @Summary 同步媒体库
-// @Description 同步指定服务器的媒体库信息
+// @Description 创建同步指定服务器媒体库的后台任务，立即返回任务信息，进度通过WebSocket的sync-progress消息推送
 // @Tags Media
 // @Security BearerAuth
 // @Param id path int true "服务器ID"
-// @Success 200 {object} map[string]interface{} "同步结果"
+// @Param full query bool false "是否强制全量同步媒体项目"
+// @Success 202 {object} map[string]interface{} "同步任务"
+// @Success 200 {object} map[string]interface{} "服务器正在同步，返回运行中的任务"
 // @Failure 400 {object} map[string]interface{} "请求错误"
+// @Failure 409 {object} map[string]interface{} "服务器正在进行非任务同步"
 // @Failure 401 {object} map[string]interface{} "未授权"
 // @Failure 500 {object} map[string]interface{} "同步失败"
 // @Router /api/media/sync/:id [post]
@@ -120,41 +132,65 @@ func (h *MediaHandler) SyncMediaLibraries(c *gin.Context) {
 		return
 	}
 
-	count, err := h.mediaService.SyncMediaLibraries(c.Request.Context(), uint(id))
+	full := c.Query("full") == "true"
+
+	job, created, err := h.syncJobService.StartServerSync(uint(id), c.GetUint("user_id"), full)
+	if errors.Is(err, services.ErrServerSyncing) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	middleware.SetAuditDetails(c, gin.H{"job_id": job.ID, "full": full, "created": created})
 
-	c.JSON(http.StatusOK, gin.H{
-		"code":    200,
-		"message": "同步成功",
-		"data": gin.H{
-			"count": count,
-		},
+	if !created {
+		// 服务器已在同步，返回正在运行的任务
+		c.JSON(http.StatusOK, gin.H{
+			"code":    200,
+			"message": "服务器正在同步中",
+			"data":    job,
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"code":    202,
+		"message": "同步任务已创建",
+		"data":    job,
 	})
 }
 
 // SyncAllServers 同步所有服务器媒体库
 // @Summary 同步所有服务器
-// @Description 同步所有在线服务器的媒体库信息
+// @Description 创建同步当前用户可操作的所有在线服务器媒体库的后台任务，立即返回任务信息
 // @Tags Media
 // @Security BearerAuth
-// @Success 200 {object} map[string]interface{} "同步结果"
+// @Param full query bool false "是否强制全量同步媒体项目"
+// @Success 202 {object} map[string]interface{} "同步任务"
 // @Failure 401 {object} map[string]interface{} "未授权"
 // @Failure 500 {object} map[string]interface{} "同步失败"
 // @Router /api/media/sync-all [post]
 func (h *MediaHandler) SyncAllServers(c *gin.Context) {
-	results, err := h.mediaService.SyncAllServers(c.Request.Context())
+	full := c.Query("full") == "true"
+
+	serverIDs, ok := accessibleServerIDs(c, services.ServerRoleOperator)
+	if !ok {
+		return
+	}
+
+	job, err := h.syncJobService.StartAllSync(c.GetUint("user_id"), serverIDs, full)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	middleware.SetAuditDetails(c, gin.H{"job_id": job.ID, "full": full, "server_ids": serverIDs})
 
-	c.JSON(http.StatusOK, gin.H{
-		"code":    200,
-		"message": "同步完成",
-		"data":    results,
+	c.JSON(http.StatusAccepted, gin.H{
+		"code":    202,
+		"message": "同步任务已创建",
+		"data":    job,
 	})
 }
 
@@ -190,7 +226,7 @@ func (h *MediaHandler) RefreshMediaLibrary(c *gin.Context) {
 
 // GetMediaLibraryStats 获取媒体库统计
 // @Summary 获取媒体库统计
-// @Description 获取所有媒体库的统计信息
+// @Description 获取当前用户可访问的所有媒体库的统计信息
 // @Tags Media
 // @Security BearerAuth
 // @Success 200 {object} map[string]interface{} "统计信息"
@@ -198,7 +234,12 @@ func (h *MediaHandler) RefreshMediaLibrary(c *gin.Context) {
 // @Failure 500 {object} map[string]interface{} "获取失败"
 // @Router /api/media/stats [get]
 func (h *MediaHandler) GetMediaLibraryStats(c *gin.Context) {
-	stats, err := h.mediaService.GetMediaLibraryStats()
+	serverIDs, ok := accessibleServerIDs(c, services.ServerRoleViewer)
+	if !ok {
+		return
+	}
+
+	stats, err := h.mediaService.GetMediaLibraryStats(serverIDs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
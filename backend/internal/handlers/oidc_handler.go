@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/emby-client-go/backend/internal/dto"
+	"github.com/emby-client-go/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// oidcStateCookie 保存发起登录的浏览器的state，回调时必须一致，防止登录CSRF
+	oidcStateCookie = "oidc_state"
+	// oidcStateCookiePath 只在登录和回调路径发送
+	oidcStateCookiePath = "/api/auth/oidc"
+)
+
+// OIDCHandler OIDC单点登录处理器
+type OIDCHandler struct {
+	oidcService      *services.OIDCService
+	twoFactorService *services.TwoFactorService
+	sessionService   *services.SessionService
+}
+
+// NewOIDCHandler 创建OIDC单点登录处理器
+func NewOIDCHandler() *OIDCHandler {
+	return &OIDCHandler{
+		oidcService:      services.NewOIDCService(),
+		twoFactorService: services.NewTwoFactorService(),
+		sessionService:   services.NewSessionService(),
+	}
+}
+
+// GetConfig 获取单点登录配置
+// @Summary 获取单点登录配置
+// @Description 登录页据此决定是否显示单点登录按钮
+// @Tags 用户认证
+// @Produce json
+// @Success 200 {object} dto.ApiResponse{data=dto.OIDCConfigResponse}
+// @Router /auth/oidc/config [get]
+func (h *OIDCHandler) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data: dto.OIDCConfigResponse{
+			Enabled:      h.oidcService.Enabled(),
+			ProviderName: h.oidcService.ProviderName(),
+		},
+	})
+}
+
+// Login 跳转到身份提供商
+// @Summary 单点登录
+// @Description 生成state、nonce和PKCE校验码后302跳转到身份提供商的授权页面，state同时写入HttpOnly Cookie
+// @Tags 用户认证
+// @Success 302
+// @Failure 404 {object} dto.ApiResponse
+// @Router /auth/oidc/login [get]
+func (h *OIDCHandler) Login(c *gin.Context) {
+	if !h.oidcService.Enabled() {
+		c.JSON(http.StatusNotFound, dto.ApiResponse{
+			Code:    404,
+			Message: services.ErrOIDCDisabled.Error(),
+		})
+		return
+	}
+
+	authURL, state, err := h.oidcService.AuthCodeURL(c.Request.Context())
+	if err != nil {
+		log.Printf("生成单点登录地址失败: %v", err)
+		h.redirectFrontend(c, "oidc_error", err.Error())
+		return
+	}
+	h.setStateCookie(c, state, int(services.OIDCStateTTL.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback 身份提供商回调
+// @Summary 单点登录回调
+// @Description 校验state与发起登录时写入的Cookie一致，校验授权码和ID Token后跳转回前端，URL片段中携带一次性登录码（oidc_code）或错误信息（oidc_error）
+// @Tags 用户认证
+// @Param code query string true "授权码"
+// @Param state query string true "state"
+// @Success 302
+// @Router /auth/oidc/callback [get]
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	cookieState, _ := c.Cookie(oidcStateCookie)
+	h.setStateCookie(c, "", -1)
+
+	if errMsg := c.Query("error"); errMsg != "" {
+		if desc := c.Query("error_description"); desc != "" {
+			errMsg += ": " + desc
+		}
+		h.redirectFrontend(c, "oidc_error", errMsg)
+		return
+	}
+
+	// 回调必须由发起登录的同一浏览器完成，否则攻击者可以让受害者登录到攻击者的账户
+	state := c.Query("state")
+	if cookieState == "" || subtle.ConstantTimeCompare([]byte(cookieState), []byte(state)) != 1 {
+		log.Printf("单点登录回调失败: state与Cookie不一致")
+		h.redirectFrontend(c, "oidc_error", services.ErrOIDCInvalidState.Error())
+		return
+	}
+
+	loginCode, err := h.oidcService.HandleCallback(c.Request.Context(), c.Query("code"), state)
+	if err != nil {
+		log.Printf("单点登录回调失败: %v", err)
+		h.redirectFrontend(c, "oidc_error", err.Error())
+		return
+	}
+	h.redirectFrontend(c, "oidc_code", loginCode)
+}
+
+// Exchange 使用一次性登录码换取令牌
+// @Summary 单点登录换取令牌
+// @Description 使用回调返回的一次性登录码完成登录，已启用两步验证时返回two_factor_token
+// @Tags 用户认证
+// @Accept json
+// @Produce json
+// @Param request body dto.OIDCExchangeRequest true "登录码"
+// @Success 200 {object} dto.ApiResponse{data=dto.LoginResponse}
+// @Failure 401 {object} dto.ApiResponse
+// @Router /auth/oidc/exchange [post]
+func (h *OIDCHandler) Exchange(c *gin.Context) {
+	var req dto.OIDCExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := h.oidcService.ExchangeLoginCode(req.Code)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, dto.ApiResponse{
+			Code:    401,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	continueLogin(c, h.twoFactorService, h.sessionService, user, req.DeviceName)
+}
+
+// setStateCookie 写入或清除（maxAge为负数）state Cookie
+// 身份提供商回调是跨站的顶层跳转，SameSite=Lax时Cookie仍会随GET请求发送
+func (h *OIDCHandler) setStateCookie(c *gin.Context, state string, maxAge int) {
+	secure := c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, maxAge, oidcStateCookiePath, "", secure, true)
+}
+
+// redirectFrontend 跳转回前端，参数放在URL片段中避免被记录到服务器日志
+func (h *OIDCHandler) redirectFrontend(c *gin.Context, key, value string) {
+	c.Redirect(http.StatusFound, h.oidcService.FrontendURL()+"#"+key+"="+url.QueryEscape(value))
+}
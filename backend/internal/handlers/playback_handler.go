@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/emby-client-go/backend/internal/middleware"
 	"github.com/emby-client-go/backend/internal/services"
 	"github.com/gin-gonic/gin"
 )
@@ -43,6 +44,7 @@ func (h *PlaybackHandler) SendPlayCommand(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	middleware.SetAuditDetails(c, gin.H{"server_id": serverID, "command": cmd})
 
 	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "命令发送成功"})
 }
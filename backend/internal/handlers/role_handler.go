@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/emby-client-go/backend/internal/dto"
+	"github.com/emby-client-go/backend/internal/middleware"
+	"github.com/emby-client-go/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// RoleHandler 角色权限处理器
+type RoleHandler struct {
+	rbacService *services.RBACService
+}
+
+// NewRoleHandler 创建角色权限处理器
+func NewRoleHandler() *RoleHandler {
+	return &RoleHandler{
+		rbacService: services.NewRBACService(),
+	}
+}
+
+// GetRoles 获取角色列表
+// @Summary 获取角色列表
+// @Description 获取所有角色及其权限（需要roles.manage权限）
+// @Tags 角色管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /roles [get]
+func (h *RoleHandler) GetRoles(c *gin.Context) {
+	roles, err := h.rbacService.GetRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data:    roles,
+	})
+}
+
+// GetPermissions 获取权限列表
+// @Summary 获取权限列表
+// @Description 获取系统支持的所有权限（需要roles.manage权限）
+// @Tags 角色管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /roles/permissions [get]
+func (h *RoleHandler) GetPermissions(c *gin.Context) {
+	permissions, err := h.rbacService.GetPermissions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data:    permissions,
+	})
+}
+
+// CreateRole 创建角色
+// @Summary 创建自定义角色
+// @Description 使用指定权限创建自定义角色，不能包含自己没有的权限（需要roles.manage权限）
+// @Tags 角色管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body dto.CreateRoleRequest true "角色信息"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /roles [post]
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req dto.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	role, err := h.rbacService.CreateRole(c.GetString("role"), req.Name, req.Description, req.Permissions)
+	if err != nil {
+		c.JSON(roleErrorStatus(err), dto.ApiResponse{
+			Code:    roleErrorStatus(err),
+			Message: err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditTarget(c, role.ID)
+	middleware.SetAuditDetails(c, role)
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "角色创建成功",
+		Data:    role,
+	})
+}
+
+// UpdateRole 更新角色
+// @Summary 更新角色
+// @Description 更新角色描述和权限，admin角色的权限不可修改，不能添加自己没有的权限（需要roles.manage权限）
+// @Tags 角色管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "角色ID"
+// @Param request body dto.UpdateRoleRequest true "角色信息"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Failure 404 {object} dto.ApiResponse
+// @Router /roles/{id} [put]
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req dto.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	role, err := h.rbacService.UpdateRole(c.GetString("role"), uint(id), req.Description, req.Permissions)
+	if err != nil {
+		c.JSON(roleErrorStatus(err), dto.ApiResponse{
+			Code:    roleErrorStatus(err),
+			Message: err.Error(),
+		})
+		return
+	}
+	middleware.SetAuditDetails(c, role)
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "更新成功",
+		Data:    role,
+	})
+}
+
+// DeleteRole 删除角色
+// @Summary 删除角色
+// @Description 删除自定义角色，内置角色和仍有用户使用的角色不能删除（需要roles.manage权限）
+// @Tags 角色管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "角色ID"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 404 {object} dto.ApiResponse
+// @Router /roles/{id} [delete]
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.rbacService.DeleteRole(uint(id)); err != nil {
+		c.JSON(roleErrorStatus(err), dto.ApiResponse{
+			Code:    roleErrorStatus(err),
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "删除成功",
+	})
+}
+
+// roleErrorStatus 角色操作错误对应的HTTP状态码
+func roleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrRoleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrPermissionNotHeld):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
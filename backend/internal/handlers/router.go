@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"github.com/emby-client-go/backend/internal/config"
+	"github.com/emby-client-go/backend/internal/metrics"
 	"github.com/emby-client-go/backend/internal/middleware"
+	"github.com/emby-client-go/backend/internal/scheduler"
+	"github.com/emby-client-go/backend/internal/services"
 	"github.com/emby-client-go/backend/pkg/websocket"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -9,7 +13,13 @@ import (
 )
 
 // SetupRoutes 设置路由
-func SetupRoutes(r *gin.Engine, hub *websocket.Hub, wsManager *websocket.Manager) {
+func SetupRoutes(r *gin.Engine, hub *websocket.Hub, wsManager *websocket.Manager, sched *scheduler.Scheduler, syncJobService *services.SyncJobService) {
+	// Prometheus指标，中间件需要在注册路由之前添加
+	if cfg := config.AppConfig.Metrics; cfg.Enabled {
+		r.Use(middleware.Metrics())
+		r.GET("/metrics", middleware.MetricsToken(cfg.Token), gin.WrapH(metrics.Handler()))
+	}
+
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
@@ -22,8 +32,38 @@ func SetupRoutes(r *gin.Engine, hub *websocket.Hub, wsManager *websocket.Manager
 	userHandler := NewUserHandler()
 	serverHandler := NewServerHandler()
 	wsHandler := NewWebSocketHandler(hub, wsManager)
-	mediaHandler := NewMediaHandler()
+	mediaHandler := NewMediaHandler(syncJobService)
 	searchHandler := NewSearchHandler()
+	schedulerHandler := NewSchedulerHandler(sched)
+	jobHandler := NewJobHandler(syncJobService)
+	roleHandler := NewRoleHandler()
+	twoFactorHandler := NewTwoFactorHandler()
+	securityHandler := NewSecurityHandler()
+	oidcHandler := NewOIDCHandler()
+	ldapHandler := NewLDAPHandler()
+	apiTokenHandler := NewAPITokenHandler()
+	accountHandler := NewAccountHandler()
+	auditHandler := NewAuditHandler()
+	alertHandler := NewAlertHandler()
+
+	// 服务器访问权限：查看 < 操作 < 所有者，拥有server.manage权限的用户不受限制
+	// 同步、播放控制等操作还需要角色拥有对应的功能权限
+	serverParam := middleware.ServerParam("id")
+	viewer := func(resolve middleware.ServerResolver) gin.HandlerFunc {
+		return middleware.RequireServerRole(services.ServerRoleViewer, resolve)
+	}
+	operator := func(resolve middleware.ServerResolver) gin.HandlerFunc {
+		return middleware.RequireServerRole(services.ServerRoleOperator, resolve)
+	}
+	owner := func(resolve middleware.ServerResolver) gin.HandlerFunc {
+		return middleware.RequireServerRole(services.ServerRoleOwner, resolve)
+	}
+	mediaSync := middleware.RequirePermission(services.PermMediaSync)
+	// 没有服务器角色检查的只读路由，使用个人访问令牌时要求令牌至少包含查看服务器所需的权限范围
+	viewerScope := middleware.RequireTokenScope(middleware.ServerRoleScopes(services.ServerRoleViewer)...)
+
+	// 没有在服务中记录审计事件的写操作，由中间件在请求成功后记录
+	audit := middleware.Audit
 
 	// API路由组
 	api := r.Group("/api")
@@ -34,8 +74,24 @@ func SetupRoutes(r *gin.Engine, hub *websocket.Hub, wsManager *websocket.Manager
 			auth.POST("/register", userHandler.Register)
 			auth.POST("/login", userHandler.Login)
 			auth.POST("/refresh", userHandler.RefreshToken)
+			// 找回密码和邮箱验证
+			auth.GET("/password-policy", accountHandler.GetPasswordPolicy)
+			auth.POST("/forgot-password", accountHandler.ForgotPassword)
+			auth.POST("/reset-password", accountHandler.ResetPassword)
+			auth.POST("/verify-email", accountHandler.VerifyEmail)
+			auth.POST("/resend-verification", accountHandler.ResendVerification)
 			// 登出需要认证
-			auth.POST("/logout", middleware.AuthMiddleware(), userHandler.Logout)
+			auth.POST("/logout", middleware.AuthMiddleware(), middleware.RequireSessionAuth(), userHandler.Logout)
+			auth.POST("/logout-all", middleware.AuthMiddleware(), middleware.RequireSessionAuth(), userHandler.LogoutAll)
+			// 两步验证登录，使用登录返回的临时令牌
+			auth.POST("/2fa/verify", twoFactorHandler.VerifyLogin)
+			auth.POST("/2fa/setup", twoFactorHandler.SetupLogin)
+			auth.POST("/2fa/enable", twoFactorHandler.EnableLogin)
+			// OIDC单点登录
+			auth.GET("/oidc/config", oidcHandler.GetConfig)
+			auth.GET("/oidc/login", oidcHandler.Login)
+			auth.GET("/oidc/callback", oidcHandler.Callback)
+			auth.POST("/oidc/exchange", oidcHandler.Exchange)
 		}
 
 		// 用户路由（需要认证）
@@ -43,56 +99,152 @@ func SetupRoutes(r *gin.Engine, hub *websocket.Hub, wsManager *websocket.Manager
 		user.Use(middleware.AuthMiddleware())
 		{
 			user.GET("/profile", userHandler.GetProfile)
-			user.POST("/change-password", userHandler.ChangePassword)
 
-			// 管理员权限路由
-			admin := user.Group("")
-			admin.Use(middleware.AdminMiddleware())
+			// 账户安全操作只能在登录会话中进行，个人访问令牌无法使用
+			account := user.Group("")
+			account.Use(middleware.RequireSessionAuth())
+			{
+				account.POST("/change-password", userHandler.ChangePassword)
+				account.GET("/sessions", userHandler.GetSessions)
+				account.DELETE("/sessions/:id", userHandler.RevokeSession)
+
+				// 两步验证
+				account.GET("/2fa", twoFactorHandler.GetStatus)
+				account.POST("/2fa/setup", twoFactorHandler.Setup)
+				account.POST("/2fa/enable", twoFactorHandler.Enable)
+				account.POST("/2fa/disable", twoFactorHandler.Disable)
+				account.POST("/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+
+				// 个人访问令牌
+				account.GET("/tokens", apiTokenHandler.GetTokens)
+				account.GET("/tokens/scopes", apiTokenHandler.GetScopes)
+				account.POST("/tokens", apiTokenHandler.CreateToken)
+				account.PUT("/tokens/:id", apiTokenHandler.UpdateToken)
+				account.DELETE("/tokens/:id", apiTokenHandler.DeleteToken)
+			}
+
+			// 用户管理路由
+			manage := user.Group("")
+			manage.Use(middleware.RequirePermission(services.PermUsersManage))
 			{
-				admin.GET("/list", userHandler.GetUsers)
+				manage.GET("/list", userHandler.GetUsers)
+				manage.POST("/create", userHandler.CreateUser)
+				manage.GET("/:id", userHandler.GetUser)
+				manage.PUT("/:id", userHandler.UpdateUser)
+				manage.DELETE("/:id", userHandler.DeleteUser)
+				manage.POST("/:id/disable", userHandler.DisableUser)
+				manage.POST("/:id/enable", userHandler.EnableUser)
+				manage.POST("/:id/unlock", userHandler.UnlockUser)
+				manage.POST("/:id/reset-password", userHandler.ResetPassword)
+				manage.PUT("/:id/role", userHandler.ChangeRole)
+				manage.PUT("/:id/auth-source", userHandler.SetAuthSource)
 			}
 		}
 
+		// 角色管理路由（需要roles.manage权限）
+		roles := api.Group("/roles")
+		roles.Use(middleware.AuthMiddleware(), middleware.RequirePermission(services.PermRolesManage))
+		{
+			roles.GET("", roleHandler.GetRoles)
+			roles.GET("/permissions", roleHandler.GetPermissions)
+			roles.POST("", audit(services.AuditActionRoleCreate, services.AuditTargetRole, ""), roleHandler.CreateRole)
+			roles.PUT("/:id", audit(services.AuditActionRoleUpdate, services.AuditTargetRole, "id"), roleHandler.UpdateRole)
+			roles.DELETE("/:id", audit(services.AuditActionRoleDelete, services.AuditTargetRole, "id"), roleHandler.DeleteRole)
+		}
+
+		// 系统管理路由（需要system.manage权限）
+		system := api.Group("/system")
+		system.Use(middleware.AuthMiddleware(), middleware.RequirePermission(services.PermSystemManage))
+		{
+			system.GET("/security-policy", securityHandler.GetSecurityPolicy)
+			system.PUT("/security-policy", audit(services.AuditActionSecurityPolicy, services.AuditTargetSystem, ""), securityHandler.UpdateSecurityPolicy)
+			system.POST("/ldap/sync", audit(services.AuditActionLDAPSync, services.AuditTargetSystem, ""), ldapHandler.SyncGroups)
+		}
+
+		// 审计日志路由（需要audit.view权限）
+		auditLog := api.Group("/audit")
+		auditLog.Use(middleware.AuthMiddleware(), middleware.RequirePermission(services.PermAuditView))
+		{
+			auditLog.GET("", auditHandler.GetEvents)
+			auditLog.GET("/export", auditHandler.ExportEvents)
+		}
+
+		// 告警路由（需要alerts.manage权限）
+		alerts := api.Group("/alerts")
+		alerts.Use(middleware.AuthMiddleware(), middleware.RequirePermission(services.PermAlertsManage))
+		{
+			alerts.GET("", alertHandler.GetAlerts)
+			alerts.GET("/rules", alertHandler.GetRules)
+			alerts.POST("/rules", audit(services.AuditActionAlertRuleCreate, services.AuditTargetAlertRule, ""), alertHandler.CreateRule)
+			alerts.PUT("/rules/:id", audit(services.AuditActionAlertRuleUpdate, services.AuditTargetAlertRule, "id"), alertHandler.UpdateRule)
+			alerts.DELETE("/rules/:id", audit(services.AuditActionAlertRuleDelete, services.AuditTargetAlertRule, "id"), alertHandler.DeleteRule)
+			alerts.GET("/channels", alertHandler.GetChannels)
+			alerts.POST("/channels", audit(services.AuditActionAlertChannelCreate, services.AuditTargetAlertChannel, ""), alertHandler.CreateChannel)
+			alerts.PUT("/channels/:id", audit(services.AuditActionAlertChannelUpdate, services.AuditTargetAlertChannel, "id"), alertHandler.UpdateChannel)
+			alerts.DELETE("/channels/:id", audit(services.AuditActionAlertChannelDelete, services.AuditTargetAlertChannel, "id"), alertHandler.DeleteChannel)
+			alerts.POST("/channels/:id/test", alertHandler.TestChannel)
+			alerts.GET("/silences", alertHandler.GetSilences)
+			alerts.POST("/silences", audit(services.AuditActionAlertSilenceCreate, services.AuditTargetAlertSilence, ""), alertHandler.CreateSilence)
+			alerts.DELETE("/silences/:id", audit(services.AuditActionAlertSilenceDelete, services.AuditTargetAlertSilence, "id"), alertHandler.DeleteSilence)
+		}
+
 		// 服务器管理路由（需要认证）
 		server := api.Group("/server")
 		server.Use(middleware.AuthMiddleware())
 		{
-			server.POST("/create", serverHandler.CreateServer)
-			server.GET("/list", serverHandler.GetServers)
-			server.GET("/:id", serverHandler.GetServer)
-			server.PUT("/:id", serverHandler.UpdateServer)
-			server.DELETE("/:id", serverHandler.DeleteServer)
-			server.POST("/:id/test", serverHandler.TestConnection)
-			server.POST("/:id/sync-devices", serverHandler.SyncDevices)
-			server.POST("/:id/sync-libraries", serverHandler.SyncLibraries)
+			server.POST("/create", middleware.RequirePermission(services.PermServerCreate), serverHandler.CreateServer)
+			server.GET("/list", viewerScope, serverHandler.GetServers)
+			server.GET("/:id", viewer(serverParam), serverHandler.GetServer)
+			server.PUT("/:id", owner(serverParam), serverHandler.UpdateServer)
+			server.DELETE("/:id", owner(serverParam), serverHandler.DeleteServer)
+			server.POST("/:id/test", operator(serverParam), serverHandler.TestConnection)
+			server.GET("/:id/connection-logs", viewer(serverParam), serverHandler.GetConnectionLogs)
+			server.GET("/:id/connection-stats", viewer(serverParam), serverHandler.GetConnectionStats)
+			server.GET("/:id/metrics", viewer(serverParam), serverHandler.GetServerMetrics)
+			server.POST("/:id/sync-devices", mediaSync, operator(serverParam), audit(services.AuditActionServerSyncDevices, services.AuditTargetServer, "id"), serverHandler.SyncDevices)
+			server.POST("/:id/sync-libraries", mediaSync, operator(serverParam), audit(services.AuditActionServerSyncLibrary, services.AuditTargetServer, "id"), serverHandler.SyncLibraries)
+
+			// 服务器共享（需要服务器所有者权限）
+			server.GET("/:id/shares", owner(serverParam), serverHandler.GetServerShares)
+			server.POST("/:id/share", owner(serverParam), serverHandler.ShareServer)
+			server.DELETE("/:id/share/:user_id", owner(serverParam), serverHandler.UnshareServer)
 		}
 
 		// WebSocket路由（需要认证）
 		ws := api.Group("/ws")
 		ws.Use(middleware.AuthMiddleware())
 		{
-			ws.GET("/status", wsHandler.GetConnectionStatus)
-			ws.GET("/server/:id", wsHandler.GetServerConnection)
-			ws.POST("/server/:id/reconnect", wsHandler.ReconnectServer)
+			ws.GET("/status", viewerScope, wsHandler.GetConnectionStatus)
+			ws.GET("/server/:id", viewer(serverParam), wsHandler.GetServerConnection)
+			ws.POST("/server/:id/reconnect", operator(serverParam), audit(services.AuditActionServerReconnect, services.AuditTargetServer, "id"), wsHandler.ReconnectServer)
 		}
 
 		// 媒体库路由（需要认证）
 		media := api.Group("/media")
 		media.Use(middleware.AuthMiddleware())
 		{
-			media.GET("/libraries", mediaHandler.GetMediaLibraries)
-			media.GET("/libraries/:id", mediaHandler.GetMediaLibrary)
-			media.POST("/sync/:id", mediaHandler.SyncMediaLibraries)
-			media.POST("/sync-all", mediaHandler.SyncAllServers)
-			media.POST("/libraries/:id/refresh", mediaHandler.RefreshMediaLibrary)
-			media.GET("/stats", mediaHandler.GetMediaLibraryStats)
-			media.GET("/items", mediaHandler.GetMediaItems)
-			media.GET("/items/:id", mediaHandler.GetMediaItem)
+			media.GET("/libraries", viewer(middleware.ServerQuery("server_id")), mediaHandler.GetMediaLibraries)
+			media.GET("/libraries/:id", viewer(middleware.LibraryParam("id")), mediaHandler.GetMediaLibrary)
+			media.POST("/sync/:id", mediaSync, operator(serverParam), audit(services.AuditActionMediaSync, services.AuditTargetServer, "id"), mediaHandler.SyncMediaLibraries)
+			media.POST("/sync-all", mediaSync, audit(services.AuditActionMediaSyncAll, services.AuditTargetServer, ""), mediaHandler.SyncAllServers)
+			media.POST("/libraries/:id/refresh", mediaSync, operator(middleware.LibraryParam("id")), audit(services.AuditActionMediaRefresh, services.AuditTargetLibrary, "id"), mediaHandler.RefreshMediaLibrary)
+			media.GET("/stats", viewerScope, mediaHandler.GetMediaLibraryStats)
+			media.GET("/items", viewer(middleware.LibraryQuery("library_id")), mediaHandler.GetMediaItems)
+			media.GET("/items/:id", viewer(middleware.MediaItemParam("id")), mediaHandler.GetMediaItem)
+		}
+
+		// 同步任务路由（需要认证）
+		syncJobs := api.Group("/jobs")
+		syncJobs.Use(middleware.AuthMiddleware(), middleware.RequireTokenScope(services.PermMediaSync, services.PermJobsManage))
+		{
+			syncJobs.GET("", jobHandler.GetJobs)
+			syncJobs.GET("/:id", jobHandler.GetJob)
+			syncJobs.POST("/:id/cancel", audit(services.AuditActionJobCancel, services.AuditTargetSyncJob, "id"), jobHandler.CancelJob)
 		}
 
 		// 搜索路由（需要认证）
 		search := api.Group("/search")
-		search.Use(middleware.AuthMiddleware())
+		search.Use(middleware.AuthMiddleware(), viewerScope)
 		{
 			search.GET("", searchHandler.SearchMedia)                    // /api/search
 			search.GET("/suggestions", searchHandler.GetSearchSuggestions)  // /api/search/suggestions
@@ -106,12 +258,26 @@ func SetupRoutes(r *gin.Engine, hub *websocket.Hub, wsManager *websocket.Manager
 		playback.Use(middleware.AuthMiddleware())
 		{
 			playbackHandler := NewPlaybackHandler()
-			playback.POST("/:server_id/:device_id/command", playbackHandler.SendPlayCommand)
-			playback.GET("/sessions", playbackHandler.GetActiveSessions)
-			playback.GET("/history", playbackHandler.GetPlaybackHistory)
+			playback.POST("/:server_id/:device_id/command",
+				middleware.RequirePermission(services.PermPlaybackControl),
+				operator(middleware.ServerParam("server_id")),
+				audit(services.AuditActionPlaybackCommand, services.AuditTargetDevice, "device_id"),
+				playbackHandler.SendPlayCommand)
+			playback.GET("/sessions", viewer(middleware.ServerQuery("server_id")), playbackHandler.GetActiveSessions)
+			playback.GET("/history", viewerScope, playbackHandler.GetPlaybackHistory)
+		}
+
+		// 定时任务管理路由（需要scheduler.manage权限）
+		jobs := api.Group("/scheduler")
+		jobs.Use(middleware.AuthMiddleware(), middleware.RequirePermission(services.PermSchedulerManage))
+		{
+			jobs.GET("/jobs", schedulerHandler.GetJobs)
+			jobs.POST("/jobs/:id/pause", audit(services.AuditActionSchedulerPause, services.AuditTargetScheduledJob, "id"), schedulerHandler.PauseJob)
+			jobs.POST("/jobs/:id/resume", audit(services.AuditActionSchedulerResume, services.AuditTargetScheduledJob, "id"), schedulerHandler.ResumeJob)
+			jobs.POST("/jobs/:id/trigger", audit(services.AuditActionSchedulerTrigger, services.AuditTargetScheduledJob, "id"), schedulerHandler.TriggerJob)
 		}
 	}
 
 	// WebSocket连接端点（需要认证）
-	r.GET("/ws", middleware.AuthMiddleware(), wsHandler.HandleWebSocket)
+	r.GET("/ws", middleware.AuthMiddleware(), viewerScope, wsHandler.HandleWebSocket)
 }
\ No newline at end of file
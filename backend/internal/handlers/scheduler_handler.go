@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/emby-client-go/backend/internal/dto"
+	"github.com/emby-client-go/backend/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// SchedulerHandler 定时任务处理器
+type SchedulerHandler struct {
+	scheduler *scheduler.Scheduler
+}
+
+// NewSchedulerHandler 创建定时任务处理器
+func NewSchedulerHandler(sched *scheduler.Scheduler) *SchedulerHandler {
+	return &SchedulerHandler{
+		scheduler: sched,
+	}
+}
+
+// GetJobs 获取定时任务列表
+// @Summary 获取定时任务列表
+// @Description 获取所有服务器的后台定时任务及其上次/下次运行时间（需要scheduler.manage权限）
+// @Tags 定时任务
+// @Produce json
+// @Security ApiKeyAuth
+// @Param server_id query int false "服务器ID（可选）"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 500 {object} dto.ApiResponse
+// @Router /scheduler/jobs [get]
+func (h *SchedulerHandler) GetJobs(c *gin.Context) {
+	serverID, _ := strconv.ParseUint(c.DefaultQuery("server_id", "0"), 10, 32)
+
+	jobs, err := h.scheduler.ListJobs(uint(serverID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data:    jobs,
+	})
+}
+
+// PauseJob 暂停定时任务
+// @Summary 暂停定时任务
+// @Tags 定时任务
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "任务ID"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Router /scheduler/jobs/{id}/pause [post]
+func (h *SchedulerHandler) PauseJob(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.scheduler.PauseJob(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "任务已暂停",
+	})
+}
+
+// ResumeJob 恢复定时任务
+// @Summary 恢复定时任务
+// @Tags 定时任务
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "任务ID"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Router /scheduler/jobs/{id}/resume [post]
+func (h *SchedulerHandler) ResumeJob(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.scheduler.ResumeJob(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "任务已恢复",
+	})
+}
+
+// TriggerJob 立即执行定时任务
+// @Summary 立即执行定时任务
+// @Tags 定时任务
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "任务ID"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 409 {object} dto.ApiResponse
+// @Router /scheduler/jobs/{id}/trigger [post]
+func (h *SchedulerHandler) TriggerJob(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.scheduler.TriggerJob(uint(id)); err != nil {
+		c.JSON(http.StatusConflict, dto.ApiResponse{
+			Code:    409,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "任务已触发",
+	})
+}
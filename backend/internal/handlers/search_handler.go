@@ -6,7 +6,6 @@ import (
 	"strings"
 
 	"github.com/emby-client-go/backend/internal/services"
-	"github.com/emby-client-go/backend/internal/models"
 	"github.com/gin-gonic/gin"
 )
 
@@ -115,6 +114,13 @@ func (h *SearchHandler) SearchMedia(c *gin.Context) {
 		req.Offset = offset
 	}
 
+	// 限制在当前用户可访问的服务器内
+	allowed, ok := accessibleServerIDs(c, services.ServerRoleViewer)
+	if !ok {
+		return
+	}
+	req.AllowedServerIDs = allowed
+
 	// 执行搜索
 	result, err := h.searchService.Search(c.Request.Context(), req)
 	if err != nil {
@@ -156,7 +162,12 @@ func (h *SearchHandler) GetSearchSuggestions(c *gin.Context) {
 		}
 	}
 
-	suggestions, err := h.searchService.GetSuggestions(c.Request.Context(), query, limit)
+	allowed, ok := accessibleServerIDs(c, services.ServerRoleViewer)
+	if !ok {
+		return
+	}
+
+	suggestions, err := h.searchService.GetSuggestions(c.Request.Context(), query, limit, allowed)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "获取搜索建议失败: " + err.Error(),
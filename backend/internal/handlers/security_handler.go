@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/emby-client-go/backend/internal/config"
+
+	"github.com/emby-client-go/backend/internal/dto"
+	"github.com/emby-client-go/backend/internal/middleware"
+	"github.com/emby-client-go/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SecurityHandler 系统安全策略处理器
+type SecurityHandler struct {
+	twoFactorService *services.TwoFactorService
+	policyService    *services.SecurityPolicyService
+}
+
+// NewSecurityHandler 创建系统安全策略处理器
+func NewSecurityHandler() *SecurityHandler {
+	return &SecurityHandler{
+		twoFactorService: services.NewTwoFactorService(),
+		policyService:    services.NewSecurityPolicyService(),
+	}
+}
+
+// GetSecurityPolicy 获取安全策略
+// @Summary 获取安全策略
+// @Description 获取系统安全策略（需要system.manage权限）
+// @Tags 系统管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} dto.ApiResponse{data=dto.SecurityPolicyResponse}
+// @Failure 403 {object} dto.ApiResponse
+// @Router /system/security-policy [get]
+func (h *SecurityHandler) GetSecurityPolicy(c *gin.Context) {
+	h.respondPolicy(c, "获取成功")
+}
+
+// UpdateSecurityPolicy 更新安全策略
+// @Summary 更新安全策略
+// @Description 更新系统安全策略，只更新传入的项。开启两步验证要求后未绑定的用户下次登录时必须先完成绑定；密码规则只对之后设置的密码生效（需要system.manage权限）
+// @Tags 系统管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body dto.SecurityPolicyRequest true "安全策略"
+// @Success 200 {object} dto.ApiResponse{data=dto.SecurityPolicyResponse}
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /system/security-policy [put]
+func (h *SecurityHandler) UpdateSecurityPolicy(c *gin.Context) {
+	var req dto.SecurityPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	policy, err := h.policyService.GetPolicy()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+	applySecurityPolicy(policy, req)
+	if err := h.policyService.SavePolicy(policy); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.RequireTwoFactor != nil {
+		if err := h.twoFactorService.SetRequired(*req.RequireTwoFactor); err != nil {
+			c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+				Code:    500,
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+	middleware.SetAuditDetails(c, req)
+
+	h.respondPolicy(c, "更新成功")
+}
+
+// respondPolicy 返回当前安全策略
+func (h *SecurityHandler) respondPolicy(c *gin.Context, message string) {
+	requireTwoFactor, err := h.twoFactorService.IsRequired()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+	policy, err := h.policyService.GetPolicy()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: message,
+		Data: dto.SecurityPolicyResponse{
+			RequireTwoFactor:       requireTwoFactor,
+			PasswordPolicyResponse: toPasswordPolicyResponse(policy),
+			PasswordCheckBreached:  policy.PasswordCheckBreached,
+			BreachedListConfigured: config.AppConfig.Security.BreachedPasswordFile != "",
+			PasswordHistory:        policy.PasswordHistory,
+			LockoutThreshold:       policy.LockoutThreshold,
+			LockoutMinutes:         policy.LockoutMinutes,
+			LockoutMaxMinutes:      policy.LockoutMaxMinutes,
+			IPMaxFailures:          policy.IPMaxFailures,
+			IPWindowMinutes:        policy.IPWindowMinutes,
+		},
+	})
+}
+
+// applySecurityPolicy 把请求中传入的项合并到当前策略
+func applySecurityPolicy(policy *services.SecurityPolicy, req dto.SecurityPolicyRequest) {
+	if req.PasswordMinLength != nil {
+		policy.PasswordMinLength = *req.PasswordMinLength
+	}
+	if req.PasswordRequireUpper != nil {
+		policy.PasswordRequireUpper = *req.PasswordRequireUpper
+	}
+	if req.PasswordRequireLower != nil {
+		policy.PasswordRequireLower = *req.PasswordRequireLower
+	}
+	if req.PasswordRequireDigit != nil {
+		policy.PasswordRequireDigit = *req.PasswordRequireDigit
+	}
+	if req.PasswordRequireSymbol != nil {
+		policy.PasswordRequireSymbol = *req.PasswordRequireSymbol
+	}
+	if req.PasswordCheckBreached != nil {
+		policy.PasswordCheckBreached = *req.PasswordCheckBreached
+	}
+	if req.PasswordHistory != nil {
+		policy.PasswordHistory = *req.PasswordHistory
+	}
+	if req.LockoutThreshold != nil {
+		policy.LockoutThreshold = *req.LockoutThreshold
+	}
+	if req.LockoutMinutes != nil {
+		policy.LockoutMinutes = *req.LockoutMinutes
+	}
+	if req.LockoutMaxMinutes != nil {
+		policy.LockoutMaxMinutes = *req.LockoutMaxMinutes
+	}
+	if req.IPMaxFailures != nil {
+		policy.IPMaxFailures = *req.IPMaxFailures
+	}
+	if req.IPWindowMinutes != nil {
+		policy.IPWindowMinutes = *req.IPWindowMinutes
+	}
+}
+
+// toPasswordPolicyResponse 转换为密码规则响应
+func toPasswordPolicyResponse(policy *services.SecurityPolicy) dto.PasswordPolicyResponse {
+	return dto.PasswordPolicyResponse{
+		MinLength:     policy.PasswordMinLength,
+		RequireUpper:  policy.PasswordRequireUpper,
+		RequireLower:  policy.PasswordRequireLower,
+		RequireDigit:  policy.PasswordRequireDigit,
+		RequireSymbol: policy.PasswordRequireSymbol,
+	}
+}
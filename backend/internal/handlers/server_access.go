@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/emby-client-go/backend/internal/middleware"
+	"github.com/emby-client-go/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// serverAccess 服务器访问控制服务
+var serverAccess = services.NewServerAccessService()
+
+// hasPermission 当前请求是否拥有指定权限，查询失败时视为没有权限
+func hasPermission(c *gin.Context, permission string) bool {
+	allowed, err := middleware.HasPermission(c, permission)
+	return err == nil && allowed
+}
+
+// canManageAllServers 当前用户是否可以管理所有服务器
+func canManageAllServers(c *gin.Context) bool {
+	return hasPermission(c, services.PermServerManage)
+}
+
+// accessibleServerIDs 获取当前用户至少具有指定角色的服务器ID，可管理所有服务器时返回nil表示不限制
+// 查询失败时写入错误响应并返回false
+func accessibleServerIDs(c *gin.Context, required string) ([]uint, bool) {
+	serverIDs, err := serverAccess.AccessibleServerIDs(c.GetUint("user_id"), canManageAllServers(c), required)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return serverIDs, true
+}
@@ -1,22 +1,32 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/emby-client-go/backend/internal/dto"
+	"github.com/emby-client-go/backend/internal/middleware"
 	"github.com/emby-client-go/backend/internal/models"
 	"github.com/emby-client-go/backend/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
 type ServerHandler struct {
-	serverService *services.ServerService
+	serverService  *services.ServerService
+	accessService  *services.ServerAccessService
+	metricsService *services.ServerMetricsService
 }
 
 func NewServerHandler() *ServerHandler {
 	return &ServerHandler{
-		serverService: services.NewServerService(),
+		serverService:  services.NewServerService(),
+		accessService:  services.NewServerAccessService(),
+		metricsService: services.NewServerMetricsService(),
 	}
 }
 
@@ -41,16 +51,33 @@ func (h *ServerHandler) CreateServer(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("user_id")
+	if req.APIKey == "" && (req.Username == "" || req.Password == "") {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "请提供API密钥或Emby用户名和密码",
+		})
+		return
+	}
 
 	server := &models.EmbyServer{
 		Name:        req.Name,
 		URL:         req.URL,
-		APIKey:      req.APIKey,
+		APIKey:      models.EncryptedString(req.APIKey),
+		AuthType:    services.AuthTypeAPIKey,
 		Description: req.Description,
+		Timeout:     positiveOrNil(req.Timeout),
+		MaxRetries:  req.MaxRetries,
+		CacheTTL:    positiveOrNil(req.CacheTTL),
+		AuthMode:    req.AuthMode,
+	}
+
+	if req.APIKey == "" {
+		server.AuthType = services.AuthTypePassword
+		server.EmbyUsername = req.Username
+		server.EmbyPassword = models.EncryptedString(req.Password)
 	}
 
-	if err := h.serverService.CreateServer(server, userID.(uint)); err != nil {
+	if err := h.serverService.CreateServer(middleware.AuditActor(c), server); err != nil {
 		c.JSON(http.StatusBadRequest, dto.ApiResponse{
 			Code:    400,
 			Message: err.Error(),
@@ -58,21 +85,7 @@ func (h *ServerHandler) CreateServer(c *gin.Context) {
 		return
 	}
 
-	response := dto.ServerResponse{
-		ID:          server.ID,
-		Name:        server.Name,
-		URL:         server.URL,
-		Version:     server.Version,
-		OS:          server.OS,
-		Status:      server.Status,
-		Description: server.Description,
-		CreatedAt:   server.CreatedAt.Format("2006-01-02 15:04:05"),
-		UpdatedAt:   server.UpdatedAt.Format("2006-01-02 15:04:05"),
-	}
-
-	if server.LastCheck != nil {
-		response.LastCheck = server.LastCheck.Format("2006-01-02 15:04:05")
-	}
+	response := newServerResponse(server)
 
 	c.JSON(http.StatusOK, dto.ApiResponse{
 		Code:    200,
@@ -83,7 +96,7 @@ func (h *ServerHandler) CreateServer(c *gin.Context) {
 
 // GetServers 获取服务器列表
 // @Summary 获取服务器列表
-// @Description 获取当前用户的Emby服务器列表
+// @Description 获取当前用户可访问的Emby服务器列表，拥有server.manage权限时返回所有服务器
 // @Tags 服务器管理
 // @Produce json
 // @Security ApiKeyAuth
@@ -97,7 +110,7 @@ func (h *ServerHandler) GetServers(c *gin.Context) {
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
 	userID, _ := c.Get("user_id")
 
-	servers, total, err := h.serverService.GetServers(userID.(uint), page, pageSize)
+	servers, total, err := h.serverService.GetServers(userID.(uint), canManageAllServers(c), page, pageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
 			Code:    500,
@@ -108,21 +121,7 @@ func (h *ServerHandler) GetServers(c *gin.Context) {
 
 	var serverResponses []dto.ServerResponse
 	for _, server := range servers {
-		response := dto.ServerResponse{
-			ID:          server.ID,
-			Name:        server.Name,
-			URL:         server.URL,
-			Version:     server.Version,
-			OS:          server.OS,
-			Status:      server.Status,
-			Description: server.Description,
-			CreatedAt:   server.CreatedAt.Format("2006-01-02 15:04:05"),
-			UpdatedAt:   server.UpdatedAt.Format("2006-01-02 15:04:05"),
-		}
-
-		if server.LastCheck != nil {
-			response.LastCheck = server.LastCheck.Format("2006-01-02 15:04:05")
-		}
+		response := newServerResponse(&server)
 
 		serverResponses = append(serverResponses, response)
 	}
@@ -163,21 +162,7 @@ func (h *ServerHandler) GetServer(c *gin.Context) {
 		return
 	}
 
-	response := dto.ServerResponse{
-		ID:          server.ID,
-		Name:        server.Name,
-		URL:         server.URL,
-		Version:     server.Version,
-		OS:          server.OS,
-		Status:      server.Status,
-		Description: server.Description,
-		CreatedAt:   server.CreatedAt.Format("2006-01-02 15:04:05"),
-		UpdatedAt:   server.UpdatedAt.Format("2006-01-02 15:04:05"),
-	}
-
-	if server.LastCheck != nil {
-		response.LastCheck = server.LastCheck.Format("2006-01-02 15:04:05")
-	}
+	response := newServerResponse(server)
 
 	c.JSON(http.StatusOK, dto.ApiResponse{
 		Code:    200,
@@ -218,13 +203,41 @@ func (h *ServerHandler) UpdateServer(c *gin.Context) {
 		updates["url"] = req.URL
 	}
 	if req.APIKey != "" {
-		updates["api_key"] = req.APIKey
+		// 切换为API密钥认证，清除登录信息
+		updates["api_key"] = models.EncryptedString(req.APIKey)
+		updates["auth_type"] = services.AuthTypeAPIKey
+		updates["emby_username"] = ""
+		updates["emby_password"] = models.EncryptedString("")
+		updates["emby_user_id"] = ""
+	} else if req.Password != "" {
+		updates["auth_type"] = services.AuthTypePassword
+		updates["emby_password"] = models.EncryptedString(req.Password)
+		if req.Username != "" {
+			updates["emby_username"] = req.Username
+		}
 	}
 	if req.Description != "" {
 		updates["description"] = req.Description
 	}
+	// 传0表示清除覆盖项，恢复使用全局配置；重试次数0表示不重试，传负数恢复使用全局配置
+	if req.Timeout != nil {
+		updates["timeout"] = positiveOrNil(req.Timeout)
+	}
+	if req.MaxRetries != nil {
+		updates["max_retries"] = nonNegativeOrNil(req.MaxRetries)
+	}
+	if req.CacheTTL != nil {
+		updates["cache_ttl"] = positiveOrNil(req.CacheTTL)
+	}
+	if req.AuthMode != nil {
+		authMode := *req.AuthMode
+		if authMode == "default" {
+			authMode = ""
+		}
+		updates["auth_mode"] = authMode
+	}
 
-	if err := h.serverService.UpdateServer(uint(id), updates); err != nil {
+	if err := h.serverService.UpdateServer(middleware.AuditActor(c), uint(id), updates); err != nil {
 		c.JSON(http.StatusBadRequest, dto.ApiResponse{
 			Code:    400,
 			Message: err.Error(),
@@ -251,7 +264,7 @@ func (h *ServerHandler) UpdateServer(c *gin.Context) {
 func (h *ServerHandler) DeleteServer(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 
-	if err := h.serverService.DeleteServer(uint(id)); err != nil {
+	if err := h.serverService.DeleteServer(middleware.AuditActor(c), uint(id)); err != nil {
 		c.JSON(http.StatusBadRequest, dto.ApiResponse{
 			Code:    400,
 			Message: "删除失败",
@@ -279,7 +292,7 @@ func (h *ServerHandler) TestConnection(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 	userID, _ := c.Get("user_id")
 
-	duration, err := h.serverService.TestConnection(uint(id), userID.(uint))
+	duration, err := h.serverService.TestConnection(c.Request.Context(), uint(id), userID.(uint))
 
 	response := dto.TestConnectionResponse{}
 
@@ -320,7 +333,7 @@ func (h *ServerHandler) TestConnection(c *gin.Context) {
 func (h *ServerHandler) SyncDevices(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 
-	if err := h.serverService.SyncDevices(uint(id)); err != nil {
+	if err := h.serverService.SyncDevices(c.Request.Context(), uint(id)); err != nil {
 		c.JSON(http.StatusBadRequest, dto.ApiResponse{
 			Code:    400,
 			Message: "同步设备失败: " + err.Error(),
@@ -360,3 +373,492 @@ func (h *ServerHandler) SyncLibraries(c *gin.Context) {
 		Message: "媒体库同步成功",
 	})
 }
+
+// GetServerShares 获取服务器共享用户
+// @Summary 获取服务器共享用户
+// @Description 获取可以访问指定服务器的用户及其角色，需要服务器所有者权限
+// @Tags 服务器管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Success 200 {object} dto.ApiResponse{data=[]dto.ServerShareResponse}
+// @Failure 403 {object} dto.ApiResponse
+// @Router /server/{id}/shares [get]
+func (h *ServerHandler) GetServerShares(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	members, err := h.accessService.GetMembers(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	shares := make([]dto.ServerShareResponse, 0, len(members))
+	for _, member := range members {
+		share := dto.ServerShareResponse{
+			UserID:    member.UserID,
+			Role:      member.Role,
+			CreatedAt: member.CreatedAt.Format("2006-01-02 15:04:05"),
+		}
+		if member.User != nil {
+			share.Username = member.User.Username
+			share.Nickname = member.User.Nickname
+		}
+		shares = append(shares, share)
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data:    shares,
+	})
+}
+
+// ShareServer 共享服务器
+// @Summary 共享服务器
+// @Description 将服务器共享给指定用户，用户已有访问权限时更新其角色，需要服务器所有者权限
+// @Tags 服务器管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param request body dto.ShareServerRequest true "共享信息"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /server/{id}/share [post]
+func (h *ServerHandler) ShareServer(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req dto.ShareServerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	member, err := h.accessService.ShareServer(middleware.AuditActor(c), uint(id), req.UserID, req.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "共享成功",
+		Data:    member,
+	})
+}
+
+// UnshareServer 取消共享服务器
+// @Summary 取消共享服务器
+// @Description 移除指定用户对服务器的访问权限，需要服务器所有者权限
+// @Tags 服务器管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param user_id path int true "用户ID"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /server/{id}/share/{user_id} [delete]
+func (h *ServerHandler) UnshareServer(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "无效的用户ID",
+		})
+		return
+	}
+
+	if err := h.accessService.UnshareServer(middleware.AuditActor(c), uint(id), uint(userID)); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "已取消共享",
+	})
+}
+
+// GetConnectionLogs 获取连接日志
+// @Summary 获取连接日志
+// @Description 分页获取服务器的连接测试、健康检查、WebSocket连接和重连记录，最新的在前
+// @Tags 服务器管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Param action query string false "动作" Enums(connect, test, health_check, reconnect, disconnect, error)
+// @Param status query string false "结果" Enums(success, failed)
+// @Param source query string false "来源" Enums(http, websocket)
+// @Param from query string false "开始时间（RFC3339，包含）"
+// @Param to query string false "结束时间（RFC3339，不包含）"
+// @Success 200 {object} dto.ApiResponse{data=dto.PageResponse{list=[]dto.ConnectionLogResponse}}
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /server/{id}/connection-logs [get]
+func (h *ServerHandler) GetConnectionLogs(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req dto.ConnectionLogListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+	from, to, err := parseTimeRange(req.From, req.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	query := services.ConnectionLogQuery{
+		Action: req.Action,
+		Status: req.Status,
+		Source: req.Source,
+		From:   from,
+		To:     to,
+	}
+	logs, total, err := h.serverService.GetConnectionLogs(uint(id), query, req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	responses := make([]dto.ConnectionLogResponse, 0, len(logs))
+	for _, l := range logs {
+		response := dto.ConnectionLogResponse{
+			ID:           l.ID,
+			Action:       l.Action,
+			Source:       l.Source,
+			Status:       l.Status,
+			Message:      l.Message,
+			ResponseTime: l.ResponseTime,
+			UserID:       l.UserID,
+			CreatedAt:    l.CreatedAt.Format("2006-01-02 15:04:05"),
+		}
+		if l.User != nil {
+			response.Username = l.User.Username
+		}
+		responses = append(responses, response)
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data: dto.PageResponse{
+			List:     responses,
+			Total:    total,
+			Page:     req.Page,
+			PageSize: req.PageSize,
+		},
+	})
+}
+
+// GetConnectionStats 获取连接统计
+// @Summary 获取连接统计
+// @Description 统计服务器在时间范围内的可用率和响应时间分布，可用率按创建、测试和健康检查的成功比例计算；未指定时间范围时统计最近24小时，时间范围最长31天
+// @Tags 服务器管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param from query string false "开始时间（RFC3339，包含）"
+// @Param to query string false "结束时间（RFC3339，不包含）"
+// @Success 200 {object} dto.ApiResponse{data=dto.ConnectionStatsResponse}
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /server/{id}/connection-stats [get]
+func (h *ServerHandler) GetConnectionStats(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req dto.ConnectionStatsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+	from, to, err := parseTimeRange(req.From, req.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	stats, err := h.serverService.GetConnectionStats(uint(id), from, to)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidTimeRange) || errors.Is(err, services.ErrTimeRangeTooLarge) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response := dto.ConnectionStatsResponse{
+		From:             stats.From.Format(time.RFC3339),
+		To:               stats.To.Format(time.RFC3339),
+		Checks:           stats.Checks,
+		SuccessfulChecks: stats.SuccessfulChecks,
+		Failures:         stats.Failures,
+		Disconnects:      stats.Disconnects,
+	}
+	if stats.UptimePercent != nil {
+		// 保留两位小数
+		uptime := math.Round(*stats.UptimePercent*100) / 100
+		response.UptimePercent = &uptime
+	}
+	if stats.Latency != nil {
+		response.Latency = &dto.LatencyStatsResponse{
+			Min: stats.Latency.Min,
+			Avg: stats.Latency.Avg,
+			P50: stats.Latency.P50,
+			P90: stats.Latency.P90,
+			P95: stats.Latency.P95,
+			P99: stats.Latency.P99,
+			Max: stats.Latency.Max,
+		}
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data:    response,
+	})
+}
+
+// 时间序列的步长限制
+const (
+	minMetricsStep   = time.Minute
+	maxMetricsPoints = 2000
+)
+
+// GetServerMetrics 获取服务器可用率和延迟时间序列
+// @Summary 获取服务器可用率和延迟时间序列
+// @Description 按步长汇总定时健康检查的结果，步长为整天或整小时时使用预先汇总的数据（按UTC对齐），开始时间按步长向下对齐；没有采样的时间段也会返回
+// @Tags 服务器管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param from query string false "开始时间（RFC3339），默认为结束时间前24小时"
+// @Param to query string false "结束时间（RFC3339），默认为当前时间"
+// @Param step query string false "步长，如 5m、1h、1d，默认24小时内为5m、7天内为1h、更长为1d"
+// @Success 200 {object} dto.ApiResponse{data=dto.ServerMetricsResponse}
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /server/{id}/metrics [get]
+func (h *ServerHandler) GetServerMetrics(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req dto.ServerMetricsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+	from, to, err := parseTimeRange(req.From, req.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-24 * time.Hour)
+	}
+
+	step, err := parseMetricsStep(req.Step, to.Sub(from))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	series, err := h.metricsService.GetSeries(uint(id), from, to, step)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidTimeRange) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response := dto.ServerMetricsResponse{
+		ServerID:   uint(id),
+		From:       series.From.Format(time.RFC3339),
+		To:         series.To.Format(time.RFC3339),
+		Step:       int64(series.Step / time.Second),
+		Resolution: series.Resolution,
+		Summary:    toMetricPointResponse(&series.Summary),
+		Points:     make([]dto.MetricPointResponse, 0, len(series.Points)),
+	}
+	for i := range series.Points {
+		response.Points = append(response.Points, toMetricPointResponse(&series.Points[i]))
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data:    response,
+	})
+}
+
+// parseMetricsStep 解析步长，支持Go时间间隔格式和以d结尾的天数；为空时按时间范围选择
+func parseMetricsStep(value string, span time.Duration) (time.Duration, error) {
+	var step time.Duration
+	switch {
+	case value == "":
+		switch {
+		case span <= 24*time.Hour:
+			step = 5 * time.Minute
+		case span <= 7*24*time.Hour:
+			step = time.Hour
+		default:
+			step = 24 * time.Hour
+		}
+	case strings.HasSuffix(value, "d"):
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("步长格式错误: %s", value)
+		}
+		step = time.Duration(days) * 24 * time.Hour
+	default:
+		var err error
+		if step, err = time.ParseDuration(value); err != nil {
+			return 0, fmt.Errorf("步长格式错误: %s", value)
+		}
+	}
+
+	if step < minMetricsStep {
+		return 0, fmt.Errorf("步长不能小于1分钟")
+	}
+	if span/step >= maxMetricsPoints {
+		return 0, fmt.Errorf("数据点过多，请增大步长或缩短时间范围（最多%d个）", maxMetricsPoints)
+	}
+	return step, nil
+}
+
+// toMetricPointResponse 转换为时间序列数据点响应
+func toMetricPointResponse(point *services.MetricPoint) dto.MetricPointResponse {
+	response := dto.MetricPointResponse{
+		Time:       point.Time.Format(time.RFC3339),
+		Samples:    point.Samples,
+		UpSamples:  point.UpSamples,
+		LatencyAvg: point.LatencyAvg(),
+	}
+	if uptime := point.UptimePercent(); uptime != nil {
+		rounded := math.Round(*uptime*100) / 100
+		response.UptimePercent = &rounded
+	}
+	if point.UpSamples > 0 {
+		latencyMin, latencyMax := point.LatencyMin, point.LatencyMax
+		response.LatencyMin = &latencyMin
+		response.LatencyMax = &latencyMax
+	}
+	return response
+}
+
+// parseTimeRange 解析RFC3339格式的开始和结束时间，为空时返回零值
+func parseTimeRange(fromValue, toValue string) (from, to time.Time, err error) {
+	if fromValue != "" {
+		if from, err = time.Parse(time.RFC3339, fromValue); err != nil {
+			return from, to, fmt.Errorf("开始时间格式错误，应为RFC3339格式，如 2006-01-02T15:04:05Z")
+		}
+	}
+	if toValue != "" {
+		if to, err = time.Parse(time.RFC3339, toValue); err != nil {
+			return from, to, fmt.Errorf("结束时间格式错误，应为RFC3339格式，如 2006-01-02T15:04:05Z")
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, services.ErrInvalidTimeRange
+	}
+	return from, to, nil
+}
+
+// newServerResponse 构造服务器响应
+func newServerResponse(server *models.EmbyServer) dto.ServerResponse {
+	response := dto.ServerResponse{
+		ID:                server.ID,
+		Name:              server.Name,
+		URL:               server.URL,
+		Version:           server.Version,
+		OS:                server.OS,
+		Status:            server.Status,
+		Description:       server.Description,
+		APIKeyFingerprint: server.APIKey.String(),
+		AuthType:          server.AuthType,
+		Username:          server.EmbyUsername,
+		EmbyUserID:        server.EmbyUserID,
+		Timeout:           server.Timeout,
+		MaxRetries:        server.MaxRetries,
+		CacheTTL:          server.CacheTTL,
+		AuthMode:          server.AuthMode,
+		CreatedAt:         server.CreatedAt.Format("2006-01-02 15:04:05"),
+		UpdatedAt:         server.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+
+	if server.LastCheck != nil {
+		response.LastCheck = server.LastCheck.Format("2006-01-02 15:04:05")
+	}
+
+	return response
+}
+
+// positiveOrNil 将非正数的覆盖项转换为nil
+func positiveOrNil(v *int) *int {
+	if v == nil || *v <= 0 {
+		return nil
+	}
+	return v
+}
+
+// nonNegativeOrNil 将负数的覆盖项转换为nil
+func nonNegativeOrNil(v *int) *int {
+	if v == nil || *v < 0 {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/emby-client-go/backend/internal/dto"
+	"github.com/emby-client-go/backend/internal/middleware"
+	"github.com/emby-client-go/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorHandler 两步验证处理器
+type TwoFactorHandler struct {
+	twoFactorService *services.TwoFactorService
+	sessionService   *services.SessionService
+	userService      *services.UserService
+}
+
+// NewTwoFactorHandler 创建两步验证处理器
+func NewTwoFactorHandler() *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: services.NewTwoFactorService(),
+		sessionService:   services.NewSessionService(),
+		userService:      services.NewUserService(),
+	}
+}
+
+// VerifyLogin 登录第二步
+// @Summary 提交两步验证码完成登录
+// @Description 使用登录返回的two_factor_token和TOTP验证码（或恢复码）完成登录
+// @Tags 用户认证
+// @Accept json
+// @Produce json
+// @Param request body dto.TwoFactorLoginRequest true "验证码"
+// @Success 200 {object} dto.ApiResponse{data=dto.LoginResponse}
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 401 {object} dto.ApiResponse
+// @Failure 429 {object} dto.ApiResponse "同一IP登录失败次数过多"
+// @Router /auth/2fa/verify [post]
+func (h *TwoFactorHandler) VerifyLogin(c *gin.Context) {
+	var req dto.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	claims, ok := h.parseInterimToken(c, req.TwoFactorToken, middleware.TwoFactorPurposeVerify)
+	if !ok {
+		return
+	}
+
+	user, err := h.twoFactorService.VerifyLogin(claims.UserID, req.Code, c.ClientIP())
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrLoginThrottled) {
+			status = http.StatusTooManyRequests
+		}
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.sessionService.RevokeAccessToken(c.Request.Context(), claims.ID, claims.ExpiresAt.Time)
+	issueLogin(c, h.sessionService, user, claims.DeviceName, nil)
+}
+
+// SetupLogin 登录时生成两步验证密钥
+// @Summary 登录时绑定两步验证
+// @Description 系统要求两步验证但用户尚未绑定时，使用登录返回的two_factor_token生成密钥
+// @Tags 用户认证
+// @Accept json
+// @Produce json
+// @Param request body dto.TwoFactorSetupLoginRequest true "临时令牌"
+// @Success 200 {object} dto.ApiResponse{data=dto.TwoFactorSetupResponse}
+// @Failure 401 {object} dto.ApiResponse
+// @Router /auth/2fa/setup [post]
+func (h *TwoFactorHandler) SetupLogin(c *gin.Context) {
+	var req dto.TwoFactorSetupLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	claims, ok := h.parseInterimToken(c, req.TwoFactorToken, middleware.TwoFactorPurposeSetup)
+	if !ok {
+		return
+	}
+
+	h.beginSetup(c, claims.UserID)
+}
+
+// EnableLogin 登录时确认绑定两步验证并完成登录
+// @Summary 登录时确认绑定两步验证
+// @Description 提交身份验证器中的验证码，启用两步验证并完成登录，响应中包含只显示一次的恢复码
+// @Tags 用户认证
+// @Accept json
+// @Produce json
+// @Param request body dto.TwoFactorEnableLoginRequest true "验证码"
+// @Success 200 {object} dto.ApiResponse{data=dto.LoginResponse}
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 401 {object} dto.ApiResponse
+// @Router /auth/2fa/enable [post]
+func (h *TwoFactorHandler) EnableLogin(c *gin.Context) {
+	var req dto.TwoFactorEnableLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	claims, ok := h.parseInterimToken(c, req.TwoFactorToken, middleware.TwoFactorPurposeSetup)
+	if !ok {
+		return
+	}
+
+	codes, err := h.twoFactorService.Enable(claims.UserID, req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	user, err := h.userService.GetUserByID(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.sessionService.RevokeAccessToken(c.Request.Context(), claims.ID, claims.ExpiresAt.Time)
+	issueLogin(c, h.sessionService, user, claims.DeviceName, codes)
+}
+
+// GetStatus 获取两步验证状态
+// @Summary 获取两步验证状态
+// @Description 获取当前用户是否启用两步验证、系统是否要求以及剩余恢复码数量
+// @Tags 两步验证
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} dto.ApiResponse{data=services.TwoFactorStatus}
+// @Router /user/2fa [get]
+func (h *TwoFactorHandler) GetStatus(c *gin.Context) {
+	status, err := h.twoFactorService.GetStatus(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data:    status,
+	})
+}
+
+// Setup 生成两步验证密钥
+// @Summary 生成两步验证密钥
+// @Description 生成新的TOTP密钥和otpauth URI（用于生成二维码），需调用启用接口确认后才生效
+// @Tags 两步验证
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} dto.ApiResponse{data=dto.TwoFactorSetupResponse}
+// @Failure 400 {object} dto.ApiResponse
+// @Router /user/2fa/setup [post]
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	h.beginSetup(c, c.GetUint("user_id"))
+}
+
+// Enable 启用两步验证
+// @Summary 启用两步验证
+// @Description 提交身份验证器中的验证码确认绑定，返回只显示一次的恢复码
+// @Tags 两步验证
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body dto.TwoFactorCodeRequest true "验证码"
+// @Success 200 {object} dto.ApiResponse{data=dto.RecoveryCodesResponse}
+// @Failure 400 {object} dto.ApiResponse
+// @Router /user/2fa/enable [post]
+func (h *TwoFactorHandler) Enable(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	codes, err := h.twoFactorService.Enable(c.GetUint("user_id"), req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "两步验证已启用，请妥善保存恢复码",
+		Data:    dto.RecoveryCodesResponse{RecoveryCodes: codes},
+	})
+}
+
+// Disable 关闭两步验证
+// @Summary 关闭两步验证
+// @Description 校验密码和验证码后关闭两步验证，系统要求两步验证时不能关闭
+// @Tags 两步验证
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body dto.TwoFactorDisableRequest true "密码和验证码"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Router /user/2fa/disable [post]
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	var req dto.TwoFactorDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.twoFactorService.Disable(c.GetUint("user_id"), req.Password, req.Code); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrTwoFactorRequired) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "两步验证已关闭",
+	})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+// @Summary 重新生成恢复码
+// @Description 校验验证码后重新生成恢复码，旧恢复码全部失效
+// @Tags 两步验证
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body dto.TwoFactorCodeRequest true "验证码"
+// @Success 200 {object} dto.ApiResponse{data=dto.RecoveryCodesResponse}
+// @Failure 400 {object} dto.ApiResponse
+// @Router /user/2fa/recovery-codes [post]
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(c.GetUint("user_id"), req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "恢复码已重新生成，请妥善保存",
+		Data:    dto.RecoveryCodesResponse{RecoveryCodes: codes},
+	})
+}
+
+// beginSetup 生成两步验证密钥并返回绑定信息
+func (h *TwoFactorHandler) beginSetup(c *gin.Context, userID uint) {
+	secret, uri, err := h.twoFactorService.BeginSetup(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "请使用身份验证器扫描二维码",
+		Data: dto.TwoFactorSetupResponse{
+			Secret:          secret,
+			ProvisioningURI: uri,
+		},
+	})
+}
+
+// parseInterimToken 解析两步验证临时令牌，已使用或无效时直接返回401
+func (h *TwoFactorHandler) parseInterimToken(c *gin.Context, token, purpose string) (*middleware.TwoFactorClaims, bool) {
+	claims, err := middleware.ParseTwoFactorToken(token, purpose)
+	if err == nil {
+		var revoked bool
+		revoked, err = h.sessionService.IsTokenRevoked(c.Request.Context(), claims.ID)
+		if err == nil && revoked {
+			err = services.ErrTokenRevoked
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, dto.ApiResponse{
+			Code:    401,
+			Message: "临时令牌无效或已过期，请重新登录",
+		})
+		return nil, false
+	}
+	return claims, true
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -13,18 +14,22 @@ import (
 
 // UserHandler 用户处理器
 type UserHandler struct {
-	userService *services.UserService
+	userService      *services.UserService
+	sessionService   *services.SessionService
+	twoFactorService *services.TwoFactorService
 }
 
 func NewUserHandler() *UserHandler {
 	return &UserHandler{
-		userService: services.NewUserService(),
+		userService:      services.NewUserService(),
+		sessionService:   services.NewSessionService(),
+		twoFactorService: services.NewTwoFactorService(),
 	}
 }
 
 // Register 用户注册
 // @Summary 用户注册
-// @Description 创建新用户账户
+// @Description 创建新用户账户，并向注册邮箱发送验证邮件
 // @Tags 用户认证
 // @Accept json
 // @Produce json
@@ -51,20 +56,10 @@ func (h *UserHandler) Register(c *gin.Context) {
 		return
 	}
 
-	userResponse := dto.UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Email:     user.Email,
-		Nickname:  user.Nickname,
-		Role:      user.Role,
-		Status:    user.Status,
-		CreatedAt: user.CreatedAt.Format("2006-01-02 15:04:05"),
-	}
-
 	c.JSON(http.StatusOK, dto.ApiResponse{
 		Code:    200,
-		Message: "注册成功",
-		Data:    userResponse,
+		Message: "注册成功，验证邮件已发送到注册邮箱",
+		Data:    toUserResponse(user),
 	})
 }
 
@@ -75,8 +70,10 @@ func (h *UserHandler) Register(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param request body dto.LoginRequest true "登录信息"
-// @Success 200 {object} dto.ApiResponse{data=dto.LoginResponse}
+// @Success 200 {object} dto.ApiResponse{data=dto.LoginResponse} "需要两步验证时只返回two_factor_token"
 // @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse "邮箱尚未验证"
+// @Failure 429 {object} dto.ApiResponse "同一IP登录失败次数过多"
 // @Router /auth/login [post]
 func (h *UserHandler) Login(c *gin.Context) {
 	var req dto.LoginRequest
@@ -88,17 +85,77 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.Login(req)
+	user, err := h.userService.Login(req, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, dto.ApiResponse{
-			Code:    400,
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrEmailNotVerified) {
+			status = http.StatusForbidden
+		} else if errors.Is(err, services.ErrLoginThrottled) {
+			status = http.StatusTooManyRequests
+		}
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	continueLogin(c, h.twoFactorService, h.sessionService, user, req.DeviceName)
+}
+
+// continueLogin 身份验证通过后继续登录：需要两步验证时返回临时令牌，否则直接签发令牌
+func continueLogin(c *gin.Context, twoFactorService *services.TwoFactorService, sessionService *services.SessionService, user *models.User, deviceName string) {
+	// 已启用两步验证或系统要求两步验证时，先返回临时令牌
+	required, err := twoFactorService.IsRequired()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+	if user.TOTPEnabled || required {
+		purpose := middleware.TwoFactorPurposeVerify
+		if !user.TOTPEnabled {
+			purpose = middleware.TwoFactorPurposeSetup
+		}
+
+		twoFactorToken, err := middleware.GenerateTwoFactorToken(*user, purpose, deviceName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+				Code:    500,
+				Message: "生成token失败",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, dto.ApiResponse{
+			Code:    200,
+			Message: "请完成两步验证",
+			Data: dto.LoginResponse{
+				TwoFactorRequired:      true,
+				TwoFactorSetupRequired: purpose == middleware.TwoFactorPurposeSetup,
+				TwoFactorToken:         twoFactorToken,
+			},
+		})
+		return
+	}
+
+	issueLogin(c, sessionService, user, deviceName, nil)
+}
+
+// issueLogin 创建登录会话并返回访问令牌和刷新令牌
+func issueLogin(c *gin.Context, sessionService *services.SessionService, user *models.User, deviceName string, recoveryCodes []string) {
+	session, refreshToken, err := sessionService.CreateSession(user.ID, sessionClient(c, deviceName))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
 			Message: err.Error(),
 		})
 		return
 	}
 
-	// 生成JWT token
-	token, err := middleware.GenerateToken(*user)
+	token, err := middleware.GenerateToken(*user, session.SID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
 			Code:    500,
@@ -113,19 +170,24 @@ func (h *UserHandler) Login(c *gin.Context) {
 	}
 
 	userResponse := dto.UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Email:     user.Email,
-		Nickname:  user.Nickname,
-		Role:      user.Role,
-		Status:    user.Status,
-		LastLogin: &lastLoginStr,
-		CreatedAt: user.CreatedAt.Format("2006-01-02 15:04:05"),
+		ID:               user.ID,
+		Username:         user.Username,
+		Email:            user.Email,
+		Nickname:         user.Nickname,
+		Role:             user.Role,
+		Status:           user.Status,
+		LastLogin:        &lastLoginStr,
+		CreatedAt:        user.CreatedAt.Format("2006-01-02 15:04:05"),
+		TwoFactorEnabled: user.TOTPEnabled,
+		AuthSource:       user.AuthSource,
 	}
 
 	loginResponse := dto.LoginResponse{
-		Token: token,
-		User:  userResponse,
+		Token:         token,
+		RefreshToken:  refreshToken,
+		ExpiresIn:     int(services.AccessTokenTTL().Seconds()),
+		User:          &userResponse,
+		RecoveryCodes: recoveryCodes,
 	}
 
 	c.JSON(http.StatusOK, dto.ApiResponse{
@@ -137,13 +199,14 @@ func (h *UserHandler) Login(c *gin.Context) {
 
 // RefreshToken 刷新令牌
 // @Summary 刷新令牌
-// @Description 使用旧令牌刷新获取新令牌
+// @Description 使用刷新令牌换取新的访问令牌和刷新令牌，旧的刷新令牌立即失效；重复使用已失效的刷新令牌会吊销整个会话
 // @Tags 用户认证
 // @Accept json
 // @Produce json
 // @Param request body dto.RefreshTokenRequest true "刷新令牌请求"
 // @Success 200 {object} dto.ApiResponse{data=dto.RefreshTokenResponse}
 // @Failure 400 {object} dto.ApiResponse
+// @Failure 401 {object} dto.ApiResponse
 // @Router /auth/refresh [post]
 func (h *UserHandler) RefreshToken(c *gin.Context) {
 	var req dto.RefreshTokenRequest
@@ -155,18 +218,63 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// 刷新令牌
-	newToken, err := middleware.RefreshToken(req.Token)
+	session, refreshToken, err := h.sessionService.RotateRefreshToken(c.Request.Context(), req.RefreshToken, sessionClient(c, ""))
 	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: "令牌刷新失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 验证用户是否仍然有效
+	user, err := h.userService.GetUserByID(session.UserID)
+	if err != nil || user.Status != "active" {
+		h.sessionService.RevokeSession(c.Request.Context(), session.UserID, session.ID)
 		c.JSON(http.StatusUnauthorized, dto.ApiResponse{
 			Code:    401,
-			Message: "令牌刷新失败: " + err.Error(),
+			Message: "令牌刷新失败: 用户不存在或已被禁用",
+		})
+		return
+	}
+
+	// 系统开启两步验证要求后，未绑定的用户需要重新登录完成绑定
+	if !user.TOTPEnabled {
+		required, err := h.twoFactorService.IsRequired()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+				Code:    500,
+				Message: err.Error(),
+			})
+			return
+		}
+		if required {
+			h.sessionService.RevokeSession(c.Request.Context(), session.UserID, session.ID)
+			c.JSON(http.StatusUnauthorized, dto.ApiResponse{
+				Code:    401,
+				Message: "系统要求启用两步验证，请重新登录",
+			})
+			return
+		}
+	}
+
+	token, err := middleware.GenerateToken(*user, session.SID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: "生成token失败",
 		})
 		return
 	}
 
 	response := dto.RefreshTokenResponse{
-		Token: newToken,
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(services.AccessTokenTTL().Seconds()),
 	}
 
 	c.JSON(http.StatusOK, dto.ApiResponse{
@@ -178,7 +286,7 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 
 // Logout 用户登出
 // @Summary 用户登出
-// @Description 用户登出（客户端需清除本地令牌）
+// @Description 吊销当前会话，会话的访问令牌和刷新令牌立即失效
 // @Tags 用户认证
 // @Produce json
 // @Security ApiKeyAuth
@@ -186,13 +294,23 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 // @Failure 401 {object} dto.ApiResponse
 // @Router /auth/logout [post]
 func (h *UserHandler) Logout(c *gin.Context) {
-	// JWT是无状态的，登出主要由客户端处理（删除本地存储的token）
-	// 这里可以记录登出日志或执行其他清理操作
-	userID, exists := c.Get("user_id")
-	if exists {
-		// 可以在这里记录登出日志
-		// 例如：记录到数据库或日志文件
-		_ = userID
+	ctx := c.Request.Context()
+
+	if err := h.sessionService.RevokeAccessToken(ctx, c.GetString("token_id"), c.GetTime("token_expires_at")); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	err := h.sessionService.RevokeSessionBySID(ctx, c.GetString("session_id"))
+	if err != nil && !errors.Is(err, services.ErrSessionNotFound) {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, dto.ApiResponse{
@@ -201,6 +319,117 @@ func (h *UserHandler) Logout(c *gin.Context) {
 	})
 }
 
+// LogoutAll 登出所有会话
+// @Summary 登出所有会话
+// @Description 吊销当前用户的所有会话，包括当前会话
+// @Tags 用户认证
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} dto.ApiResponse
+// @Failure 401 {object} dto.ApiResponse
+// @Router /auth/logout-all [post]
+func (h *UserHandler) LogoutAll(c *gin.Context) {
+	count, err := h.sessionService.RevokeAllSessions(c.Request.Context(), c.GetUint("user_id"), "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "已登出所有会话",
+		Data:    gin.H{"revoked": count},
+	})
+}
+
+// GetSessions 获取登录会话
+// @Summary 获取登录会话
+// @Description 获取当前用户所有有效的登录会话，包括设备和IP
+// @Tags 用户管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} dto.ApiResponse{data=[]dto.SessionResponse}
+// @Failure 401 {object} dto.ApiResponse
+// @Router /user/sessions [get]
+func (h *UserHandler) GetSessions(c *gin.Context) {
+	sessions, err := h.sessionService.ListSessions(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	currentSID := c.GetString("session_id")
+	responses := make([]dto.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responses = append(responses, dto.SessionResponse{
+			ID:         session.ID,
+			DeviceName: session.DeviceName,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt.Format("2006-01-02 15:04:05"),
+			LastUsedAt: session.LastUsedAt.Format("2006-01-02 15:04:05"),
+			ExpiresAt:  session.ExpiresAt.Format("2006-01-02 15:04:05"),
+			Current:    session.SID == currentSID,
+		})
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data:    responses,
+	})
+}
+
+// RevokeSession 吊销登录会话
+// @Summary 吊销登录会话
+// @Description 吊销当前用户的指定会话，该会话的设备需要重新登录
+// @Tags 用户管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "会话ID"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 404 {object} dto.ApiResponse
+// @Router /user/sessions/{id} [delete]
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	err := h.sessionService.RevokeSession(c.Request.Context(), c.GetUint("user_id"), uint(id))
+	if errors.Is(err, services.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, dto.ApiResponse{
+			Code:    404,
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "会话已吊销",
+	})
+}
+
+// sessionClient 从请求中提取会话的客户端信息
+func sessionClient(c *gin.Context, deviceName string) services.SessionClient {
+	return services.SessionClient{
+		DeviceName: deviceName,
+		UserAgent:  c.Request.UserAgent(),
+		IP:         c.ClientIP(),
+	}
+}
+
 // GetProfile 获取用户信息
 // @Summary 获取用户信息
 // @Description 获取当前登录用户的详细信息
@@ -221,21 +450,7 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 	}
 
 	userModel := user.(models.User)
-	var lastLoginStr string
-	if userModel.LastLogin != nil {
-		lastLoginStr = userModel.LastLogin.Format("2006-01-02 15:04:05")
-	}
-
-	userResponse := dto.UserResponse{
-		ID:        userModel.ID,
-		Username:  userModel.Username,
-		Email:     userModel.Email,
-		Nickname:  userModel.Nickname,
-		Role:      userModel.Role,
-		Status:    userModel.Status,
-		LastLogin: &lastLoginStr,
-		CreatedAt: userModel.CreatedAt.Format("2006-01-02 15:04:05"),
-	}
+	userResponse := toUserResponse(&userModel)
 
 	c.JSON(http.StatusOK, dto.ApiResponse{
 		Code:    200,
@@ -246,7 +461,7 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 
 // ChangePassword 修改密码
 // @Summary 修改密码
-// @Description 修改当前用户密码
+// @Description 修改当前用户密码，目录（LDAP）用户的密码只能在目录中修改
 // @Tags 用户管理
 // @Accept json
 // @Produce json
@@ -254,6 +469,7 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 // @Param request body dto.ChangePasswordRequest true "密码信息"
 // @Success 200 {object} dto.ApiResponse
 // @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse "目录用户不能修改密码"
 // @Router /user/change-password [post]
 func (h *UserHandler) ChangePassword(c *gin.Context) {
 	var req dto.ChangePasswordRequest
@@ -268,13 +484,26 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	err := h.userService.ChangePassword(userID.(uint), req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, dto.ApiResponse{
-			Code:    400,
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrDirectoryManagedUser) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
 			Message: err.Error(),
 		})
 		return
 	}
 
+	// 修改密码后其他设备需要重新登录
+	if _, err := h.sessionService.RevokeAllSessions(c.Request.Context(), userID.(uint), c.GetString("session_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ApiResponse{
+			Code:    500,
+			Message: "密码已修改，但吊销其他会话失败: " + err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, dto.ApiResponse{
 		Code:    200,
 		Message: "密码修改成功",
@@ -283,7 +512,7 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 
 // GetUsers 获取用户列表
 // @Summary 获取用户列表
-// @Description 获取用户列表（管理员权限）
+// @Description 获取用户列表（需要users.manage权限）
 // @Tags 用户管理
 // @Produce json
 // @Security ApiKeyAuth
@@ -308,22 +537,8 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 	}
 
 	var userResponses []dto.UserResponse
-	for _, user := range users {
-		var lastLoginStr string
-		if user.LastLogin != nil {
-			lastLoginStr = user.LastLogin.Format("2006-01-02 15:04:05")
-		}
-
-		userResponses = append(userResponses, dto.UserResponse{
-			ID:        user.ID,
-			Username:  user.Username,
-			Email:     user.Email,
-			Nickname:  user.Nickname,
-			Role:      user.Role,
-			Status:    user.Status,
-			LastLogin: &lastLoginStr,
-			CreatedAt: user.CreatedAt.Format("2006-01-02 15:04:05"),
-		})
+	for i := range users {
+		userResponses = append(userResponses, toUserResponse(&users[i]))
 	}
 
 	pageResponse := dto.PageResponse{
@@ -338,4 +553,390 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 		Message: "获取成功",
 		Data:    pageResponse,
 	})
-}
\ No newline at end of file
+}
+
+// SetAuthSource 设置用户认证来源
+// @Summary 设置用户认证来源
+// @Description 把用户标记为本地账户或目录（LDAP）用户，标记为目录用户时按用户名在目录中查找（需要users.manage权限）
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Param request body dto.SetAuthSourceRequest true "认证来源"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse "不能管理拥有更多权限的用户"
+// @Router /user/{id}/auth-source [put]
+func (h *UserHandler) SetAuthSource(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req dto.SetAuthSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.userService.SetAuthSource(middleware.AuditActor(c), uint(id), req); err != nil {
+		status := userErrorStatus(err)
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "认证来源设置成功",
+	})
+}
+
+// GetUser 获取用户详情
+// @Summary 获取用户详情
+// @Description 获取指定用户的详细信息（需要users.manage权限）
+// @Tags 用户管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Success 200 {object} dto.ApiResponse{data=dto.UserResponse}
+// @Failure 404 {object} dto.ApiResponse
+// @Router /user/{id} [get]
+func (h *UserHandler) GetUser(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	user, err := h.userService.GetUserByID(uint(id))
+	if err != nil {
+		status := userErrorStatus(err)
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "获取成功",
+		Data:    toUserResponse(user),
+	})
+}
+
+// CreateUser 创建用户
+// @Summary 创建用户
+// @Description 管理员创建本地用户，不指定角色时为普通用户（需要users.manage权限）
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body dto.CreateUserRequest true "用户信息"
+// @Success 200 {object} dto.ApiResponse{data=dto.UserResponse}
+// @Failure 400 {object} dto.ApiResponse
+// @Router /user/create [post]
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	var req dto.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := h.userService.CreateUser(middleware.AuditActor(c), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "用户创建成功",
+		Data:    toUserResponse(user),
+	})
+}
+
+// UpdateUser 编辑用户
+// @Summary 编辑用户
+// @Description 修改指定用户的用户名、邮箱或昵称，未提供的字段不修改（需要users.manage权限）
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Param request body dto.UpdateUserRequest true "用户信息"
+// @Success 200 {object} dto.ApiResponse{data=dto.UserResponse}
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse "不能管理拥有更多权限的用户"
+// @Failure 404 {object} dto.ApiResponse
+// @Router /user/{id} [put]
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req dto.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := h.userService.EditUser(middleware.AuditActor(c), uint(id), req)
+	if err != nil {
+		status := userErrorStatus(err)
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "用户更新成功",
+		Data:    toUserResponse(user),
+	})
+}
+
+// DisableUser 禁用用户
+// @Summary 禁用用户
+// @Description 禁用指定用户并吊销其全部会话，不能禁用自己或最后一个管理员（需要users.manage权限）
+// @Tags 用户管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse "不能管理拥有更多权限的用户"
+// @Failure 404 {object} dto.ApiResponse
+// @Router /user/{id}/disable [post]
+func (h *UserHandler) DisableUser(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.userService.DisableUser(c.Request.Context(), middleware.AuditActor(c), uint(id)); err != nil {
+		status := userErrorStatus(err)
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "用户已禁用",
+	})
+}
+
+// EnableUser 启用用户
+// @Summary 启用用户
+// @Description 启用指定用户，同时解除登录失败锁定（需要users.manage权限）
+// @Tags 用户管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 404 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse "不能管理拥有更多权限的用户"
+// @Router /user/{id}/enable [post]
+func (h *UserHandler) EnableUser(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.userService.EnableUser(middleware.AuditActor(c), uint(id)); err != nil {
+		status := userErrorStatus(err)
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "用户已启用",
+	})
+}
+
+// UnlockUser 解锁用户
+// @Summary 解锁用户
+// @Description 解除登录失败次数过多导致的锁定并清空失败次数（需要users.manage权限）
+// @Tags 用户管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse "不能管理拥有更多权限的用户"
+// @Failure 404 {object} dto.ApiResponse
+// @Router /user/{id}/unlock [post]
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.userService.UnlockUser(middleware.AuditActor(c), uint(id)); err != nil {
+		status := userErrorStatus(err)
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "用户已解锁",
+	})
+}
+
+// ResetPassword 重置用户密码
+// @Summary 重置用户密码
+// @Description 为本地用户设置新密码并吊销其全部会话，目录（LDAP）用户的密码只能在目录中修改（需要users.manage权限）
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Param request body dto.ResetPasswordRequest true "新密码"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse "目录用户不能重置密码"
+// @Failure 404 {object} dto.ApiResponse
+// @Router /user/{id}/reset-password [post]
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req dto.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.userService.ResetPassword(c.Request.Context(), middleware.AuditActor(c), uint(id), req.Password); err != nil {
+		status := userErrorStatus(err)
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "密码重置成功",
+	})
+}
+
+// ChangeRole 设置用户角色
+// @Summary 设置用户角色
+// @Description 修改指定用户的角色，不能授予超出自己权限的角色，admin角色只能由管理员授予（需要users.manage权限）
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Param request body dto.AssignRoleRequest true "角色"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse
+// @Failure 404 {object} dto.ApiResponse
+// @Router /user/{id}/role [put]
+func (h *UserHandler) ChangeRole(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	var req dto.AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ApiResponse{
+			Code:    400,
+			Message: "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.userService.ChangeRole(middleware.AuditActor(c), uint(id), req.Role); err != nil {
+		status := userErrorStatus(err)
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "角色设置成功",
+	})
+}
+
+// DeleteUser 删除用户
+// @Summary 删除用户
+// @Description 删除指定用户，同时吊销其会话并清理访问令牌、外部身份和服务器共享，不能删除自己或最后一个管理员（需要users.manage权限）
+// @Tags 用户管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Success 200 {object} dto.ApiResponse
+// @Failure 400 {object} dto.ApiResponse
+// @Failure 403 {object} dto.ApiResponse "不能管理拥有更多权限的用户"
+// @Failure 404 {object} dto.ApiResponse
+// @Router /user/{id} [delete]
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+
+	if err := h.userService.DeleteUser(c.Request.Context(), middleware.AuditActor(c), uint(id)); err != nil {
+		status := userErrorStatus(err)
+		c.JSON(status, dto.ApiResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ApiResponse{
+		Code:    200,
+		Message: "用户删除成功",
+	})
+}
+
+// userErrorStatus 用户管理错误对应的HTTP状态码
+func userErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrRoleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrDirectoryManagedUser), errors.Is(err, services.ErrPermissionNotHeld):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// toUserResponse 转换为用户响应
+func toUserResponse(user *models.User) dto.UserResponse {
+	var lastLoginStr string
+	if user.LastLogin != nil {
+		lastLoginStr = user.LastLogin.Format("2006-01-02 15:04:05")
+	}
+
+	return dto.UserResponse{
+		ID:               user.ID,
+		Username:         user.Username,
+		Email:            user.Email,
+		Nickname:         user.Nickname,
+		Role:             user.Role,
+		Status:           user.Status,
+		LastLogin:        &lastLoginStr,
+		CreatedAt:        user.CreatedAt.Format("2006-01-02 15:04:05"),
+		TwoFactorEnabled: user.TOTPEnabled,
+		AuthSource:       user.AuthSource,
+		EmailVerified:    user.EmailVerified,
+	}
+}
+
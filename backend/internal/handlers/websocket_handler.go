@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 
+	"github.com/emby-client-go/backend/internal/services"
 	"github.com/emby-client-go/backend/pkg/websocket"
 	"github.com/gin-gonic/gin"
 	gorilla "github.com/gorilla/websocket"
@@ -42,6 +44,7 @@ func NewWebSocketHandler(hub *websocket.Hub, manager *websocket.Manager) *WebSoc
 // @Success 101 {string} string "Switching Protocols"
 // @Failure 400 {object} map[string]interface{} "请求错误"
 // @Failure 401 {object} map[string]interface{} "未授权"
+// @Failure 404 {object} map[string]interface{} "服务器不存在"
 // @Failure 500 {object} map[string]interface{} "服务器错误"
 // @Router /ws [get]
 func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
@@ -58,8 +61,24 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
-	// 获取可选的服务器ID
+	// 获取可选的服务器ID，订阅服务器消息需要该服务器的查看权限
 	serverID := c.Query("server_id")
+	if serverID != "" {
+		id, err := strconv.ParseUint(serverID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+			return
+		}
+		err = serverAccess.CheckAccess(userID, canManageAllServers(c), uint(id), services.ServerRoleViewer)
+		if errors.Is(err, services.ErrServerNotFound) || errors.Is(err, services.ErrServerAccessDenied) {
+			c.JSON(http.StatusNotFound, gin.H{"error": services.ErrServerNotFound.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	// 升级HTTP连接为WebSocket
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -90,7 +109,7 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 
 // GetConnectionStatus 获取连接状态
 // @Summary 获取WebSocket连接状态
-// @Description 获取所有Emby服务器的WebSocket连接状态
+// @Description 获取当前用户可访问的Emby服务器的WebSocket连接状态，客户端统计仅对拥有server.manage权限的用户可见
 // @Tags WebSocket
 // @Security BearerAuth
 // @Success 200 {object} map[string]interface{} "连接状态"
@@ -98,11 +117,30 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 // @Router /api/ws/status [get]
 func (h *WebSocketHandler) GetConnectionStatus(c *gin.Context) {
 	status := h.manager.GetConnectionStatus()
-	clientInfo := h.hub.GetClientInfo()
+
+	if canManageAllServers(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"emby_connections": status,
+			"client_info":      h.hub.GetClientInfo(),
+		})
+		return
+	}
+
+	serverIDs, ok := accessibleServerIDs(c, services.ServerRoleViewer)
+	if !ok {
+		return
+	}
+
+	visible := make(map[string]string, len(serverIDs))
+	for _, id := range serverIDs {
+		key := strconv.FormatUint(uint64(id), 10)
+		if s, exists := status[key]; exists {
+			visible[key] = s
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"emby_connections": status,
-		"client_info":      clientInfo,
+		"emby_connections": visible,
 	})
 }
 
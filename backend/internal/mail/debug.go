@@ -0,0 +1,55 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// FileSender 把邮件写入目录，每封邮件一个.eml文件，用于开发和测试
+type FileSender struct {
+	from string
+	dir  string
+	seq  atomic.Uint64
+}
+
+// NewFileSender 创建文件发送驱动
+func NewFileSender(from, dir string) *FileSender {
+	return &FileSender{from: from, dir: dir}
+}
+
+// Send 写入邮件文件
+func (s *FileSender) Send(ctx context.Context, msg Message) error {
+	data, err := buildMessage(s.from, msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("创建邮件目录失败: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%d.eml", time.Now().Format("20060102-150405.000000"), s.seq.Add(1))
+	if err := os.WriteFile(filepath.Join(s.dir, name), data, 0o600); err != nil {
+		return fmt.Errorf("写入邮件文件失败: %w", err)
+	}
+	return nil
+}
+
+// LogSender 只把邮件打印到日志，不实际发送
+type LogSender struct{}
+
+// NewLogSender 创建日志发送驱动
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+// Send 打印邮件内容
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	log.Printf("邮件（未发送） 收件人: %s 主题: %s\n%s", strings.Join(msg.To, ", "), msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,102 @@
+// Package mail 邮件发送，支持SMTP以及写入文件、打印日志两种调试驱动
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/config"
+)
+
+// Message 一封纯文本邮件
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Sender 邮件发送驱动
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// defaultSender 全局发送驱动，未初始化时只打印日志
+var defaultSender Sender = NewLogSender()
+
+// Init 按配置初始化全局发送驱动
+func Init(cfg config.MailConfig) error {
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return fmt.Errorf("发件人地址无效: %w", err)
+	}
+
+	switch cfg.Driver {
+	case "smtp":
+		sender, err := NewSMTPSender(cfg)
+		if err != nil {
+			return err
+		}
+		defaultSender = sender
+	case "file":
+		defaultSender = NewFileSender(cfg.From, cfg.FileDir)
+	case "log", "":
+		defaultSender = NewLogSender()
+	default:
+		return fmt.Errorf("不支持的邮件驱动: %s", cfg.Driver)
+	}
+	return nil
+}
+
+// Send 使用全局发送驱动发送邮件
+func Send(ctx context.Context, msg Message) error {
+	return defaultSender.Send(ctx, msg)
+}
+
+// buildMessage 生成RFC 5322格式的邮件内容，正文使用quoted-printable编码
+func buildMessage(from string, msg Message) ([]byte, error) {
+	if len(msg.To) == 0 {
+		return nil, fmt.Errorf("缺少收件人")
+	}
+	for _, to := range msg.To {
+		if strings.ContainsAny(to, "\r\n") {
+			return nil, fmt.Errorf("收件人地址无效: %q", to)
+		}
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(addr.Address, "@"); at >= 0 {
+			domain = addr.Address[at+1:]
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+	if _, err := w.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
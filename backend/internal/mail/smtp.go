@@ -0,0 +1,119 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/config"
+)
+
+// SMTPSender 通过SMTP服务器发送邮件
+type SMTPSender struct {
+	from       string
+	envelope   string // MAIL FROM使用的纯邮箱地址
+	host       string
+	addr       string
+	username   string
+	password   string
+	encryption string
+	timeout    time.Duration
+}
+
+// NewSMTPSender 创建SMTP发送驱动
+func NewSMTPSender(cfg config.MailConfig) (*SMTPSender, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("发件人地址无效: %w", err)
+	}
+	switch cfg.SMTPEncryption {
+	case "none", "starttls", "tls":
+	default:
+		return nil, fmt.Errorf("不支持的SMTP加密方式: %s", cfg.SMTPEncryption)
+	}
+
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &SMTPSender{
+		from:       cfg.From,
+		envelope:   from.Address,
+		host:       cfg.SMTPHost,
+		addr:       net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		username:   cfg.SMTPUsername,
+		password:   cfg.SMTPPassword,
+		encryption: cfg.SMTPEncryption,
+		timeout:    timeout,
+	}, nil
+}
+
+// Send 发送邮件
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	data, err := buildMessage(s.from, msg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("连接SMTP服务器失败: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	tlsConfig := &tls.Config{ServerName: s.host}
+	if s.encryption == "tls" {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("连接SMTP服务器失败: %w", err)
+	}
+	defer client.Close()
+
+	if s.encryption == "starttls" {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("SMTP STARTTLS失败: %w", err)
+		}
+	}
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("SMTP认证失败: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.envelope); err != nil {
+		return fmt.Errorf("SMTP发件人被拒绝: %w", err)
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("SMTP收件人 %s 被拒绝: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	return client.Quit()
+}
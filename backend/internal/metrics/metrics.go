@@ -0,0 +1,183 @@
+// Package metrics Prometheus监控指标
+// 指标注册在独立的Registry中，由/metrics接口导出
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emby-client-go/backend/pkg/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "emby_manager"
+
+// Registry 导出的指标注册表
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP请求处理耗时，按路由模板统计",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	embyRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emby_requests_total",
+		Help:      "Emby API调用次数，重试不单独计数",
+	}, []string{"server_id", "method"})
+
+	embyRequestErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emby_request_errors_total",
+		Help:      "重试后仍然失败的Emby API调用次数",
+	}, []string{"server_id", "method"})
+
+	embyRequestRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emby_request_retries_total",
+		Help:      "Emby API调用的重试次数",
+	}, []string{"server_id"})
+
+	embyRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "emby_request_duration_seconds",
+		Help:      "Emby API调用耗时，包含重试",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"server_id"})
+
+	syncJobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sync_job_duration_seconds",
+		Help:      "媒体库同步任务耗时",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+	}, []string{"scope", "status"})
+
+	scheduledJobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scheduled_job_duration_seconds",
+		Help:      "后台定时任务耗时",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 15, 30, 60, 300, 600, 1800},
+	}, []string{"job_type", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestDuration,
+		embyRequestsTotal,
+		embyRequestErrorsTotal,
+		embyRequestRetriesTotal,
+		embyRequestDuration,
+		syncJobDuration,
+		scheduledJobDuration,
+	)
+}
+
+// Handler 导出指标的HTTP处理器
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Register 注册WebSocket和数据库连接池指标，在创建Hub、Manager并连接数据库后调用一次
+func Register(hub *websocket.Hub, manager *websocket.Manager, db *sql.DB) {
+	if hub != nil {
+		Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "websocket_clients",
+			Help:      "当前连接的前端WebSocket客户端数",
+		}, func() float64 {
+			return float64(hub.GetClientCount())
+		}))
+	}
+	if manager != nil {
+		Registry.MustRegister(&connectionCollector{manager: manager})
+	}
+	if db != nil {
+		Registry.MustRegister(collectors.NewDBStatsCollector(db, "main"))
+	}
+}
+
+// ObserveHTTPRequest 记录一次HTTP请求，route为路由模板
+func ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	httpRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// ObserveEmbyRequest 记录一次Emby API调用
+func ObserveEmbyRequest(serverID uint, method string, retries int, duration time.Duration, err error) {
+	id := serverLabel(serverID)
+	embyRequestsTotal.WithLabelValues(id, method).Inc()
+	if err != nil {
+		embyRequestErrorsTotal.WithLabelValues(id, method).Inc()
+	}
+	if retries > 0 {
+		embyRequestRetriesTotal.WithLabelValues(id).Add(float64(retries))
+	}
+	embyRequestDuration.WithLabelValues(id).Observe(duration.Seconds())
+}
+
+// ObserveSyncJob 记录一次结束的媒体库同步任务
+func ObserveSyncJob(scope, status string, duration time.Duration) {
+	syncJobDuration.WithLabelValues(scope, status).Observe(duration.Seconds())
+}
+
+// ObserveScheduledJob 记录一次定时任务的运行
+func ObserveScheduledJob(jobType, status string, duration time.Duration) {
+	scheduledJobDuration.WithLabelValues(jobType, status).Observe(duration.Seconds())
+}
+
+// DeleteServer 删除服务器的Emby API指标，服务器删除后调用
+func DeleteServer(serverID uint) {
+	labels := prometheus.Labels{"server_id": serverLabel(serverID)}
+	embyRequestsTotal.DeletePartialMatch(labels)
+	embyRequestErrorsTotal.DeletePartialMatch(labels)
+	embyRequestRetriesTotal.DeletePartialMatch(labels)
+	embyRequestDuration.DeletePartialMatch(labels)
+}
+
+func serverLabel(serverID uint) string {
+	return strconv.FormatUint(uint64(serverID), 10)
+}
+
+// connectionStates 导出的Emby WebSocket连接状态
+var connectionStates = []websocket.ConnectionStatus{
+	websocket.Disconnected,
+	websocket.Connecting,
+	websocket.Connected,
+	websocket.Reconnecting,
+	websocket.Failed,
+}
+
+var connectionStateDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "emby_websocket_connection_state"),
+	"Emby WebSocket连接状态，当前状态为1，其余为0",
+	[]string{"server_id", "state"}, nil,
+)
+
+// connectionCollector 在抓取时读取Manager中每个连接的状态
+type connectionCollector struct {
+	manager *websocket.Manager
+}
+
+func (c *connectionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- connectionStateDesc
+}
+
+func (c *connectionCollector) Collect(ch chan<- prometheus.Metric) {
+	for serverID, current := range c.manager.GetConnectionStatus() {
+		for _, state := range connectionStates {
+			value := 0.0
+			if state.String() == current {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(connectionStateDesc, prometheus.GaugeValue, value, serverID, state.String())
+		}
+	}
+}
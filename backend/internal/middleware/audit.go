@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"github.com/emby-client-go/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// 处理器补充审计信息使用的上下文键
+const (
+	auditDetailsKey  = "audit_details"
+	auditTargetIDKey = "audit_target_id"
+)
+
+// AuditActor 当前请求的操作者及其客户端信息
+func AuditActor(c *gin.Context) services.AuditActor {
+	return services.AuditActor{
+		UserID:    c.GetUint("user_id"),
+		Username:  c.GetString("username"),
+		Role:      c.GetString("role"),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+// Audit 请求成功（状态码小于400）后记录审计事件，对象ID取自路径参数targetParam，为空时不记录对象ID
+// 已在服务中记录操作前后快照的操作不需要使用此中间件
+func Audit(action, targetType, targetParam string) gin.HandlerFunc {
+	auditService := services.NewAuditService()
+
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.IsAborted() || c.Writer.Status() >= 400 {
+			return
+		}
+
+		var targetID interface{}
+		if targetParam != "" {
+			targetID = c.Param(targetParam)
+		}
+		if id, ok := c.Get(auditTargetIDKey); ok {
+			targetID = id
+		}
+		details, _ := c.Get(auditDetailsKey)
+		auditService.Record(AuditActor(c), action, targetType, targetID, nil, details)
+	}
+}
+
+// SetAuditDetails 补充审计事件的操作内容，如播放命令和创建的同步任务，写入事件的after字段
+func SetAuditDetails(c *gin.Context, details interface{}) {
+	c.Set(auditDetailsKey, details)
+}
+
+// SetAuditTarget 设置审计事件的对象ID，用于创建操作等对象ID不在路径参数中的请求
+func SetAuditTarget(c *gin.Context, targetID interface{}) {
+	c.Set(auditTargetIDKey, targetID)
+}
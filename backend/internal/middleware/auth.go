@@ -1,7 +1,9 @@
 package middleware
 
 import (
-	"fmt"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"net/http"
 	"strings"
 	"time"
@@ -9,6 +11,7 @@ import (
 	"github.com/emby-client-go/backend/internal/config"
 	"github.com/emby-client-go/backend/internal/database"
 	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -18,20 +21,30 @@ type Claims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	// SessionID 所属登录会话，会话吊销后其签发的所有访问令牌失效
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成JWT token
-func GenerateToken(user models.User) (string, error) {
+// GenerateToken 为登录会话生成短期访问令牌
+func GenerateToken(user models.User, sessionID string) (string, error) {
 	// 设置过期时间
-	expirationTime := time.Now().Add(time.Duration(config.AppConfig.JWT.ExpireTime) * time.Second)
+	expirationTime := time.Now().Add(services.AccessTokenTTL())
+
+	// 令牌唯一标识，用于单独吊销
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
 
 	// 创建claims
 	claims := &Claims{
-		UserID:   user.ID,
-		Username: user.Username,
-		Role:     user.Role,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(jti),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    config.AppConfig.JWT.Issuer,
@@ -50,6 +63,70 @@ func GenerateToken(user models.User) (string, error) {
 	return tokenString, nil
 }
 
+// 两步验证临时令牌的用途
+const (
+	TwoFactorPurposeVerify = "2fa_verify" // 已启用两步验证，需要提交验证码
+	TwoFactorPurposeSetup  = "2fa_setup"  // 系统要求两步验证但用户尚未启用，需要先完成绑定
+)
+
+// twoFactorAudience 临时令牌的受众，与访问令牌区分
+const twoFactorAudience = "two-factor"
+
+// twoFactorTokenTTL 临时令牌有效期
+const twoFactorTokenTTL = 5 * time.Minute
+
+// TwoFactorClaims 密码验证通过后、完成两步验证前使用的临时令牌
+type TwoFactorClaims struct {
+	UserID     uint   `json:"user_id"`
+	Purpose    string `json:"purpose"`
+	DeviceName string `json:"device_name,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GenerateTwoFactorToken 生成两步验证临时令牌
+func GenerateTwoFactorToken(user models.User, purpose, deviceName string) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &TwoFactorClaims{
+		UserID:     user.ID,
+		Purpose:    purpose,
+		DeviceName: deviceName,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(jti),
+			Audience:  jwt.ClaimStrings{twoFactorAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(twoFactorTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    config.AppConfig.JWT.Issuer,
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(config.AppConfig.JWT.Secret))
+}
+
+// ParseTwoFactorToken 解析两步验证临时令牌并检查用途
+func ParseTwoFactorToken(tokenString, purpose string) (*TwoFactorClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &TwoFactorClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte(config.AppConfig.JWT.Secret), nil
+	}, jwt.WithAudience(twoFactorAudience))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*TwoFactorClaims)
+	if !ok || !token.Valid {
+		return nil, jwt.ErrInvalidKey
+	}
+	if claims.Purpose != purpose {
+		return nil, errors.New("临时令牌用途不匹配")
+	}
+	return claims, nil
+}
+
 // ParseToken 解析JWT token
 func ParseToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
@@ -67,8 +144,17 @@ func ParseToken(tokenString string) (*Claims, error) {
 	return nil, jwt.ErrInvalidKey
 }
 
-// AuthMiddleware JWT认证中间件
+// 请求的认证方式
+const (
+	AuthTypeSession  = "session"   // 登录会话签发的JWT
+	AuthTypeAPIToken = "api_token" // 个人访问令牌
+)
+
+// AuthMiddleware JWT认证中间件，同时接受个人访问令牌
 func AuthMiddleware() gin.HandlerFunc {
+	sessionService := services.NewSessionService()
+	apiTokenService := services.NewAPITokenService()
+
 	return func(c *gin.Context) {
 		// 获取Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -92,6 +178,42 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 个人访问令牌
+		if strings.HasPrefix(parts[1], services.APITokenPrefix) {
+			token, user, err := apiTokenService.Authenticate(parts[1], c.ClientIP())
+			if err != nil {
+				status := http.StatusUnauthorized
+				if !errors.Is(err, services.ErrAPITokenInvalid) {
+					status = http.StatusInternalServerError
+				}
+				c.JSON(status, gin.H{
+					"code":    status,
+					"message": err.Error(),
+				})
+				c.Abort()
+				return
+			}
+			if user.Status != "active" {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"code":    401,
+					"message": "用户已被禁用",
+				})
+				c.Abort()
+				return
+			}
+
+			c.Set("user_id", user.ID)
+			c.Set("username", user.Username)
+			c.Set("role", user.Role)
+			c.Set("user", *user)
+			c.Set("auth_type", AuthTypeAPIToken)
+			c.Set("api_token_id", token.ID)
+			c.Set("token_scopes", []string(token.Scopes))
+
+			c.Next()
+			return
+		}
+
 		// 解析token
 		claims, err := ParseToken(parts[1])
 		if err != nil {
@@ -104,6 +226,20 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 检查令牌和会话是否已吊销
+		if err := sessionService.ValidateAccessToken(c.Request.Context(), claims.ID, claims.SessionID); err != nil {
+			status := http.StatusUnauthorized
+			if !errors.Is(err, services.ErrTokenRevoked) {
+				status = http.StatusInternalServerError
+			}
+			c.JSON(status, gin.H{
+				"code":    status,
+				"message": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+
 		// 验证用户是否存在且活跃
 		var user models.User
 		if err := database.DB.First(&user, claims.UserID).Error; err != nil {
@@ -124,24 +260,27 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 将用户信息存储到上下文
+		// 将用户信息存储到上下文，角色以数据库为准，修改角色后无需重新登录
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
+		c.Set("role", user.Role)
 		c.Set("user", user)
+		c.Set("session_id", claims.SessionID)
+		c.Set("token_id", claims.ID)
+		c.Set("token_expires_at", claims.ExpiresAt.Time)
+		c.Set("auth_type", AuthTypeSession)
 
 		c.Next()
 	}
 }
 
-// AdminMiddleware 管理员权限中间件
-func AdminMiddleware() gin.HandlerFunc {
+// RequireSessionAuth 要求使用登录会话认证，用于管理令牌、修改密码等账户安全操作
+func RequireSessionAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists || role != "admin" {
+		if c.GetString("auth_type") == AuthTypeAPIToken {
 			c.JSON(http.StatusForbidden, gin.H{
 				"code":    403,
-				"message": "需要管理员权限",
+				"message": "该操作不支持使用访问令牌，请登录后操作",
 			})
 			c.Abort()
 			return
@@ -150,38 +289,86 @@ func AdminMiddleware() gin.HandlerFunc {
 	}
 }
 
-// RefreshToken 刷新JWT token
-func RefreshToken(oldToken string) (string, error) {
-	// 解析旧token（即使过期也要解析）
-	token, err := jwt.ParseWithClaims(oldToken, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(config.AppConfig.JWT.Secret), nil
-	})
-
-	if err != nil && !strings.Contains(err.Error(), "token is expired") {
-		return "", err
+// HasPermission 判断当前请求是否拥有指定权限
+// 使用个人访问令牌时，还要求令牌的权限范围包含该权限
+func HasPermission(c *gin.Context, permission string) (bool, error) {
+	if value, ok := c.Get("token_scopes"); ok {
+		scopes, _ := value.([]string)
+		inScope := false
+		for _, scope := range scopes {
+			if scope == permission {
+				inScope = true
+				break
+			}
+		}
+		if !inScope {
+			return false, nil
+		}
 	}
+	return services.NewRBACService().HasPermission(c.GetString("role"), permission)
+}
 
-	claims, ok := token.Claims.(*Claims)
+// tokenHasScope 使用个人访问令牌时判断令牌是否包含任一权限范围，其他认证方式直接返回true
+func tokenHasScope(c *gin.Context, scopes ...string) bool {
+	value, ok := c.Get("token_scopes")
 	if !ok {
-		return "", jwt.ErrInvalidKey
+		return true
 	}
-
-	// 验证用户是否仍然有效
-	var user models.User
-	if err := database.DB.First(&user, claims.UserID).Error; err != nil {
-		return "", err
+	granted, _ := value.([]string)
+	for _, scope := range granted {
+		for _, s := range scopes {
+			if scope == s {
+				return true
+			}
+		}
 	}
+	return false
+}
 
-	if user.Status != "active" {
-		return "", fmt.Errorf("用户已被禁用")
+// RequireTokenScope 要求个人访问令牌包含任一权限范围，用于没有对应角色权限检查的路由
+func RequireTokenScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tokenHasScope(c, scopes...) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "访问令牌缺少权限范围: " + strings.Join(scopes, " 或 "),
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
 	}
+}
 
-	// 生成新token
-	return GenerateToken(user)
+// RequirePermission 权限中间件，要求当前用户的角色拥有指定权限
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, err := HasPermission(c, permission)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "缺少权限: " + permission,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
 }
 
 // OptionalAuthMiddleware 可选认证中间件（不强制要求登录）
 func OptionalAuthMiddleware() gin.HandlerFunc {
+	sessionService := services.NewSessionService()
+	apiTokenService := services.NewAPITokenService()
+
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -195,20 +382,39 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if strings.HasPrefix(parts[1], services.APITokenPrefix) {
+			if token, user, err := apiTokenService.Authenticate(parts[1], c.ClientIP()); err == nil && user.Status == "active" {
+				c.Set("user_id", user.ID)
+				c.Set("username", user.Username)
+				c.Set("role", user.Role)
+				c.Set("user", *user)
+				c.Set("auth_type", AuthTypeAPIToken)
+				c.Set("api_token_id", token.ID)
+				c.Set("token_scopes", []string(token.Scopes))
+			}
+			c.Next()
+			return
+		}
+
 		claims, err := ParseToken(parts[1])
 		if err != nil {
 			c.Next()
 			return
 		}
+		if err := sessionService.ValidateAccessToken(c.Request.Context(), claims.ID, claims.SessionID); err != nil {
+			c.Next()
+			return
+		}
 
 		var user models.User
 		if err := database.DB.First(&user, claims.UserID).Error; err == nil && user.Status == "active" {
 			c.Set("user_id", claims.UserID)
 			c.Set("username", claims.Username)
-			c.Set("role", claims.Role)
+			c.Set("role", user.Role)
 			c.Set("user", user)
+			c.Set("auth_type", AuthTypeSession)
 		}
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
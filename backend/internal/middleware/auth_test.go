@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/emby-client-go/backend/internal/config"
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/secrets"
+	"github.com/emby-client-go/backend/internal/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB 使用临时SQLite数据库初始化内置角色
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	config.Init()
+	config.AppConfig.Database.Type = "sqlite"
+	config.AppConfig.Database.Database = filepath.Join(t.TempDir(), "test.db")
+	if err := secrets.Init("", "", "", "test-secret"); err != nil {
+		t.Fatalf("初始化加密密钥失败: %v", err)
+	}
+	if err := database.Init(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	database.DB.Logger = logger.Default.LogMode(logger.Silent)
+	t.Cleanup(func() { database.Close() })
+
+	if err := services.NewRBACService().EnsureDefaults(); err != nil {
+		t.Fatalf("初始化角色权限失败: %v", err)
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	setupTestDB(t)
+	if _, err := services.NewRBACService().CreateRole(services.RoleAdmin, "operator", "", []string{
+		services.PermMediaSync, services.PermJobsManage,
+	}); err != nil {
+		t.Fatalf("创建角色失败: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		role       string
+		scopes     []string // nil表示使用登录会话认证
+		permission string
+		want       bool
+	}{
+		{"会话认证按角色权限", "operator", nil, services.PermMediaSync, true},
+		{"会话认证角色没有权限", "operator", nil, services.PermUsersManage, false},
+		{"管理员拥有全部权限", services.RoleAdmin, nil, services.PermRolesManage, true},
+		{"令牌范围和角色都包含", "operator", []string{services.PermMediaSync}, services.PermMediaSync, true},
+		{"令牌范围不包含", "operator", []string{services.PermMediaSync}, services.PermJobsManage, false},
+		{"令牌范围包含但角色已没有该权限", "operator", []string{services.PermUsersManage}, services.PermUsersManage, false},
+		{"管理员的令牌也受范围限制", services.RoleAdmin, []string{services.PermMediaSync}, services.PermRolesManage, false},
+		{"空范围的令牌没有任何权限", services.RoleAdmin, []string{}, services.PermMediaSync, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Set("role", tt.role)
+			if tt.scopes != nil {
+				c.Set("token_scopes", tt.scopes)
+			}
+
+			got, err := HasPermission(c, tt.permission)
+			if err != nil {
+				t.Fatalf("HasPermission err = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("HasPermission(%q) = %v, want %v", tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenHasScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string // nil表示使用登录会话认证
+		check  []string
+		want   bool
+	}{
+		{"会话认证不受范围限制", nil, []string{services.PermAuditView}, true},
+		{"包含任一范围", []string{services.PermAuditView}, []string{services.PermSystemManage, services.PermAuditView}, true},
+		{"不包含任何范围", []string{services.PermMediaSync}, []string{services.PermAuditView}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			if tt.scopes != nil {
+				c.Set("token_scopes", tt.scopes)
+			}
+			if got := tokenHasScope(c, tt.check...); got != tt.want {
+				t.Fatalf("tokenHasScope(%v) = %v, want %v", tt.check, got, tt.want)
+			}
+		})
+	}
+}
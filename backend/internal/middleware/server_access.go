@@ -0,0 +1,205 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/internal/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ServerResolver 从请求中解析目标服务器ID，返回0表示请求未指定服务器
+type ServerResolver func(c *gin.Context) (uint, error)
+
+// resolveError 解析服务器ID时的请求错误
+type resolveError struct {
+	status  int
+	message string
+}
+
+func (e *resolveError) Error() string {
+	return e.message
+}
+
+// parseID 解析ID，空字符串返回0
+func parseID(value, message string) (uint, error) {
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || id == 0 {
+		return 0, &resolveError{status: http.StatusBadRequest, message: message}
+	}
+	return uint(id), nil
+}
+
+// ServerParam 从路径参数读取服务器ID
+func ServerParam(name string) ServerResolver {
+	return func(c *gin.Context) (uint, error) {
+		return parseID(c.Param(name), "无效的服务器ID")
+	}
+}
+
+// ServerQuery 从查询参数读取服务器ID，未传时不做检查
+func ServerQuery(name string) ServerResolver {
+	return func(c *gin.Context) (uint, error) {
+		return parseID(c.Query(name), "无效的服务器ID")
+	}
+}
+
+// LibraryParam 从路径参数读取媒体库ID并查询所属服务器
+func LibraryParam(name string) ServerResolver {
+	return func(c *gin.Context) (uint, error) {
+		return libraryServerID(c.Param(name))
+	}
+}
+
+// LibraryQuery 从查询参数读取媒体库ID并查询所属服务器，未传时不做检查
+func LibraryQuery(name string) ServerResolver {
+	return func(c *gin.Context) (uint, error) {
+		return libraryServerID(c.Query(name))
+	}
+}
+
+// MediaItemParam 从路径参数读取媒体项目ID并查询所属服务器
+func MediaItemParam(name string) ServerResolver {
+	return func(c *gin.Context) (uint, error) {
+		id, err := parseID(c.Param(name), "无效的媒体项目ID")
+		if err != nil || id == 0 {
+			return 0, err
+		}
+
+		var library models.MediaLibrary
+		err = database.DB.Select("media_libraries.emby_server_id").
+			Joins("JOIN media_items ON media_items.media_library_id = media_libraries.id").
+			Where("media_items.id = ?", id).
+			First(&library).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, &resolveError{status: http.StatusNotFound, message: "媒体项目不存在"}
+		}
+		return library.EmbyServerID, err
+	}
+}
+
+// libraryServerID 查询媒体库所属服务器
+func libraryServerID(value string) (uint, error) {
+	id, err := parseID(value, "无效的媒体库ID")
+	if err != nil || id == 0 {
+		return 0, err
+	}
+
+	var library models.MediaLibrary
+	err = database.DB.Select("emby_server_id").First(&library, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, &resolveError{status: http.StatusNotFound, message: "媒体库不存在"}
+	}
+	return library.EmbyServerID, err
+}
+
+// serverRoleScopes 使用个人访问令牌访问服务器时，各级服务器角色要求令牌包含的权限范围之一
+var serverRoleScopes = map[string][]string{
+	services.ServerRoleViewer:   {services.PermServerCreate, services.PermServerManage, services.PermMediaSync, services.PermPlaybackControl},
+	services.ServerRoleOperator: {services.PermServerManage, services.PermMediaSync, services.PermPlaybackControl},
+	services.ServerRoleOwner:    {services.PermServerCreate, services.PermServerManage},
+}
+
+// ServerRoleScopes 返回指定服务器角色接受的令牌权限范围
+func ServerRoleScopes(role string) []string {
+	return serverRoleScopes[role]
+}
+
+// RequireServerRole 服务器访问权限中间件，要求当前用户对目标服务器至少具有指定角色
+// 拥有server.manage权限的用户可以访问所有服务器；使用个人访问令牌时还要求令牌包含该角色对应的权限范围
+// 检查通过后将服务器ID和角色写入上下文
+func RequireServerRole(required string, resolve ServerResolver) gin.HandlerFunc {
+	accessService := services.NewServerAccessService()
+	scopes := ServerRoleScopes(required)
+
+	return func(c *gin.Context) {
+		if !tokenHasScope(c, scopes...) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "访问令牌缺少权限范围: " + strings.Join(scopes, " 或 "),
+			})
+			c.Abort()
+			return
+		}
+
+		serverID, err := resolve(c)
+		if err != nil {
+			var re *resolveError
+			if errors.As(err, &re) {
+				c.JSON(re.status, gin.H{
+					"code":    re.status,
+					"message": re.message,
+				})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"code":    500,
+					"message": "查询服务器权限失败",
+				})
+			}
+			c.Abort()
+			return
+		}
+		if serverID == 0 {
+			c.Next()
+			return
+		}
+
+		manageAll, err := HasPermission(c, services.PermServerManage)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+
+		role, err := accessService.GetRole(c.GetUint("user_id"), manageAll, serverID)
+		if errors.Is(err, services.ErrServerNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+
+		// 没有任何角色时按不存在处理，避免泄露其他用户的服务器
+		if role == "" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": services.ErrServerNotFound.Error(),
+			})
+			c.Abort()
+			return
+		}
+		if !services.ServerRoleAllows(role, required) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": services.ErrServerAccessDenied.Error(),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("server_id", serverID)
+		c.Set("server_role", role)
+		c.Next()
+	}
+}
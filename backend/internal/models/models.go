@@ -1,10 +1,100 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
+
+	"github.com/emby-client-go/backend/internal/secrets"
 	"gorm.io/gorm"
 )
 
+// EncryptedString 落库时加密、读取时解密的敏感字符串，JSON序列化时只输出掩码指纹
+type EncryptedString string
+
+// Value 实现driver.Valuer接口
+func (s EncryptedString) Value() (driver.Value, error) {
+	if s == "" {
+		return "", nil
+	}
+	return secrets.Encrypt(string(s))
+}
+
+// Scan 实现sql.Scanner接口，兼容尚未加密的历史数据
+func (s *EncryptedString) Scan(value interface{}) error {
+	var raw string
+	switch v := value.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("无法将 %T 转换为EncryptedString", value)
+	}
+
+	plaintext, err := secrets.Decrypt(raw)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
+
+// GormDataType 密文长度不固定，统一使用text类型
+func (EncryptedString) GormDataType() string {
+	return "text"
+}
+
+// MarshalJSON 只输出掩码指纹
+func (s EncryptedString) MarshalJSON() ([]byte, error) {
+	return json.Marshal(secrets.Fingerprint(string(s)))
+}
+
+// String 返回掩码指纹，避免明文被打印到日志
+func (s EncryptedString) String() string {
+	return secrets.Fingerprint(string(s))
+}
+
+// StringList 以JSON形式存储的字符串列表
+type StringList []string
+
+// Value 实现driver.Valuer接口
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现sql.Scanner接口
+func (l *StringList) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("无法将 %T 转换为StringList", value)
+	}
+	if len(data) == 0 {
+		*l = nil
+		return nil
+	}
+	return json.Unmarshal(data, l)
+}
+
 // User 用户模型
 type User struct {
 	ID               uint           `json:"id" gorm:"primaryKey"`
@@ -12,11 +102,17 @@ type User struct {
 	Email            string         `json:"email" gorm:"uniqueIndex;not null"`
 	Password         string         `json:"-" gorm:"not null"`
 	Nickname         string         `json:"nickname"`
-	Role             string         `json:"role" gorm:"default:'user'"` // admin, user
+	Role             string         `json:"role" gorm:"default:'user'"` // 角色名称，对应Role.Name
 	Status           string         `json:"status" gorm:"default:'active'"` // active, inactive, locked
+	AuthSource       string         `json:"auth_source" gorm:"size:20;default:'local'"` // local: 本地密码, ldap: 目录认证
+	EmailVerified    bool           `json:"email_verified" gorm:"default:false"`
 	LastLogin        *time.Time     `json:"last_login"`
 	FailedLoginCount int            `json:"-" gorm:"default:0"` // 登录失败次数
 	LockedUntil      *time.Time     `json:"-"` // 账户锁定截止时间
+	LockoutCount     int            `json:"-" gorm:"default:0"` // 连续被锁定的次数，锁定时长按次数指数增长
+	TOTPSecret       EncryptedString `json:"-" gorm:"column:totp_secret"` // 两步验证密钥，启用前为待确认的密钥
+	TOTPEnabled      bool           `json:"totp_enabled" gorm:"column:totp_enabled;default:false"`
+	TOTPLastCounter  int64          `json:"-" gorm:"column:totp_last_counter;default:0"` // 最近一次通过验证的时间步，防止验证码重放
 	CreatedAt        time.Time      `json:"created_at"`
 	UpdatedAt        time.Time      `json:"updated_at"`
 	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
@@ -25,17 +121,143 @@ type User struct {
 	EmbyServers []EmbyServer `json:"emby_servers,omitempty" gorm:"many2many:user_emby_servers;"`
 }
 
+// UserSession 用户登录会话，每次登录创建一个会话，刷新令牌在会话内轮换
+type UserSession struct {
+	ID                  uint       `json:"id" gorm:"primaryKey"`
+	SID                 string     `json:"-" gorm:"uniqueIndex;size:64;not null"` // 写入访问令牌的会话标识
+	UserID              uint       `json:"user_id" gorm:"index;not null"`
+	RefreshTokenHash    string     `json:"-" gorm:"uniqueIndex;size:64;not null"`
+	PreviousRefreshHash string     `json:"-" gorm:"index;size:64"` // 上一个刷新令牌，被再次使用时说明令牌泄露
+	DeviceName          string     `json:"device_name"`
+	UserAgent           string     `json:"user_agent"`
+	IP                  string     `json:"ip"`
+	LastUsedAt          time.Time  `json:"last_used_at"`
+	ExpiresAt           time.Time  `json:"expires_at" gorm:"index"`
+	RevokedAt           *time.Time `json:"revoked_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// RecoveryCode 两步验证恢复码，每个只能使用一次
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	CodeHash  string     `json:"-" gorm:"index;size:64;not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// APIToken 个人访问令牌，供脚本等自动化场景调用API，数据库中只保存摘要
+type APIToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"index;not null"`
+	Name       string     `json:"name" gorm:"size:100;not null"`
+	TokenHash  string     `json:"-" gorm:"uniqueIndex;size:64;not null"`
+	Prefix     string     `json:"prefix" gorm:"size:16"`  // 令牌开头几位，便于用户辨认
+	Scopes     StringList `json:"scopes" gorm:"type:text"` // 权限范围，实际权限为角色权限与范围的交集
+	ExpiresAt  *time.Time `json:"expires_at"`              // 为空表示永不过期
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip" gorm:"size:64"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// PasswordHistory 用户使用过的密码摘要，用于禁止重复使用最近的密码
+type PasswordHistory struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"user_id" gorm:"index;not null"`
+	PasswordHash string    `json:"-" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AuditEvent 审计事件，记录谁在什么时候对什么对象做了什么操作
+type AuditEvent struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	ActorID    *uint     `json:"actor_id" gorm:"index"` // 系统自动执行时为空
+	ActorName  string    `json:"actor_name" gorm:"size:100"`
+	Action     string    `json:"action" gorm:"size:100;index;not null"` // 如 user.create
+	TargetType string    `json:"target_type" gorm:"size:50;index:idx_audit_target"`
+	TargetID   string    `json:"target_id" gorm:"size:100;index:idx_audit_target"`
+	Before     string    `json:"before" gorm:"type:text"` // 操作前的对象快照（JSON）
+	After      string    `json:"after" gorm:"type:text"`  // 操作后的对象快照（JSON）
+	IP         string    `json:"ip" gorm:"size:64"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+// UserIdentity 用户关联的外部身份，同一身份提供商的subject只能关联一个用户
+type UserIdentity struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"index;not null"`
+	Provider    string     `json:"provider" gorm:"size:50;not null;uniqueIndex:idx_user_identity_subject"` // oidc, ldap
+	Subject     string     `json:"subject" gorm:"size:255;not null;uniqueIndex:idx_user_identity_subject"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// OIDCLoginState OIDC登录过程中的临时状态，回调完成后换成一次性登录码
+type OIDCLoginState struct {
+	State         string          `json:"-" gorm:"primaryKey;size:64"`
+	Nonce         string          `json:"-" gorm:"size:64"`
+	CodeVerifier  EncryptedString `json:"-"` // PKCE校验码
+	UserID        uint            `json:"-"` // 回调验证通过后写入
+	LoginCodeHash string          `json:"-" gorm:"index;size:64"`
+	ExpiresAt     time.Time       `json:"-" gorm:"index"`
+	CreatedAt     time.Time       `json:"-"`
+}
+
+// TableName 指定表名，避免OIDC被拆分为o_id_c
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
+}
+
+// RevokedToken 已吊销但尚未过期的访问令牌或会话
+type RevokedToken struct {
+	ID        string    `json:"id" gorm:"primaryKey;size:64"` // 访问令牌的JTI或会话SID
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Role 角色，由一组权限组成
+type Role struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"uniqueIndex;size:50;not null"`
+	Description string    `json:"description"`
+	BuiltIn     bool      `json:"built_in" gorm:"default:false"` // 内置角色不能删除
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// 关联
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions;"`
+}
+
+// Permission 权限
+type Permission struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	Name        string `json:"name" gorm:"uniqueIndex;size:100;not null"` // 如 server.manage
+	Description string `json:"description"`
+}
+
 // EmbyServer Emby服务器模型
 type EmbyServer struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
 	Name        string    `json:"name" gorm:"not null"`
 	URL         string    `json:"url" gorm:"not null"`
-	APIKey      string    `json:"api_key" gorm:"not null"`
+	APIKey      EncryptedString `json:"api_key_fingerprint" gorm:"not null"` // API密钥，用户名密码登录时为访问令牌
+	AuthType    string    `json:"auth_type" gorm:"default:'api_key'"` // api_key, password
+	EmbyUsername string   `json:"emby_username"`
+	EmbyPassword EncryptedString `json:"-"`      // 用于访问令牌失效后重新登录
+	EmbyUserID  string    `json:"emby_user_id"` // 登录用户在Emby中的ID
 	Version     string    `json:"version"`
 	OS          string    `json:"os"`
 	Status      string    `json:"status" gorm:"default:'offline'"` // online, offline, error
 	LastCheck   *time.Time `json:"last_check"`
 	Description string    `json:"description"`
+	Timeout     *int      `json:"timeout"`     // 请求超时（秒），为空时使用全局配置
+	MaxRetries  *int      `json:"max_retries"` // 最大重试次数，为空时使用全局配置
+	CacheTTL    *int      `json:"cache_ttl"`   // 缓存有效期（秒），为空时使用全局配置
+	AuthMode    string    `json:"auth_mode"`   // header, query，为空时使用全局配置
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
@@ -45,10 +267,21 @@ type EmbyServer struct {
 	MediaLibraries []MediaLibrary `json:"media_libraries,omitempty"`
 }
 
+// UserEmbyServer 用户与服务器的关联，记录用户对服务器的访问角色
+type UserEmbyServer struct {
+	UserID       uint      `json:"user_id" gorm:"primaryKey"`
+	EmbyServerID uint      `json:"emby_server_id" gorm:"primaryKey"`
+	Role         string    `json:"role" gorm:"size:20"` // owner, operator, viewer
+	CreatedAt    time.Time `json:"created_at"`
+
+	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
 // MediaLibrary 媒体库模型
 type MediaLibrary struct {
 	ID             uint      `json:"id" gorm:"primaryKey"`
 	EmbyServerID   uint      `json:"emby_server_id" gorm:"not null"`
+	EmbyLibraryID  string    `json:"emby_library_id" gorm:"index"` // Emby中的媒体库ID
 	Name           string    `json:"name" gorm:"not null"`
 	Type           string    `json:"type" gorm:"not null"` // movies, tvshows, music, photos
 	Path           string    `json:"path"`
@@ -56,6 +289,15 @@ type MediaLibrary struct {
 	TotalSize      int64     `json:"total_size" gorm:"default:0"`
 	LastRefresh    *time.Time `json:"last_refresh"`
 	CollectionType string    `json:"collection_type"`
+
+	// 项目同步状态
+	ItemHighWaterMark *time.Time `json:"item_high_water_mark"` // 已同步项目的最大DateLastSaved
+	LastSyncAt        *time.Time `json:"last_sync_at"`
+	LastSyncMode      string     `json:"last_sync_mode"` // full, incremental
+	LastSyncAdded     int        `json:"last_sync_added" gorm:"default:0"`
+	LastSyncUpdated   int        `json:"last_sync_updated" gorm:"default:0"`
+	LastSyncRemoved   int        `json:"last_sync_removed" gorm:"default:0"`
+	LastSyncDuration  int64      `json:"last_sync_duration" gorm:"default:0"` // 毫秒
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
@@ -67,17 +309,40 @@ type MediaLibrary struct {
 // ConnectionLog 连接日志模型
 type ConnectionLog struct {
 	ID           uint      `json:"id" gorm:"primaryKey"`
-	EmbyServerID uint      `json:"emby_server_id" gorm:"not null"`
-	UserID       uint      `json:"user_id" gorm:"not null"`
-	Action       string    `json:"action" gorm:"not null"` // connect, disconnect, error
+	EmbyServerID uint      `json:"emby_server_id" gorm:"not null;index:idx_connection_logs_server_time"`
+	UserID       *uint     `json:"user_id" gorm:"index"` // 系统自动检测时为空
+	Action       string    `json:"action" gorm:"not null"` // connect, test, health_check, reconnect, disconnect, error
+	Source       string    `json:"source" gorm:"size:20;default:'http'"` // http: 接口请求和健康检查, websocket: WebSocket连接
 	Message      string    `json:"message"`
 	ResponseTime int       `json:"response_time"` // 毫秒
 	Status       string    `json:"status" gorm:"not null"` // success, failed
-	CreatedAt    time.Time `json:"created_at"`
+	CreatedAt    time.Time `json:"created_at" gorm:"index:idx_connection_logs_server_time"`
 
 	// 关联
 	EmbyServer EmbyServer `json:"emby_server,omitempty" gorm:"foreignKey:EmbyServerID"`
-	User       User       `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	User       *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// ServerMetric 定时健康检查的原始采样
+type ServerMetric struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	EmbyServerID uint      `json:"emby_server_id" gorm:"not null;index:idx_server_metrics_server_time"`
+	CheckedAt    time.Time `json:"checked_at" gorm:"not null;index:idx_server_metrics_server_time"`
+	Up           bool      `json:"up"`
+	ResponseTime int       `json:"response_time"` // 毫秒，失败时为0
+}
+
+// ServerMetricRollup 按小时或按天（UTC）汇总的采样，延迟只统计成功的采样
+type ServerMetricRollup struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	EmbyServerID uint      `json:"emby_server_id" gorm:"not null;uniqueIndex:idx_server_metric_rollups_bucket"`
+	Resolution   string    `json:"resolution" gorm:"size:10;not null;uniqueIndex:idx_server_metric_rollups_bucket"` // hour, day
+	BucketStart  time.Time `json:"bucket_start" gorm:"not null;uniqueIndex:idx_server_metric_rollups_bucket"`
+	Samples      int       `json:"samples"`
+	UpSamples    int       `json:"up_samples"`
+	LatencySum   int64     `json:"latency_sum"` // 毫秒
+	LatencyMin   int       `json:"latency_min"`
+	LatencyMax   int       `json:"latency_max"`
 }
 
 // Device 设备模型
@@ -104,8 +369,8 @@ type Device struct {
 // MediaItem 媒体项目模型
 type MediaItem struct {
 	ID             uint           `json:"id" gorm:"primaryKey"`
-	MediaLibraryID uint           `json:"media_library_id" gorm:"not null;index"`
-	EmbyItemID     string         `json:"emby_item_id" gorm:"not null;index"` // Emby中的项目ID
+	MediaLibraryID uint           `json:"media_library_id" gorm:"not null;index;uniqueIndex:idx_media_items_library_item"`
+	EmbyItemID     string         `json:"emby_item_id" gorm:"not null;index;uniqueIndex:idx_media_items_library_item"` // Emby中的项目ID
 	Name           string         `json:"name" gorm:"not null;index"`
 	Type           string         `json:"type" gorm:"not null;index"` // Movie, Episode, Audio, etc.
 	Path           string         `json:"path"`
@@ -120,6 +385,7 @@ type MediaItem struct {
 	VideoCodec     string         `json:"video_codec"`
 	AudioCodec     string         `json:"audio_codec"`
 	Resolution     string         `json:"resolution"`
+	LastSeenAt     *time.Time     `json:"last_seen_at" gorm:"index"` // 最近一次同步时在Emby中出现的时间
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
@@ -178,6 +444,60 @@ type PlaybackRecord struct {
 	Device     *Device    `json:"device,omitempty" gorm:"foreignKey:DeviceID"`
 }
 
+// ScheduledJob 后台定时任务模型（每个服务器每种任务一条记录）
+type ScheduledJob struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	EmbyServerID uint       `json:"emby_server_id" gorm:"not null;uniqueIndex:idx_scheduled_jobs_server_type"`
+	JobType      string     `json:"job_type" gorm:"not null;uniqueIndex:idx_scheduled_jobs_server_type"` // health_check, sync_devices, sync_libraries, sync_sessions, metrics_rollup
+	Schedule     string     `json:"schedule"`                                                            // 调度表达式
+	Interval     int64      `json:"interval"`                                                            // 间隔（秒）
+	Paused       bool       `json:"paused" gorm:"default:false"`
+	ConfigPaused bool       `json:"config_paused" gorm:"default:false"` // 因配置中禁用而暂停，重新配置后自动恢复
+	Status       string     `json:"status" gorm:"default:'idle'"`       // idle, running, success, failed
+	LastRunAt    *time.Time `json:"last_run_at"`
+	NextRunAt    *time.Time `json:"next_run_at" gorm:"index"`
+	HeartbeatAt  *time.Time `json:"heartbeat_at"`  // 运行中任务的心跳，超时视为执行实例已退出
+	LastDuration int64      `json:"last_duration"` // 毫秒
+	LastError    string     `json:"last_error" gorm:"type:text"`
+	RunCount     int        `json:"run_count" gorm:"default:0"`
+	FailCount    int        `json:"fail_count" gorm:"default:0"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// 关联
+	EmbyServer EmbyServer `json:"emby_server,omitempty" gorm:"foreignKey:EmbyServerID"`
+}
+
+// SyncJob 媒体库同步任务模型
+type SyncJob struct {
+	ID                 uint       `json:"id" gorm:"primaryKey"`
+	EmbyServerID       uint       `json:"emby_server_id" gorm:"index"` // 为0表示同步所有在线服务器
+	Scope              string     `json:"scope" gorm:"not null"`       // server, all
+	Full               bool       `json:"full"`                        // 是否强制全量同步
+	Status             string     `json:"status" gorm:"not null;index"` // pending, running, completed, failed, cancelled
+	Progress           float64    `json:"progress"`                    // 百分比 0-100
+	TotalLibraries     int        `json:"total_libraries"`
+	ProcessedLibraries int        `json:"processed_libraries"`
+	CurrentLibrary     string     `json:"current_library"`
+	Errors             StringList `json:"errors" gorm:"type:text"`
+	CreatedBy          uint       `json:"created_by" gorm:"index"` // 发起用户ID，0表示系统任务
+	StartedAt          *time.Time `json:"started_at"`
+	FinishedAt         *time.Time `json:"finished_at"`
+	HeartbeatAt        *time.Time `json:"heartbeat_at"` // 运行实例最后一次心跳时间
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// SyncLease 服务器同步租约，保证同一时间只有一个实例同步某台服务器
+type SyncLease struct {
+	EmbyServerID uint      `json:"emby_server_id" gorm:"primaryKey;autoIncrement:false"`
+	Holder       string    `json:"holder" gorm:"not null"`   // 持有租约的实例标识
+	SyncJobID    uint      `json:"sync_job_id"`              // 关联的同步任务，0表示非任务同步
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index"`  // 到期后可被其他实例接管
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
 // SystemConfig 系统配置模型
 type SystemConfig struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
@@ -187,4 +507,69 @@ type SystemConfig struct {
 	Description string `json:"description"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-}
\ No newline at end of file
+}
+// AlertChannel 告警通知渠道
+type AlertChannel struct {
+	ID         uint            `json:"id" gorm:"primaryKey"`
+	Name       string          `json:"name" gorm:"size:100;not null"`
+	Type       string          `json:"type" gorm:"size:20;not null"` // webhook, slack, email, telegram
+	URL        string          `json:"url"`                          // webhook和slack的地址，telegram的API地址（留空使用官方地址）
+	Secret     EncryptedString `json:"secret_fingerprint"`           // webhook签名密钥或telegram机器人令牌
+	ChatID     string          `json:"chat_id" gorm:"size:100"`      // telegram会话ID
+	Recipients StringList      `json:"recipients" gorm:"type:text"`  // 邮件收件人
+	Enabled    bool            `json:"enabled"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// AlertRule 告警规则，条件持续达到Duration后触发告警
+type AlertRule struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	Name           string    `json:"name" gorm:"size:100;not null"`
+	Event          string    `json:"event" gorm:"size:30;not null;index"` // server_offline, sync_failed, websocket_failed
+	EmbyServerID   *uint     `json:"emby_server_id" gorm:"index"`         // 为空时适用于所有服务器
+	Duration       int       `json:"duration"`                            // 条件持续多少秒后告警，0表示立即告警
+	Severity       string    `json:"severity" gorm:"size:20;not null"`    // info, warning, critical
+	RepeatInterval int       `json:"repeat_interval"`                     // 告警未恢复时重复通知的间隔（秒），0表示不重复
+	NotifyResolved bool      `json:"notify_resolved"`                     // 恢复时是否通知
+	Enabled        bool      `json:"enabled"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	// 关联
+	Channels []AlertChannel `json:"channels" gorm:"many2many:alert_rule_channels;"`
+}
+
+// Alert 告警实例，同一规则和服务器同时只有一个未恢复的告警，条件持续期间的重复事件合并到该告警
+type Alert struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	AlertRuleID    uint       `json:"alert_rule_id" gorm:"not null;index"`
+	EmbyServerID   uint       `json:"emby_server_id" gorm:"index"`
+	Event          string     `json:"event" gorm:"size:30;not null"`
+	Severity       string     `json:"severity" gorm:"size:20"`
+	Status         string     `json:"status" gorm:"size:20;not null;index"` // pending: 等待条件持续达到时长, firing: 已触发, resolved: 已恢复
+	ActiveKey      *string    `json:"-" gorm:"size:40;uniqueIndex"`         // 未恢复时为"规则ID:服务器ID"，恢复后清空，保证同一规则和服务器只有一个未恢复的告警
+	Message        string     `json:"message"`                              // 最近一次事件的详情
+	StartedAt      time.Time  `json:"started_at"`                           // 条件开始的时间
+	FiredAt        *time.Time `json:"fired_at"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+	LastNotifiedAt *time.Time `json:"last_notified_at"` // 已触发但为空表示通知被静默，静默结束后补发
+	NotifyCount    int        `json:"notify_count"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// 关联
+	Rule *AlertRule `json:"rule,omitempty" gorm:"foreignKey:AlertRuleID"`
+}
+
+// AlertSilence 静默窗口，期间匹配的告警照常记录但不发送通知
+type AlertSilence struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	AlertRuleID  *uint     `json:"alert_rule_id" gorm:"index"` // 为空时匹配所有规则
+	EmbyServerID *uint     `json:"emby_server_id"`             // 为空时匹配所有服务器
+	StartsAt     time.Time `json:"starts_at" gorm:"not null"`
+	EndsAt       time.Time `json:"ends_at" gorm:"not null;index"`
+	Reason       string    `json:"reason"`
+	CreatedBy    uint      `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
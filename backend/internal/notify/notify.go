@@ -0,0 +1,305 @@
+// Package notify 告警通知发送，支持通用Webhook、Slack兼容Webhook、邮件和Telegram机器人
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/mail"
+)
+
+// 渠道类型
+const (
+	TypeWebhook  = "webhook"
+	TypeSlack    = "slack"
+	TypeEmail    = "email"
+	TypeTelegram = "telegram"
+)
+
+// 通知状态
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+	StatusTest     = "test"
+)
+
+// DefaultTelegramAPI Telegram机器人API地址，自建的兼容服务可以在渠道中覆盖
+const DefaultTelegramAPI = "https://api.telegram.org"
+
+// Notification 一条告警通知
+type Notification struct {
+	Status     string    `json:"status"` // firing, resolved, test
+	Severity   string    `json:"severity"`
+	Event      string    `json:"event"`
+	Title      string    `json:"title"`
+	Message    string    `json:"message"`
+	RuleID     uint      `json:"rule_id,omitempty"`
+	RuleName   string    `json:"rule_name,omitempty"`
+	ServerID   uint      `json:"server_id,omitempty"`
+	ServerName string    `json:"server_name,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	SentAt     time.Time `json:"sent_at"`
+}
+
+// statusLabels 通知状态在文本中的显示名称
+var statusLabels = map[string]string{
+	StatusFiring:   "告警",
+	StatusResolved: "已恢复",
+	StatusTest:     "测试",
+}
+
+// Subject 通知标题，如 "[告警][critical] 服务器 Home 离线"
+func (n Notification) Subject() string {
+	label := statusLabels[n.Status]
+	if label == "" {
+		label = n.Status
+	}
+	return fmt.Sprintf("[%s][%s] %s", label, n.Severity, n.Title)
+}
+
+// Text 纯文本格式的通知内容
+func (n Notification) Text() string {
+	var b strings.Builder
+	b.WriteString(n.Subject())
+	b.WriteString("\n")
+	if n.ServerName != "" {
+		fmt.Fprintf(&b, "服务器: %s (#%d)\n", n.ServerName, n.ServerID)
+	}
+	if n.RuleName != "" {
+		fmt.Fprintf(&b, "规则: %s\n", n.RuleName)
+	}
+	if !n.StartedAt.IsZero() {
+		fmt.Fprintf(&b, "开始时间: %s\n", n.StartedAt.Format("2006-01-02 15:04:05"))
+	}
+	if n.Message != "" {
+		fmt.Fprintf(&b, "详情: %s\n", n.Message)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Channel 渠道配置
+type Channel struct {
+	Type       string
+	URL        string   // webhook和slack的地址，telegram的API地址（留空使用官方地址）
+	Secret     string   // webhook的签名密钥或telegram的机器人令牌
+	ChatID     string   // telegram会话ID
+	Recipients []string // 邮件收件人
+}
+
+// Sender 通知渠道的发送驱动
+type Sender interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// NewSender 按渠道类型创建发送驱动，配置不完整时返回错误
+func NewSender(ch Channel, client *http.Client) (Sender, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	switch ch.Type {
+	case TypeWebhook:
+		if err := validateURL(ch.URL); err != nil {
+			return nil, err
+		}
+		return &webhookSender{client: client, url: ch.URL, secret: ch.Secret}, nil
+	case TypeSlack:
+		if err := validateURL(ch.URL); err != nil {
+			return nil, err
+		}
+		return &slackSender{client: client, url: ch.URL}, nil
+	case TypeEmail:
+		if len(ch.Recipients) == 0 {
+			return nil, errors.New("缺少邮件收件人")
+		}
+		return &emailSender{recipients: ch.Recipients}, nil
+	case TypeTelegram:
+		api := ch.URL
+		if api == "" {
+			api = DefaultTelegramAPI
+		}
+		if err := validateURL(api); err != nil {
+			return nil, err
+		}
+		if ch.Secret == "" || ch.ChatID == "" {
+			return nil, errors.New("缺少机器人令牌或会话ID")
+		}
+		return &telegramSender{client: client, api: strings.TrimSuffix(api, "/"), token: ch.Secret, chatID: ch.ChatID}, nil
+	default:
+		return nil, fmt.Errorf("不支持的渠道类型: %s", ch.Type)
+	}
+}
+
+// validateURL 只允许http和https地址
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("地址无效: %q", raw)
+	}
+	return nil
+}
+
+// webhookSender 以JSON格式POST完整的通知内容
+// 设置了密钥时在X-Signature请求头中附带 "sha256=" 加请求体的HMAC-SHA256签名
+type webhookSender struct {
+	client *http.Client
+	url    string
+	secret string
+}
+
+func (s *webhookSender) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{}
+	if s.secret != "" {
+		mac := hmac.New(sha256.New, []byte(s.secret))
+		mac.Write(body)
+		headers["X-Signature"] = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	_, err = postJSON(ctx, s.client, s.url, body, headers)
+	return err
+}
+
+// slackSender 发送Slack Incoming Webhook格式的消息，Mattermost、Rocket.Chat等兼容服务同样适用
+type slackSender struct {
+	client *http.Client
+	url    string
+}
+
+// slackColors 按级别显示的颜色
+var slackColors = map[string]string{
+	"info":     "#439FE0",
+	"warning":  "warning",
+	"critical": "danger",
+}
+
+func (s *slackSender) Send(ctx context.Context, n Notification) error {
+	color := slackColors[n.Severity]
+	if n.Status == StatusResolved {
+		color = "good"
+	}
+	payload := map[string]interface{}{
+		"text": n.Subject(),
+		"attachments": []map[string]interface{}{{
+			"color":    color,
+			"text":     n.Text(),
+			"fallback": n.Text(),
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = postJSON(ctx, s.client, s.url, body, nil)
+	return err
+}
+
+// emailSender 通过全局邮件驱动发送纯文本邮件
+type emailSender struct {
+	recipients []string
+}
+
+func (s *emailSender) Send(ctx context.Context, n Notification) error {
+	return mail.Send(ctx, mail.Message{
+		To:      s.recipients,
+		Subject: n.Subject(),
+		Body:    n.Text(),
+	})
+}
+
+// telegramSender 调用Telegram机器人的sendMessage接口
+type telegramSender struct {
+	client *http.Client
+	api    string
+	token  string
+	chatID string
+}
+
+func (s *telegramSender) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"chat_id":                  s.chatID,
+		"text":                     n.Text(),
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return err
+	}
+
+	respBody, err := postJSON(ctx, s.client, s.api+"/bot"+s.token+"/sendMessage", body, nil)
+	var respErr *ResponseError
+	if errors.As(err, &respErr) {
+		respErr.Detail = strings.ReplaceAll(respErr.Detail, s.token, "***")
+		return respErr
+	}
+	if err != nil {
+		return errors.New(strings.ReplaceAll(err.Error(), s.token, "***"))
+	}
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(respBody, &result); err == nil && !result.OK {
+		return &ResponseError{StatusCode: http.StatusOK, Detail: strings.ReplaceAll(result.Description, s.token, "***")}
+	}
+	return nil
+}
+
+// ResponseError 接收方返回了失败响应
+// 错误信息只包含状态码，响应内容可能来自任意地址，只保存在Detail中供服务端日志使用，不能返回给调用方
+type ResponseError struct {
+	StatusCode int
+	Detail     string // 截断后的响应内容
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("请求失败，状态码: %d", e.StatusCode)
+}
+
+// postJSON 发送JSON请求，状态码不是2xx时返回*ResponseError
+// 错误中不包含请求地址，避免地址中的令牌被记录到日志
+func postJSON(ctx context.Context, client *http.Client, target string, body []byte, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.New("创建请求失败")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "EmbyManager/1.0")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail := string(respBody)
+		if len(detail) > 200 {
+			detail = detail[:200]
+		}
+		return nil, &ResponseError{StatusCode: resp.StatusCode, Detail: detail}
+	}
+	return respBody, nil
+}
@@ -0,0 +1,545 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/config"
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/metrics"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/internal/services"
+)
+
+// 任务类型
+const (
+	JobHealthCheck   = "health_check"
+	JobSyncDevices   = "sync_devices"
+	JobSyncLibrary   = "sync_libraries"
+	JobSyncSessions  = "sync_sessions"
+	JobMetricsRollup = "metrics_rollup"
+)
+
+// unlimitedJobs 耗时短且需要按时执行的任务，不占用服务器并发槽位，避免被长时间的媒体库同步阻塞
+var unlimitedJobs = map[string]bool{
+	JobHealthCheck:  true,
+	JobSyncSessions: true,
+}
+
+// 任务状态
+const (
+	StatusIdle    = "idle"
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// heartbeatStaleTicks 运行中任务超过多少个检查周期未更新心跳视为执行实例已退出
+const heartbeatStaleTicks = 3
+
+// jobFunc 任务执行函数
+type jobFunc func(ctx context.Context, serverID uint) error
+
+// Scheduler 后台定时任务调度器，按服务器定期执行健康检查和各类同步
+type Scheduler struct {
+	cfg       config.SchedulerConfig
+	jobs      map[string]jobFunc
+	schedules map[string]string
+
+	// 每个服务器的并发槽位，unlimitedJobs中的任务不占用
+	serverSlots map[uint]chan struct{}
+	// 正在运行的任务ID
+	running map[uint]bool
+	mutex   sync.Mutex
+
+	// 停止时取消正在运行的任务
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// New 创建调度器
+func New(cfg config.SchedulerConfig, syncJobService *services.SyncJobService) *Scheduler {
+	serverService := services.NewServerService()
+	playbackService := services.NewPlaybackService()
+	metricsService := services.NewServerMetricsService()
+
+	if cfg.TickInterval <= 0 {
+		cfg.TickInterval = 10
+	}
+	if cfg.MaxConcurrentPerServer <= 0 {
+		cfg.MaxConcurrentPerServer = 1
+	}
+	if cfg.JobTimeout <= 0 {
+		cfg.JobTimeout = 1800
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Scheduler{
+		cfg: cfg,
+		jobs: map[string]jobFunc{
+			JobHealthCheck: func(ctx context.Context, serverID uint) error {
+				_, err := serverService.TestConnection(ctx, serverID, 0)
+				return err
+			},
+			JobSyncDevices: func(ctx context.Context, serverID uint) error {
+				return serverService.SyncDevices(ctx, serverID)
+			},
+			JobSyncLibrary: func(ctx context.Context, serverID uint) error {
+				return syncJobService.RunServerSync(ctx, serverID, false)
+			},
+			JobSyncSessions: func(ctx context.Context, serverID uint) error {
+				return playbackService.SyncPlaybackSessions(ctx, serverID)
+			},
+			JobMetricsRollup: func(ctx context.Context, serverID uint) error {
+				return metricsService.Rollup(serverID, time.Now())
+			},
+		},
+		schedules: map[string]string{
+			JobHealthCheck:   cfg.HealthCheckSchedule,
+			JobSyncDevices:   cfg.DeviceSyncSchedule,
+			JobSyncLibrary:   cfg.LibrarySyncSchedule,
+			JobSyncSessions:  cfg.SessionSyncSchedule,
+			JobMetricsRollup: cfg.MetricsRollupSchedule,
+		},
+		serverSlots: make(map[uint]chan struct{}),
+		running:     make(map[uint]bool),
+		ctx:         ctx,
+		cancel:      cancel,
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// ParseSchedule 解析调度表达式，返回执行间隔
+// 支持 "@every <duration>"、"@hourly"、"@daily"、"@weekly" 以及直接的时间间隔
+func ParseSchedule(spec string) (time.Duration, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "":
+		return 0, nil
+	case "@hourly":
+		return time.Hour, nil
+	case "@daily", "@midnight":
+		return 24 * time.Hour, nil
+	case "@weekly":
+		return 7 * 24 * time.Hour, nil
+	}
+
+	spec = strings.TrimSpace(strings.TrimPrefix(spec, "@every"))
+	interval, err := time.ParseDuration(spec)
+	if err != nil {
+		return 0, fmt.Errorf("无效的调度表达式 %q: %w", spec, err)
+	}
+	if interval < time.Second {
+		return 0, fmt.Errorf("调度间隔不能小于1秒: %s", spec)
+	}
+	return interval, nil
+}
+
+// Start 启动调度器
+func (s *Scheduler) Start() {
+	s.wg.Add(1)
+	go s.loop()
+
+	log.Printf("后台任务调度器已启动，检查周期 %ds", s.cfg.TickInterval)
+}
+
+// Stop 停止调度器，取消并等待正在运行的任务结束
+func (s *Scheduler) Stop() {
+	close(s.stopChan)
+	s.cancel()
+	s.wg.Wait()
+	log.Println("后台任务调度器已停止")
+}
+
+// loop 调度主循环
+func (s *Scheduler) loop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Duration(s.cfg.TickInterval) * time.Second)
+	defer ticker.Stop()
+
+	s.tick()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.tick()
+		}
+	}
+}
+
+// tick 同步任务记录并执行到期任务
+func (s *Scheduler) tick() {
+	if err := s.ensureJobs(); err != nil {
+		log.Printf("同步定时任务失败: %v", err)
+		return
+	}
+
+	s.resetStaleJobs()
+
+	var due []models.ScheduledJob
+	if err := database.DB.Where("paused = ? AND status <> ? AND next_run_at <= ?", false, StatusRunning, time.Now()).
+		Order("next_run_at").
+		Find(&due).Error; err != nil {
+		log.Printf("查询到期任务失败: %v", err)
+		return
+	}
+
+	for _, job := range due {
+		// 并发已满、任务已在运行或已被其他实例抢占时跳过，等待下一个周期
+		s.dispatch(job, true)
+	}
+}
+
+// resetStaleJobs 将心跳超时的运行中任务恢复为空闲，执行实例异常退出后任务可被重新调度
+func (s *Scheduler) resetStaleJobs() {
+	staleBefore := time.Now().Add(-time.Duration(heartbeatStaleTicks*s.cfg.TickInterval) * time.Second)
+	result := database.DB.Model(&models.ScheduledJob{}).
+		Where("status = ? AND (heartbeat_at IS NULL OR heartbeat_at < ?)", StatusRunning, staleBefore).
+		Update("status", StatusIdle)
+	if result.Error != nil {
+		log.Printf("恢复超时任务失败: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("已恢复 %d 个心跳超时的定时任务", result.RowsAffected)
+	}
+}
+
+// claim 通过条件更新抢占任务，多实例部署时保证同一任务只由一个实例执行
+// scheduled为true时要求任务仍处于到期状态，手动触发时只要求任务未在运行
+func (s *Scheduler) claim(job *models.ScheduledJob, scheduled bool) bool {
+	now := time.Now()
+	query := database.DB.Model(&models.ScheduledJob{}).
+		Where("id = ? AND status <> ?", job.ID, StatusRunning)
+	if scheduled {
+		query = query.Where("paused = ? AND next_run_at <= ?", false, now)
+	}
+	result := query.Updates(map[string]interface{}{
+		"status":       StatusRunning,
+		"last_run_at":  &now,
+		"heartbeat_at": &now,
+	})
+	if result.Error != nil {
+		log.Printf("抢占定时任务 %d 失败: %v", job.ID, result.Error)
+		return false
+	}
+	return result.RowsAffected == 1
+}
+
+// ensureJobs 为每个服务器创建缺失的任务记录，并清理已删除服务器的任务
+func (s *Scheduler) ensureJobs() error {
+	var servers []models.EmbyServer
+	if err := database.DB.Select("id").Find(&servers).Error; err != nil {
+		return fmt.Errorf("查询服务器失败: %w", err)
+	}
+
+	serverIDs := make([]uint, 0, len(servers))
+	for _, server := range servers {
+		serverIDs = append(serverIDs, server.ID)
+	}
+
+	// 清理已删除服务器的任务
+	cleanup := database.DB.Model(&models.ScheduledJob{})
+	if len(serverIDs) > 0 {
+		cleanup = cleanup.Where("emby_server_id NOT IN ?", serverIDs)
+	} else {
+		cleanup = cleanup.Where("1 = 1")
+	}
+	if err := cleanup.Delete(&models.ScheduledJob{}).Error; err != nil {
+		return fmt.Errorf("清理定时任务失败: %w", err)
+	}
+
+	var existing []models.ScheduledJob
+	if err := database.DB.Find(&existing).Error; err != nil {
+		return fmt.Errorf("查询定时任务失败: %w", err)
+	}
+	existingJobs := make(map[string]models.ScheduledJob, len(existing))
+	for _, job := range existing {
+		existingJobs[jobKey(job.EmbyServerID, job.JobType)] = job
+	}
+
+	now := time.Now()
+	for jobType, spec := range s.schedules {
+		interval, err := ParseSchedule(spec)
+		if err != nil {
+			return err
+		}
+
+		for _, serverID := range serverIDs {
+			job, exists := existingJobs[jobKey(serverID, jobType)]
+			if !exists {
+				if interval == 0 {
+					continue
+				}
+				// 首次运行时间在一个间隔内随机分布，避免所有服务器同时执行
+				next := now.Add(time.Duration(rand.Int63n(int64(interval))))
+				job = models.ScheduledJob{
+					EmbyServerID: serverID,
+					JobType:      jobType,
+					Schedule:     spec,
+					Interval:     int64(interval / time.Second),
+					Status:       StatusIdle,
+					NextRunAt:    &next,
+				}
+				if err := database.DB.Create(&job).Error; err != nil {
+					return fmt.Errorf("创建定时任务失败: %w", err)
+				}
+				continue
+			}
+
+			// 配置变更后同步调度表达式，禁用的任务自动暂停，重新启用后恢复因配置而暂停的任务
+			// 用户手动暂停的任务保持暂停
+			if job.Schedule != spec {
+				updates := map[string]interface{}{
+					"schedule": spec,
+					"interval": int64(interval / time.Second),
+				}
+				if interval == 0 {
+					if !job.Paused {
+						updates["paused"] = true
+						updates["config_paused"] = true
+					}
+				} else {
+					if job.ConfigPaused {
+						updates["paused"] = false
+						updates["config_paused"] = false
+					}
+					next := s.nextRun(now, interval)
+					updates["next_run_at"] = &next
+				}
+				if err := database.DB.Model(&job).Updates(updates).Error; err != nil {
+					return fmt.Errorf("更新定时任务失败: %w", err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// dispatch 在服务器并发槽位可用且抢占成功时异步执行任务
+func (s *Scheduler) dispatch(job models.ScheduledJob, scheduled bool) error {
+	run, ok := s.jobs[job.JobType]
+	if !ok {
+		return fmt.Errorf("未知的任务类型: %s", job.JobType)
+	}
+
+	select {
+	case <-s.stopChan:
+		return fmt.Errorf("调度器已停止")
+	default:
+	}
+
+	s.mutex.Lock()
+	if s.running[job.ID] {
+		s.mutex.Unlock()
+		return fmt.Errorf("任务 %d 正在运行", job.ID)
+	}
+	var slots chan struct{}
+	if !unlimitedJobs[job.JobType] {
+		var exists bool
+		slots, exists = s.serverSlots[job.EmbyServerID]
+		if !exists {
+			slots = make(chan struct{}, s.cfg.MaxConcurrentPerServer)
+			s.serverSlots[job.EmbyServerID] = slots
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			s.mutex.Unlock()
+			return fmt.Errorf("服务器 %d 的任务并发已达上限", job.EmbyServerID)
+		}
+	}
+	s.running[job.ID] = true
+	s.mutex.Unlock()
+
+	release := func() {
+		s.mutex.Lock()
+		delete(s.running, job.ID)
+		s.mutex.Unlock()
+		if slots != nil {
+			<-slots
+		}
+	}
+
+	if !s.claim(&job, scheduled) {
+		release()
+		return fmt.Errorf("任务 %d 正在运行", job.ID)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer release()
+
+		s.execute(job, run)
+	}()
+
+	return nil
+}
+
+// execute 执行已抢占的任务并持久化运行结果
+func (s *Scheduler) execute(job models.ScheduledJob, run jobFunc) {
+	started := time.Now()
+
+	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.JobTimeout)*time.Second)
+	defer cancel()
+
+	stopHeartbeat := s.heartbeat(job.ID)
+	err := s.safeRun(ctx, job.EmbyServerID, run)
+	stopHeartbeat()
+
+	finished := time.Now()
+	next := s.nextRun(finished, time.Duration(job.Interval)*time.Second)
+	status := StatusSuccess
+	if err != nil {
+		status = StatusFailed
+	}
+	updates := map[string]interface{}{
+		"status":        status,
+		"last_duration": finished.Sub(started).Milliseconds(),
+		"last_error":    "",
+		"next_run_at":   &next,
+		"heartbeat_at":  nil,
+		"run_count":     job.RunCount + 1,
+	}
+	if err != nil {
+		updates["last_error"] = err.Error()
+		updates["fail_count"] = job.FailCount + 1
+		log.Printf("定时任务 %s (服务器 %d) 执行失败: %v", job.JobType, job.EmbyServerID, err)
+	}
+	metrics.ObserveScheduledJob(job.JobType, status, finished.Sub(started))
+
+	if err := database.DB.Model(&job).Updates(updates).Error; err != nil {
+		log.Printf("保存定时任务 %d 运行结果失败: %v", job.ID, err)
+	}
+}
+
+// heartbeat 在任务运行期间定期更新心跳，返回停止函数
+func (s *Scheduler) heartbeat(jobID uint) func() {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(time.Duration(s.cfg.TickInterval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				database.DB.Model(&models.ScheduledJob{}).
+					Where("id = ? AND status = ?", jobID, StatusRunning).
+					Update("heartbeat_at", &now)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// safeRun 执行任务并捕获panic
+func (s *Scheduler) safeRun(ctx context.Context, serverID uint, run jobFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("任务异常: %v", r)
+		}
+	}()
+	return run(ctx, serverID)
+}
+
+// nextRun 计算带随机抖动的下次运行时间
+func (s *Scheduler) nextRun(from time.Time, interval time.Duration) time.Time {
+	if interval <= 0 {
+		interval = time.Duration(s.cfg.TickInterval) * time.Second
+	}
+	next := from.Add(interval)
+	if s.cfg.Jitter > 0 {
+		maxJitter := int64(float64(interval) * s.cfg.Jitter)
+		if maxJitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(maxJitter)))
+		}
+	}
+	return next
+}
+
+// ListJobs 获取任务列表，serverID为0时返回全部
+func (s *Scheduler) ListJobs(serverID uint) ([]models.ScheduledJob, error) {
+	query := database.DB.Model(&models.ScheduledJob{}).Preload("EmbyServer")
+	if serverID != 0 {
+		query = query.Where("emby_server_id = ?", serverID)
+	}
+
+	var jobs []models.ScheduledJob
+	if err := query.Order("emby_server_id, job_type").Find(&jobs).Error; err != nil {
+		return nil, fmt.Errorf("查询定时任务失败: %w", err)
+	}
+	return jobs, nil
+}
+
+// PauseJob 暂停任务
+func (s *Scheduler) PauseJob(id uint) error {
+	job, err := s.getJob(id)
+	if err != nil {
+		return err
+	}
+	// 用户手动暂停后即使配置重新启用也保持暂停
+	return database.DB.Model(job).Updates(map[string]interface{}{
+		"paused":        true,
+		"config_paused": false,
+	}).Error
+}
+
+// ResumeJob 恢复任务
+func (s *Scheduler) ResumeJob(id uint) error {
+	job, err := s.getJob(id)
+	if err != nil {
+		return err
+	}
+	if job.Interval <= 0 {
+		return fmt.Errorf("任务 %s 已在配置中禁用", job.JobType)
+	}
+
+	next := s.nextRun(time.Now(), time.Duration(job.Interval)*time.Second)
+	return database.DB.Model(job).Updates(map[string]interface{}{
+		"paused":        false,
+		"config_paused": false,
+		"next_run_at":   &next,
+	}).Error
+}
+
+// TriggerJob 立即执行任务
+func (s *Scheduler) TriggerJob(id uint) error {
+	job, err := s.getJob(id)
+	if err != nil {
+		return err
+	}
+	return s.dispatch(*job, false)
+}
+
+// getJob 获取任务记录
+func (s *Scheduler) getJob(id uint) (*models.ScheduledJob, error) {
+	var job models.ScheduledJob
+	if err := database.DB.First(&job, id).Error; err != nil {
+		return nil, fmt.Errorf("任务不存在: %w", err)
+	}
+	return &job, nil
+}
+
+// jobKey 任务唯一键
+func jobKey(serverID uint, jobType string) string {
+	return fmt.Sprintf("%d:%s", serverID, jobType)
+}
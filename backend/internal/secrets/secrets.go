@@ -0,0 +1,313 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// 密文格式: enc:v1:<密钥ID>:<被主密钥加密的数据密钥>:<被数据密钥加密的明文>
+const (
+	encryptedPrefix = "enc:v1:"
+	dataKeySize     = 32
+)
+
+// DefaultKeyID 未配置主密钥时由JWT密钥派生的默认主密钥ID
+const DefaultKeyID = "default"
+
+var (
+	// ErrNotInitialized 密钥环未初始化
+	ErrNotInitialized = errors.New("加密密钥未初始化")
+	// ErrUnknownKey 密文使用的主密钥不在密钥环中
+	ErrUnknownKey = errors.New("未知的加密密钥")
+)
+
+// Keyring 主密钥环，使用当前主密钥加密，可用任一已知主密钥解密
+type Keyring struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+var (
+	keyring *Keyring
+	mutex   sync.RWMutex
+)
+
+// NewKeyring 创建密钥环，keys中必须包含currentID对应的主密钥
+func NewKeyring(currentID string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[currentID]; !ok {
+		return nil, fmt.Errorf("缺少当前加密密钥: %s", currentID)
+	}
+	for id, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("加密密钥 %s 长度必须为32字节", id)
+		}
+		if strings.Contains(id, ":") {
+			return nil, fmt.Errorf("加密密钥ID不能包含冒号: %s", id)
+		}
+	}
+	return &Keyring{currentID: currentID, keys: keys}, nil
+}
+
+// Init 初始化全局密钥环
+// key为base64编码的32字节主密钥，previous为"ID:base64密钥"逗号分隔的历史密钥列表；
+// key为空时使用由fallbackSecret派生的默认主密钥；默认主密钥始终在密钥环中，用于解密配置key之前加密的数据
+func Init(keyID, key, previous, fallbackSecret string) error {
+	keys := map[string][]byte{
+		DefaultKeyID: DeriveKey(fallbackSecret),
+	}
+
+	currentID := DefaultKeyID
+	if key != "" {
+		decoded, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return fmt.Errorf("解析加密密钥失败: %w", err)
+		}
+		if keyID == "" {
+			keyID = "primary"
+		}
+		keys[keyID] = decoded
+		currentID = keyID
+	}
+
+	for _, entry := range strings.Split(previous, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return fmt.Errorf("历史加密密钥格式应为 ID:base64密钥")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("解析历史加密密钥 %s 失败: %w", id, err)
+		}
+		if _, exists := keys[id]; !exists {
+			keys[id] = decoded
+		}
+	}
+
+	kr, err := NewKeyring(currentID, keys)
+	if err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	keyring = kr
+	mutex.Unlock()
+	return nil
+}
+
+// DeriveKey 从任意字符串派生32字节主密钥
+func DeriveKey(secret string) []byte {
+	sum := sha256.Sum256([]byte("emby-manager-encryption:" + secret))
+	return sum[:]
+}
+
+// current 获取全局密钥环
+func current() (*Keyring, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	if keyring == nil {
+		return nil, ErrNotInitialized
+	}
+	return keyring, nil
+}
+
+// CurrentKeyID 当前主密钥ID
+func CurrentKeyID() (string, error) {
+	kr, err := current()
+	if err != nil {
+		return "", err
+	}
+	return kr.currentID, nil
+}
+
+// Encrypt 使用全局密钥环加密
+func Encrypt(plaintext string) (string, error) {
+	kr, err := current()
+	if err != nil {
+		return "", err
+	}
+	return kr.Encrypt(plaintext)
+}
+
+// Decrypt 使用全局密钥环解密，非密文格式的值原样返回
+func Decrypt(value string) (string, error) {
+	kr, err := current()
+	if err != nil {
+		return "", err
+	}
+	return kr.Decrypt(value)
+}
+
+// Rewrap 使用当前主密钥重新加密数据密钥，明文值会被加密
+// 返回值已由当前主密钥加密时changed为false
+func Rewrap(value string) (result string, changed bool, err error) {
+	kr, err := current()
+	if err != nil {
+		return "", false, err
+	}
+	return kr.Rewrap(value)
+}
+
+// IsEncrypted 判断值是否为密文格式
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// KeyIDOf 获取密文使用的主密钥ID
+func KeyIDOf(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	return id
+}
+
+// Fingerprint 生成明文的掩码指纹，可用于比对而不暴露原值
+func Fingerprint(plaintext string) string {
+	if plaintext == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(plaintext))
+	return "****" + hex.EncodeToString(sum[:4])
+}
+
+// Encrypt 生成随机数据密钥加密明文，再用当前主密钥加密数据密钥
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", fmt.Errorf("生成数据密钥失败: %w", err)
+	}
+
+	ciphertext, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return k.wrap(dataKey, ciphertext)
+}
+
+// Decrypt 解密密文，非密文格式的值视为尚未迁移的明文原样返回
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	dataKey, ciphertext, err := k.unwrap(value)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(dataKey, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("解密失败: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Rewrap 使用当前主密钥重新加密数据密钥，数据密文保持不变
+func (k *Keyring) Rewrap(value string) (string, bool, error) {
+	if !IsEncrypted(value) {
+		encrypted, err := k.Encrypt(value)
+		return encrypted, err == nil, err
+	}
+	if KeyIDOf(value) == k.currentID {
+		return value, false, nil
+	}
+
+	dataKey, ciphertext, err := k.unwrap(value)
+	if err != nil {
+		return "", false, err
+	}
+
+	rewrapped, err := k.wrap(dataKey, ciphertext)
+	return rewrapped, err == nil, err
+}
+
+// wrap 用当前主密钥加密数据密钥并拼接密文
+func (k *Keyring) wrap(dataKey, ciphertext []byte) (string, error) {
+	wrappedKey, err := seal(k.keys[k.currentID], dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	return encryptedPrefix + k.currentID + ":" +
+		base64.StdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// unwrap 解析密文并解密出数据密钥
+func (k *Keyring) unwrap(value string) ([]byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return nil, nil, fmt.Errorf("密文格式错误")
+	}
+
+	masterKey, ok := k.keys[parts[0]]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnknownKey, parts[0])
+	}
+
+	wrappedKey, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("密文格式错误: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, fmt.Errorf("密文格式错误: %w", err)
+	}
+
+	dataKey, err := open(masterKey, wrappedKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解密数据密钥失败: %w", err)
+	}
+	return dataKey, ciphertext, nil
+}
+
+// seal AES-GCM加密，随机nonce置于密文前
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("生成随机数失败: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open AES-GCM解密
+func open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("密文长度不足")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+// newGCM 创建AES-GCM实例
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建加密器失败: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/config"
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/mail"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// 账户链接令牌的用途
+const (
+	AccountPurposePasswordReset = "password_reset"
+	AccountPurposeVerifyEmail   = "verify_email"
+)
+
+// accountTokenAudience 账户链接令牌的受众，与访问令牌区分
+const accountTokenAudience = "account"
+
+// AuditActionUserRecoverPassword 用户通过邮件链接重置密码
+const AuditActionUserRecoverPassword = "user.recover_password"
+
+var (
+	// ErrAccountTokenInvalid 链接令牌无效、已过期或已使用
+	ErrAccountTokenInvalid = errors.New("链接无效或已过期")
+	// ErrEmailNotVerified 要求邮箱验证时，未验证的用户不能登录
+	ErrEmailNotVerified = errors.New("邮箱尚未验证，请先点击验证邮件中的链接")
+)
+
+// accountClaims 密码重置和邮箱验证链接中的签名令牌
+// Fingerprint绑定签发时的密码摘要或邮箱，密码修改或邮箱变更后旧链接自动失效
+type accountClaims struct {
+	Purpose     string `json:"purpose"`
+	Fingerprint string `json:"fp"`
+	jwt.RegisteredClaims
+}
+
+// AccountService 账户找回和邮箱验证服务
+type AccountService struct {
+	sessionService *SessionService
+	auditService   *AuditService
+	policyService  *SecurityPolicyService
+}
+
+// NewAccountService 创建账户找回和邮箱验证服务
+func NewAccountService() *AccountService {
+	return &AccountService{
+		sessionService: NewSessionService(),
+		auditService:   NewAuditService(),
+		policyService:  NewSecurityPolicyService(),
+	}
+}
+
+// SendVerificationEmail 发送邮箱验证邮件，邮件在后台发送
+func (s *AccountService) SendVerificationEmail(user *models.User) error {
+	ttl := time.Duration(config.AppConfig.Account.EmailVerificationTTL) * time.Hour
+	token, err := s.issueToken(user, AccountPurposeVerifyEmail, ttl)
+	if err != nil {
+		return err
+	}
+
+	s.deliver(mail.Message{
+		To:      []string{user.Email},
+		Subject: "验证您的邮箱",
+		Body: fmt.Sprintf("%s，您好：\n\n请点击以下链接验证您的邮箱地址，链接 %d 小时内有效：\n\n%s\n\n如果您没有注册过账户，请忽略此邮件。\n",
+			displayName(user), config.AppConfig.Account.EmailVerificationTTL, accountLink("verify_token", token)),
+	})
+	return nil
+}
+
+// ResendVerification 按用户名或邮箱重新发送验证邮件
+// 用户不存在或已验证时同样返回成功，避免泄露账户是否存在
+func (s *AccountService) ResendVerification(account string) error {
+	var user models.User
+	err := database.DB.Where("username = ? OR email = ?", account, account).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("查询用户失败: %w", err)
+	}
+	if user.EmailVerified || user.AuthSource == AuthSourceLDAP || user.Status == "inactive" {
+		return nil
+	}
+	return s.SendVerificationEmail(&user)
+}
+
+// VerifyEmail 校验邮箱验证链接并标记邮箱已验证
+func (s *AccountService) VerifyEmail(ctx context.Context, token string) (*models.User, error) {
+	user, claims, err := s.parseToken(ctx, token, AccountPurposeVerifyEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Model(user).Update("email_verified", true).Error; err != nil {
+		return nil, fmt.Errorf("更新用户失败: %w", err)
+	}
+	s.consumeToken(ctx, claims)
+	return user, nil
+}
+
+// RequestPasswordReset 发送密码重置邮件
+// 邮箱不存在、账户已禁用或为目录用户时不发送，但同样返回成功，避免泄露账户是否存在
+func (s *AccountService) RequestPasswordReset(email string) error {
+	var user models.User
+	err := database.DB.Where("email = ?", email).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("查询用户失败: %w", err)
+	}
+	if user.AuthSource == AuthSourceLDAP || user.Status == "inactive" {
+		log.Printf("用户 %s 请求重置密码被忽略（认证来源 %s，状态 %s）", user.Username, user.AuthSource, user.Status)
+		return nil
+	}
+
+	ttl := time.Duration(config.AppConfig.Account.PasswordResetTTL) * time.Minute
+	token, err := s.issueToken(&user, AccountPurposePasswordReset, ttl)
+	if err != nil {
+		return err
+	}
+
+	s.deliver(mail.Message{
+		To:      []string{user.Email},
+		Subject: "重置密码",
+		Body: fmt.Sprintf("%s，您好：\n\n我们收到了重置您账户密码的请求，请点击以下链接设置新密码，链接 %d 分钟内有效且只能使用一次：\n\n%s\n\n如果这不是您本人的操作，请忽略此邮件，您的密码不会改变。\n",
+			displayName(&user), config.AppConfig.Account.PasswordResetTTL, accountLink("reset_token", token)),
+	})
+	return nil
+}
+
+// ResetPassword 校验密码重置链接并设置新密码，同时解除锁定并吊销全部会话
+func (s *AccountService) ResetPassword(ctx context.Context, actor AuditActor, token, password string) error {
+	user, claims, err := s.parseToken(ctx, token, AccountPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	if user.AuthSource == AuthSourceLDAP {
+		return ErrDirectoryManagedUser
+	}
+	if user.Status == "inactive" {
+		return fmt.Errorf("用户已被禁用")
+	}
+
+	hashedPassword, err := s.policyService.HashPassword(user, password)
+	if err != nil {
+		return err
+	}
+
+	// 能收到重置邮件说明邮箱可用，同时视为完成邮箱验证
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"password":           hashedPassword,
+		"email_verified":     true,
+		"status":             "active",
+		"failed_login_count": 0,
+		"lockout_count":      0,
+		"locked_until":       nil,
+	}).Error; err != nil {
+		return fmt.Errorf("重置密码失败: %v", err)
+	}
+	s.consumeToken(ctx, claims)
+	s.policyService.RecordPassword(user.ID, hashedPassword)
+
+	if _, err := s.sessionService.RevokeAllSessions(ctx, user.ID, ""); err != nil {
+		return fmt.Errorf("密码已重置，但吊销会话失败: %v", err)
+	}
+
+	actor.UserID = user.ID
+	actor.Username = user.Username
+	s.auditService.Record(actor, AuditActionUserRecoverPassword, AuditTargetUser, user.ID, nil, nil)
+	return nil
+}
+
+// issueToken 签发账户链接令牌
+func (s *AccountService) issueToken(user *models.User, purpose string, ttl time.Duration) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &accountClaims{
+		Purpose:     purpose,
+		Fingerprint: accountFingerprint(user, purpose),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			Audience:  jwt.ClaimStrings{accountTokenAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    config.AppConfig.JWT.Issuer,
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(config.AppConfig.JWT.Secret))
+}
+
+// parseToken 校验令牌签名、用途、有效期和指纹，返回令牌对应的用户
+func (s *AccountService) parseToken(ctx context.Context, tokenString, purpose string) (*models.User, *accountClaims, error) {
+	claims := &accountClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(config.AppConfig.JWT.Secret), nil
+	}, jwt.WithAudience(accountTokenAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid || claims.Purpose != purpose {
+		return nil, nil, ErrAccountTokenInvalid
+	}
+
+	revoked, err := s.sessionService.IsTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked {
+		return nil, nil, ErrAccountTokenInvalid
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 32)
+	if err != nil {
+		return nil, nil, ErrAccountTokenInvalid
+	}
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, nil, ErrAccountTokenInvalid
+	}
+	if claims.Fingerprint != accountFingerprint(&user, purpose) {
+		return nil, nil, ErrAccountTokenInvalid
+	}
+	return &user, claims, nil
+}
+
+// consumeToken 把令牌加入吊销列表，保证链接只能使用一次
+func (s *AccountService) consumeToken(ctx context.Context, claims *accountClaims) {
+	if err := s.sessionService.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		log.Printf("吊销账户链接令牌失败: %v", err)
+	}
+}
+
+// deliver 在后台发送邮件，发送耗时不影响响应，也不会暴露账户是否存在
+func (s *AccountService) deliver(msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := mail.Send(ctx, msg); err != nil {
+			log.Printf("发送邮件 %q 到 %s 失败: %v", msg.Subject, strings.Join(msg.To, ", "), err)
+		}
+	}()
+}
+
+// accountFingerprint 令牌绑定的用户状态：重置密码绑定当前密码摘要，验证邮箱绑定邮箱地址
+func accountFingerprint(user *models.User, purpose string) string {
+	value := strings.ToLower(user.Email)
+	if purpose == AccountPurposePasswordReset {
+		value = user.Password
+	}
+	return hashToken(purpose + ":" + value)[:32]
+}
+
+// accountLink 生成邮件中的前端链接，令牌放在URL片段中，不会出现在服务器访问日志里
+func accountLink(param, token string) string {
+	return strings.TrimRight(config.AppConfig.Account.FrontendURL, "#") + "#" + param + "=" + token
+}
+
+// displayName 邮件中的称呼
+func displayName(user *models.User) string {
+	if user.Nickname != "" {
+		return user.Nickname
+	}
+	return user.Username
+}
@@ -0,0 +1,731 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/config"
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/dto"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/internal/notify"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 告警事件
+const (
+	AlertEventServerOffline   = "server_offline"   // 健康检查或API请求失败，服务器恢复在线时恢复
+	AlertEventSyncFailed      = "sync_failed"      // 媒体库同步失败，下次同步成功时恢复
+	AlertEventWebSocketFailed = "websocket_failed" // WebSocket连接失败，重新连接成功时恢复
+)
+
+// 告警级别
+const (
+	AlertSeverityInfo     = "info"
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+)
+
+// 告警状态
+const (
+	AlertStatusPending  = "pending"
+	AlertStatusFiring   = "firing"
+	AlertStatusResolved = "resolved"
+)
+
+var (
+	// ErrAlertRuleNotFound 告警规则不存在
+	ErrAlertRuleNotFound = errors.New("告警规则不存在")
+	// ErrAlertChannelNotFound 通知渠道不存在
+	ErrAlertChannelNotFound = errors.New("通知渠道不存在")
+	// ErrAlertSilenceNotFound 静默不存在
+	ErrAlertSilenceNotFound = errors.New("静默不存在")
+)
+
+// activeAlertStatuses 未恢复的告警状态
+var activeAlertStatuses = []string{AlertStatusPending, AlertStatusFiring}
+
+// alertEventTitles 通知标题，参数为服务器名称
+var alertEventTitles = map[string]string{
+	AlertEventServerOffline:   "服务器 %s 离线",
+	AlertEventSyncFailed:      "服务器 %s 同步失败",
+	AlertEventWebSocketFailed: "服务器 %s WebSocket连接失败",
+}
+
+// alertMutex 串行化本实例内的告警状态变更
+// 多副本部署时由active_key唯一索引保证同一规则和服务器只有一个未恢复的告警，触发和通知通过条件更新认领
+var alertMutex sync.Mutex
+
+// AlertService 告警服务
+type AlertService struct{}
+
+// NewAlertService 创建告警服务
+func NewAlertService() *AlertService {
+	return &AlertService{}
+}
+
+// raiseAlert 告警条件成立时调用，失败只记录日志不影响调用方
+func raiseAlert(event string, serverID uint, message string) {
+	if err := NewAlertService().Raise(event, serverID, message, time.Now()); err != nil {
+		log.Printf("处理服务器 %d 的告警事件 %s 失败: %v", serverID, event, err)
+	}
+}
+
+// resolveAlert 告警条件解除时调用，失败只记录日志不影响调用方
+func resolveAlert(event string, serverID uint) {
+	if err := NewAlertService().Resolve(event, serverID, time.Now()); err != nil {
+		log.Printf("恢复服务器 %d 的告警 %s 失败: %v", serverID, event, err)
+	}
+}
+
+// Raise 告警条件成立，为每个匹配的启用规则创建待触发的告警，持续时长为0的规则立即触发
+// 已有未恢复的告警时只更新详情，不重复通知
+func (s *AlertService) Raise(event string, serverID uint, message string, now time.Time) error {
+	alertMutex.Lock()
+	defer alertMutex.Unlock()
+
+	var rules []models.AlertRule
+	if err := database.DB.Preload("Channels").
+		Where("enabled = ? AND event = ? AND (emby_server_id IS NULL OR emby_server_id = ?)", true, event, serverID).
+		Find(&rules).Error; err != nil {
+		return fmt.Errorf("查询告警规则失败: %w", err)
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+
+		var alert models.Alert
+		result := database.DB.Where("alert_rule_id = ? AND emby_server_id = ? AND status IN ?", rule.ID, serverID, activeAlertStatuses).
+			Limit(1).Find(&alert)
+		if result.Error != nil {
+			return fmt.Errorf("查询告警失败: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			if alert.Message != message {
+				database.DB.Model(&alert).Update("message", message)
+			}
+			continue
+		}
+
+		activeKey := alertActiveKey(rule.ID, serverID)
+		alert = models.Alert{
+			AlertRuleID:  rule.ID,
+			EmbyServerID: serverID,
+			Event:        event,
+			Severity:     rule.Severity,
+			Status:       AlertStatusPending,
+			ActiveKey:    &activeKey,
+			Message:      message,
+			StartedAt:    now,
+		}
+		result = database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&alert)
+		if result.Error != nil {
+			return fmt.Errorf("创建告警失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			// 其他实例已创建未恢复的告警
+			continue
+		}
+		if rule.Duration == 0 {
+			if err := s.fire(rule, &alert, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Resolve 告警条件解除，尚未触发的告警直接删除，已触发的告警标记为恢复并按规则发送恢复通知
+func (s *AlertService) Resolve(event string, serverID uint, now time.Time) error {
+	alertMutex.Lock()
+	defer alertMutex.Unlock()
+
+	var alerts []models.Alert
+	if err := database.DB.Preload("Rule.Channels").
+		Where("event = ? AND emby_server_id = ? AND status IN ?", event, serverID, activeAlertStatuses).
+		Find(&alerts).Error; err != nil {
+		return fmt.Errorf("查询告警失败: %w", err)
+	}
+
+	for i := range alerts {
+		if err := s.resolve(&alerts[i], now, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Evaluate 触发持续时长已到的告警，补发静默结束后的通知，并按规则重复通知
+func (s *AlertService) Evaluate(now time.Time) error {
+	alertMutex.Lock()
+	defer alertMutex.Unlock()
+
+	var alerts []models.Alert
+	if err := database.DB.Preload("Rule.Channels").
+		Where("status IN ?", activeAlertStatuses).
+		Find(&alerts).Error; err != nil {
+		return fmt.Errorf("查询告警失败: %w", err)
+	}
+
+	for i := range alerts {
+		alert := &alerts[i]
+		rule := alert.Rule
+		if rule == nil || !rule.Enabled {
+			continue
+		}
+
+		var err error
+		switch {
+		case alert.Status == AlertStatusPending:
+			if now.Sub(alert.StartedAt) >= time.Duration(rule.Duration)*time.Second {
+				err = s.fire(rule, alert, now)
+			}
+		case alert.LastNotifiedAt == nil:
+			// 触发时处于静默期间
+			err = s.notifyFiring(rule, alert, now)
+		case rule.RepeatInterval > 0 && now.Sub(*alert.LastNotifiedAt) >= time.Duration(rule.RepeatInterval)*time.Second:
+			err = s.notifyFiring(rule, alert, now)
+		}
+		if err != nil {
+			log.Printf("评估告警 %d 失败: %v", alert.ID, err)
+		}
+	}
+	return nil
+}
+
+// StartEvaluator 启动定期评估，返回停止函数
+func (s *AlertService) StartEvaluator(interval time.Duration) func() {
+	stopChan := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopChan:
+				return
+			case now := <-ticker.C:
+				if err := s.Evaluate(now); err != nil {
+					log.Printf("告警评估失败: %v", err)
+				}
+			}
+		}
+	}()
+
+	log.Printf("告警评估已启动，间隔 %s", interval)
+	return func() { close(stopChan) }
+}
+
+// fire 触发待触发的告警并发送通知
+func (s *AlertService) fire(rule *models.AlertRule, alert *models.Alert, now time.Time) error {
+	result := database.DB.Model(&models.Alert{}).
+		Where("id = ? AND status = ?", alert.ID, AlertStatusPending).
+		Updates(map[string]interface{}{
+			"status":   AlertStatusFiring,
+			"fired_at": &now,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("触发告警失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	alert.Status = AlertStatusFiring
+	alert.FiredAt = &now
+	return s.notifyFiring(rule, alert, now)
+}
+
+// notifyFiring 发送告警通知，静默期间不发送，静默结束后由Evaluate补发
+func (s *AlertService) notifyFiring(rule *models.AlertRule, alert *models.Alert, now time.Time) error {
+	silenced, err := s.silenced(rule.ID, alert.EmbyServerID, now)
+	if err != nil || silenced {
+		return err
+	}
+
+	// 按通知次数认领本次通知，多副本部署时只有一个实例发送
+	result := database.DB.Model(&models.Alert{}).
+		Where("id = ? AND notify_count = ?", alert.ID, alert.NotifyCount).
+		Updates(map[string]interface{}{
+			"last_notified_at": &now,
+			"notify_count":     alert.NotifyCount + 1,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("更新告警失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	alert.LastNotifiedAt = &now
+	alert.NotifyCount++
+	s.dispatch(rule, alert, notify.StatusFiring)
+	return nil
+}
+
+// resolve 结束未恢复的告警，notifyResolved为false时不发送恢复通知
+func (s *AlertService) resolve(alert *models.Alert, now time.Time, notifyResolved bool) error {
+	if alert.Status == AlertStatusPending {
+		// 未达到持续时长就已恢复，不算一次告警
+		if err := database.DB.Where("id = ? AND status = ?", alert.ID, AlertStatusPending).
+			Delete(&models.Alert{}).Error; err != nil {
+			return fmt.Errorf("删除告警失败: %w", err)
+		}
+		return nil
+	}
+
+	result := database.DB.Model(&models.Alert{}).
+		Where("id = ? AND status = ?", alert.ID, AlertStatusFiring).
+		Updates(map[string]interface{}{
+			"status":      AlertStatusResolved,
+			"active_key":  nil,
+			"resolved_at": &now,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("恢复告警失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+	alert.Status = AlertStatusResolved
+	alert.ResolvedAt = &now
+
+	// 只有发送过告警通知的才发送恢复通知
+	rule := alert.Rule
+	if !notifyResolved || rule == nil || !rule.NotifyResolved || alert.LastNotifiedAt == nil {
+		return nil
+	}
+	silenced, err := s.silenced(rule.ID, alert.EmbyServerID, now)
+	if err != nil || silenced {
+		return err
+	}
+	s.dispatch(rule, alert, notify.StatusResolved)
+	return nil
+}
+
+// closeAlerts 结束匹配条件的所有未恢复告警，不发送恢复通知，用于规则停用、删除和服务器删除
+func (s *AlertService) closeAlerts(query string, args ...interface{}) error {
+	alertMutex.Lock()
+	defer alertMutex.Unlock()
+
+	var alerts []models.Alert
+	if err := database.DB.Where("status IN ?", activeAlertStatuses).Where(query, args...).
+		Find(&alerts).Error; err != nil {
+		return fmt.Errorf("查询告警失败: %w", err)
+	}
+	now := time.Now()
+	for i := range alerts {
+		if err := s.resolve(&alerts[i], now, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CloseServerAlerts 服务器删除后结束其所有未恢复的告警
+func (s *AlertService) CloseServerAlerts(serverID uint) error {
+	return s.closeAlerts("emby_server_id = ?", serverID)
+}
+
+// alertActiveKey 未恢复告警的唯一键
+func alertActiveKey(ruleID, serverID uint) string {
+	return fmt.Sprintf("%d:%d", ruleID, serverID)
+}
+
+// silenced 告警当前是否处于静默期间
+func (s *AlertService) silenced(ruleID, serverID uint, now time.Time) (bool, error) {
+	var count int64
+	if err := database.DB.Model(&models.AlertSilence{}).
+		Where("starts_at <= ? AND ends_at > ?", now, now).
+		Where("alert_rule_id IS NULL OR alert_rule_id = ?", ruleID).
+		Where("emby_server_id IS NULL OR emby_server_id = ?", serverID).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("查询静默失败: %w", err)
+	}
+	return count > 0, nil
+}
+
+// dispatch 异步发送通知到规则的所有启用渠道，发送失败只记录日志
+func (s *AlertService) dispatch(rule *models.AlertRule, alert *models.Alert, status string) {
+	serverName := fmt.Sprintf("#%d", alert.EmbyServerID)
+	var server models.EmbyServer
+	if err := database.DB.Unscoped().Select("id, name").First(&server, alert.EmbyServerID).Error; err == nil {
+		serverName = server.Name
+	}
+
+	title := alert.Event
+	if format, ok := alertEventTitles[alert.Event]; ok {
+		title = fmt.Sprintf(format, serverName)
+	}
+	n := notify.Notification{
+		Status:     status,
+		Severity:   alert.Severity,
+		Event:      alert.Event,
+		Title:      title,
+		Message:    alert.Message,
+		RuleID:     rule.ID,
+		RuleName:   rule.Name,
+		ServerID:   alert.EmbyServerID,
+		ServerName: serverName,
+		StartedAt:  alert.StartedAt,
+		SentAt:     time.Now(),
+	}
+
+	var channels []models.AlertChannel
+	for _, channel := range rule.Channels {
+		if channel.Enabled {
+			channels = append(channels, channel)
+		}
+	}
+	if len(channels) == 0 {
+		return
+	}
+
+	go func() {
+		for i := range channels {
+			if err := s.send(&channels[i], n); err != nil {
+				log.Printf("告警 %d 通过渠道 %s 发送失败: %v", alert.ID, channels[i].Name, err)
+			}
+		}
+	}()
+}
+
+// send 通过渠道发送一条通知
+func (s *AlertService) send(channel *models.AlertChannel, n notify.Notification) error {
+	timeout := 10 * time.Second
+	if config.AppConfig != nil && config.AppConfig.Alerting.Timeout > 0 {
+		timeout = time.Duration(config.AppConfig.Alerting.Timeout) * time.Second
+	}
+
+	sender, err := notify.NewSender(alertNotifyChannel(channel), &http.Client{Timeout: timeout})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err = sender.Send(ctx, n)
+
+	// 响应内容只记录在服务端日志中
+	var respErr *notify.ResponseError
+	if errors.As(err, &respErr) {
+		log.Printf("通知渠道 %s 返回状态码 %d，响应: %s", channel.Name, respErr.StatusCode, respErr.Detail)
+	}
+	return err
+}
+
+// alertNotifyChannel 转换为通知渠道配置
+func alertNotifyChannel(channel *models.AlertChannel) notify.Channel {
+	return notify.Channel{
+		Type:       channel.Type,
+		URL:        channel.URL,
+		Secret:     string(channel.Secret),
+		ChatID:     channel.ChatID,
+		Recipients: channel.Recipients,
+	}
+}
+
+// ListAlerts 分页查询告警，最新的在前
+func (s *AlertService) ListAlerts(status string, ruleID, serverID uint, page, pageSize int) ([]models.Alert, int64, error) {
+	db := database.DB.Model(&models.Alert{})
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	if ruleID != 0 {
+		db = db.Where("alert_rule_id = ?", ruleID)
+	}
+	if serverID != 0 {
+		db = db.Where("emby_server_id = ?", serverID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询告警失败: %w", err)
+	}
+	var alerts []models.Alert
+	if err := db.Preload("Rule").Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&alerts).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询告警失败: %w", err)
+	}
+	return alerts, total, nil
+}
+
+// ListRules 获取所有告警规则
+func (s *AlertService) ListRules() ([]models.AlertRule, error) {
+	var rules []models.AlertRule
+	if err := database.DB.Preload("Channels").Order("id").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("查询告警规则失败: %w", err)
+	}
+	return rules, nil
+}
+
+// CreateRule 创建告警规则
+func (s *AlertService) CreateRule(req dto.AlertRuleRequest) (*models.AlertRule, error) {
+	rule := models.AlertRule{Enabled: true}
+	if err := applyAlertRuleRequest(&rule, req); err != nil {
+		return nil, err
+	}
+	channels, err := findAlertChannels(req.ChannelIDs)
+	if err != nil {
+		return nil, err
+	}
+	rule.Channels = channels
+
+	if err := database.DB.Omit("Channels.*").Create(&rule).Error; err != nil {
+		return nil, fmt.Errorf("创建告警规则失败: %w", err)
+	}
+	return &rule, nil
+}
+
+// UpdateRule 更新告警规则，规则停用或事件、服务器变化时结束其未恢复的告警
+func (s *AlertService) UpdateRule(id uint, req dto.AlertRuleRequest) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	if err := database.DB.First(&rule, id).Error; err != nil {
+		return nil, ErrAlertRuleNotFound
+	}
+	before := rule
+
+	if err := applyAlertRuleRequest(&rule, req); err != nil {
+		return nil, err
+	}
+	channels, err := findAlertChannels(req.ChannelIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Channels").Save(&rule).Error; err != nil {
+			return err
+		}
+		return tx.Model(&rule).Omit("Channels.*").Association("Channels").Replace(channels)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("更新告警规则失败: %w", err)
+	}
+	rule.Channels = channels
+
+	if !rule.Enabled || rule.Event != before.Event || !sameServerScope(rule.EmbyServerID, before.EmbyServerID) {
+		if err := s.closeAlerts("alert_rule_id = ?", rule.ID); err != nil {
+			return nil, err
+		}
+	}
+	return &rule, nil
+}
+
+// DeleteRule 删除告警规则及其静默，未恢复的告警直接结束
+func (s *AlertService) DeleteRule(id uint) error {
+	var rule models.AlertRule
+	if err := database.DB.First(&rule, id).Error; err != nil {
+		return ErrAlertRuleNotFound
+	}
+	if err := s.closeAlerts("alert_rule_id = ?", rule.ID); err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&rule).Association("Channels").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Where("alert_rule_id = ?", rule.ID).Delete(&models.AlertSilence{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&rule).Error
+	})
+}
+
+// applyAlertRuleRequest 把请求写入规则并校验服务器
+func applyAlertRuleRequest(rule *models.AlertRule, req dto.AlertRuleRequest) error {
+	if req.EmbyServerID != nil {
+		var count int64
+		if err := database.DB.Model(&models.EmbyServer{}).Where("id = ?", *req.EmbyServerID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrServerNotFound
+		}
+	}
+
+	rule.Name = req.Name
+	rule.Event = req.Event
+	rule.EmbyServerID = req.EmbyServerID
+	rule.Duration = req.Duration
+	rule.Severity = req.Severity
+	rule.RepeatInterval = req.RepeatInterval
+	rule.NotifyResolved = req.NotifyResolved
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	return nil
+}
+
+// findAlertChannels 按ID查询通知渠道，有不存在的ID时返回错误
+func findAlertChannels(ids []uint) ([]models.AlertChannel, error) {
+	channels := []models.AlertChannel{}
+	if len(ids) == 0 {
+		return channels, nil
+	}
+	if err := database.DB.Where("id IN ?", ids).Find(&channels).Error; err != nil {
+		return nil, fmt.Errorf("查询通知渠道失败: %w", err)
+	}
+
+	found := make(map[uint]bool, len(channels))
+	for _, channel := range channels {
+		found[channel.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, fmt.Errorf("%w: %d", ErrAlertChannelNotFound, id)
+		}
+	}
+	return channels, nil
+}
+
+// sameServerScope 两个规则的服务器范围是否相同
+func sameServerScope(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// ListChannels 获取所有通知渠道
+func (s *AlertService) ListChannels() ([]models.AlertChannel, error) {
+	var channels []models.AlertChannel
+	if err := database.DB.Order("id").Find(&channels).Error; err != nil {
+		return nil, fmt.Errorf("查询通知渠道失败: %w", err)
+	}
+	return channels, nil
+}
+
+// CreateChannel 创建通知渠道
+func (s *AlertService) CreateChannel(req dto.AlertChannelRequest) (*models.AlertChannel, error) {
+	channel := models.AlertChannel{Enabled: true}
+	if err := applyAlertChannelRequest(&channel, req); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Create(&channel).Error; err != nil {
+		return nil, fmt.Errorf("创建通知渠道失败: %w", err)
+	}
+	return &channel, nil
+}
+
+// UpdateChannel 更新通知渠道，secret为null时保留原密钥
+func (s *AlertService) UpdateChannel(id uint, req dto.AlertChannelRequest) (*models.AlertChannel, error) {
+	var channel models.AlertChannel
+	if err := database.DB.First(&channel, id).Error; err != nil {
+		return nil, ErrAlertChannelNotFound
+	}
+	if err := applyAlertChannelRequest(&channel, req); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Save(&channel).Error; err != nil {
+		return nil, fmt.Errorf("更新通知渠道失败: %w", err)
+	}
+	return &channel, nil
+}
+
+// DeleteChannel 删除通知渠道，并从引用它的规则中移除
+func (s *AlertService) DeleteChannel(id uint) error {
+	var channel models.AlertChannel
+	if err := database.DB.First(&channel, id).Error; err != nil {
+		return ErrAlertChannelNotFound
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM alert_rule_channels WHERE alert_channel_id = ?", channel.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&channel).Error
+	})
+}
+
+// TestChannel 通过渠道同步发送一条测试通知，停用的渠道也可以测试
+func (s *AlertService) TestChannel(id uint) error {
+	var channel models.AlertChannel
+	if err := database.DB.First(&channel, id).Error; err != nil {
+		return ErrAlertChannelNotFound
+	}
+
+	now := time.Now()
+	return s.send(&channel, notify.Notification{
+		Status:    notify.StatusTest,
+		Severity:  AlertSeverityInfo,
+		Event:     "test",
+		Title:     "测试通知",
+		Message:   fmt.Sprintf("收到这条消息说明通知渠道 %s 配置正确", channel.Name),
+		StartedAt: now,
+		SentAt:    now,
+	})
+}
+
+// applyAlertChannelRequest 把请求写入渠道并校验配置是否完整
+func applyAlertChannelRequest(channel *models.AlertChannel, req dto.AlertChannelRequest) error {
+	channel.Name = req.Name
+	channel.Type = req.Type
+	channel.URL = req.URL
+	channel.ChatID = req.ChatID
+	channel.Recipients = req.Recipients
+	if req.Secret != nil {
+		channel.Secret = models.EncryptedString(*req.Secret)
+	}
+	if req.Enabled != nil {
+		channel.Enabled = *req.Enabled
+	}
+
+	if _, err := notify.NewSender(alertNotifyChannel(channel), nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListSilences 获取静默，active为true时只返回未结束的静默
+func (s *AlertService) ListSilences(active bool) ([]models.AlertSilence, error) {
+	db := database.DB.Order("starts_at DESC")
+	if active {
+		db = db.Where("ends_at > ?", time.Now())
+	}
+
+	var silences []models.AlertSilence
+	if err := db.Find(&silences).Error; err != nil {
+		return nil, fmt.Errorf("查询静默失败: %w", err)
+	}
+	return silences, nil
+}
+
+// CreateSilence 创建静默
+func (s *AlertService) CreateSilence(silence *models.AlertSilence) error {
+	if !silence.EndsAt.After(silence.StartsAt) || !silence.EndsAt.After(time.Now()) {
+		return ErrInvalidTimeRange
+	}
+	if silence.AlertRuleID != nil {
+		if err := database.DB.First(&models.AlertRule{}, *silence.AlertRuleID).Error; err != nil {
+			return ErrAlertRuleNotFound
+		}
+	}
+	if silence.EmbyServerID != nil {
+		if err := database.DB.First(&models.EmbyServer{}, *silence.EmbyServerID).Error; err != nil {
+			return ErrServerNotFound
+		}
+	}
+
+	if err := database.DB.Create(silence).Error; err != nil {
+		return fmt.Errorf("创建静默失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteSilence 删除静默，静默期间被抑制的告警通知会在下次评估时补发
+func (s *AlertService) DeleteSilence(id uint) error {
+	result := database.DB.Delete(&models.AlertSilence{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除静默失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAlertSilenceNotFound
+	}
+	return nil
+}
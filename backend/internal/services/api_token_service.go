@@ -0,0 +1,221 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// APITokenPrefix 个人访问令牌的前缀，用于和JWT区分
+const APITokenPrefix = "emt_"
+
+const (
+	// maxAPITokensPerUser 每个用户最多可创建的令牌数
+	maxAPITokensPerUser = 50
+	// apiTokenTouchInterval 最近使用时间的更新间隔，避免每个请求都写库
+	apiTokenTouchInterval = time.Minute
+)
+
+var (
+	// ErrAPITokenInvalid 令牌无效、已过期或已删除
+	ErrAPITokenInvalid = errors.New("访问令牌无效或已过期")
+	// ErrAPITokenNotFound 令牌不存在
+	ErrAPITokenNotFound = errors.New("访问令牌不存在")
+)
+
+// APITokenService 个人访问令牌服务
+type APITokenService struct {
+	rbacService *RBACService
+}
+
+// NewAPITokenService 创建个人访问令牌服务
+func NewAPITokenService() *APITokenService {
+	return &APITokenService{
+		rbacService: NewRBACService(),
+	}
+}
+
+// AvailableScopes 用户可以授予令牌的权限范围，即其角色拥有的权限
+func (s *APITokenService) AvailableScopes(role string) ([]models.Permission, error) {
+	granted, err := s.rbacService.RolePermissions(role)
+	if err != nil {
+		return nil, err
+	}
+	all, err := s.rbacService.GetPermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := make([]models.Permission, 0, len(all))
+	for _, permission := range all {
+		if granted[permission.Name] {
+			scopes = append(scopes, permission)
+		}
+	}
+	return scopes, nil
+}
+
+// CreateToken 创建令牌，返回令牌记录和只显示一次的明文
+// expiresInDays为0表示永不过期
+func (s *APITokenService) CreateToken(user *models.User, name string, scopes []string, expiresInDays int) (*models.APIToken, string, error) {
+	scopes, err := s.validateScopes(user.Role, scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.APIToken{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+		return nil, "", fmt.Errorf("查询访问令牌失败: %w", err)
+	}
+	if count >= maxAPITokensPerUser {
+		return nil, "", fmt.Errorf("每个用户最多创建 %d 个访问令牌", maxAPITokensPerUser)
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	plaintext := APITokenPrefix + secret
+
+	token := &models.APIToken{
+		UserID:    user.ID,
+		Name:      name,
+		TokenHash: hashToken(plaintext),
+		Prefix:    plaintext[:len(APITokenPrefix)+6],
+		Scopes:    models.StringList(scopes),
+	}
+	if expiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, expiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := database.DB.Create(token).Error; err != nil {
+		return nil, "", fmt.Errorf("创建访问令牌失败: %w", err)
+	}
+	return token, plaintext, nil
+}
+
+// ListTokens 获取用户的全部令牌
+func (s *APITokenService) ListTokens(userID uint) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("查询访问令牌失败: %w", err)
+	}
+	return tokens, nil
+}
+
+// UpdateToken 修改令牌名称或权限范围，nil表示不修改
+func (s *APITokenService) UpdateToken(user *models.User, id uint, name *string, scopes []string) (*models.APIToken, error) {
+	token, err := s.getToken(user.ID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if name != nil {
+		updates["name"] = *name
+	}
+	if scopes != nil {
+		validated, err := s.validateScopes(user.Role, scopes)
+		if err != nil {
+			return nil, err
+		}
+		updates["scopes"] = models.StringList(validated)
+	}
+	if len(updates) == 0 {
+		return token, nil
+	}
+
+	if err := database.DB.Model(token).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新访问令牌失败: %w", err)
+	}
+	return s.getToken(user.ID, id)
+}
+
+// DeleteToken 删除令牌，立即失效
+func (s *APITokenService) DeleteToken(userID, id uint) error {
+	result := database.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.APIToken{})
+	if result.Error != nil {
+		return fmt.Errorf("删除访问令牌失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPITokenNotFound
+	}
+	return nil
+}
+
+// Authenticate 校验令牌明文，返回令牌记录和所属用户，并记录最近使用时间
+func (s *APITokenService) Authenticate(plaintext, ip string) (*models.APIToken, *models.User, error) {
+	if !strings.HasPrefix(plaintext, APITokenPrefix) {
+		return nil, nil, ErrAPITokenInvalid
+	}
+
+	var token models.APIToken
+	if err := database.DB.Where("token_hash = ?", hashToken(plaintext)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrAPITokenInvalid
+		}
+		return nil, nil, fmt.Errorf("查询访问令牌失败: %w", err)
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, nil, ErrAPITokenInvalid
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, token.UserID).Error; err != nil {
+		return nil, nil, ErrAPITokenInvalid
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= apiTokenTouchInterval || token.LastUsedIP != ip {
+		database.DB.Model(&token).UpdateColumns(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ip,
+		})
+	}
+	return &token, &user, nil
+}
+
+// validateScopes 检查权限范围不超过角色拥有的权限，并去除重复项
+func (s *APITokenService) validateScopes(role string, scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("至少需要一个权限范围")
+	}
+
+	granted, err := s.rbacService.RolePermissions(role)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(scopes))
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if seen[scope] {
+			continue
+		}
+		if !granted[scope] {
+			return nil, fmt.Errorf("当前角色没有权限: %s", scope)
+		}
+		seen[scope] = true
+		result = append(result, scope)
+	}
+	return result, nil
+}
+
+// getToken 获取用户的指定令牌
+func (s *APITokenService) getToken(userID, id uint) (*models.APIToken, error) {
+	var token models.APIToken
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPITokenNotFound
+		}
+		return nil, fmt.Errorf("查询访问令牌失败: %w", err)
+	}
+	return &token, nil
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/database"
+)
+
+func TestValidateScopes(t *testing.T) {
+	setupTestDB(t)
+	service := NewAPITokenService()
+	createTestRole(t, "operator", PermMediaSync, PermJobsManage)
+
+	tests := []struct {
+		name    string
+		role    string
+		scopes  []string
+		want    []string
+		wantErr bool
+	}{
+		{"角色拥有的权限", "operator", []string{PermMediaSync}, []string{PermMediaSync}, false},
+		{"去除重复项", "operator", []string{PermJobsManage, PermMediaSync, PermJobsManage}, []string{PermJobsManage, PermMediaSync}, false},
+		{"至少需要一个权限范围", "operator", nil, nil, true},
+		{"不能超出角色拥有的权限", "operator", []string{PermMediaSync, PermUsersManage}, nil, true},
+		{"不存在的权限", "operator", []string{"unknown.scope"}, nil, true},
+		{"管理员可以授予任意权限", RoleAdmin, []string{PermUsersManage, PermRolesManage}, []string{PermUsersManage, PermRolesManage}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.validateScopes(tt.role, tt.scopes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateScopes(%q, %v) err = %v, wantErr %v", tt.role, tt.scopes, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("validateScopes(%q, %v) = %v, want %v", tt.role, tt.scopes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateRejectsExpiredAndUnknownTokens(t *testing.T) {
+	setupTestDB(t)
+	service := NewAPITokenService()
+	user := createTestUser(t, "alice", RoleUser)
+
+	_, valid, err := service.CreateToken(user, "valid", []string{PermMediaSync}, 30)
+	if err != nil {
+		t.Fatalf("创建令牌失败: %v", err)
+	}
+	expiredToken, expired, err := service.CreateToken(user, "expired", []string{PermMediaSync}, 1)
+	if err != nil {
+		t.Fatalf("创建令牌失败: %v", err)
+	}
+	if err := database.DB.Model(expiredToken).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatalf("修改过期时间失败: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		plaintext string
+		wantErr   bool
+	}{
+		{"有效令牌", valid, false},
+		{"已过期", expired, true},
+		{"缺少前缀", valid[len(APITokenPrefix):], true},
+		{"未知令牌", APITokenPrefix + "unknown", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, owner, err := service.Authenticate(tt.plaintext, "127.0.0.1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (owner.ID != user.ID || !reflect.DeepEqual([]string(token.Scopes), []string{PermMediaSync})) {
+				t.Fatalf("Authenticate 返回了错误的用户或权限范围")
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/dto"
+	"github.com/emby-client-go/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// 审计事件的操作类型
+const (
+	AuditActionUserCreate        = "user.create"
+	AuditActionUserUpdate        = "user.update"
+	AuditActionUserDisable       = "user.disable"
+	AuditActionUserEnable        = "user.enable"
+	AuditActionUserUnlock        = "user.unlock"
+	AuditActionUserResetPassword = "user.reset_password"
+	AuditActionUserRoleChange    = "user.role_change"
+	AuditActionUserAuthSource    = "user.auth_source"
+	AuditActionUserDelete        = "user.delete"
+
+	AuditActionServerCreate      = "server.create"
+	AuditActionServerUpdate      = "server.update"
+	AuditActionServerDelete      = "server.delete"
+	AuditActionServerShare       = "server.share"
+	AuditActionServerUnshare     = "server.unshare"
+	AuditActionServerReconnect   = "server.reconnect"
+	AuditActionServerSyncDevices = "server.sync_devices"
+	AuditActionServerSyncLibrary = "server.sync_libraries"
+
+	AuditActionMediaSync        = "media.sync"
+	AuditActionMediaSyncAll     = "media.sync_all"
+	AuditActionMediaRefresh     = "media.refresh_library"
+	AuditActionPlaybackCommand  = "playback.command"
+	AuditActionJobCancel        = "job.cancel"
+	AuditActionSchedulerPause   = "scheduler.pause"
+	AuditActionSchedulerResume  = "scheduler.resume"
+	AuditActionSchedulerTrigger = "scheduler.trigger"
+
+	AuditActionRoleCreate     = "role.create"
+	AuditActionRoleUpdate     = "role.update"
+	AuditActionRoleDelete     = "role.delete"
+	AuditActionSecurityPolicy = "system.security_policy"
+	AuditActionLDAPSync       = "system.ldap_sync"
+
+	AuditActionAlertRuleCreate    = "alert.rule_create"
+	AuditActionAlertRuleUpdate    = "alert.rule_update"
+	AuditActionAlertRuleDelete    = "alert.rule_delete"
+	AuditActionAlertChannelCreate = "alert.channel_create"
+	AuditActionAlertChannelUpdate = "alert.channel_update"
+	AuditActionAlertChannelDelete = "alert.channel_delete"
+	AuditActionAlertSilenceCreate = "alert.silence_create"
+	AuditActionAlertSilenceDelete = "alert.silence_delete"
+)
+
+// 审计事件的对象类型
+const (
+	AuditTargetUser         = "user"
+	AuditTargetServer       = "server"
+	AuditTargetLibrary      = "library"
+	AuditTargetDevice       = "device"
+	AuditTargetSyncJob      = "sync_job"
+	AuditTargetScheduledJob = "scheduled_job"
+	AuditTargetRole         = "role"
+	AuditTargetSystem       = "system"
+	AuditTargetAlertRule    = "alert_rule"
+	AuditTargetAlertChannel = "alert_channel"
+	AuditTargetAlertSilence = "alert_silence"
+)
+
+// auditExportLimit 单次导出的最大事件数
+const auditExportLimit = 100000
+
+// AuditActor 执行操作的用户及其客户端信息
+type AuditActor struct {
+	UserID    uint
+	Username  string
+	Role      string // 操作者当前的角色，用于检查授予的角色和权限不超出操作者自身
+	IP        string
+	UserAgent string
+}
+
+// AuditService 审计日志服务
+type AuditService struct{}
+
+// NewAuditService 创建审计日志服务
+func NewAuditService() *AuditService {
+	return &AuditService{}
+}
+
+// AuditQuery 审计事件查询条件，零值表示不限制
+type AuditQuery struct {
+	ActorID    uint
+	Actor      string // 操作者用户名
+	Action     string // 以 "." 结尾时按前缀匹配，如 "server."
+	TargetType string
+	TargetID   string
+	IP         string
+	From       time.Time
+	To         time.Time
+}
+
+// Record 写入一条审计事件，before和after为操作前后的对象快照，可以为nil；targetID为空时表示没有具体对象
+// 审计写入失败只记录日志，不影响已经完成的操作
+func (s *AuditService) Record(actor AuditActor, action, targetType string, targetID interface{}, before, after interface{}) {
+	event := models.AuditEvent{
+		ActorName:  actor.Username,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   auditTargetID(targetID),
+		Before:     auditSnapshot(before),
+		After:      auditSnapshot(after),
+		IP:         actor.IP,
+		UserAgent:  actor.UserAgent,
+	}
+	if actor.UserID != 0 {
+		actorID := actor.UserID
+		event.ActorID = &actorID
+	}
+
+	if err := database.DB.Create(&event).Error; err != nil {
+		log.Printf("写入审计事件 %s 失败: %v", action, err)
+	}
+}
+
+// ListEvents 按条件分页查询审计事件，最新的在前
+func (s *AuditService) ListEvents(query AuditQuery, page, pageSize int) ([]models.AuditEvent, int64, error) {
+	var events []models.AuditEvent
+	var total int64
+
+	db := applyAuditQuery(database.DB.Model(&models.AuditEvent{}), query)
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询审计事件失败: %w", err)
+	}
+
+	offset := (page - 1) * pageSize
+	if err := db.Order("id DESC").Offset(offset).Limit(pageSize).Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询审计事件失败: %w", err)
+	}
+	return events, total, nil
+}
+
+// ExportEvents 按条件分批读取审计事件并逐条交给fn，最新的在前，最多导出auditExportLimit条
+func (s *AuditService) ExportEvents(query AuditQuery, fn func(event *models.AuditEvent) error) error {
+	const batchSize = 500
+	exported := 0
+	lastID := uint(0)
+	for exported < auditExportLimit {
+		db := applyAuditQuery(database.DB.Model(&models.AuditEvent{}), query)
+		if lastID > 0 {
+			db = db.Where("id < ?", lastID)
+		}
+		var events []models.AuditEvent
+		if err := db.Order("id DESC").Limit(batchSize).Find(&events).Error; err != nil {
+			return fmt.Errorf("查询审计事件失败: %w", err)
+		}
+		for i := range events {
+			if exported >= auditExportLimit {
+				return nil
+			}
+			if err := fn(&events[i]); err != nil {
+				return err
+			}
+			exported++
+		}
+		if len(events) < batchSize {
+			return nil
+		}
+		lastID = events[len(events)-1].ID
+	}
+	return nil
+}
+
+// applyAuditQuery 把查询条件应用到查询上
+func applyAuditQuery(db *gorm.DB, query AuditQuery) *gorm.DB {
+	if query.ActorID != 0 {
+		db = db.Where("actor_id = ?", query.ActorID)
+	}
+	if query.Actor != "" {
+		db = db.Where("actor_name = ?", query.Actor)
+	}
+	if strings.HasSuffix(query.Action, ".") {
+		db = db.Where("action LIKE ?", query.Action+"%")
+	} else if query.Action != "" {
+		db = db.Where("action = ?", query.Action)
+	}
+	if query.TargetType != "" {
+		db = db.Where("target_type = ?", query.TargetType)
+	}
+	if query.TargetID != "" {
+		db = db.Where("target_id = ?", query.TargetID)
+	}
+	if query.IP != "" {
+		db = db.Where("ip = ?", query.IP)
+	}
+	if !query.From.IsZero() {
+		db = db.Where("created_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		db = db.Where("created_at < ?", query.To)
+	}
+	return db
+}
+
+// AuditDiff 比较操作前后的快照，返回发生变化的字段
+// 创建时before为空，删除时after为空，此时返回全部字段
+func AuditDiff(before, after string) map[string]dto.AuditChange {
+	beforeFields := map[string]interface{}{}
+	afterFields := map[string]interface{}{}
+	if before != "" {
+		if err := json.Unmarshal([]byte(before), &beforeFields); err != nil {
+			return nil
+		}
+	}
+	if after != "" {
+		if err := json.Unmarshal([]byte(after), &afterFields); err != nil {
+			return nil
+		}
+	}
+
+	changes := map[string]dto.AuditChange{}
+	for key, value := range beforeFields {
+		afterValue, ok := afterFields[key]
+		if !ok || !auditValueEqual(value, afterValue) {
+			changes[key] = dto.AuditChange{Before: value, After: afterValue}
+		}
+	}
+	for key, value := range afterFields {
+		if _, ok := beforeFields[key]; !ok {
+			changes[key] = dto.AuditChange{After: value}
+		}
+	}
+	return changes
+}
+
+// auditValueEqual 比较两个JSON值是否相同
+func auditValueEqual(a, b interface{}) bool {
+	left, _ := json.Marshal(a)
+	right, _ := json.Marshal(b)
+	return string(left) == string(right)
+}
+
+// auditTargetID 对象ID转为字符串，nil和0表示没有具体对象
+func auditTargetID(id interface{}) string {
+	switch v := id.(type) {
+	case nil:
+		return ""
+	case uint:
+		if v == 0 {
+			return ""
+		}
+	case string:
+		return v
+	}
+	return fmt.Sprint(id)
+}
+
+// auditSnapshot 把对象快照序列化为JSON
+func auditSnapshot(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// userAuditSnapshot 用户的审计快照，不包含密码等敏感字段
+func userAuditSnapshot(user *models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             user.ID,
+		"username":       user.Username,
+		"email":          user.Email,
+		"nickname":       user.Nickname,
+		"role":           user.Role,
+		"status":         user.Status,
+		"auth_source":    user.AuthSource,
+		"email_verified": user.EmailVerified,
+	}
+}
+
+// serverAuditSnapshot 服务器的审计快照，API密钥只记录指纹，不包含登录密码
+func serverAuditSnapshot(server *models.EmbyServer) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                  server.ID,
+		"name":                server.Name,
+		"url":                 server.URL,
+		"auth_type":           server.AuthType,
+		"api_key_fingerprint": server.APIKey,
+		"emby_username":       server.EmbyUsername,
+		"description":         server.Description,
+		"timeout":             server.Timeout,
+		"max_retries":         server.MaxRetries,
+		"cache_ttl":           server.CacheTTL,
+		"auth_mode":           server.AuthMode,
+	}
+}
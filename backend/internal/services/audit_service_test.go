@@ -0,0 +1,71 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/emby-client-go/backend/internal/dto"
+)
+
+func TestAuditDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   map[string]dto.AuditChange
+	}{
+		{
+			name:   "修改字段",
+			before: `{"name":"a","role":"user","enabled":true}`,
+			after:  `{"name":"a","role":"admin","enabled":true}`,
+			want:   map[string]dto.AuditChange{"role": {Before: "user", After: "admin"}},
+		},
+		{
+			name:   "创建时返回全部字段",
+			before: "",
+			after:  `{"name":"a","port":8096}`,
+			want:   map[string]dto.AuditChange{"name": {After: "a"}, "port": {After: float64(8096)}},
+		},
+		{
+			name:   "删除时返回全部字段",
+			before: `{"name":"a"}`,
+			after:  "",
+			want:   map[string]dto.AuditChange{"name": {Before: "a"}},
+		},
+		{
+			name:   "新增和移除字段",
+			before: `{"old":1}`,
+			after:  `{"new":2}`,
+			want:   map[string]dto.AuditChange{"old": {Before: float64(1)}, "new": {After: float64(2)}},
+		},
+		{
+			name:   "嵌套值按内容比较",
+			before: `{"scopes":["a","b"],"meta":{"x":1}}`,
+			after:  `{"scopes":["a","b"],"meta":{"x":2}}`,
+			want: map[string]dto.AuditChange{"meta": {
+				Before: map[string]interface{}{"x": float64(1)},
+				After:  map[string]interface{}{"x": float64(2)},
+			}},
+		},
+		{
+			name:   "没有变化",
+			before: `{"name":"a"}`,
+			after:  `{"name":"a"}`,
+			want:   map[string]dto.AuditChange{},
+		},
+		{
+			name:   "快照不是JSON",
+			before: "not json",
+			after:  `{"name":"a"}`,
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AuditDiff(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("AuditDiff() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
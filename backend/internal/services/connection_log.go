@@ -0,0 +1,204 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/pkg/websocket"
+	"gorm.io/gorm"
+)
+
+// 连接日志动作
+const (
+	ConnectionActionConnect     = "connect"      // 创建服务器、客户端连接恢复、WebSocket连接建立
+	ConnectionActionTest        = "test"         // 手动测试连接
+	ConnectionActionHealthCheck = "health_check" // 定时健康检查
+	ConnectionActionReconnect   = "reconnect"    // WebSocket重连
+	ConnectionActionDisconnect  = "disconnect"   // WebSocket连接断开
+	ConnectionActionError       = "error"        // 客户端请求连续失败、WebSocket重连放弃
+)
+
+// 连接日志来源
+const (
+	ConnectionSourceHTTP      = "http"
+	ConnectionSourceWebSocket = "websocket"
+)
+
+// 连接日志结果
+const (
+	ConnectionStatusSuccess = "success"
+	ConnectionStatusFailed  = "failed"
+)
+
+// ErrInvalidTimeRange 开始时间不早于结束时间
+var ErrInvalidTimeRange = errors.New("开始时间必须早于结束时间")
+
+// ErrTimeRangeTooLarge 统计的时间范围超过上限
+var ErrTimeRangeTooLarge = fmt.Errorf("统计时间范围不能超过%d天", maxConnectionStatsWindow/(24*time.Hour))
+
+// connectionCheckActions 计算可用率和延迟时使用的主动探测动作
+var connectionCheckActions = []string{ConnectionActionConnect, ConnectionActionTest, ConnectionActionHealthCheck}
+
+// defaultConnectionStatsWindow 未指定开始时间时统计的时间范围
+const defaultConnectionStatsWindow = 24 * time.Hour
+
+// maxConnectionStatsWindow 统计的最大时间范围，更长时间的可用率和延迟使用汇总后的时间序列
+const maxConnectionStatsWindow = 31 * 24 * time.Hour
+
+// ConnectionLogQuery 连接日志筛选条件，字段为空时不筛选
+type ConnectionLogQuery struct {
+	Action string
+	Status string
+	Source string
+	From   time.Time // 包含
+	To     time.Time // 不包含
+}
+
+// ConnectionStats 一段时间内的连接统计
+type ConnectionStats struct {
+	From             time.Time
+	To               time.Time
+	Checks           int64    // 主动探测次数
+	SuccessfulChecks int64    // 成功的主动探测次数
+	UptimePercent    *float64 // 没有探测记录时为空
+	Failures         int64    // 所有失败的日志数
+	Disconnects      int64    // WebSocket断开次数
+	Latency          *LatencyStats
+}
+
+// LatencyStats 成功探测的响应时间分布，单位毫秒
+type LatencyStats struct {
+	Min int
+	Avg int
+	P50 int
+	P90 int
+	P95 int
+	P99 int
+	Max int
+}
+
+// applyConnectionLogQuery 添加连接日志筛选条件
+func applyConnectionLogQuery(db *gorm.DB, q ConnectionLogQuery) *gorm.DB {
+	if q.Action != "" {
+		db = db.Where("action = ?", q.Action)
+	}
+	if q.Status != "" {
+		db = db.Where("status = ?", q.Status)
+	}
+	if q.Source != "" {
+		db = db.Where("source = ?", q.Source)
+	}
+	if !q.From.IsZero() {
+		db = db.Where("created_at >= ?", q.From)
+	}
+	if !q.To.IsZero() {
+		db = db.Where("created_at < ?", q.To)
+	}
+	return db
+}
+
+// recordConnectionLog 写入连接日志，失败只记录日志不影响调用方
+func recordConnectionLog(connLog *models.ConnectionLog) {
+	if connLog.Source == "" {
+		connLog.Source = ConnectionSourceHTTP
+	}
+	if err := database.DB.Create(connLog).Error; err != nil {
+		log.Printf("记录服务器 %d 连接日志失败: %v", connLog.EmbyServerID, err)
+	}
+}
+
+// RecordWebSocketStatus 记录WebSocket连接的状态变化并触发或恢复连接失败告警，连接中、重连中等中间状态不记录
+func RecordWebSocketStatus(event websocket.StatusEvent) {
+	serverID, err := strconv.ParseUint(event.ServerID, 10, 32)
+	if err != nil {
+		return
+	}
+
+	connLog := models.ConnectionLog{
+		EmbyServerID: uint(serverID),
+		Source:       ConnectionSourceWebSocket,
+		ResponseTime: int(event.Latency.Milliseconds()),
+	}
+	retry := event.Previous == websocket.Reconnecting
+
+	switch {
+	case event.Status == websocket.Connected:
+		connLog.Action = ConnectionActionConnect
+		connLog.Status = ConnectionStatusSuccess
+		connLog.Message = "WebSocket连接已建立"
+		if retry {
+			connLog.Action = ConnectionActionReconnect
+			connLog.Message = fmt.Sprintf("WebSocket第 %d 次重连成功", event.Attempt)
+		}
+	case event.Status == websocket.Disconnected:
+		connLog.Action = ConnectionActionDisconnect
+		connLog.Status = ConnectionStatusFailed
+		connLog.Message = "WebSocket连接断开"
+		if event.Err != nil {
+			connLog.Message = fmt.Sprintf("WebSocket连接断开: %v", event.Err)
+		}
+	case event.Status == websocket.Failed && event.Latency == 0:
+		// 没有发起连接，重连次数已达上限
+		connLog.Action = ConnectionActionError
+		connLog.Status = ConnectionStatusFailed
+		connLog.Message = fmt.Sprintf("WebSocket连接失败: %v", event.Err)
+	case event.Status == websocket.Failed:
+		connLog.Action = ConnectionActionConnect
+		connLog.Status = ConnectionStatusFailed
+		connLog.Message = fmt.Sprintf("WebSocket连接失败: %v", event.Err)
+		if retry {
+			connLog.Action = ConnectionActionReconnect
+			connLog.Message = fmt.Sprintf("WebSocket第 %d 次重连失败: %v", event.Attempt, event.Err)
+		}
+	default:
+		return
+	}
+
+	recordConnectionLog(&connLog)
+
+	switch event.Status {
+	case websocket.Connected:
+		resolveAlert(AlertEventWebSocketFailed, connLog.EmbyServerID)
+	case websocket.Failed:
+		raiseAlert(AlertEventWebSocketFailed, connLog.EmbyServerID, connLog.Message)
+	}
+}
+
+// latencyPercentile 按最近秩法计算已排序数据的百分位数
+func latencyPercentile(sorted []int, p float64) int {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// newLatencyStats 根据升序排列的响应时间计算延迟分布，没有数据时返回nil
+func newLatencyStats(sorted []int) *LatencyStats {
+	if len(sorted) == 0 {
+		return nil
+	}
+
+	var sum int64
+	for _, v := range sorted {
+		sum += int64(v)
+	}
+	return &LatencyStats{
+		Min: sorted[0],
+		Avg: int(sum / int64(len(sorted))),
+		P50: latencyPercentile(sorted, 50),
+		P90: latencyPercentile(sorted, 90),
+		P95: latencyPercentile(sorted, 95),
+		P99: latencyPercentile(sorted, 99),
+		Max: sorted[len(sorted)-1],
+	}
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/config"
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/metrics"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/pkg/emby"
+)
+
+// clientRegistry 按服务器ID复用的Emby客户端注册表
+// 复用客户端可以保留连接状态、重试计数以及状态变化回调
+type clientRegistry struct {
+	clients map[uint]*pooledClient
+	mutex   sync.Mutex
+}
+
+// pooledClient 注册表中的客户端及创建时使用的连接参数
+type pooledClient struct {
+	client   *emby.Client
+	url      string
+	apiKey   string
+	options  emby.ClientOptions
+	cacheTTL time.Duration // 服务器数据的缓存有效期，与客户端选项一同随服务器配置更新
+}
+
+// embyClients 全局客户端注册表
+var embyClients = &clientRegistry{
+	clients: make(map[uint]*pooledClient),
+}
+
+// embyClientOptions 根据全局配置和服务器的覆盖项生成客户端选项
+func embyClientOptions(server *models.EmbyServer) emby.ClientOptions {
+	opts := emby.DefaultClientOptions()
+
+	if config.AppConfig != nil {
+		if timeout := config.AppConfig.Emby.DefaultTimeout; timeout > 0 {
+			opts.Timeout = time.Duration(timeout) * time.Second
+		}
+		if retries := config.AppConfig.Emby.MaxRetryTimes; retries >= 0 {
+			opts.MaxRetries = retries
+		}
+		if mode := config.AppConfig.Emby.AuthMode; mode != "" {
+			opts.AuthMode = emby.AuthMode(mode)
+		}
+		if name := config.AppConfig.Emby.ClientName; name != "" {
+			opts.ClientName = name
+			opts.DeviceName = name
+		}
+		opts.DeviceID = embyDeviceID()
+	}
+
+	if server.Timeout != nil && *server.Timeout > 0 {
+		opts.Timeout = time.Duration(*server.Timeout) * time.Second
+	}
+	if server.MaxRetries != nil && *server.MaxRetries >= 0 {
+		opts.MaxRetries = *server.MaxRetries
+	}
+	if server.AuthMode != "" {
+		opts.AuthMode = emby.AuthMode(server.AuthMode)
+	}
+
+	return opts
+}
+
+// embyDeviceID 上报给Emby的设备ID，未配置时按主机名生成
+func embyDeviceID() string {
+	if config.AppConfig != nil && config.AppConfig.Emby.DeviceID != "" {
+		return config.AppConfig.Emby.DeviceID
+	}
+
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return emby.DefaultClientName
+	}
+	return emby.DefaultClientName + "-" + host
+}
+
+// serverCacheTTL 服务器数据的缓存有效期，返回0表示不缓存
+func serverCacheTTL(server *models.EmbyServer) time.Duration {
+	ttl := 5 * time.Minute
+	if config.AppConfig != nil {
+		if !config.AppConfig.Emby.EnableCache {
+			return 0
+		}
+		if config.AppConfig.Emby.CacheTTL > 0 {
+			ttl = time.Duration(config.AppConfig.Emby.CacheTTL) * time.Second
+		}
+	}
+
+	if server.CacheTTL != nil && *server.CacheTTL > 0 {
+		ttl = time.Duration(*server.CacheTTL) * time.Second
+	}
+	return ttl
+}
+
+// newEmbyClient 创建不加入注册表的临时客户端，用于保存前的连接测试
+func newEmbyClient(server *models.EmbyServer) *emby.Client {
+	return emby.NewClientWithOptions(server.URL, string(server.APIKey), embyClientOptions(server))
+}
+
+// GetEmbyClient 获取服务器对应的共享客户端
+// 服务器地址、API密钥或客户端选项变化时自动重建客户端
+func GetEmbyClient(server *models.EmbyServer) *emby.Client {
+	embyClients.mutex.Lock()
+	defer embyClients.mutex.Unlock()
+
+	opts := embyClientOptions(server)
+	cacheTTL := serverCacheTTL(server)
+	if pooled, ok := embyClients.clients[server.ID]; ok &&
+		pooled.url == server.URL && pooled.apiKey == string(server.APIKey) && pooled.options == opts {
+		pooled.cacheTTL = cacheTTL
+		return pooled.client
+	}
+
+	serverID := server.ID
+	client := emby.NewClientWithOptions(server.URL, string(server.APIKey), opts)
+	client.SetStatusChangeCallback(func(status emby.ConnectionStatus, err error) {
+		onClientStatusChange(serverID, status, err)
+	})
+	client.SetRequestObserver(func(result emby.RequestResult) {
+		metrics.ObserveEmbyRequest(serverID, result.Method, result.Retries, result.Duration, result.Err)
+	})
+	if server.AuthType == AuthTypePassword {
+		client.SetTokenRefresher(func(ctx context.Context) (string, error) {
+			return renewServerToken(ctx, serverID)
+		})
+	}
+
+	embyClients.clients[server.ID] = &pooledClient{
+		client:   client,
+		url:      server.URL,
+		apiKey:   string(server.APIKey),
+		options:  opts,
+		cacheTTL: cacheTTL,
+	}
+	return client
+}
+
+// pooledCacheTTL 从注册表读取服务器的缓存有效期，服务器还没有共享客户端时ok为false
+func pooledCacheTTL(serverID uint) (ttl time.Duration, ok bool) {
+	embyClients.mutex.Lock()
+	defer embyClients.mutex.Unlock()
+
+	pooled, ok := embyClients.clients[serverID]
+	if !ok {
+		return 0, false
+	}
+	return pooled.cacheTTL, true
+}
+
+// authenticateServer 使用服务器保存的用户名密码登录Emby，并将访问令牌写入server
+func authenticateServer(ctx context.Context, server *models.EmbyServer) error {
+	if server.EmbyUsername == "" {
+		return fmt.Errorf("缺少Emby用户名")
+	}
+
+	client := emby.NewClientWithOptions(server.URL, "", embyClientOptions(server))
+	result, err := client.AuthenticateByName(ctx, server.EmbyUsername, string(server.EmbyPassword))
+	if err != nil {
+		return err
+	}
+
+	server.APIKey = models.EncryptedString(result.AccessToken)
+	server.EmbyUserID = result.User.ID
+	return nil
+}
+
+// renewServerToken 访问令牌失效时重新登录，并更新数据库和共享客户端
+func renewServerToken(ctx context.Context, serverID uint) (string, error) {
+	var server models.EmbyServer
+	if err := database.DB.First(&server, serverID).Error; err != nil {
+		return "", fmt.Errorf("服务器不存在: %w", err)
+	}
+
+	if err := authenticateServer(ctx, &server); err != nil {
+		return "", err
+	}
+
+	if err := database.DB.Model(&server).Updates(map[string]interface{}{
+		"api_key":      server.APIKey,
+		"emby_user_id": server.EmbyUserID,
+	}).Error; err != nil {
+		return "", fmt.Errorf("保存访问令牌失败: %w", err)
+	}
+
+	// 同步更新注册表中的记录，避免下次获取时因密钥变化重建客户端
+	embyClients.mutex.Lock()
+	if pooled, ok := embyClients.clients[serverID]; ok {
+		pooled.apiKey = string(server.APIKey)
+	}
+	embyClients.mutex.Unlock()
+
+	log.Printf("服务器 %d 访问令牌已续期", serverID)
+	return string(server.APIKey), nil
+}
+
+// InvalidateEmbyClient 移除服务器的共享客户端，下次获取时重新创建
+func InvalidateEmbyClient(serverID uint) {
+	embyClients.mutex.Lock()
+	defer embyClients.mutex.Unlock()
+
+	if pooled, ok := embyClients.clients[serverID]; ok {
+		pooled.client.SetStatusChangeCallback(nil)
+		pooled.client.SetRequestObserver(nil)
+		delete(embyClients.clients, serverID)
+	}
+}
+
+// onClientStatusChange 客户端状态变化时更新服务器状态、记录连接日志并触发或恢复离线告警
+func onClientStatusChange(serverID uint, status emby.ConnectionStatus, err error) {
+	var serverStatus string
+	connLog := models.ConnectionLog{EmbyServerID: serverID}
+
+	switch status {
+	case emby.StatusConnected:
+		serverStatus = "online"
+		connLog.Action = ConnectionActionConnect
+		connLog.Status = ConnectionStatusSuccess
+		connLog.Message = "服务器连接已恢复"
+	case emby.StatusError:
+		serverStatus = "offline"
+		connLog.Action = ConnectionActionError
+		connLog.Status = ConnectionStatusFailed
+		connLog.Message = "服务器连接异常"
+		if err != nil {
+			connLog.Message = fmt.Sprintf("服务器连接异常: %v", err)
+		}
+	default:
+		// 连接中等中间状态不落库
+		return
+	}
+
+	// 仅在状态确实发生变化时记录，避免多个调用方重复写日志
+	now := time.Now()
+	result := database.DB.Model(&models.EmbyServer{}).
+		Where("id = ? AND status <> ?", serverID, serverStatus).
+		Updates(map[string]interface{}{
+			"status":     serverStatus,
+			"last_check": &now,
+		})
+	if result.Error != nil {
+		log.Printf("更新服务器 %d 状态失败: %v", serverID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	recordConnectionLog(&connLog)
+	log.Printf("服务器 %d 状态变为 %s", serverID, serverStatus)
+
+	if status == emby.StatusConnected {
+		resolveAlert(AlertEventServerOffline, serverID)
+	} else {
+		raiseAlert(AlertEventServerOffline, serverID, connLog.Message)
+	}
+}
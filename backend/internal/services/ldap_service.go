@@ -0,0 +1,634 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/config"
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/internal/utils"
+	"github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+)
+
+// 用户认证来源
+const (
+	AuthSourceLocal = "local"
+	AuthSourceLDAP  = "ldap"
+)
+
+// IdentityProviderLDAP LDAP身份在UserIdentity中的提供商名称，subject为用户DN
+const IdentityProviderLDAP = "ldap"
+
+var (
+	// ErrLDAPDisabled 未启用LDAP认证
+	ErrLDAPDisabled = errors.New("未启用LDAP认证")
+	// ErrLDAPUserNotFound 目录中不存在该用户
+	ErrLDAPUserNotFound = errors.New("目录中不存在该用户")
+	// ErrDirectoryManagedUser 目录用户的密码由LDAP管理
+	ErrDirectoryManagedUser = errors.New("目录用户的密码由LDAP管理，请在目录中修改")
+
+	// errLDAPMultipleEntries 搜索匹配到多个目录条目
+	errLDAPMultipleEntries = errors.New("匹配到多个目录条目")
+)
+
+// LDAPEntry 从目录读取的用户信息
+type LDAPEntry struct {
+	DN       string
+	Username string
+	Email    string
+	Name     string
+	Groups   []string // 组名和组DN
+}
+
+// LDAPSyncResult 一次组同步的结果
+type LDAPSyncResult struct {
+	Checked  int `json:"checked"`
+	Updated  int `json:"updated"`
+	Disabled int `json:"disabled"`
+	Failed   int `json:"failed"`
+}
+
+// LDAPService LDAP目录认证服务
+type LDAPService struct {
+	cfg            config.LDAPConfig
+	rbacService    *RBACService
+	sessionService *SessionService
+}
+
+// NewLDAPService 创建LDAP目录认证服务
+func NewLDAPService() *LDAPService {
+	return &LDAPService{
+		cfg:            config.AppConfig.LDAP,
+		rbacService:    NewRBACService(),
+		sessionService: NewSessionService(),
+	}
+}
+
+// Enabled 是否启用LDAP认证
+func (s *LDAPService) Enabled() bool {
+	return s.cfg.Enabled && s.cfg.URL != "" && s.cfg.BaseDN != ""
+}
+
+// Authenticate 按登录名搜索目录用户并校验密码
+func (s *LDAPService) Authenticate(username, password string) (*LDAPEntry, error) {
+	return s.authenticate(password, func(conn *ldap.Conn) (*LDAPEntry, error) {
+		entry, err := s.searchUser(conn, username)
+		if err != nil {
+			return nil, err
+		}
+		return s.readEntry(conn, entry)
+	})
+}
+
+// AuthenticateUser 校验目录用户的密码并返回最新的目录信息，优先使用已关联的DN
+func (s *LDAPService) AuthenticateUser(user *models.User, password string) (*LDAPEntry, error) {
+	return s.authenticate(password, func(conn *ldap.Conn) (*LDAPEntry, error) {
+		return s.findUser(conn, user)
+	})
+}
+
+// authenticate 先用服务账号查找用户和组，再用用户DN和密码绑定
+func (s *LDAPService) authenticate(password string, find func(conn *ldap.Conn) (*LDAPEntry, error)) (*LDAPEntry, error) {
+	if !s.Enabled() {
+		return nil, ErrLDAPDisabled
+	}
+	// 空密码绑定在多数目录中会被当作匿名绑定而成功
+	if password == "" {
+		return nil, ErrInvalidPassword
+	}
+
+	conn, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := s.bindService(conn); err != nil {
+		return nil, err
+	}
+	entry, err := find(conn)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.bindUser(conn, entry.DN, password); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// LookupUser 按登录名查询目录用户（不校验密码），用于管理员把账户标记为目录用户
+func (s *LDAPService) LookupUser(username string) (*LDAPEntry, error) {
+	if !s.Enabled() {
+		return nil, ErrLDAPDisabled
+	}
+
+	conn, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := s.bindService(conn); err != nil {
+		return nil, err
+	}
+	entry, err := s.searchUser(conn, username)
+	if err != nil {
+		return nil, err
+	}
+	return s.readEntry(conn, entry)
+}
+
+// LoginNewUser 本地不存在的用户通过目录认证后自动创建账户
+func (s *LDAPService) LoginNewUser(username, password string) (*models.User, error) {
+	entry, err := s.Authenticate(username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一DN已关联过账户（如本地用户名被修改过）
+	var identity models.UserIdentity
+	err = database.DB.Where("provider = ? AND subject = ?", IdentityProviderLDAP, entry.DN).First(&identity).Error
+	if err == nil {
+		var user models.User
+		if err := database.DB.First(&user, identity.UserID).Error; err != nil {
+			return nil, fmt.Errorf("关联的用户不存在")
+		}
+		if user.AuthSource != AuthSourceLDAP {
+			return nil, fmt.Errorf("该账户不是目录用户，请使用本地密码登录")
+		}
+		if err := s.SyncUser(&user, entry); err != nil {
+			log.Printf("同步目录用户 %s 失败: %v", user.Username, err)
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("查询外部身份失败: %w", err)
+	}
+
+	if !s.cfg.AutoProvision {
+		return nil, fmt.Errorf("用户不存在，请联系管理员开通账户")
+	}
+	if entry.Email == "" {
+		return nil, fmt.Errorf("目录中未设置 %s 的邮箱，无法创建账户", entry.Username)
+	}
+	// 不自动接管同邮箱的本地账户，需要管理员显式标记为目录用户
+	var count int64
+	if err := database.DB.Model(&models.User{}).Where("email = ?", entry.Email).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("邮箱 %s 已被本地账户使用，请联系管理员将其标记为目录用户", entry.Email)
+	}
+
+	return s.provisionUser(entry)
+}
+
+// LinkUser 把已有账户关联到目录用户
+func (s *LDAPService) LinkUser(user *models.User, entry *LDAPEntry) error {
+	var identity models.UserIdentity
+	err := database.DB.Where("provider = ? AND subject = ?", IdentityProviderLDAP, entry.DN).First(&identity).Error
+	if err == nil && identity.UserID != user.ID {
+		return fmt.Errorf("目录用户 %s 已关联到其他账户", entry.DN)
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("查询外部身份失败: %w", err)
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND provider = ?", user.ID, IdentityProviderLDAP).
+			Delete(&models.UserIdentity{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.UserIdentity{
+			UserID:   user.ID,
+			Provider: IdentityProviderLDAP,
+			Subject:  entry.DN,
+			Email:    entry.Email,
+		}).Error; err != nil {
+			return fmt.Errorf("关联目录用户失败: %w", err)
+		}
+		return tx.Model(user).Update("auth_source", AuthSourceLDAP).Error
+	})
+}
+
+// SyncUser 用目录信息更新本地账户的邮箱、昵称和角色
+func (s *LDAPService) SyncUser(user *models.User, entry *LDAPEntry) error {
+	updates := map[string]interface{}{}
+	if entry.Name != "" && entry.Name != user.Nickname {
+		updates["nickname"] = entry.Name
+	}
+	if entry.Email != "" && !strings.EqualFold(entry.Email, user.Email) {
+		var count int64
+		database.DB.Model(&models.User{}).Where("email = ? AND id <> ?", entry.Email, user.ID).Count(&count)
+		if count == 0 {
+			updates["email"] = entry.Email
+		} else {
+			log.Printf("目录用户 %s 的邮箱 %s 已被其他账户使用，跳过同步", entry.DN, entry.Email)
+		}
+	}
+	if len(updates) > 0 {
+		if err := database.DB.Model(user).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新用户失败: %w", err)
+		}
+	}
+
+	database.DB.Model(&models.UserIdentity{}).
+		Where("user_id = ? AND provider = ?", user.ID, IdentityProviderLDAP).
+		Updates(map[string]interface{}{
+			"subject": entry.DN,
+			"email":   entry.Email,
+		})
+
+	// 目录中的组决定角色，被移出所有映射的组时降为默认角色；组映射来自系统配置，按管理员授权
+	if role := s.mapRole(entry.Groups); role != "" && role != user.Role {
+		if err := s.rbacService.AssignRole(RoleAdmin, user.ID, role); err != nil {
+			return fmt.Errorf("同步用户 %s 的角色失败: %w", user.Username, err)
+		}
+		log.Printf("目录用户 %s 的角色按组同步为 %s", user.Username, role)
+		user.Role = role
+	}
+	return nil
+}
+
+// SyncGroups 同步所有目录用户的组和状态，目录中已删除的用户会被禁用
+func (s *LDAPService) SyncGroups(ctx context.Context) (*LDAPSyncResult, error) {
+	if !s.Enabled() {
+		return nil, ErrLDAPDisabled
+	}
+
+	var users []models.User
+	if err := database.DB.Where("auth_source = ?", AuthSourceLDAP).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("查询目录用户失败: %w", err)
+	}
+
+	result := &LDAPSyncResult{}
+	if len(users) == 0 {
+		return result, nil
+	}
+
+	conn, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := s.bindService(conn); err != nil {
+		return nil, err
+	}
+
+	for i := range users {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		user := &users[i]
+		result.Checked++
+
+		entry, err := s.findUser(conn, user)
+		if errors.Is(err, ErrLDAPUserNotFound) {
+			if user.Status == "active" {
+				if err := s.disableUser(ctx, user); err != nil {
+					log.Printf("禁用目录用户 %s 失败: %v", user.Username, err)
+					result.Failed++
+					continue
+				}
+				result.Disabled++
+			}
+			continue
+		}
+		if err != nil {
+			log.Printf("查询目录用户 %s 失败: %v", user.Username, err)
+			result.Failed++
+			continue
+		}
+
+		role := user.Role
+		if err := s.SyncUser(user, entry); err != nil {
+			log.Printf("同步目录用户 %s 失败: %v", user.Username, err)
+			result.Failed++
+			continue
+		}
+		if user.Role != role {
+			result.Updated++
+		}
+	}
+
+	return result, nil
+}
+
+// StartGroupSync 按固定间隔在后台同步目录用户，返回停止函数
+func (s *LDAPService) StartGroupSync(interval time.Duration) func() {
+	stopChan := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopChan:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				result, err := s.SyncGroups(ctx)
+				cancel()
+				if err != nil {
+					log.Printf("LDAP组同步失败: %v", err)
+					continue
+				}
+				log.Printf("LDAP组同步完成: 检查 %d，角色变更 %d，禁用 %d，失败 %d",
+					result.Checked, result.Updated, result.Disabled, result.Failed)
+			}
+		}
+	}()
+
+	log.Printf("LDAP组同步已启动，间隔 %s", interval)
+	return func() { close(stopChan) }
+}
+
+// provisionUser 首次登录时创建目录用户，本地密码随机生成且不会被使用
+func (s *LDAPService) provisionUser(entry *LDAPEntry) (*models.User, error) {
+	username, err := uniqueUsername(entry.Username, entry.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	randomPassword, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := utils.HashPassword(randomPassword)
+	if err != nil {
+		return nil, fmt.Errorf("密码加密失败: %v", err)
+	}
+
+	role := s.mapRole(entry.Groups)
+	if role == "" {
+		role = s.cfg.DefaultRole
+	}
+	if role == "" {
+		role = RoleUser
+	}
+
+	now := time.Now()
+	user := models.User{
+		Username:   username,
+		Email:      entry.Email,
+		Password:   hashedPassword,
+		Nickname:   entry.Name,
+		Role:       role,
+		Status:     "active",
+		AuthSource: AuthSourceLDAP,
+		// 目录中的邮箱由目录管理员维护，视为已验证
+		EmailVerified: true,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return fmt.Errorf("创建用户失败: %v", err)
+		}
+		return tx.Create(&models.UserIdentity{
+			UserID:      user.ID,
+			Provider:    IdentityProviderLDAP,
+			Subject:     entry.DN,
+			Email:       entry.Email,
+			LastLoginAt: &now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("通过LDAP创建用户 %s（角色 %s）", user.Username, user.Role)
+	return &user, nil
+}
+
+// disableUser 禁用目录中已删除的用户并吊销其会话
+func (s *LDAPService) disableUser(ctx context.Context, user *models.User) error {
+	if err := database.DB.Model(user).Update("status", "inactive").Error; err != nil {
+		return err
+	}
+	if _, err := s.sessionService.RevokeAllSessions(ctx, user.ID, ""); err != nil {
+		return err
+	}
+	log.Printf("目录用户 %s 已不存在，账户已禁用", user.Username)
+	return nil
+}
+
+// mapRole 按配置顺序返回第一个匹配的组对应的角色，配置了映射但没有匹配的组时返回默认角色
+func (s *LDAPService) mapRole(groups []string) string {
+	if len(s.cfg.RoleMapping) == 0 {
+		return ""
+	}
+	if role := mapGroupsToRole(s.cfg.RoleMapping, groups); role != "" {
+		return role
+	}
+	return s.cfg.DefaultRole
+}
+
+// connect 连接目录服务器
+func (s *LDAPService) connect() (*ldap.Conn, error) {
+	timeout := time.Duration(s.cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: s.cfg.InsecureSkipVerify}
+	if u, err := url.Parse(s.cfg.URL); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	conn, err := ldap.DialURL(s.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("连接LDAP服务器失败: %w", err)
+	}
+	conn.SetTimeout(timeout)
+
+	if s.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("LDAP StartTLS失败: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// bindService 使用服务账号绑定，未配置时保持匿名
+func (s *LDAPService) bindService(conn *ldap.Conn) error {
+	if s.cfg.BindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(s.cfg.BindDN, s.cfg.BindPassword); err != nil {
+		return fmt.Errorf("LDAP服务账号绑定失败: %w", err)
+	}
+	return nil
+}
+
+// bindUser 使用用户DN和密码绑定
+func (s *LDAPService) bindUser(conn *ldap.Conn, dn, password string) error {
+	if err := conn.Bind(dn, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return ErrInvalidPassword
+		}
+		return fmt.Errorf("LDAP绑定失败: %w", err)
+	}
+	return nil
+}
+
+// searchUser 按登录名搜索用户，必须唯一匹配
+func (s *LDAPService) searchUser(conn *ldap.Conn, username string) (*ldap.Entry, error) {
+	filter := strings.ReplaceAll(s.cfg.UserFilter, "{username}", ldap.EscapeFilter(username))
+	entry, err := s.searchUnique(conn, filter)
+	if errors.Is(err, errLDAPMultipleEntries) {
+		return nil, fmt.Errorf("登录名 %s 匹配到多个目录用户，请检查user_filter", username)
+	}
+	return entry, err
+}
+
+// searchUserByEmail 按邮箱搜索用户，必须唯一匹配
+func (s *LDAPService) searchUserByEmail(conn *ldap.Conn, email string) (*ldap.Entry, error) {
+	filter := fmt.Sprintf("(%s=%s)", ldap.EscapeFilter(s.cfg.EmailAttribute), ldap.EscapeFilter(email))
+	entry, err := s.searchUnique(conn, filter)
+	if errors.Is(err, errLDAPMultipleEntries) {
+		return nil, fmt.Errorf("邮箱 %s 匹配到多个目录用户", email)
+	}
+	return entry, err
+}
+
+// searchUnique 在BaseDN下搜索唯一匹配的用户
+func (s *LDAPService) searchUnique(conn *ldap.Conn, filter string) (*ldap.Entry, error) {
+	result, err := conn.Search(ldap.NewSearchRequest(
+		s.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		filter, s.userAttributes(), nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("搜索LDAP用户失败: %w", err)
+	}
+	if result == nil || len(result.Entries) == 0 {
+		return nil, ErrLDAPUserNotFound
+	}
+	if len(result.Entries) > 1 {
+		return nil, errLDAPMultipleEntries
+	}
+	return result.Entries[0], nil
+}
+
+// lookupDN 按DN读取用户
+func (s *LDAPService) lookupDN(conn *ldap.Conn, dn string) (*ldap.Entry, error) {
+	result, err := conn.Search(ldap.NewSearchRequest(
+		dn, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, 0, false,
+		"(objectClass=*)", s.userAttributes(), nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, ErrLDAPUserNotFound
+		}
+		return nil, fmt.Errorf("读取LDAP用户失败: %w", err)
+	}
+	if len(result.Entries) == 0 {
+		return nil, ErrLDAPUserNotFound
+	}
+	return result.Entries[0], nil
+}
+
+// findUser 查找本地账户对应的目录用户
+// 已关联的DN失效时只按关联时记录的邮箱重新查找（用户在目录中被移动或改名），不按本地用户名搜索，
+// 避免目录中同名的其他用户接管账户；找不到时返回ErrLDAPUserNotFound，组同步会禁用该账户
+func (s *LDAPService) findUser(conn *ldap.Conn, user *models.User) (*LDAPEntry, error) {
+	var identity models.UserIdentity
+	err := database.DB.Where("user_id = ? AND provider = ?", user.ID, IdentityProviderLDAP).First(&identity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 没有关联记录时按登录名搜索
+		entry, err := s.searchUser(conn, user.Username)
+		if err != nil {
+			return nil, err
+		}
+		return s.readEntry(conn, entry)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询外部身份失败: %w", err)
+	}
+
+	entry, err := s.lookupDN(conn, identity.Subject)
+	if errors.Is(err, ErrLDAPUserNotFound) {
+		if identity.Email == "" {
+			return nil, ErrLDAPUserNotFound
+		}
+		if entry, err = s.searchUserByEmail(conn, identity.Email); err != nil {
+			return nil, err
+		}
+		// 新DN已关联到其他账户时不接管
+		var count int64
+		if err := database.DB.Model(&models.UserIdentity{}).
+			Where("provider = ? AND subject = ? AND user_id <> ?", IdentityProviderLDAP, entry.DN, user.ID).
+			Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("查询外部身份失败: %w", err)
+		}
+		if count > 0 {
+			return nil, ErrLDAPUserNotFound
+		}
+		log.Printf("目录用户 %s 的DN已变更为 %s", identity.Subject, entry.DN)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.readEntry(conn, entry)
+}
+
+// readEntry 读取用户属性和所属组
+func (s *LDAPService) readEntry(conn *ldap.Conn, entry *ldap.Entry) (*LDAPEntry, error) {
+	result := &LDAPEntry{
+		DN:       entry.DN,
+		Username: entry.GetAttributeValue(s.cfg.UsernameAttribute),
+		Email:    entry.GetAttributeValue(s.cfg.EmailAttribute),
+		Name:     entry.GetAttributeValue(s.cfg.NameAttribute),
+	}
+
+	if s.cfg.GroupBaseDN == "" {
+		for _, dn := range entry.GetAttributeValues("memberOf") {
+			result.Groups = append(result.Groups, dn)
+			if name := firstRDNValue(dn); name != "" {
+				result.Groups = append(result.Groups, name)
+			}
+		}
+		return result, nil
+	}
+
+	filter := strings.NewReplacer(
+		"{dn}", ldap.EscapeFilter(entry.DN),
+		"{username}", ldap.EscapeFilter(result.Username),
+	).Replace(s.cfg.GroupFilter)
+	groups, err := conn.Search(ldap.NewSearchRequest(
+		s.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		filter, []string{s.cfg.GroupNameAttribute}, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("搜索LDAP组失败: %w", err)
+	}
+	for _, group := range groups.Entries {
+		result.Groups = append(result.Groups, group.DN)
+		if name := group.GetAttributeValue(s.cfg.GroupNameAttribute); name != "" {
+			result.Groups = append(result.Groups, name)
+		}
+	}
+	return result, nil
+}
+
+// userAttributes 搜索用户时读取的属性
+func (s *LDAPService) userAttributes() []string {
+	return []string{s.cfg.UsernameAttribute, s.cfg.EmailAttribute, s.cfg.NameAttribute, "memberOf"}
+}
+
+// firstRDNValue 返回DN第一个RDN的值，如 cn=admins,ou=groups 返回 admins
+func firstRDNValue(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 || len(parsed.RDNs[0].Attributes) == 0 {
+		return ""
+	}
+	return parsed.RDNs[0].Attributes[0].Value
+}
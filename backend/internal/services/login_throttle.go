@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// LoginThrottleStore 按IP统计登录失败次数的计数器，计数在时间窗口结束后清零
+type LoginThrottleStore interface {
+	// Failures 返回IP在当前窗口内的失败次数
+	Failures(ctx context.Context, ip string) (int, error)
+	// AddFailure 增加一次失败，返回增加后的次数；窗口从第一次失败开始计算
+	AddFailure(ctx context.Context, ip string, window time.Duration) (int, error)
+}
+
+// loginThrottleCleanupInterval 内存计数器清理过期记录的间隔
+const loginThrottleCleanupInterval = time.Minute
+
+// redisLoginFailurePrefix Redis中IP登录失败计数的键前缀
+const redisLoginFailurePrefix = "emby-manager:login-failures:"
+
+var (
+	loginThrottleStore LoginThrottleStore = newMemoryLoginThrottleStore()
+	loginThrottleMutex sync.RWMutex
+)
+
+// InitLoginThrottle 根据配置初始化IP登录失败计数器，启用Redis时多副本共享计数，连接失败时回退到内存
+func InitLoginThrottle(cfg config.RedisConfig) {
+	var store LoginThrottleStore = newMemoryLoginThrottleStore()
+
+	if cfg.Enabled {
+		client, err := connectRedis(cfg)
+		if err != nil {
+			log.Printf("连接Redis失败，登录失败计数保存在内存中: %v", err)
+		} else {
+			store = &redisLoginThrottleStore{client: client}
+			log.Println("登录失败计数使用Redis")
+		}
+	}
+
+	loginThrottleMutex.Lock()
+	loginThrottleStore = store
+	loginThrottleMutex.Unlock()
+}
+
+// getLoginThrottleStore 获取当前的IP登录失败计数器
+func getLoginThrottleStore() LoginThrottleStore {
+	loginThrottleMutex.RLock()
+	defer loginThrottleMutex.RUnlock()
+	return loginThrottleStore
+}
+
+// memoryLoginThrottleStore 基于内存的计数器，只在单个实例内生效
+type memoryLoginThrottleStore struct {
+	entries     map[string]*loginFailureWindow
+	lastCleanup time.Time
+	mutex       sync.Mutex
+}
+
+type loginFailureWindow struct {
+	count     int
+	expiresAt time.Time
+}
+
+func newMemoryLoginThrottleStore() *memoryLoginThrottleStore {
+	return &memoryLoginThrottleStore{entries: make(map[string]*loginFailureWindow)}
+}
+
+func (s *memoryLoginThrottleStore) Failures(ctx context.Context, ip string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.entries[ip]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return 0, nil
+	}
+	return entry.count, nil
+}
+
+func (s *memoryLoginThrottleStore) AddFailure(ctx context.Context, ip string, window time.Duration) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	s.cleanup(now)
+
+	entry, ok := s.entries[ip]
+	if !ok || now.After(entry.expiresAt) {
+		entry = &loginFailureWindow{expiresAt: now.Add(window)}
+		s.entries[ip] = entry
+	}
+	entry.count++
+	return entry.count, nil
+}
+
+// cleanup 定期删除窗口已结束的记录，调用方需持有锁
+func (s *memoryLoginThrottleStore) cleanup(now time.Time) {
+	if now.Sub(s.lastCleanup) < loginThrottleCleanupInterval {
+		return
+	}
+	s.lastCleanup = now
+	for ip, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, ip)
+		}
+	}
+}
+
+// redisLoginThrottleStore 基于Redis的计数器，计数随窗口过期自动删除
+type redisLoginThrottleStore struct {
+	client *redis.Client
+}
+
+func (s *redisLoginThrottleStore) Failures(ctx context.Context, ip string) (int, error) {
+	n, err := s.client.Get(ctx, redisLoginFailurePrefix+ip).Int()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+func (s *redisLoginThrottleStore) AddFailure(ctx context.Context, ip string, window time.Duration) (int, error) {
+	key := redisLoginFailurePrefix + ip
+	n, err := s.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if n == 1 {
+		if err := s.client.Expire(ctx, key, window).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return int(n), nil
+}
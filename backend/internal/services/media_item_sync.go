@@ -0,0 +1,408 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/pkg/emby"
+)
+
+// libraryItemPageSize 目录同步时每页抓取的项目数
+const libraryItemPageSize = 200
+
+// 项目同步模式
+const (
+	SyncModeFull        = "full"
+	SyncModeIncremental = "incremental"
+)
+
+// deleteBatchSize 对账删除时每批处理的项目数
+const deleteBatchSize = 500
+
+// ItemSyncResult 媒体项目同步结果
+type ItemSyncResult struct {
+	Mode     string `json:"mode"`
+	Added    int    `json:"added"`
+	Updated  int    `json:"updated"`
+	Removed  int    `json:"removed"`
+	Total    int    `json:"total"`
+	Duration int64  `json:"duration"` // 毫秒
+}
+
+// SyncLibraryItems 同步媒体库项目到MediaItem表。
+// 首次同步或full为true时全量抓取，否则只请求上次同步后变更的项目，再单独对账删除
+func (s *MediaService) SyncLibraryItems(ctx context.Context, client *emby.Client, library *models.MediaLibrary, full bool) (*ItemSyncResult, error) {
+	if library.EmbyLibraryID == "" {
+		return nil, fmt.Errorf("媒体库 %s 缺少Emby媒体库ID", library.Name)
+	}
+
+	started := time.Now()
+	result := &ItemSyncResult{Mode: SyncModeIncremental}
+	if full || library.ItemHighWaterMark == nil {
+		result.Mode = SyncModeFull
+	}
+
+	// 截断到毫秒，避免数据库时间精度不同导致误删
+	syncStart := started.Truncate(time.Millisecond)
+
+	fetch := func(startIndex int) ([]emby.MediaItem, int, error) {
+		if result.Mode == SyncModeFull {
+			return client.GetLibraryItems(ctx, library.EmbyLibraryID, startIndex, libraryItemPageSize)
+		}
+		return client.GetChangedLibraryItems(ctx, library.EmbyLibraryID, *library.ItemHighWaterMark, startIndex, libraryItemPageSize)
+	}
+
+	highWaterMark := library.ItemHighWaterMark
+	startIndex, totalCount := 0, 0
+	for {
+		items, total, err := fetch(startIndex)
+		if err != nil {
+			return result, fmt.Errorf("获取媒体库 %s 项目失败: %w", library.Name, err)
+		}
+
+		added, updated, err := s.upsertMediaItems(ctx, library.ID, items, syncStart)
+		if err != nil {
+			return result, err
+		}
+		result.Added += added
+		result.Updated += updated
+		// 取各页报告的最大总数，避免异常的末页把总数改小
+		if total > totalCount {
+			totalCount = total
+		}
+
+		for _, item := range items {
+			if saved := item.LastSavedAt(); !saved.IsZero() && (highWaterMark == nil || saved.After(*highWaterMark)) {
+				highWaterMark = &saved
+			}
+		}
+
+		startIndex += len(items)
+		if len(items) == 0 || startIndex >= totalCount {
+			break
+		}
+	}
+
+	// 软删除上游已不存在的项目
+	var removed int
+	var err error
+	if result.Mode == SyncModeFull {
+		removed, err = s.removeStaleItems(ctx, library, startIndex, totalCount, syncStart)
+	} else {
+		removed, err = s.reconcileDeletedItems(ctx, client, library)
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Removed = removed
+	result.Total = result.Added + result.Updated
+	result.Duration = time.Since(started).Milliseconds()
+
+	if err := s.recordLibrarySync(ctx, library.ID, result, highWaterMark); err != nil {
+		return result, err
+	}
+
+	log.Printf("媒体库 %s 项目同步完成 [%s]: 新增 %d, 更新 %d, 移除 %d, 耗时 %dms",
+		library.Name, result.Mode, result.Added, result.Updated, result.Removed, result.Duration)
+
+	return result, nil
+}
+
+// upsertMediaItems 批量写入一页媒体项目，已软删除的项目重新出现时会被恢复
+func (s *MediaService) upsertMediaItems(ctx context.Context, libraryID uint, items []emby.MediaItem, seenAt time.Time) (int, int, error) {
+	if len(items) == 0 {
+		return 0, 0, nil
+	}
+
+	embyIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		embyIDs = append(embyIDs, item.ID)
+	}
+
+	var existing []models.MediaItem
+	if err := database.DB.WithContext(ctx).Unscoped().
+		Select("id", "emby_item_id").
+		Where("media_library_id = ? AND emby_item_id IN ?", libraryID, embyIDs).
+		Find(&existing).Error; err != nil {
+		return 0, 0, fmt.Errorf("查询已有媒体项目失败: %w", err)
+	}
+
+	existingIDs := make(map[string]uint, len(existing))
+	for _, item := range existing {
+		existingIDs[item.EmbyItemID] = item.ID
+	}
+
+	tx := database.DB.WithContext(ctx).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	added, updated := 0, 0
+	var newItems []models.MediaItem
+
+	for _, item := range items {
+		record := buildMediaItem(libraryID, item, seenAt)
+
+		id, exists := existingIDs[item.ID]
+		if !exists {
+			newItems = append(newItems, record)
+			existingIDs[item.ID] = 0 // 防止同一页内重复项目
+			continue
+		}
+		if id == 0 {
+			continue
+		}
+
+		updates := map[string]interface{}{
+			"name":           record.Name,
+			"type":           record.Type,
+			"path":           record.Path,
+			"parent_id":      record.ParentID,
+			"series_name":    record.SeriesName,
+			"season_number":  record.SeasonNumber,
+			"episode_number": record.EpisodeNumber,
+			"year":           record.Year,
+			"run_time_ticks": record.RunTimeTicks,
+			"size":           record.Size,
+			"container":      record.Container,
+			"video_codec":    record.VideoCodec,
+			"audio_codec":    record.AudioCodec,
+			"resolution":     record.Resolution,
+			"last_seen_at":   record.LastSeenAt,
+			"deleted_at":     nil,
+		}
+		if err := tx.Unscoped().Model(&models.MediaItem{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+			tx.Rollback()
+			return 0, 0, fmt.Errorf("更新媒体项目失败: %w", err)
+		}
+		updated++
+	}
+
+	if len(newItems) > 0 {
+		if err := tx.CreateInBatches(newItems, 100).Error; err != nil {
+			tx.Rollback()
+			return 0, 0, fmt.Errorf("创建媒体项目失败: %w", err)
+		}
+		added = len(newItems)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return 0, 0, fmt.Errorf("提交事务失败: %w", err)
+	}
+
+	return added, updated, nil
+}
+
+// removeStaleItems 软删除本次同步中未出现的媒体项目
+// 分页在拿到上游报告的总数之前中断（空页或短页），或上游返回空而本地有项目时放弃清理，避免一次异常响应清空媒体库
+func (s *MediaService) removeStaleItems(ctx context.Context, library *models.MediaLibrary, fetched, total int, syncStart time.Time) (int, error) {
+	if fetched < total {
+		log.Printf("媒体库 %s 只获取到 %d/%d 个项目，跳过删除清理", library.Name, fetched, total)
+		return 0, nil
+	}
+	if total == 0 {
+		var localCount int64
+		if err := database.DB.WithContext(ctx).Model(&models.MediaItem{}).
+			Where("media_library_id = ?", library.ID).
+			Count(&localCount).Error; err != nil {
+			return 0, fmt.Errorf("查询本地媒体项目失败: %w", err)
+		}
+		if localCount > 0 {
+			log.Printf("媒体库 %s 上游未返回任何项目，本地有 %d 个项目，跳过删除清理", library.Name, localCount)
+			return 0, nil
+		}
+	}
+
+	result := database.DB.WithContext(ctx).
+		Where("media_library_id = ? AND (last_seen_at IS NULL OR last_seen_at < ?)", library.ID, syncStart).
+		Delete(&models.MediaItem{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("清理已移除的媒体项目失败: %w", result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
+
+// reconcileDeletedItems 只拉取上游项目ID，软删除本地多出的项目
+// 增量同步不会恢复被误删的项目，所以拿到的ID数少于上游报告的总数，或上游返回空而本地有项目时放弃本次对账，留给全量同步处理
+func (s *MediaService) reconcileDeletedItems(ctx context.Context, client *emby.Client, library *models.MediaLibrary) (int, error) {
+	upstreamIDs, total, err := client.GetLibraryItemIDs(ctx, library.EmbyLibraryID)
+	if err != nil {
+		return 0, fmt.Errorf("获取媒体库 %s 项目ID失败: %w", library.Name, err)
+	}
+
+	upstream := make(map[string]struct{}, len(upstreamIDs))
+	for _, id := range upstreamIDs {
+		upstream[id] = struct{}{}
+	}
+	if len(upstream) < total {
+		log.Printf("媒体库 %s 只获取到 %d/%d 个项目ID，跳过删除对账", library.Name, len(upstream), total)
+		return 0, nil
+	}
+
+	var localIDs []string
+	if err := database.DB.WithContext(ctx).Model(&models.MediaItem{}).
+		Where("media_library_id = ?", library.ID).
+		Pluck("emby_item_id", &localIDs).Error; err != nil {
+		return 0, fmt.Errorf("查询本地媒体项目失败: %w", err)
+	}
+	if len(upstream) == 0 && len(localIDs) > 0 {
+		log.Printf("媒体库 %s 上游未返回任何项目，本地有 %d 个项目，跳过删除对账", library.Name, len(localIDs))
+		return 0, nil
+	}
+
+	var missing []string
+	for _, id := range localIDs {
+		if _, ok := upstream[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+
+	removed := 0
+	for start := 0; start < len(missing); start += deleteBatchSize {
+		end := start + deleteBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+
+		result := database.DB.WithContext(ctx).
+			Where("media_library_id = ? AND emby_item_id IN ?", library.ID, missing[start:end]).
+			Delete(&models.MediaItem{})
+		if result.Error != nil {
+			return removed, fmt.Errorf("清理已移除的媒体项目失败: %w", result.Error)
+		}
+		removed += int(result.RowsAffected)
+	}
+
+	return removed, nil
+}
+
+// recordLibrarySync 重新计算媒体库的项目数和总大小，并记录本次同步统计和高水位
+func (s *MediaService) recordLibrarySync(ctx context.Context, libraryID uint, result *ItemSyncResult, highWaterMark *time.Time) error {
+	var totals struct {
+		TotalItems int64
+		TotalSize  int64
+	}
+	if err := database.DB.WithContext(ctx).Model(&models.MediaItem{}).
+		Select("COUNT(*) AS total_items, COALESCE(SUM(size), 0) AS total_size").
+		Where("media_library_id = ?", libraryID).
+		Scan(&totals).Error; err != nil {
+		return fmt.Errorf("统计媒体库项目失败: %w", err)
+	}
+
+	now := time.Now()
+	if err := database.DB.WithContext(ctx).Model(&models.MediaLibrary{}).
+		Where("id = ?", libraryID).
+		Updates(map[string]interface{}{
+			"total_items":          totals.TotalItems,
+			"total_size":           totals.TotalSize,
+			"last_refresh":         &now,
+			"item_high_water_mark": highWaterMark,
+			"last_sync_at":         &now,
+			"last_sync_mode":       result.Mode,
+			"last_sync_added":      result.Added,
+			"last_sync_updated":    result.Updated,
+			"last_sync_removed":    result.Removed,
+			"last_sync_duration":   result.Duration,
+		}).Error; err != nil {
+		return fmt.Errorf("更新媒体库统计失败: %w", err)
+	}
+
+	return nil
+}
+
+// buildMediaItem 将Emby项目转换为MediaItem模型
+func buildMediaItem(libraryID uint, item emby.MediaItem, seenAt time.Time) models.MediaItem {
+	record := models.MediaItem{
+		MediaLibraryID: libraryID,
+		EmbyItemID:     item.ID,
+		Name:           item.Name,
+		Type:           item.Type,
+		Path:           item.Path,
+		ParentID:       item.ParentID,
+		SeriesName:     item.SeriesName,
+		Year:           item.ProductionYear,
+		RunTimeTicks:   item.RunTimeTicks,
+		Container:      item.Container,
+		LastSeenAt:     &seenAt,
+	}
+
+	switch item.Type {
+	case "Season":
+		record.ParentID = firstNonEmpty(item.SeriesID, item.ParentID)
+		record.SeasonNumber = item.IndexNumber
+	case "Episode":
+		record.ParentID = firstNonEmpty(item.SeriesID, item.ParentID)
+		record.SeasonNumber = item.ParentIndexNumber
+		record.EpisodeNumber = item.IndexNumber
+	case "Series":
+		record.SeriesName = item.Name
+	}
+
+	streams := item.MediaStreams
+	if len(item.MediaSources) > 0 {
+		source := item.MediaSources[0]
+		record.Size = source.Size
+		record.Container = firstNonEmpty(record.Container, source.Container)
+		record.Path = firstNonEmpty(record.Path, source.Path)
+		if record.RunTimeTicks == 0 {
+			record.RunTimeTicks = source.RunTimeTicks
+		}
+		if len(streams) == 0 {
+			streams = source.MediaStreams
+		}
+	}
+
+	width, height := item.Width, item.Height
+	for _, stream := range streams {
+		switch stream.Type {
+		case "Video":
+			if record.VideoCodec == "" {
+				record.VideoCodec = stream.Codec
+				if width == 0 {
+					width, height = stream.Width, stream.Height
+				}
+			}
+		case "Audio":
+			if record.AudioCodec == "" {
+				record.AudioCodec = stream.Codec
+			}
+		}
+	}
+	record.Resolution = resolutionLabel(width, height)
+
+	return record
+}
+
+// resolutionLabel 根据视频宽高生成分辨率标签
+func resolutionLabel(width, height int) string {
+	switch {
+	case width == 0 && height == 0:
+		return ""
+	case width >= 3800 || height >= 2100:
+		return "2160p"
+	case width >= 1900 || height >= 1060:
+		return "1080p"
+	case width >= 1260 || height >= 700:
+		return "720p"
+	case width >= 700 || height >= 470:
+		return "480p"
+	default:
+		return "SD"
+	}
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -4,26 +4,23 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/emby-client-go/backend/internal/database"
 	"github.com/emby-client-go/backend/internal/models"
-	"github.com/emby-client-go/backend/pkg/emby"
+	"gorm.io/gorm"
 )
 
 // MediaService 媒体库服务
 type MediaService struct {
-	cache      sync.Map // 简单的内存缓存
-	cacheTTL   time.Duration
-	syncMutex  sync.Mutex // 同步锁，防止并发同步同一服务器
+	cache      sync.Map // 简单的内存缓存，有效期由emby配置及服务器的cache_ttl决定
 }
 
 // NewMediaService 创建媒体库服务
 func NewMediaService() *MediaService {
-	return &MediaService{
-		cacheTTL: 5 * time.Minute, // 缓存5分钟
-	}
+	return &MediaService{}
 }
 
 // cacheKey 缓存键结构
@@ -38,12 +35,30 @@ type cacheValue struct {
 	expiresAt time.Time
 }
 
-// setCache 设置缓存
+// cacheTTL 获取服务器的缓存有效期，优先使用共享客户端记录的值，避免每次写缓存都查询数据库
+func (s *MediaService) cacheTTL(serverID uint) time.Duration {
+	if ttl, ok := pooledCacheTTL(serverID); ok {
+		return ttl
+	}
+
+	var server models.EmbyServer
+	if err := database.DB.Select("id", "cache_ttl").First(&server, serverID).Error; err != nil {
+		return serverCacheTTL(&models.EmbyServer{})
+	}
+	return serverCacheTTL(&server)
+}
+
+// setCache 设置缓存，缓存被禁用时不做任何处理
 func (s *MediaService) setCache(serverID uint, key string, value interface{}) {
+	ttl := s.cacheTTL(serverID)
+	if ttl <= 0 {
+		return
+	}
+
 	ck := cacheKey{serverID: serverID, key: key}
 	cv := cacheValue{
 		data:      value,
-		expiresAt: time.Now().Add(s.cacheTTL),
+		expiresAt: time.Now().Add(ttl),
 	}
 	s.cache.Store(ck, cv)
 }
@@ -76,20 +91,39 @@ func (s *MediaService) clearCache(serverID uint) {
 	})
 }
 
-// SyncMediaLibraries 同步服务器的媒体库
-func (s *MediaService) SyncMediaLibraries(ctx context.Context, serverID uint) (int, error) {
+// SyncProgressFunc 媒体库同步进度回调，done/total为已处理/总媒体库数
+type SyncProgressFunc func(library string, done, total int)
+
+// SyncMediaLibraries 同步服务器的媒体库，full为true时强制全量同步媒体项目
+// 同步期间持有该服务器的同步租约，服务器正在同步时返回ErrServerSyncing
+func (s *MediaService) SyncMediaLibraries(ctx context.Context, serverID uint, full bool) (int, error) {
+	lease, acquired, err := acquireSyncLease(serverID, 0)
+	if err != nil {
+		return 0, err
+	}
+	if !acquired {
+		return 0, syncingError(lease)
+	}
+	defer releaseSyncLease(serverID, 0)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go keepSyncLease(ctx, cancel, serverID, 0)
+
+	return s.syncMediaLibraries(ctx, serverID, full, nil)
+}
+
+// syncMediaLibraries 同步服务器的媒体库，并在每个媒体库处理完成后回调进度
+// 调用方需先持有该服务器的同步租约
+func (s *MediaService) syncMediaLibraries(ctx context.Context, serverID uint, full bool, onProgress SyncProgressFunc) (int, error) {
 	// 获取服务器信息
 	var server models.EmbyServer
 	if err := database.DB.First(&server, serverID).Error; err != nil {
 		return 0, fmt.Errorf("服务器不存在: %w", err)
 	}
 
-	// 防止并发同步
-	s.syncMutex.Lock()
-	defer s.syncMutex.Unlock()
-
 	// 创建Emby客户端
-	client := emby.NewClient(server.URL, server.APIKey)
+	client := GetEmbyClient(&server)
 
 	// 获取媒体库列表
 	libraries, err := client.GetLibraries(ctx)
@@ -121,6 +155,7 @@ func (s *MediaService) SyncMediaLibraries(ctx context.Context, serverID uint) (i
 			// 不存在，创建新记录
 			newLib := models.MediaLibrary{
 				EmbyServerID:   serverID,
+				EmbyLibraryID:  lib.LibraryID(),
 				Name:           lib.Name,
 				Type:           lib.CollectionType,
 				CollectionType: lib.CollectionType,
@@ -138,6 +173,7 @@ func (s *MediaService) SyncMediaLibraries(ctx context.Context, serverID uint) (i
 		} else {
 			// 已存在，更新记录
 			updates := map[string]interface{}{
+				"emby_library_id": lib.LibraryID(),
 				"type":            lib.CollectionType,
 				"collection_type": lib.CollectionType,
 				"total_items":     lib.ItemCount,
@@ -159,9 +195,40 @@ func (s *MediaService) SyncMediaLibraries(ctx context.Context, serverID uint) (i
 		return 0, fmt.Errorf("提交事务失败: %w", err)
 	}
 
+	// 同步各媒体库的项目
+	var syncedLibraries []models.MediaLibrary
+	if err := database.DB.Where("emby_server_id = ? AND emby_library_id <> ''", serverID).
+		Find(&syncedLibraries).Error; err != nil {
+		return syncCount, fmt.Errorf("查询媒体库失败: %w", err)
+	}
+
+	if onProgress != nil {
+		onProgress("", 0, len(syncedLibraries))
+	}
+
+	var itemErrors []string
+	for i := range syncedLibraries {
+		if ctx.Err() != nil {
+			return syncCount, ctx.Err()
+		}
+
+		if _, err := s.SyncLibraryItems(ctx, client, &syncedLibraries[i], full); err != nil {
+			log.Printf("同步媒体库 %s 项目失败: %v", syncedLibraries[i].Name, err)
+			itemErrors = append(itemErrors, fmt.Sprintf("%s: %v", syncedLibraries[i].Name, err))
+		}
+
+		if onProgress != nil {
+			onProgress(syncedLibraries[i].Name, i+1, len(syncedLibraries))
+		}
+	}
+
 	// 清除缓存
 	s.clearCache(serverID)
 
+	if len(itemErrors) > 0 {
+		return syncCount, fmt.Errorf("部分媒体库项目同步失败: %s", strings.Join(itemErrors, "; "))
+	}
+
 	log.Printf("服务器 %s 媒体库同步完成，共同步 %d 个媒体库", server.Name, syncCount)
 
 	return syncCount, nil
@@ -188,10 +255,11 @@ func (s *MediaService) GetMediaLibraries(serverID uint) ([]models.MediaLibrary,
 	return libraries, nil
 }
 
-// GetAllMediaLibraries 获取所有服务器的媒体库
-func (s *MediaService) GetAllMediaLibraries() ([]models.MediaLibrary, error) {
+// GetAllMediaLibraries 获取指定服务器范围内的媒体库，serverIDs为nil时返回所有服务器的媒体库
+func (s *MediaService) GetAllMediaLibraries(serverIDs []uint) ([]models.MediaLibrary, error) {
 	var libraries []models.MediaLibrary
-	if err := database.DB.Preload("EmbyServer").
+	if err := scopeServers(database.DB, "emby_server_id", serverIDs).
+		Preload("EmbyServer").
 		Order("emby_server_id, created_at DESC").
 		Find(&libraries).Error; err != nil {
 		return nil, fmt.Errorf("查询所有媒体库失败: %w", err)
@@ -228,7 +296,7 @@ func (s *MediaService) SyncAllServers(ctx context.Context) (map[uint]int, error)
 		go func(srv models.EmbyServer) {
 			defer wg.Done()
 
-			count, err := s.SyncMediaLibraries(ctx, srv.ID)
+			count, err := s.SyncMediaLibraries(ctx, srv.ID, false)
 			mu.Lock()
 			if err != nil {
 				log.Printf("同步服务器 %s 失败: %v", srv.Name, err)
@@ -254,10 +322,14 @@ func (s *MediaService) RefreshMediaLibrary(ctx context.Context, libraryID uint)
 	}
 
 	// 创建Emby客户端
-	client := emby.NewClient(library.EmbyServer.URL, library.EmbyServer.APIKey)
+	client := GetEmbyClient(&library.EmbyServer)
 
 	// 获取媒体库项目统计（分页获取第一页即可获取总数）
-	_, totalCount, err := client.GetLibraryItems(ctx, library.Name, 0, 1)
+	if library.EmbyLibraryID == "" {
+		return fmt.Errorf("媒体库 %s 缺少Emby媒体库ID，请先同步媒体库", library.Name)
+	}
+
+	_, totalCount, err := client.GetLibraryItems(ctx, library.EmbyLibraryID, 0, 1)
 	if err != nil {
 		return fmt.Errorf("获取媒体库统计失败: %w", err)
 	}
@@ -281,8 +353,12 @@ func (s *MediaService) RefreshMediaLibrary(ctx context.Context, libraryID uint)
 	return nil
 }
 
-// GetMediaLibraryStats 获取媒体库统计信息
-func (s *MediaService) GetMediaLibraryStats() (map[string]interface{}, error) {
+// GetMediaLibraryStats 获取指定服务器范围内的媒体库统计信息，serverIDs为nil时统计所有服务器
+func (s *MediaService) GetMediaLibraryStats(serverIDs []uint) (map[string]interface{}, error) {
+	libraries := func() *gorm.DB {
+		return scopeServers(database.DB.Model(&models.MediaLibrary{}), "emby_server_id", serverIDs)
+	}
+
 	var stats struct {
 		TotalLibraries int
 		TotalItems     int64
@@ -291,20 +367,20 @@ func (s *MediaService) GetMediaLibraryStats() (map[string]interface{}, error) {
 
 	// 统计媒体库数量
 	var count int64
-	if err := database.DB.Model(&models.MediaLibrary{}).Count(&count).Error; err != nil {
+	if err := libraries().Count(&count).Error; err != nil {
 		return nil, fmt.Errorf("统计媒体库数量失败: %w", err)
 	}
 	stats.TotalLibraries = int(count)
 
 	// 统计总项目数
-	if err := database.DB.Model(&models.MediaLibrary{}).
+	if err := libraries().
 		Select("COALESCE(SUM(total_items), 0)").
 		Scan(&stats.TotalItems).Error; err != nil {
 		return nil, fmt.Errorf("统计总项目数失败: %w", err)
 	}
 
 	// 统计总大小
-	if err := database.DB.Model(&models.MediaLibrary{}).
+	if err := libraries().
 		Select("COALESCE(SUM(total_size), 0)").
 		Scan(&stats.TotalSize).Error; err != nil {
 		return nil, fmt.Errorf("统计总大小失败: %w", err)
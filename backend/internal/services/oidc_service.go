@@ -0,0 +1,450 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/emby-client-go/backend/internal/config"
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/internal/utils"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+// IdentityProviderOIDC OIDC身份在UserIdentity中的提供商名称
+const IdentityProviderOIDC = "oidc"
+
+const (
+	// OIDCStateTTL 跳转到身份提供商后完成登录的时限
+	OIDCStateTTL = 10 * time.Minute
+	// oidcLoginCodeTTL 回调后前端换取令牌的时限
+	oidcLoginCodeTTL = time.Minute
+)
+
+var (
+	// ErrOIDCDisabled 未启用OIDC登录
+	ErrOIDCDisabled = errors.New("未启用单点登录")
+	// ErrOIDCInvalidState 登录状态无效或已过期
+	ErrOIDCInvalidState = errors.New("登录状态无效或已过期，请重新登录")
+)
+
+// OIDCClaims 从ID Token中读取的用户信息
+type OIDCClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified *bool
+	Username      string
+	Name          string
+	Groups        []string
+}
+
+// oidcProviderCache 身份提供商的发现结果，首次使用时加载，失败后下次重试
+var oidcProviderCache struct {
+	provider *oidc.Provider
+	mutex    sync.Mutex
+}
+
+// OIDCService OIDC单点登录服务
+type OIDCService struct {
+	cfg         config.OIDCConfig
+	rbacService *RBACService
+}
+
+// NewOIDCService 创建OIDC单点登录服务
+func NewOIDCService() *OIDCService {
+	return &OIDCService{
+		cfg:         config.AppConfig.OIDC,
+		rbacService: NewRBACService(),
+	}
+}
+
+// Enabled 是否启用OIDC登录
+func (s *OIDCService) Enabled() bool {
+	return s.cfg.Enabled && s.cfg.Issuer != "" && s.cfg.ClientID != ""
+}
+
+// ProviderName 登录页显示的身份提供商名称
+func (s *OIDCService) ProviderName() string {
+	return s.cfg.ProviderName
+}
+
+// FrontendURL 登录完成后浏览器跳转的前端地址
+func (s *OIDCService) FrontendURL() string {
+	return s.cfg.FrontendURL
+}
+
+// provider 获取身份提供商的发现信息
+func (s *OIDCService) provider(ctx context.Context) (*oidc.Provider, error) {
+	oidcProviderCache.mutex.Lock()
+	defer oidcProviderCache.mutex.Unlock()
+
+	if oidcProviderCache.provider != nil {
+		return oidcProviderCache.provider, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, s.cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("获取身份提供商配置失败: %w", err)
+	}
+	oidcProviderCache.provider = provider
+	return provider, nil
+}
+
+// oauth2Config 构造授权码模式的客户端配置
+func (s *OIDCService) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	scopes := s.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	return &oauth2.Config{
+		ClientID:     s.cfg.ClientID,
+		ClientSecret: s.cfg.ClientSecret,
+		RedirectURL:  s.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       scopes,
+	}
+}
+
+// AuthCodeURL 生成跳转到身份提供商的授权地址并返回state，state、nonce和PKCE校验码保存在数据库中
+func (s *OIDCService) AuthCodeURL(ctx context.Context) (string, string, error) {
+	if !s.Enabled() {
+		return "", "", ErrOIDCDisabled
+	}
+	provider, err := s.provider(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomToken(24)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomToken(24)
+	if err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	now := time.Now()
+	if err := database.DB.Create(&models.OIDCLoginState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: models.EncryptedString(verifier),
+		ExpiresAt:    now.Add(OIDCStateTTL),
+	}).Error; err != nil {
+		return "", "", fmt.Errorf("保存登录状态失败: %w", err)
+	}
+
+	// 清理过期的登录状态
+	database.DB.Where("expires_at < ?", now).Delete(&models.OIDCLoginState{})
+
+	return s.oauth2Config(provider).AuthCodeURL(state,
+		oidc.Nonce(nonce),
+		oauth2.S256ChallengeOption(verifier),
+	), state, nil
+}
+
+// HandleCallback 处理身份提供商回调：换取并校验ID Token，关联或创建用户，返回一次性登录码
+func (s *OIDCService) HandleCallback(ctx context.Context, code, state string) (string, error) {
+	if !s.Enabled() {
+		return "", ErrOIDCDisabled
+	}
+
+	var loginState models.OIDCLoginState
+	if err := database.DB.Where("state = ? AND user_id = 0 AND expires_at > ?", state, time.Now()).
+		First(&loginState).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrOIDCInvalidState
+		}
+		return "", fmt.Errorf("查询登录状态失败: %w", err)
+	}
+	// state只能使用一次
+	result := database.DB.Where("state = ? AND user_id = 0", state).Delete(&models.OIDCLoginState{})
+	if result.Error != nil {
+		return "", fmt.Errorf("删除登录状态失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return "", ErrOIDCInvalidState
+	}
+
+	provider, err := s.provider(ctx)
+	if err != nil {
+		return "", err
+	}
+	token, err := s.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(string(loginState.CodeVerifier)))
+	if err != nil {
+		return "", fmt.Errorf("换取令牌失败: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return "", fmt.Errorf("身份提供商未返回id_token")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: s.cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return "", fmt.Errorf("校验id_token失败: %w", err)
+	}
+	if idToken.Nonce != loginState.Nonce {
+		return "", fmt.Errorf("校验id_token失败: nonce不匹配")
+	}
+
+	claims, err := s.parseClaims(idToken)
+	if err != nil {
+		return "", err
+	}
+	user, err := s.resolveUser(claims)
+	if err != nil {
+		return "", err
+	}
+
+	loginCode, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := database.DB.Create(&models.OIDCLoginState{
+		State:         loginState.State,
+		UserID:        user.ID,
+		LoginCodeHash: hashToken(loginCode),
+		ExpiresAt:     time.Now().Add(oidcLoginCodeTTL),
+	}).Error; err != nil {
+		return "", fmt.Errorf("保存登录状态失败: %w", err)
+	}
+	return loginCode, nil
+}
+
+// ExchangeLoginCode 用回调返回的一次性登录码换取用户
+func (s *OIDCService) ExchangeLoginCode(code string) (*models.User, error) {
+	hash := hashToken(code)
+
+	var loginState models.OIDCLoginState
+	if err := database.DB.Where("login_code_hash = ? AND user_id > 0 AND expires_at > ?", hash, time.Now()).
+		First(&loginState).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOIDCInvalidState
+		}
+		return nil, fmt.Errorf("查询登录状态失败: %w", err)
+	}
+	result := database.DB.Where("login_code_hash = ?", hash).Delete(&models.OIDCLoginState{})
+	if result.Error != nil {
+		return nil, fmt.Errorf("删除登录状态失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrOIDCInvalidState
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, loginState.UserID).Error; err != nil {
+		return nil, fmt.Errorf("用户不存在")
+	}
+	if user.Status != "active" {
+		return nil, fmt.Errorf("用户已被禁用")
+	}
+	return &user, nil
+}
+
+// parseClaims 读取ID Token中的用户信息
+func (s *OIDCService) parseClaims(idToken *oidc.IDToken) (*OIDCClaims, error) {
+	var raw map[string]interface{}
+	if err := idToken.Claims(&raw); err != nil {
+		return nil, fmt.Errorf("解析id_token失败: %w", err)
+	}
+
+	claims := &OIDCClaims{Subject: idToken.Subject}
+	claims.Email, _ = raw["email"].(string)
+	claims.Username, _ = raw["preferred_username"].(string)
+	claims.Name, _ = raw["name"].(string)
+	if verified, ok := raw["email_verified"].(bool); ok {
+		claims.EmailVerified = &verified
+	}
+
+	groupsClaim := s.cfg.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	switch groups := raw[groupsClaim].(type) {
+	case []interface{}:
+		for _, g := range groups {
+			if name, ok := g.(string); ok {
+				claims.Groups = append(claims.Groups, name)
+			}
+		}
+	case string:
+		// 部分身份提供商只有一个组时返回字符串
+		claims.Groups = []string{groups}
+	}
+
+	if claims.Email == "" {
+		return nil, fmt.Errorf("身份提供商未返回邮箱，请在scopes中加入email")
+	}
+	return claims, nil
+}
+
+// resolveUser 按外部身份、邮箱的顺序查找用户，都不存在时自动创建；每次登录按组同步角色
+func (s *OIDCService) resolveUser(claims *OIDCClaims) (*models.User, error) {
+	var user models.User
+	now := time.Now()
+
+	var identity models.UserIdentity
+	err := database.DB.Where("provider = ? AND subject = ?", IdentityProviderOIDC, claims.Subject).First(&identity).Error
+	switch {
+	case err == nil:
+		if err := database.DB.First(&user, identity.UserID).Error; err != nil {
+			return nil, fmt.Errorf("关联的用户不存在")
+		}
+		database.DB.Model(&identity).Updates(map[string]interface{}{
+			"email":         claims.Email,
+			"last_login_at": now,
+		})
+
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		err = database.DB.Where("email = ?", claims.Email).First(&user).Error
+		switch {
+		case err == nil:
+			// 关联已有账户，避免身份提供商中未验证的邮箱被用来接管账户
+			if s.cfg.RequireVerifiedEmail && (claims.EmailVerified == nil || !*claims.EmailVerified) {
+				return nil, fmt.Errorf("邮箱 %s 未经身份提供商验证，无法关联已有账户", claims.Email)
+			}
+			log.Printf("OIDC身份 %s 按邮箱关联到用户 %s", claims.Subject, user.Username)
+			if !user.EmailVerified && claims.EmailVerified != nil && *claims.EmailVerified {
+				database.DB.Model(&user).Update("email_verified", true)
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if !s.cfg.AutoProvision {
+				return nil, fmt.Errorf("用户不存在，请联系管理员开通账户")
+			}
+			created, err := s.provisionUser(claims)
+			if err != nil {
+				return nil, err
+			}
+			user = *created
+		default:
+			return nil, fmt.Errorf("查询用户失败: %w", err)
+		}
+
+		if err := database.DB.Create(&models.UserIdentity{
+			UserID:      user.ID,
+			Provider:    IdentityProviderOIDC,
+			Subject:     claims.Subject,
+			Email:       claims.Email,
+			LastLoginAt: &now,
+		}).Error; err != nil {
+			return nil, fmt.Errorf("关联外部身份失败: %w", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("查询外部身份失败: %w", err)
+	}
+
+	if user.Status != "active" {
+		return nil, fmt.Errorf("用户已被禁用")
+	}
+
+	// 按组同步角色，没有匹配的组时保留现有角色；组映射来自系统配置，按管理员授权
+	if role := s.mapRole(claims.Groups); role != "" && role != user.Role {
+		if err := s.rbacService.AssignRole(RoleAdmin, user.ID, role); err != nil {
+			log.Printf("同步用户 %s 的角色失败: %v", user.Username, err)
+		} else {
+			user.Role = role
+		}
+	}
+
+	database.DB.Model(&user).Update("last_login", now)
+	user.LastLogin = &now
+	return &user, nil
+}
+
+// provisionUser 首次登录时创建用户，密码随机生成，只能通过单点登录
+func (s *OIDCService) provisionUser(claims *OIDCClaims) (*models.User, error) {
+	username, err := uniqueUsername(claims.Username, claims.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	randomPassword, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := utils.HashPassword(randomPassword)
+	if err != nil {
+		return nil, fmt.Errorf("密码加密失败: %v", err)
+	}
+
+	role := s.mapRole(claims.Groups)
+	if role == "" {
+		role = s.cfg.DefaultRole
+	}
+	if role == "" {
+		role = RoleUser
+	}
+
+	user := models.User{
+		Username: username,
+		Email:    claims.Email,
+		Password: hashedPassword,
+		Nickname: claims.Name,
+		Role:     role,
+		Status:   "active",
+		// 以身份提供商的验证结果为准
+		EmailVerified: claims.EmailVerified != nil && *claims.EmailVerified,
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		return nil, fmt.Errorf("创建用户失败: %v", err)
+	}
+	log.Printf("通过OIDC创建用户 %s（角色 %s）", user.Username, user.Role)
+	return &user, nil
+}
+
+// mapRole 按配置顺序返回第一个匹配的组对应的角色
+func (s *OIDCService) mapRole(groups []string) string {
+	return mapGroupsToRole(s.cfg.RoleMapping, groups)
+}
+
+// mapGroupsToRole 解析 "组名=角色" 格式的映射并返回第一个匹配的角色，组名比较忽略大小写
+func mapGroupsToRole(mapping, groups []string) string {
+	for _, entry := range mapping {
+		// 组名可能包含等号（如LDAP DN），角色名不包含，按最后一个等号分割
+		idx := strings.LastIndex(entry, "=")
+		if idx <= 0 {
+			continue
+		}
+		group := strings.TrimSpace(entry[:idx])
+		role := strings.TrimSpace(entry[idx+1:])
+		for _, g := range groups {
+			if strings.EqualFold(g, group) {
+				return role
+			}
+		}
+	}
+	return ""
+}
+
+// uniqueUsername 根据外部用户名或邮箱生成未被占用的用户名
+func uniqueUsername(username, email string) (string, error) {
+	base := username
+	if base == "" {
+		base = strings.SplitN(email, "@", 2)[0]
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for i := 2; i < 100; i++ {
+		var count int64
+		if err := database.DB.Unscoped().Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", fmt.Errorf("查询用户失败: %w", err)
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", base, i)
+	}
+	return "", fmt.Errorf("无法为 %s 生成可用的用户名", base)
+}
@@ -0,0 +1,35 @@
+package services
+
+import "testing"
+
+func TestMapGroupsToRole(t *testing.T) {
+	mapping := []string{
+		"emby-admins=admin",
+		" Operators = operator ",
+		"cn=viewers,ou=groups,dc=example,dc=com=viewer",
+		"invalid",
+		"=user",
+	}
+
+	tests := []struct {
+		name   string
+		groups []string
+		want   string
+	}{
+		{"精确匹配", []string{"emby-admins"}, "admin"},
+		{"忽略大小写和空格", []string{"OPERATORS"}, "operator"},
+		{"组名包含等号", []string{"CN=Viewers,OU=Groups,DC=example,DC=com"}, "viewer"},
+		{"按映射顺序返回第一个匹配", []string{"operators", "emby-admins"}, "admin"},
+		{"没有匹配的组", []string{"everyone"}, ""},
+		{"忽略格式错误和空组名的映射", []string{"invalid", ""}, ""},
+		{"没有组", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapGroupsToRole(mapping, tt.groups); got != tt.want {
+				t.Fatalf("mapGroupsToRole(%v) = %q, want %q", tt.groups, got, tt.want)
+			}
+		})
+	}
+}
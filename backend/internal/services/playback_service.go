@@ -7,7 +7,6 @@ import (
 
 	"github.com/emby-client-go/backend/internal/database"
 	"github.com/emby-client-go/backend/internal/models"
-	"github.com/emby-client-go/backend/pkg/emby"
 	"gorm.io/gorm"
 )
 
@@ -38,11 +37,12 @@ func (s *PlaybackService) SendPlayCommand(ctx context.Context, serverID uint, de
 	}
 
 	var device models.Device
-	if err := s.db.First(&device, deviceID).Error; err != nil {
+	// 设备必须属于该服务器，权限检查只针对路径中的服务器
+	if err := s.db.Where("emby_server_id = ?", serverID).First(&device, deviceID).Error; err != nil {
 		return fmt.Errorf("设备不存在: %w", err)
 	}
 
-	client := emby.NewClient(server.URL, server.APIKey)
+	client := GetEmbyClient(&server)
 
 	switch cmd.Command {
 	case "Play":
@@ -98,7 +98,7 @@ func (s *PlaybackService) SyncPlaybackSessions(ctx context.Context, serverID uin
 		return fmt.Errorf("服务器不存在: %w", err)
 	}
 
-	client := emby.NewClient(server.URL, server.APIKey)
+	client := GetEmbyClient(&server)
 	sessions, err := client.GetSessions(ctx)
 	if err != nil {
 		return fmt.Errorf("获取会话列表失败: %w", err)
@@ -0,0 +1,439 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// 权限名称
+const (
+	PermServerCreate    = "server.create"    // 添加服务器
+	PermServerManage    = "server.manage"    // 管理所有服务器，不受服务器共享限制
+	PermMediaSync       = "media.sync"       // 同步媒体库、设备及刷新媒体库
+	PermPlaybackControl = "playback.control" // 发送播放控制命令
+	PermJobsManage      = "jobs.manage"      // 查看和取消所有用户的同步任务
+	PermSchedulerManage = "scheduler.manage" // 管理定时任务
+	PermUsersManage     = "users.manage"     // 管理用户
+	PermRolesManage     = "roles.manage"     // 管理角色和权限
+	PermSystemManage    = "system.manage"    // 管理系统安全策略
+	PermAuditView       = "audit.view"       // 查看和导出审计日志
+	PermAlertsManage    = "alerts.manage"    // 管理告警规则、通知渠道和静默
+)
+
+// 内置角色
+const (
+	RoleAdmin = "admin" // 拥有全部权限，权限不可修改
+	RoleUser  = "user"  // 注册用户的默认角色
+)
+
+// rolePermissionCacheTTL 角色权限缓存有效期，多副本部署时其他实例的修改最迟在此时间后生效
+const rolePermissionCacheTTL = 30 * time.Second
+
+var (
+	// ErrRoleNotFound 角色不存在
+	ErrRoleNotFound = errors.New("角色不存在")
+	// ErrBuiltInRole 内置角色不能删除或修改权限
+	ErrBuiltInRole = errors.New("内置角色不能执行此操作")
+	// ErrPermissionNotHeld 不能授予操作者自己没有的角色或权限
+	ErrPermissionNotHeld = errors.New("不能授予自己没有的权限")
+)
+
+// permissionDefinitions 系统支持的全部权限
+var permissionDefinitions = []models.Permission{
+	{Name: PermServerCreate, Description: "添加Emby服务器"},
+	{Name: PermServerManage, Description: "管理所有Emby服务器"},
+	{Name: PermMediaSync, Description: "同步媒体库和设备"},
+	{Name: PermPlaybackControl, Description: "控制播放"},
+	{Name: PermJobsManage, Description: "管理所有同步任务"},
+	{Name: PermSchedulerManage, Description: "管理定时任务"},
+	{Name: PermUsersManage, Description: "管理用户"},
+	{Name: PermRolesManage, Description: "管理角色和权限"},
+	{Name: PermSystemManage, Description: "管理系统安全策略"},
+	{Name: PermAuditView, Description: "查看和导出审计日志"},
+	{Name: PermAlertsManage, Description: "管理告警规则和通知渠道"},
+}
+
+// defaultUserPermissions 内置user角色首次创建时的权限
+var defaultUserPermissions = []string{PermServerCreate, PermMediaSync, PermPlaybackControl}
+
+// rolePermissionCache 角色权限缓存
+type rolePermissionCache struct {
+	entries map[string]cachedRolePermissions
+	mutex   sync.RWMutex
+}
+
+type cachedRolePermissions struct {
+	permissions map[string]bool
+	loadedAt    time.Time
+}
+
+var rolePermissions = &rolePermissionCache{
+	entries: make(map[string]cachedRolePermissions),
+}
+
+// invalidate 清空缓存
+func (c *rolePermissionCache) invalidate() {
+	c.mutex.Lock()
+	c.entries = make(map[string]cachedRolePermissions)
+	c.mutex.Unlock()
+}
+
+// RBACService 角色权限服务
+type RBACService struct{}
+
+// NewRBACService 创建角色权限服务
+func NewRBACService() *RBACService {
+	return &RBACService{}
+}
+
+// EnsureDefaults 写入系统权限和内置角色，启动时调用
+func (s *RBACService) EnsureDefaults() error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, def := range permissionDefinitions {
+			perm := def
+			if err := tx.Where(models.Permission{Name: perm.Name}).
+				Assign(models.Permission{Description: perm.Description}).
+				FirstOrCreate(&perm).Error; err != nil {
+				return err
+			}
+		}
+
+		// admin角色始终拥有全部权限
+		admin, err := ensureBuiltInRole(tx, RoleAdmin, "管理员", nil)
+		if err != nil {
+			return err
+		}
+		var all []models.Permission
+		if err := tx.Find(&all).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(admin).Association("Permissions").Replace(all); err != nil {
+			return err
+		}
+
+		_, err = ensureBuiltInRole(tx, RoleUser, "普通用户", defaultUserPermissions)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("初始化角色权限失败: %w", err)
+	}
+
+	rolePermissions.invalidate()
+	log.Println("角色权限初始化完成")
+	return nil
+}
+
+// ensureBuiltInRole 内置角色不存在时创建，已存在时保留管理员修改过的权限
+func ensureBuiltInRole(tx *gorm.DB, name, description string, permissions []string) (*models.Role, error) {
+	var role models.Role
+	err := tx.Where("name = ?", name).First(&role).Error
+	if err == nil {
+		return &role, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	role = models.Role{Name: name, Description: description, BuiltIn: true}
+	if len(permissions) > 0 {
+		if err := tx.Where("name IN ?", permissions).Find(&role.Permissions).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Create(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// RolePermissions 获取角色拥有的权限（带缓存）
+func (s *RBACService) RolePermissions(role string) (map[string]bool, error) {
+	rolePermissions.mutex.RLock()
+	cached, ok := rolePermissions.entries[role]
+	rolePermissions.mutex.RUnlock()
+	if ok && time.Since(cached.loadedAt) < rolePermissionCacheTTL {
+		return cached.permissions, nil
+	}
+
+	permissions := make(map[string]bool)
+	if role == RoleAdmin {
+		// 管理员不依赖数据库中的权限，避免误操作导致无人可以管理系统
+		for _, def := range permissionDefinitions {
+			permissions[def.Name] = true
+		}
+	} else {
+		var names []string
+		if err := database.DB.Model(&models.Permission{}).
+			Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+			Joins("JOIN roles ON roles.id = role_permissions.role_id").
+			Where("roles.name = ?", role).
+			Pluck("permissions.name", &names).Error; err != nil {
+			return nil, fmt.Errorf("查询角色权限失败: %w", err)
+		}
+		for _, name := range names {
+			permissions[name] = true
+		}
+	}
+
+	rolePermissions.mutex.Lock()
+	rolePermissions.entries[role] = cachedRolePermissions{permissions: permissions, loadedAt: time.Now()}
+	rolePermissions.mutex.Unlock()
+	return permissions, nil
+}
+
+// HasPermission 判断角色是否拥有指定权限
+func (s *RBACService) HasPermission(role, permission string) (bool, error) {
+	permissions, err := s.RolePermissions(role)
+	if err != nil {
+		return false, err
+	}
+	return permissions[permission], nil
+}
+
+// GetPermissions 获取系统支持的全部权限
+func (s *RBACService) GetPermissions() ([]models.Permission, error) {
+	var permissions []models.Permission
+	if err := database.DB.Order("name").Find(&permissions).Error; err != nil {
+		return nil, fmt.Errorf("查询权限失败: %w", err)
+	}
+	return permissions, nil
+}
+
+// GetRoles 获取全部角色及其权限
+func (s *RBACService) GetRoles() ([]models.Role, error) {
+	var roles []models.Role
+	if err := database.DB.Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("查询角色失败: %w", err)
+	}
+	return roles, nil
+}
+
+// GetRole 获取角色详情
+func (s *RBACService) GetRole(id uint) (*models.Role, error) {
+	var role models.Role
+	if err := database.DB.Preload("Permissions").First(&role, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, fmt.Errorf("查询角色失败: %w", err)
+	}
+	return &role, nil
+}
+
+// CreateRole 创建自定义角色，权限不能超出操作者角色拥有的权限
+func (s *RBACService) CreateRole(actorRole, name, description string, permissions []string) (*models.Role, error) {
+	if err := s.CheckGrantPermissions(actorRole, permissions); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.Role{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询角色失败: %w", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("角色 %s 已存在", name)
+	}
+
+	perms, err := findPermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := models.Role{Name: name, Description: description, Permissions: perms}
+	if err := database.DB.Create(&role).Error; err != nil {
+		return nil, fmt.Errorf("创建角色失败: %w", err)
+	}
+	return &role, nil
+}
+
+// UpdateRole 更新角色描述和权限，permissions为nil时不修改权限
+// 新增的权限不能超出操作者角色拥有的权限，角色原有的权限可以保留或移除
+func (s *RBACService) UpdateRole(actorRole string, id uint, description *string, permissions []string) (*models.Role, error) {
+	role, err := s.GetRole(id)
+	if err != nil {
+		return nil, err
+	}
+	if permissions != nil && role.Name == RoleAdmin {
+		return nil, ErrBuiltInRole
+	}
+	if permissions != nil {
+		existing := make(map[string]bool, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			existing[permission.Name] = true
+		}
+		var added []string
+		for _, name := range permissions {
+			if !existing[name] {
+				added = append(added, name)
+			}
+		}
+		if err := s.CheckGrantPermissions(actorRole, added); err != nil {
+			return nil, err
+		}
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if description != nil {
+			if err := tx.Model(role).Update("description", *description).Error; err != nil {
+				return err
+			}
+		}
+		if permissions != nil {
+			perms, err := findPermissions(permissions)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(role).Association("Permissions").Replace(perms); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("更新角色失败: %w", err)
+	}
+
+	rolePermissions.invalidate()
+	return s.GetRole(id)
+}
+
+// DeleteRole 删除自定义角色，仍有用户使用时不能删除
+func (s *RBACService) DeleteRole(id uint) error {
+	role, err := s.GetRole(id)
+	if err != nil {
+		return err
+	}
+	if role.BuiltIn {
+		return ErrBuiltInRole
+	}
+
+	var users int64
+	if err := database.DB.Model(&models.User{}).Where("role = ?", role.Name).Count(&users).Error; err != nil {
+		return fmt.Errorf("查询角色用户失败: %w", err)
+	}
+	if users > 0 {
+		return fmt.Errorf("仍有 %d 个用户使用该角色，无法删除", users)
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+	if err != nil {
+		return fmt.Errorf("删除角色失败: %w", err)
+	}
+
+	rolePermissions.invalidate()
+	return nil
+}
+
+// RoleExists 检查角色是否存在
+func (s *RBACService) RoleExists(name string) (bool, error) {
+	var count int64
+	if err := database.DB.Model(&models.Role{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("查询角色失败: %w", err)
+	}
+	return count > 0, nil
+}
+
+// CheckGrantPermissions 检查操作者角色拥有全部要授予的权限
+func (s *RBACService) CheckGrantPermissions(actorRole string, permissions []string) error {
+	held, err := s.RolePermissions(actorRole)
+	if err != nil {
+		return err
+	}
+	for _, name := range permissions {
+		if !held[name] {
+			return fmt.Errorf("%w: %s", ErrPermissionNotHeld, name)
+		}
+	}
+	return nil
+}
+
+// CheckGrantRole 检查操作者可以把角色授予用户：admin角色只能由管理员授予，其他角色的权限不能超出操作者角色拥有的权限
+func (s *RBACService) CheckGrantRole(actorRole, roleName string) error {
+	if actorRole == RoleAdmin {
+		return nil
+	}
+	if roleName == RoleAdmin {
+		return fmt.Errorf("%w: 只有管理员可以授予admin角色", ErrPermissionNotHeld)
+	}
+
+	permissions, err := s.RolePermissions(roleName)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(permissions))
+	for name := range permissions {
+		names = append(names, name)
+	}
+	return s.CheckGrantPermissions(actorRole, names)
+}
+
+// AssignRole 设置用户角色，角色不能超出操作者角色拥有的权限
+func (s *RBACService) AssignRole(actorRole string, userID uint, roleName string) error {
+	exists, err := s.RoleExists(roleName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrRoleNotFound
+	}
+	if err := s.CheckGrantRole(actorRole, roleName); err != nil {
+		return err
+	}
+
+	// 至少保留一个管理员
+	if roleName != RoleAdmin {
+		var admins int64
+		if err := database.DB.Model(&models.User{}).
+			Where("role = ? AND id <> ?", RoleAdmin, userID).
+			Count(&admins).Error; err != nil {
+			return fmt.Errorf("查询管理员失败: %w", err)
+		}
+		if admins == 0 {
+			return fmt.Errorf("不能移除最后一个管理员")
+		}
+	}
+
+	result := database.DB.Model(&models.User{}).Where("id = ?", userID).Update("role", roleName)
+	if result.Error != nil {
+		return fmt.Errorf("设置用户角色失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// findPermissions 按名称查询权限，存在未知权限时返回错误
+func findPermissions(names []string) ([]models.Permission, error) {
+	perms := []models.Permission{}
+	if len(names) == 0 {
+		return perms, nil
+	}
+
+	if err := database.DB.Where("name IN ?", names).Find(&perms).Error; err != nil {
+		return nil, fmt.Errorf("查询权限失败: %w", err)
+	}
+
+	found := make(map[string]bool, len(perms))
+	for _, perm := range perms {
+		found[perm.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("未知的权限: %s", name)
+		}
+	}
+	return perms, nil
+}
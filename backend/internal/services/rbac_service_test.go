@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckGrantRole(t *testing.T) {
+	setupTestDB(t)
+	service := NewRBACService()
+	createTestRole(t, "operator", PermServerCreate, PermMediaSync, PermPlaybackControl, PermUsersManage)
+	createTestRole(t, "viewer", PermMediaSync)
+	createTestRole(t, "auditor", PermAuditView)
+
+	tests := []struct {
+		name      string
+		actorRole string
+		roleName  string
+		wantErr   error
+	}{
+		{"管理员可以授予admin", RoleAdmin, RoleAdmin, nil},
+		{"管理员可以授予任意角色", RoleAdmin, "auditor", nil},
+		{"非管理员不能授予admin", "operator", RoleAdmin, ErrPermissionNotHeld},
+		{"可以授予权限子集的角色", "operator", "viewer", nil},
+		{"可以授予权限相同的角色", "operator", "operator", nil},
+		{"可以授予内置user角色", "operator", RoleUser, nil},
+		{"不能授予包含自己没有的权限的角色", "operator", "auditor", ErrPermissionNotHeld},
+		{"权限较少的角色不能授予权限较多的角色", "viewer", "operator", ErrPermissionNotHeld},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.CheckGrantRole(tt.actorRole, tt.roleName)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckGrantRole(%q, %q) = %v, want %v", tt.actorRole, tt.roleName, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckGrantPermissions(t *testing.T) {
+	setupTestDB(t)
+	service := NewRBACService()
+	createTestRole(t, "operator", PermMediaSync, PermUsersManage)
+
+	tests := []struct {
+		name        string
+		actorRole   string
+		permissions []string
+		wantErr     error
+	}{
+		{"管理员拥有全部权限", RoleAdmin, []string{PermRolesManage, PermSystemManage}, nil},
+		{"自己拥有的权限", "operator", []string{PermMediaSync}, nil},
+		{"空权限列表", "operator", nil, nil},
+		{"包含自己没有的权限", "operator", []string{PermMediaSync, PermRolesManage}, ErrPermissionNotHeld},
+		{"不存在的角色没有任何权限", "missing", []string{PermMediaSync}, ErrPermissionNotHeld},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.CheckGrantPermissions(tt.actorRole, tt.permissions)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckGrantPermissions(%q, %v) = %v, want %v", tt.actorRole, tt.permissions, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/config"
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm/clause"
+)
+
+// RevocationStore 吊销列表，保存已吊销但尚未过期的访问令牌JTI和会话SID
+type RevocationStore interface {
+	Revoke(ctx context.Context, id string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, id string) (bool, error)
+}
+
+// revocationCleanupInterval 数据库吊销列表清理过期记录的间隔
+const revocationCleanupInterval = 10 * time.Minute
+
+// redisRevokedPrefix Redis中吊销记录的键前缀
+const redisRevokedPrefix = "emby-manager:revoked:"
+
+var (
+	revocationStore RevocationStore = &dbRevocationStore{}
+	revocationMutex sync.RWMutex
+)
+
+// InitRevocationStore 根据配置初始化吊销列表，启用Redis但连接失败时回退到数据库
+func InitRevocationStore(cfg config.RedisConfig) {
+	var store RevocationStore = &dbRevocationStore{}
+
+	if cfg.Enabled {
+		client, err := connectRedis(cfg)
+		if err != nil {
+			log.Printf("连接Redis失败，令牌吊销列表使用数据库: %v", err)
+		} else {
+			store = &redisRevocationStore{client: client}
+			log.Println("令牌吊销列表使用Redis")
+		}
+	}
+
+	revocationMutex.Lock()
+	revocationStore = store
+	revocationMutex.Unlock()
+}
+
+// connectRedis 连接Redis并检查连通性
+func connectRedis(cfg config.RedisConfig) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// getRevocationStore 获取当前的吊销列表
+func getRevocationStore() RevocationStore {
+	revocationMutex.RLock()
+	defer revocationMutex.RUnlock()
+	return revocationStore
+}
+
+// dbRevocationStore 基于数据库的吊销列表
+type dbRevocationStore struct {
+	lastCleanup time.Time
+	mutex       sync.Mutex
+}
+
+func (s *dbRevocationStore) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	s.cleanup(ctx)
+
+	return database.DB.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+		}).
+		Create(&models.RevokedToken{ID: id, ExpiresAt: expiresAt}).Error
+}
+
+func (s *dbRevocationStore) IsRevoked(ctx context.Context, id string) (bool, error) {
+	var count int64
+	if err := database.DB.WithContext(ctx).Model(&models.RevokedToken{}).
+		Where("id = ? AND expires_at > ?", id, time.Now()).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// cleanup 定期删除已过期的吊销记录
+func (s *dbRevocationStore) cleanup(ctx context.Context) {
+	s.mutex.Lock()
+	if time.Since(s.lastCleanup) < revocationCleanupInterval {
+		s.mutex.Unlock()
+		return
+	}
+	s.lastCleanup = time.Now()
+	s.mutex.Unlock()
+
+	if err := database.DB.WithContext(ctx).
+		Where("expires_at <= ?", time.Now()).
+		Delete(&models.RevokedToken{}).Error; err != nil {
+		log.Printf("清理过期吊销记录失败: %v", err)
+	}
+}
+
+// redisRevocationStore 基于Redis的吊销列表，记录随令牌过期自动删除
+type redisRevocationStore struct {
+	client *redis.Client
+}
+
+func (s *redisRevocationStore) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.client.Set(ctx, redisRevokedPrefix+id, 1, ttl).Err()
+}
+
+func (s *redisRevocationStore) IsRevoked(ctx context.Context, id string) (bool, error) {
+	n, err := s.client.Exists(ctx, redisRevokedPrefix+id).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -23,6 +23,8 @@ type SearchRequest struct {
 	Limit          int      `json:"limit" form:"limit"`                     // 每页数量
 	Offset         int      `json:"offset" form:"offset"`                   // 偏移量
 	IncludeSeries  bool     `json:"include_series" form:"include_series"`   // 是否包含系列信息
+
+	AllowedServerIDs []uint `json:"-" form:"-"` // 当前用户可访问的服务器，为nil时不限制
 }
 
 // SearchResult 搜索结果结构
@@ -95,7 +97,7 @@ func (s *SearchService) Search(ctx context.Context, req SearchRequest) (*SearchR
 	result.Aggregations = s.buildAggregations(ctx, req, items)
 
 	// 添加搜索建议
-	suggestions, err := s.GetSuggestions(ctx, req.Query, 5)
+	suggestions, err := s.GetSuggestions(ctx, req.Query, 5, req.AllowedServerIDs)
 	if err == nil {
 		result.Suggestions = suggestions
 	}
@@ -134,6 +136,12 @@ func (s *SearchService) buildSearchQuery(ctx context.Context, req SearchRequest)
 		}
 	}
 
+	// 访问范围过滤
+	if req.AllowedServerIDs != nil {
+		query = query.Where("media_library_id IN (?)",
+			scopeServers(db.Model(&models.MediaLibrary{}).Select("id"), "emby_server_id", req.AllowedServerIDs))
+	}
+
 	// 类型过滤
 	if len(req.Types) > 0 {
 		query = query.Where("type IN ?", req.Types)
@@ -223,8 +231,8 @@ func (s *SearchService) buildAggregations(ctx context.Context, req SearchRequest
 	return aggregations
 }
 
-// GetSuggestions 获取搜索建议
-func (s *SearchService) GetSuggestions(ctx context.Context, query string, limit int) ([]string, error) {
+// GetSuggestions 获取搜索建议，serverIDs为nil时不限制服务器
+func (s *SearchService) GetSuggestions(ctx context.Context, query string, limit int, serverIDs []uint) ([]string, error) {
 	if len(strings.TrimSpace(query)) < 2 {
 		return nil, nil
 	}
@@ -233,10 +241,15 @@ func (s *SearchService) GetSuggestions(ctx context.Context, query string, limit
 
 	// 从历史搜索记录或热门标签获取建议（这里简化实现）
 	var names []string
-	err := s.db.WithContext(ctx).
-		Model(&models.MediaItem{}).
+	db := s.db.WithContext(ctx)
+	itemQuery := db.Model(&models.MediaItem{}).
 		Where("name LIKE ? OR series_name LIKE ?",
-			strings.TrimSpace(query)+"%", strings.TrimSpace(query)+"%").
+			strings.TrimSpace(query)+"%", strings.TrimSpace(query)+"%")
+	if serverIDs != nil {
+		itemQuery = itemQuery.Where("media_library_id IN (?)",
+			scopeServers(db.Model(&models.MediaLibrary{}).Select("id"), "emby_server_id", serverIDs))
+	}
+	err := itemQuery.
 		Limit(limit).
 		Pluck("DISTINCT name", &names).Error
 
@@ -0,0 +1,659 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/emby-client-go/backend/internal/config"
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+// 密码和登录锁定策略的系统配置键，未设置时使用配置文件中的默认值
+const (
+	ConfigPasswordMinLength     = "security.password_min_length"
+	ConfigPasswordRequireUpper  = "security.password_require_upper"
+	ConfigPasswordRequireLower  = "security.password_require_lower"
+	ConfigPasswordRequireDigit  = "security.password_require_digit"
+	ConfigPasswordRequireSymbol = "security.password_require_symbol"
+	ConfigPasswordCheckBreached = "security.password_check_breached"
+	ConfigPasswordHistory       = "security.password_history"
+	ConfigLockoutThreshold      = "security.lockout_threshold"
+	ConfigLockoutMinutes        = "security.lockout_minutes"
+	ConfigLockoutMaxMinutes     = "security.lockout_max_minutes"
+	ConfigIPMaxFailures         = "security.ip_max_failures"
+	ConfigIPWindowMinutes       = "security.ip_window_minutes"
+)
+
+// maxPasswordBytes bcrypt只使用密码的前72字节
+const maxPasswordBytes = 72
+
+var (
+	// ErrLoginThrottled 同一IP登录失败次数过多，暂时拒绝该IP登录
+	ErrLoginThrottled = errors.New("登录失败次数过多，请稍后再试")
+	// ErrBreachedPassword 密码出现在泄露密码列表中
+	ErrBreachedPassword = errors.New("该密码已出现在泄露密码列表中，请更换其他密码")
+	// ErrPasswordReused 密码与最近使用过的密码相同
+	ErrPasswordReused = errors.New("不能使用最近使用过的密码")
+)
+
+// SecurityPolicy 密码和登录锁定策略
+type SecurityPolicy struct {
+	PasswordMinLength     int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+	PasswordCheckBreached bool
+	PasswordHistory       int
+	LockoutThreshold      int
+	LockoutMinutes        int
+	LockoutMaxMinutes     int
+	IPMaxFailures         int
+	IPWindowMinutes       int
+}
+
+// policyField 策略项与系统配置键的对应关系
+type policyField struct {
+	key         string
+	description string
+	intValue    *int
+	boolValue   *bool
+}
+
+// fields 策略的全部配置项
+func (p *SecurityPolicy) fields() []policyField {
+	return []policyField{
+		{key: ConfigPasswordMinLength, description: "密码最小长度", intValue: &p.PasswordMinLength},
+		{key: ConfigPasswordRequireUpper, description: "密码必须包含大写字母", boolValue: &p.PasswordRequireUpper},
+		{key: ConfigPasswordRequireLower, description: "密码必须包含小写字母", boolValue: &p.PasswordRequireLower},
+		{key: ConfigPasswordRequireDigit, description: "密码必须包含数字", boolValue: &p.PasswordRequireDigit},
+		{key: ConfigPasswordRequireSymbol, description: "密码必须包含特殊字符", boolValue: &p.PasswordRequireSymbol},
+		{key: ConfigPasswordCheckBreached, description: "拒绝泄露密码列表中的密码", boolValue: &p.PasswordCheckBreached},
+		{key: ConfigPasswordHistory, description: "不能重复使用的最近密码个数", intValue: &p.PasswordHistory},
+		{key: ConfigLockoutThreshold, description: "连续登录失败锁定阈值", intValue: &p.LockoutThreshold},
+		{key: ConfigLockoutMinutes, description: "首次锁定时长（分钟）", intValue: &p.LockoutMinutes},
+		{key: ConfigLockoutMaxMinutes, description: "锁定时长上限（分钟）", intValue: &p.LockoutMaxMinutes},
+		{key: ConfigIPMaxFailures, description: "同一IP允许的登录失败次数", intValue: &p.IPMaxFailures},
+		{key: ConfigIPWindowMinutes, description: "IP登录失败计数窗口（分钟）", intValue: &p.IPWindowMinutes},
+	}
+}
+
+// Validate 检查策略取值范围
+func (p *SecurityPolicy) Validate() error {
+	if p.PasswordMinLength < 4 || p.PasswordMinLength > maxPasswordBytes {
+		return fmt.Errorf("密码最小长度必须在4到%d之间", maxPasswordBytes)
+	}
+	if p.PasswordHistory < 0 || p.PasswordHistory > 24 {
+		return fmt.Errorf("密码历史个数必须在0到24之间")
+	}
+	if p.LockoutThreshold < 0 || p.LockoutThreshold > 100 {
+		return fmt.Errorf("锁定阈值必须在0到100之间")
+	}
+	if p.LockoutThreshold > 0 {
+		if p.LockoutMinutes < 1 {
+			return fmt.Errorf("锁定时长不能少于1分钟")
+		}
+		if p.LockoutMaxMinutes < p.LockoutMinutes || p.LockoutMaxMinutes > 30*24*60 {
+			return fmt.Errorf("锁定时长上限必须不少于首次锁定时长且不超过30天")
+		}
+	}
+	if p.IPMaxFailures < 0 {
+		return fmt.Errorf("IP登录失败次数不能为负数")
+	}
+	if p.IPMaxFailures > 0 && (p.IPWindowMinutes < 1 || p.IPWindowMinutes > 24*60) {
+		return fmt.Errorf("IP登录失败计数窗口必须在1到1440分钟之间")
+	}
+	return nil
+}
+
+// lockoutDuration 第n次锁定的时长，从首次锁定时长开始每次翻倍，不超过上限
+func (p *SecurityPolicy) lockoutDuration(n int) time.Duration {
+	minutes := p.LockoutMinutes
+	if minutes < 1 {
+		minutes = 1
+	}
+	for i := 1; i < n && minutes < p.LockoutMaxMinutes; i++ {
+		minutes *= 2
+	}
+	if p.LockoutMaxMinutes > 0 && minutes > p.LockoutMaxMinutes {
+		minutes = p.LockoutMaxMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// recordLoginFailure 记录一次登录失败（密码或两步验证码错误），连续失败达到阈值时锁定账户
+// 失败次数在SQL中累加后重新读取，并发的错误请求不会读到相同的次数而绕过锁定
+func (p *SecurityPolicy) recordLoginFailure(user *models.User, reason string) error {
+	if err := database.DB.Model(&models.User{}).Where("id = ?", user.ID).
+		UpdateColumn("failed_login_count", gorm.Expr("failed_login_count + 1")).Error; err != nil {
+		log.Printf("记录用户 %d 的登录失败次数失败: %v", user.ID, err)
+		return errors.New(reason)
+	}
+
+	var current models.User
+	if err := database.DB.Select("id", "failed_login_count", "lockout_count").First(&current, user.ID).Error; err != nil {
+		log.Printf("查询用户 %d 的登录失败次数失败: %v", user.ID, err)
+		return errors.New(reason)
+	}
+	user.FailedLoginCount = current.FailedLoginCount
+	user.LockoutCount = current.LockoutCount
+
+	if p.LockoutThreshold <= 0 {
+		return errors.New(reason)
+	}
+
+	if current.FailedLoginCount >= p.LockoutThreshold {
+		// 条件更新，并发请求中只有一个完成本次锁定，锁定次数只增加一次
+		now := time.Now()
+		duration := p.lockoutDuration(current.LockoutCount + 1)
+		lockUntil := now.Add(duration)
+		result := database.DB.Model(&models.User{}).
+			Where("id = ? AND lockout_count = ? AND (locked_until IS NULL OR locked_until < ?)", user.ID, current.LockoutCount, now).
+			UpdateColumns(map[string]interface{}{
+				"lockout_count": current.LockoutCount + 1,
+				"locked_until":  &lockUntil,
+				"status":        gorm.Expr("CASE WHEN status = ? THEN ? ELSE status END", "active", "locked"),
+			})
+		if result.Error != nil {
+			log.Printf("锁定用户 %d 失败: %v", user.ID, result.Error)
+		}
+		if result.RowsAffected == 1 {
+			user.LockoutCount = current.LockoutCount + 1
+			user.LockedUntil = &lockUntil
+			return fmt.Errorf("登录失败次数过多，账户已被锁定%s", formatLockDuration(duration))
+		}
+		return errors.New("登录失败次数过多，账户已被锁定")
+	}
+
+	return fmt.Errorf("%s，还剩 %d 次尝试机会", reason, p.LockoutThreshold-current.FailedLoginCount)
+}
+
+// checkIP 同一IP在窗口内的登录失败次数达到上限时拒绝登录
+func (p *SecurityPolicy) checkIP(ctx context.Context, ip string) error {
+	if p.IPMaxFailures <= 0 || ip == "" {
+		return nil
+	}
+	failures, err := getLoginThrottleStore().Failures(ctx, ip)
+	if err != nil {
+		// 计数器不可用时只依赖账户锁定
+		log.Printf("查询IP %s 的登录失败次数失败: %v", ip, err)
+		return nil
+	}
+	if failures >= p.IPMaxFailures {
+		return ErrLoginThrottled
+	}
+	return nil
+}
+
+// recordIPFailure 记录IP的一次登录失败，用户不存在时同样计数
+// 累加后的次数超过上限说明请求是在并发中越过了checkIP，返回ErrLoginThrottled，不向调用方透露本次的失败原因
+func (p *SecurityPolicy) recordIPFailure(ctx context.Context, ip string) error {
+	if p.IPMaxFailures <= 0 || ip == "" {
+		return nil
+	}
+	window := time.Duration(p.IPWindowMinutes) * time.Minute
+	failures, err := getLoginThrottleStore().AddFailure(ctx, ip, window)
+	if err != nil {
+		log.Printf("记录IP %s 的登录失败次数失败: %v", ip, err)
+		return nil
+	}
+	if failures == p.IPMaxFailures {
+		log.Printf("IP %s 在 %d 分钟内登录失败 %d 次，暂时拒绝该IP登录", ip, p.IPWindowMinutes, failures)
+	}
+	if failures > p.IPMaxFailures {
+		return ErrLoginThrottled
+	}
+	return nil
+}
+
+// SecurityPolicyService 密码和登录锁定策略服务
+type SecurityPolicyService struct {
+	configService *SystemConfigService
+}
+
+// NewSecurityPolicyService 创建密码和登录锁定策略服务
+func NewSecurityPolicyService() *SecurityPolicyService {
+	return &SecurityPolicyService{
+		configService: NewSystemConfigService(),
+	}
+}
+
+// GetPolicy 获取当前生效的策略，管理员修改过的项覆盖配置文件中的默认值
+func (s *SecurityPolicyService) GetPolicy() (*SecurityPolicy, error) {
+	cfg := config.AppConfig.Security
+	policy := &SecurityPolicy{
+		PasswordMinLength:     cfg.PasswordMinLength,
+		PasswordRequireUpper:  cfg.PasswordRequireUpper,
+		PasswordRequireLower:  cfg.PasswordRequireLower,
+		PasswordRequireDigit:  cfg.PasswordRequireDigit,
+		PasswordRequireSymbol: cfg.PasswordRequireSymbol,
+		PasswordCheckBreached: cfg.PasswordCheckBreached,
+		PasswordHistory:       cfg.PasswordHistory,
+		LockoutThreshold:      cfg.LockoutThreshold,
+		LockoutMinutes:        cfg.LockoutMinutes,
+		LockoutMaxMinutes:     cfg.LockoutMaxMinutes,
+		IPMaxFailures:         cfg.IPMaxFailures,
+		IPWindowMinutes:       cfg.IPWindowMinutes,
+	}
+
+	values, err := s.configService.GetCategory(ConfigCategorySecurity)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range policy.fields() {
+		value, ok := values[field.key]
+		if !ok {
+			continue
+		}
+		// 无法解析的值忽略，继续使用默认值
+		if field.intValue != nil {
+			if n, err := strconv.Atoi(value); err == nil {
+				*field.intValue = n
+			}
+		} else if b, err := strconv.ParseBool(value); err == nil {
+			*field.boolValue = b
+		}
+	}
+	return policy, nil
+}
+
+// SavePolicy 校验并保存策略，只写入与当前策略不同的项
+func (s *SecurityPolicyService) SavePolicy(policy *SecurityPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	current, err := s.GetPolicy()
+	if err != nil {
+		return err
+	}
+
+	currentFields := current.fields()
+	for i, field := range policy.fields() {
+		var err error
+		if field.intValue != nil {
+			if *field.intValue == *currentFields[i].intValue {
+				continue
+			}
+			err = s.configService.SetInt(field.key, *field.intValue, ConfigCategorySecurity, field.description)
+		} else {
+			if *field.boolValue == *currentFields[i].boolValue {
+				continue
+			}
+			err = s.configService.SetBool(field.key, *field.boolValue, ConfigCategorySecurity, field.description)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidatePassword 按策略校验新密码，user不为空时检查是否与最近使用过的密码相同
+func (s *SecurityPolicyService) ValidatePassword(user *models.User, password string) error {
+	policy, err := s.GetPolicy()
+	if err != nil {
+		return err
+	}
+
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("密码不能超过%d个字节", maxPasswordBytes)
+	}
+	if utf8.RuneCountInString(password) < policy.PasswordMinLength {
+		return fmt.Errorf("密码长度不能少于%d位", policy.PasswordMinLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+	var missing []string
+	if policy.PasswordRequireUpper && !hasUpper {
+		missing = append(missing, "大写字母")
+	}
+	if policy.PasswordRequireLower && !hasLower {
+		missing = append(missing, "小写字母")
+	}
+	if policy.PasswordRequireDigit && !hasDigit {
+		missing = append(missing, "数字")
+	}
+	if policy.PasswordRequireSymbol && !hasSymbol {
+		missing = append(missing, "特殊字符")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("密码必须包含%s", strings.Join(missing, "、"))
+	}
+
+	if policy.PasswordCheckBreached && config.AppConfig.Security.BreachedPasswordFile != "" {
+		breached, err := breachedPasswords.contains(config.AppConfig.Security.BreachedPasswordFile, password)
+		if err != nil {
+			// 列表不可用时不阻止修改密码
+			log.Printf("读取泄露密码列表失败: %v", err)
+		} else if breached {
+			return ErrBreachedPassword
+		}
+	}
+
+	if user != nil && user.ID != 0 && policy.PasswordHistory > 0 {
+		reused, err := passwordReused(user, password, policy.PasswordHistory)
+		if err != nil {
+			return err
+		}
+		if reused {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// HashPassword 按策略校验新密码并生成摘要
+func (s *SecurityPolicyService) HashPassword(user *models.User, password string) (string, error) {
+	if err := s.ValidatePassword(user, password); err != nil {
+		return "", err
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return "", fmt.Errorf("密码加密失败: %v", err)
+	}
+	return hashedPassword, nil
+}
+
+// RecordPassword 记录用户新设置的密码，只保留策略要求的个数
+// 写入失败只记录日志，不影响已经完成的密码修改
+func (s *SecurityPolicyService) RecordPassword(userID uint, passwordHash string) {
+	policy, err := s.GetPolicy()
+	if err != nil {
+		log.Printf("记录用户 %d 的密码历史失败: %v", userID, err)
+		return
+	}
+
+	if policy.PasswordHistory > 0 {
+		if err := database.DB.Create(&models.PasswordHistory{UserID: userID, PasswordHash: passwordHash}).Error; err != nil {
+			log.Printf("记录用户 %d 的密码历史失败: %v", userID, err)
+			return
+		}
+	}
+
+	var staleIDs []uint
+	if err := database.DB.Model(&models.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Offset(policy.PasswordHistory).
+		Pluck("id", &staleIDs).Error; err != nil {
+		log.Printf("清理用户 %d 的密码历史失败: %v", userID, err)
+		return
+	}
+	if len(staleIDs) > 0 {
+		if err := database.DB.Delete(&models.PasswordHistory{}, staleIDs).Error; err != nil {
+			log.Printf("清理用户 %d 的密码历史失败: %v", userID, err)
+		}
+	}
+}
+
+// passwordReused 新密码是否与当前密码或最近count个历史密码相同
+func passwordReused(user *models.User, password string, count int) (bool, error) {
+	var history []models.PasswordHistory
+	if err := database.DB.Where("user_id = ?", user.ID).
+		Order("id DESC").
+		Limit(count).
+		Find(&history).Error; err != nil {
+		return false, fmt.Errorf("查询密码历史失败: %w", err)
+	}
+
+	hashes := []string{user.Password}
+	for _, h := range history {
+		if h.PasswordHash != user.Password {
+			hashes = append(hashes, h.PasswordHash)
+		}
+	}
+	for _, hash := range hashes {
+		if hash != "" && utils.CheckPasswordHash(password, hash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// formatLockDuration 锁定时长的中文描述
+func formatLockDuration(d time.Duration) string {
+	minutes := int(d.Minutes())
+	if minutes >= 60 && minutes%60 == 0 {
+		return fmt.Sprintf("%d小时", minutes/60)
+	}
+	return fmt.Sprintf("%d分钟", minutes)
+}
+
+// maxPlaintextBreachedBytes 明文泄露密码列表需要整体加载到内存，超过该大小时拒绝加载
+const maxPlaintextBreachedBytes = 16 << 20
+
+// breachedLineMaxBytes 摘要列表单行的最大长度（摘要、出现次数和换行符）
+const breachedLineMaxBytes = 256
+
+// breachedPasswordList 泄露密码列表，文件修改后自动重新加载
+// 第一行是SHA-1摘要时视为按摘要排序的摘要列表（如HIBP按摘要排序的下载），查询时直接在文件中二分查找，不加载到内存；
+// 否则视为明文列表，以SHA-1摘要保存在内存中，文件不能超过16MB
+type breachedPasswordList struct {
+	path    string
+	modTime time.Time
+	sorted  bool
+	hashes  map[[sha1.Size]byte]struct{}
+	mutex   sync.Mutex
+}
+
+var breachedPasswords = &breachedPasswordList{}
+
+// contains 密码是否出现在列表中
+func (l *breachedPasswordList) contains(path, password string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.path != path || !info.ModTime().Equal(l.modTime) {
+		sorted, err := isSortedHashFile(path)
+		if err != nil {
+			return false, err
+		}
+		var hashes map[[sha1.Size]byte]struct{}
+		if !sorted {
+			if info.Size() > maxPlaintextBreachedBytes {
+				return false, fmt.Errorf("明文泄露密码列表 %s 超过%dMB，请改用按摘要排序的SHA-1列表", path, maxPlaintextBreachedBytes>>20)
+			}
+			if hashes, err = loadBreachedPasswords(path); err != nil {
+				return false, err
+			}
+			log.Printf("已加载泄露密码列表 %s，共 %d 条", path, len(hashes))
+		} else {
+			log.Printf("使用按摘要排序的泄露密码列表 %s", path)
+		}
+		l.path = path
+		l.modTime = info.ModTime()
+		l.sorted = sorted
+		l.hashes = hashes
+	}
+
+	digest := sha1.Sum([]byte(password))
+	if l.sorted {
+		return searchSortedHashFile(path, strings.ToUpper(hex.EncodeToString(digest[:])))
+	}
+	_, ok := l.hashes[digest]
+	return ok, nil
+}
+
+// isSortedHashFile 第一行是SHA-1摘要时视为按摘要排序的摘要列表
+func isSortedHashFile(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		return false, scanner.Err()
+	}
+	_, ok := parseSHA1Line(strings.TrimRight(scanner.Text(), "\r"))
+	return ok, nil
+}
+
+// searchSortedHashFile 在按摘要排序的列表中二分查找，target为大写的十六进制摘要
+func searchSortedHashFile(path, target string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	// lo始终是行首，目标行（如果存在）的行首在[lo, hi]之间
+	lo, hi := int64(0), info.Size()
+	for hi-lo > breachedLineMaxBytes {
+		mid := lo + (hi-lo)/2
+		start, err := nextLineStart(file, mid)
+		if err != nil {
+			return false, err
+		}
+		key, _, err := readHashLine(file, start)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case key == "" || key > target:
+			hi = mid
+		case key < target:
+			lo = start
+		default:
+			return true, nil
+		}
+	}
+
+	for offset := lo; offset <= hi; {
+		key, next, err := readHashLine(file, offset)
+		if err != nil {
+			return false, err
+		}
+		if key == "" || key > target {
+			return false, nil
+		}
+		if key == target {
+			return true, nil
+		}
+		offset = next
+	}
+	return false, nil
+}
+
+// nextLineStart 返回offset处或之后的第一个行首
+func nextLineStart(file *os.File, offset int64) (int64, error) {
+	if offset == 0 {
+		return 0, nil
+	}
+	buf := make([]byte, breachedLineMaxBytes+1)
+	n, err := file.ReadAt(buf, offset-1)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+		return offset + int64(i), nil
+	}
+	if err == io.EOF {
+		return offset + int64(n) - 1, nil
+	}
+	return 0, fmt.Errorf("泄露密码列表格式错误：行长度超过%d字节", breachedLineMaxBytes)
+}
+
+// readHashLine 读取offset处的一行，返回大写的摘要和下一行的行首；到达文件末尾时摘要为空
+func readHashLine(file *os.File, offset int64) (string, int64, error) {
+	buf := make([]byte, breachedLineMaxBytes)
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return "", 0, err
+	}
+	if n == 0 {
+		return "", offset, nil
+	}
+	line := buf[:n]
+	next := offset + int64(n)
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+		next = offset + int64(i) + 1
+	} else if err != io.EOF {
+		return "", 0, fmt.Errorf("泄露密码列表格式错误：行长度超过%d字节", breachedLineMaxBytes)
+	}
+	digest, ok := parseSHA1Line(strings.TrimRight(string(line), "\r"))
+	if !ok {
+		return "", 0, fmt.Errorf("泄露密码列表格式错误：第%d字节处不是SHA-1摘要", offset)
+	}
+	return strings.ToUpper(hex.EncodeToString(digest[:])), next, nil
+}
+
+// loadBreachedPasswords 读取明文泄露密码列表，每行一个明文密码或SHA-1摘要
+func loadBreachedPasswords(path string) (map[[sha1.Size]byte]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hashes := make(map[[sha1.Size]byte]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if digest, ok := parseSHA1Line(line); ok {
+			hashes[digest] = struct{}{}
+		} else {
+			hashes[sha1.Sum([]byte(line))] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// parseSHA1Line 解析 "摘要" 或 "摘要:次数" 格式的行
+func parseSHA1Line(line string) ([sha1.Size]byte, bool) {
+	var digest [sha1.Size]byte
+	hexLen := sha1.Size * 2
+	if len(line) < hexLen || (len(line) > hexLen && line[hexLen] != ':') {
+		return digest, false
+	}
+	if _, err := hex.Decode(digest[:], []byte(line[:hexLen])); err != nil {
+		return digest, false
+	}
+	return digest, true
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	tests := []struct {
+		name       string
+		minutes    int
+		maxMinutes int
+		n          int
+		want       time.Duration
+	}{
+		{"首次锁定", 15, 1440, 1, 15 * time.Minute},
+		{"第二次翻倍", 15, 1440, 2, 30 * time.Minute},
+		{"第四次翻倍三次", 15, 1440, 4, 120 * time.Minute},
+		{"不超过上限", 15, 60, 5, 60 * time.Minute},
+		{"次数很大时不溢出", 15, 1440, 1000, 1440 * time.Minute},
+		{"首次锁定时长不足1分钟按1分钟", 0, 1440, 1, time.Minute},
+		{"首次锁定时长超过上限", 120, 60, 1, 60 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &SecurityPolicy{LockoutMinutes: tt.minutes, LockoutMaxMinutes: tt.maxMinutes}
+			if got := policy.lockoutDuration(tt.n); got != tt.want {
+				t.Fatalf("lockoutDuration(%d) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSHA1Line(t *testing.T) {
+	const digest = "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8" // password
+
+	tests := []struct {
+		name   string
+		line   string
+		wantOK bool
+	}{
+		{"只有摘要", digest, true},
+		{"小写摘要", strings.ToLower(digest), true},
+		{"HIBP格式", digest + ":9659365", true},
+		{"明文密码", "password", false},
+		{"摘要过短", digest[:39], false},
+		{"摘要后不是冒号", digest + "0", false},
+		{"包含非十六进制字符", "Z" + digest[1:], false},
+		{"空行", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseSHA1Line(tt.line)
+			if ok != tt.wantOK {
+				t.Fatalf("parseSHA1Line(%q) ok = %v, want %v", tt.line, ok, tt.wantOK)
+			}
+			if ok && strings.ToUpper(hex.EncodeToString(got[:])) != digest {
+				t.Fatalf("parseSHA1Line(%q) = %X", tt.line, got)
+			}
+		})
+	}
+}
+
+func TestBreachedPasswordListSortedFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// 按摘要排序的HIBP格式列表，使用Windows换行符
+	lines := make([]string, 0, 2000)
+	for i := 0; i < 2000; i++ {
+		digest := sha1.Sum([]byte(fmt.Sprintf("breached-%d", i)))
+		lines = append(lines, fmt.Sprintf("%X:%d", digest, i+1))
+	}
+	sort.Strings(lines)
+	sorted := filepath.Join(dir, "sorted.txt")
+	if err := os.WriteFile(sorted, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := filepath.Join(dir, "plaintext.txt")
+	if err := os.WriteFile(plaintext, []byte("123456\npassword\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		password string
+		want     bool
+	}{
+		{"排序列表第一项", sorted, passwordForLine(t, lines[0]), true},
+		{"排序列表最后一项", sorted, passwordForLine(t, lines[len(lines)-1]), true},
+		{"排序列表中间项", sorted, "breached-1000", true},
+		{"排序列表中不存在", sorted, "not-breached", false},
+		{"明文列表", plaintext, "password", true},
+		{"明文列表中不存在", plaintext, "correct horse battery staple", false},
+	}
+
+	list := &breachedPasswordList{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := list.contains(tt.path, tt.password)
+			if err != nil {
+				t.Fatalf("contains err = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("contains(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+// passwordForLine 查找摘要对应的测试密码
+func passwordForLine(t *testing.T, line string) string {
+	t.Helper()
+	for i := 0; i < 2000; i++ {
+		password := fmt.Sprintf("breached-%d", i)
+		if strings.HasPrefix(line, fmt.Sprintf("%X", sha1.Sum([]byte(password)))) {
+			return password
+		}
+	}
+	t.Fatalf("找不到摘要 %s 对应的密码", line)
+	return ""
+}
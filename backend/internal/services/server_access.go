@@ -0,0 +1,230 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// 服务器访问角色，权限依次递增
+const (
+	ServerRoleViewer   = "viewer"   // 查看服务器、媒体库和播放会话
+	ServerRoleOperator = "operator" // 同步、测试连接、刷新媒体库和控制播放
+	ServerRoleOwner    = "owner"    // 修改、删除及共享服务器
+)
+
+var (
+	// ErrServerNotFound 服务器不存在或已删除
+	ErrServerNotFound = errors.New("服务器不存在")
+	// ErrServerAccessDenied 用户没有访问服务器所需的角色
+	ErrServerAccessDenied = errors.New("无权访问该服务器")
+	// ErrLastServerOwner 服务器至少需要保留一个所有者
+	ErrLastServerOwner = errors.New("不能移除服务器的最后一个所有者")
+)
+
+// serverRoleRank 角色等级，未知角色为0
+var serverRoleRank = map[string]int{
+	ServerRoleViewer:   1,
+	ServerRoleOperator: 2,
+	ServerRoleOwner:    3,
+}
+
+// ValidServerRole 判断角色是否有效
+func ValidServerRole(role string) bool {
+	return serverRoleRank[role] > 0
+}
+
+// ServerRoleAllows 判断角色是否满足要求的角色
+func ServerRoleAllows(role, required string) bool {
+	return serverRoleRank[role] > 0 && serverRoleRank[role] >= serverRoleRank[required]
+}
+
+// ServerAccessService 服务器访问控制服务
+type ServerAccessService struct {
+	auditService *AuditService
+}
+
+// NewServerAccessService 创建服务器访问控制服务
+func NewServerAccessService() *ServerAccessService {
+	return &ServerAccessService{
+		auditService: NewAuditService(),
+	}
+}
+
+// GetRole 获取用户对服务器的角色，管理员视为所有者；未关联时返回空字符串
+func (s *ServerAccessService) GetRole(userID uint, isAdmin bool, serverID uint) (string, error) {
+	var server models.EmbyServer
+	if err := database.DB.Select("id").First(&server, serverID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrServerNotFound
+		}
+		return "", fmt.Errorf("查询服务器失败: %w", err)
+	}
+
+	if isAdmin {
+		return ServerRoleOwner, nil
+	}
+
+	var member models.UserEmbyServer
+	err := database.DB.Where("user_id = ? AND emby_server_id = ?", userID, serverID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("查询服务器权限失败: %w", err)
+	}
+	return member.Role, nil
+}
+
+// CheckAccess 检查用户对服务器是否具有指定角色
+func (s *ServerAccessService) CheckAccess(userID uint, isAdmin bool, serverID uint, required string) error {
+	role, err := s.GetRole(userID, isAdmin, serverID)
+	if err != nil {
+		return err
+	}
+	if !ServerRoleAllows(role, required) {
+		return ErrServerAccessDenied
+	}
+	return nil
+}
+
+// AccessibleServerIDs 获取用户至少具有指定角色的服务器ID
+// 管理员返回nil，表示不限制服务器
+func (s *ServerAccessService) AccessibleServerIDs(userID uint, isAdmin bool, required string) ([]uint, error) {
+	if isAdmin {
+		return nil, nil
+	}
+
+	var roles []string
+	for role := range serverRoleRank {
+		if ServerRoleAllows(role, required) {
+			roles = append(roles, role)
+		}
+	}
+
+	serverIDs := []uint{}
+	if err := database.DB.Model(&models.UserEmbyServer{}).
+		Where("user_id = ? AND role IN ?", userID, roles).
+		Pluck("emby_server_id", &serverIDs).Error; err != nil {
+		return nil, fmt.Errorf("查询可访问服务器失败: %w", err)
+	}
+	return serverIDs, nil
+}
+
+// GetMembers 获取服务器的共享用户列表
+func (s *ServerAccessService) GetMembers(serverID uint) ([]models.UserEmbyServer, error) {
+	var members []models.UserEmbyServer
+	if err := database.DB.Where("emby_server_id = ?", serverID).
+		Preload("User").
+		Order("created_at").
+		Find(&members).Error; err != nil {
+		return nil, fmt.Errorf("查询服务器共享用户失败: %w", err)
+	}
+	return members, nil
+}
+
+// ShareServer 将服务器共享给用户，用户已有角色时更新角色
+func (s *ServerAccessService) ShareServer(actor AuditActor, serverID, userID uint, role string) (*models.UserEmbyServer, error) {
+	if !ValidServerRole(role) {
+		return nil, fmt.Errorf("无效的服务器角色: %s", role)
+	}
+
+	var user models.User
+	if err := database.DB.Select("id").First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("用户不存在")
+	}
+
+	var member models.UserEmbyServer
+	var before interface{}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND emby_server_id = ?", userID, serverID).First(&member).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			member = models.UserEmbyServer{UserID: userID, EmbyServerID: serverID, Role: role}
+			return tx.Create(&member).Error
+		}
+		if err != nil {
+			return err
+		}
+		before = serverMemberSnapshot(&member)
+
+		if member.Role == ServerRoleOwner && role != ServerRoleOwner {
+			if err := ensureOtherOwner(tx, serverID, userID); err != nil {
+				return err
+			}
+		}
+		member.Role = role
+		return tx.Model(&member).Update("role", role).Error
+	})
+	if err != nil {
+		if errors.Is(err, ErrLastServerOwner) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("共享服务器失败: %w", err)
+	}
+
+	s.auditService.Record(actor, AuditActionServerShare, AuditTargetServer, serverID, before, serverMemberSnapshot(&member))
+	return &member, nil
+}
+
+// UnshareServer 取消用户对服务器的访问
+func (s *ServerAccessService) UnshareServer(actor AuditActor, serverID, userID uint) error {
+	var member models.UserEmbyServer
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND emby_server_id = ?", userID, serverID).First(&member).Error; err != nil {
+			return err
+		}
+
+		if member.Role == ServerRoleOwner {
+			if err := ensureOtherOwner(tx, serverID, userID); err != nil {
+				return err
+			}
+		}
+		return tx.Where("user_id = ? AND emby_server_id = ?", userID, serverID).
+			Delete(&models.UserEmbyServer{}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("该用户没有此服务器的访问权限")
+	}
+	if err != nil && !errors.Is(err, ErrLastServerOwner) {
+		return fmt.Errorf("取消共享失败: %w", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.auditService.Record(actor, AuditActionServerUnshare, AuditTargetServer, serverID, serverMemberSnapshot(&member), nil)
+	return nil
+}
+
+// serverMemberSnapshot 服务器共享关系的审计快照
+func serverMemberSnapshot(member *models.UserEmbyServer) map[string]interface{} {
+	return map[string]interface{}{
+		"user_id": member.UserID,
+		"role":    member.Role,
+	}
+}
+
+// scopeServers 将查询限制在指定服务器内，serverIDs为nil时不限制
+func scopeServers(query *gorm.DB, column string, serverIDs []uint) *gorm.DB {
+	if serverIDs == nil {
+		return query
+	}
+	return query.Where(column+" IN ?", serverIDs)
+}
+
+// ensureOtherOwner 确认除指定用户外服务器还有其他所有者
+func ensureOtherOwner(tx *gorm.DB, serverID, userID uint) error {
+	var owners int64
+	if err := tx.Model(&models.UserEmbyServer{}).
+		Where("emby_server_id = ? AND role = ? AND user_id <> ?", serverID, ServerRoleOwner, userID).
+		Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrLastServerOwner
+	}
+	return nil
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/config"
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+)
+
+// 时间序列的数据来源
+const (
+	MetricResolutionRaw  = "raw"
+	MetricResolutionHour = "hour"
+	MetricResolutionDay  = "day"
+)
+
+// metricResolutionDurations 汇总分辨率对应的时间长度，按天汇总以UTC零点为界
+var metricResolutionDurations = map[string]time.Duration{
+	MetricResolutionHour: time.Hour,
+	MetricResolutionDay:  24 * time.Hour,
+}
+
+// MetricPoint 一个时间段内的采样汇总，延迟只统计成功的采样
+type MetricPoint struct {
+	Time       time.Time
+	Samples    int
+	UpSamples  int
+	LatencySum int64
+	LatencyMin int
+	LatencyMax int
+}
+
+// add 合并另一个时间段的汇总
+func (p *MetricPoint) add(o MetricPoint) {
+	if o.UpSamples > 0 {
+		if p.UpSamples == 0 || o.LatencyMin < p.LatencyMin {
+			p.LatencyMin = o.LatencyMin
+		}
+		if p.UpSamples == 0 || o.LatencyMax > p.LatencyMax {
+			p.LatencyMax = o.LatencyMax
+		}
+	}
+	p.Samples += o.Samples
+	p.UpSamples += o.UpSamples
+	p.LatencySum += o.LatencySum
+}
+
+// UptimePercent 可用率，没有采样时返回nil
+func (p *MetricPoint) UptimePercent() *float64 {
+	if p.Samples == 0 {
+		return nil
+	}
+	uptime := float64(p.UpSamples) / float64(p.Samples) * 100
+	return &uptime
+}
+
+// LatencyAvg 平均延迟（毫秒），没有成功的采样时返回nil
+func (p *MetricPoint) LatencyAvg() *int {
+	if p.UpSamples == 0 {
+		return nil
+	}
+	avg := int(p.LatencySum / int64(p.UpSamples))
+	return &avg
+}
+
+// MetricSeries 按固定步长划分的时间序列，没有采样的时间段也会返回
+type MetricSeries struct {
+	From       time.Time
+	To         time.Time
+	Step       time.Duration
+	Resolution string // 主要数据来源，最近尚未汇总的部分总是来自更细的数据
+	Points     []MetricPoint
+	Summary    MetricPoint
+}
+
+// ServerMetricsService 服务器可用率和延迟时间序列服务
+type ServerMetricsService struct{}
+
+// NewServerMetricsService 创建服务器时间序列服务
+func NewServerMetricsService() *ServerMetricsService {
+	return &ServerMetricsService{}
+}
+
+// RecordSample 记录一次健康检查的结果
+func (s *ServerMetricsService) RecordSample(serverID uint, checkedAt time.Time, up bool, responseTime int) {
+	sample := models.ServerMetric{
+		EmbyServerID: serverID,
+		CheckedAt:    checkedAt,
+		Up:           up,
+	}
+	if up {
+		sample.ResponseTime = responseTime
+	}
+	if err := database.DB.Create(&sample).Error; err != nil {
+		log.Printf("记录服务器 %d 健康检查采样失败: %v", serverID, err)
+	}
+}
+
+// MetricResolution 根据步长选择数据来源：步长是整天时使用按天汇总，整小时时使用按小时汇总，否则使用原始采样
+func MetricResolution(step time.Duration) string {
+	switch {
+	case step%(24*time.Hour) == 0:
+		return MetricResolutionDay
+	case step%time.Hour == 0:
+		return MetricResolutionHour
+	default:
+		return MetricResolutionRaw
+	}
+}
+
+// GetSeries 获取时间序列，from按步长向下对齐
+// 优先使用步长允许的最粗汇总，汇总尚未覆盖的最近时间段依次使用更细的汇总和原始采样补齐
+func (s *ServerMetricsService) GetSeries(serverID uint, from, to time.Time, step time.Duration) (*MetricSeries, error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("步长必须大于0")
+	}
+	from = from.Truncate(step)
+	if !from.Before(to) {
+		return nil, ErrInvalidTimeRange
+	}
+
+	series := &MetricSeries{
+		From:       from,
+		To:         to,
+		Step:       step,
+		Resolution: MetricResolution(step),
+	}
+
+	// 从粗到细依次读取，每一级只读取上一级尚未覆盖的部分
+	var levels []string
+	switch series.Resolution {
+	case MetricResolutionDay:
+		levels = []string{MetricResolutionDay, MetricResolutionHour}
+	case MetricResolutionHour:
+		levels = []string{MetricResolutionHour}
+	}
+
+	var sources []MetricPoint
+	cursor := from
+	for _, resolution := range levels {
+		watermark, err := s.watermark(serverID, resolution)
+		if err != nil {
+			return nil, err
+		}
+		if !watermark.After(cursor) {
+			continue
+		}
+		end := watermark
+		if to.Before(end) {
+			end = to
+		}
+
+		var rollups []models.ServerMetricRollup
+		if err := database.DB.Where("emby_server_id = ? AND resolution = ? AND bucket_start >= ? AND bucket_start < ?",
+			serverID, resolution, cursor, end).Find(&rollups).Error; err != nil {
+			return nil, fmt.Errorf("查询汇总数据失败: %w", err)
+		}
+		for _, rollup := range rollups {
+			sources = append(sources, rollupPoint(&rollup))
+		}
+		cursor = end
+	}
+
+	if cursor.Before(to) {
+		var samples []models.ServerMetric
+		if err := database.DB.Where("emby_server_id = ? AND checked_at >= ? AND checked_at < ?", serverID, cursor, to).
+			Find(&samples).Error; err != nil {
+			return nil, fmt.Errorf("查询采样数据失败: %w", err)
+		}
+		for _, sample := range samples {
+			sources = append(sources, samplePoint(&sample))
+		}
+	}
+
+	// 按步长划分时间段
+	count := int((to.Sub(from) + step - 1) / step)
+	series.Points = make([]MetricPoint, count)
+	for i := range series.Points {
+		series.Points[i].Time = from.Add(time.Duration(i) * step)
+	}
+	for _, point := range sources {
+		i := int(point.Time.Sub(from) / step)
+		if i < 0 || i >= count {
+			continue
+		}
+		series.Points[i].add(point)
+		series.Summary.add(point)
+	}
+	series.Summary.Time = from
+
+	return series, nil
+}
+
+// Rollup 把已结束的小时和天汇总，并按配置清理过期数据，由定时任务按服务器调用
+func (s *ServerMetricsService) Rollup(serverID uint, now time.Time) error {
+	if err := s.rollupHours(serverID, now.Truncate(time.Hour)); err != nil {
+		return err
+	}
+	if err := s.rollupDays(serverID, now.Truncate(24*time.Hour)); err != nil {
+		return err
+	}
+	return s.prune(serverID, now)
+}
+
+// rollupHours 把上次汇总之后到end之前的原始采样按小时汇总
+func (s *ServerMetricsService) rollupHours(serverID uint, end time.Time) error {
+	start, err := s.watermark(serverID, MetricResolutionHour)
+	if err != nil {
+		return err
+	}
+	if start.IsZero() {
+		var first models.ServerMetric
+		result := database.DB.Where("emby_server_id = ?", serverID).Order("checked_at").Limit(1).Find(&first)
+		if result.Error != nil {
+			return fmt.Errorf("查询采样数据失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		start = first.CheckedAt.Truncate(time.Hour)
+	}
+	if !start.Before(end) {
+		return nil
+	}
+
+	var samples []models.ServerMetric
+	if err := database.DB.Where("emby_server_id = ? AND checked_at >= ? AND checked_at < ?", serverID, start, end).
+		Order("checked_at").Find(&samples).Error; err != nil {
+		return fmt.Errorf("查询采样数据失败: %w", err)
+	}
+
+	points := make([]MetricPoint, 0, len(samples))
+	for _, sample := range samples {
+		points = append(points, samplePoint(&sample))
+	}
+	return saveRollups(serverID, MetricResolutionHour, points)
+}
+
+// rollupDays 把上次汇总之后到end之前的小时汇总按天汇总
+func (s *ServerMetricsService) rollupDays(serverID uint, end time.Time) error {
+	start, err := s.watermark(serverID, MetricResolutionDay)
+	if err != nil {
+		return err
+	}
+	if start.IsZero() {
+		var first models.ServerMetricRollup
+		result := database.DB.Where("emby_server_id = ? AND resolution = ?", serverID, MetricResolutionHour).
+			Order("bucket_start").Limit(1).Find(&first)
+		if result.Error != nil {
+			return fmt.Errorf("查询汇总数据失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		start = first.BucketStart.Truncate(24 * time.Hour)
+	}
+	if !start.Before(end) {
+		return nil
+	}
+
+	var hours []models.ServerMetricRollup
+	if err := database.DB.Where("emby_server_id = ? AND resolution = ? AND bucket_start >= ? AND bucket_start < ?",
+		serverID, MetricResolutionHour, start, end).Order("bucket_start").Find(&hours).Error; err != nil {
+		return fmt.Errorf("查询汇总数据失败: %w", err)
+	}
+
+	points := make([]MetricPoint, 0, len(hours))
+	for _, hour := range hours {
+		points = append(points, rollupPoint(&hour))
+	}
+	return saveRollups(serverID, MetricResolutionDay, points)
+}
+
+// saveRollups 按分辨率合并数据点并保存，数据点需要按时间升序
+func saveRollups(serverID uint, resolution string, points []MetricPoint) error {
+	duration := metricResolutionDurations[resolution]
+
+	var rollups []models.ServerMetricRollup
+	for _, point := range points {
+		bucket := point.Time.Truncate(duration)
+		if n := len(rollups); n == 0 || !rollups[n-1].BucketStart.Equal(bucket) {
+			rollups = append(rollups, models.ServerMetricRollup{
+				EmbyServerID: serverID,
+				Resolution:   resolution,
+				BucketStart:  bucket,
+			})
+		}
+		current := rollupPoint(&rollups[len(rollups)-1])
+		current.add(point)
+		applyRollupPoint(&rollups[len(rollups)-1], current)
+	}
+	if len(rollups) == 0 {
+		return nil
+	}
+
+	if err := database.DB.CreateInBatches(rollups, 500).Error; err != nil {
+		return fmt.Errorf("保存汇总数据失败: %w", err)
+	}
+	return nil
+}
+
+// prune 删除超过保留时长的数据，尚未汇总的数据不会被删除，连接日志按原始采样的保留时长清理
+func (s *ServerMetricsService) prune(serverID uint, now time.Time) error {
+	cfg := config.AppConfig.ServerMetrics
+
+	hourWatermark, err := s.watermark(serverID, MetricResolutionHour)
+	if err != nil {
+		return err
+	}
+	dayWatermark, err := s.watermark(serverID, MetricResolutionDay)
+	if err != nil {
+		return err
+	}
+
+	if cutoff := retentionCutoff(now, cfg.RawRetentionDays, hourWatermark); !cutoff.IsZero() {
+		if err := database.DB.Where("emby_server_id = ? AND checked_at < ?", serverID, cutoff).
+			Delete(&models.ServerMetric{}).Error; err != nil {
+			return fmt.Errorf("清理采样数据失败: %w", err)
+		}
+	}
+	if cutoff := retentionCutoff(now, cfg.HourlyRetentionDays, dayWatermark); !cutoff.IsZero() {
+		if err := database.DB.Where("emby_server_id = ? AND resolution = ? AND bucket_start < ?", serverID, MetricResolutionHour, cutoff).
+			Delete(&models.ServerMetricRollup{}).Error; err != nil {
+			return fmt.Errorf("清理汇总数据失败: %w", err)
+		}
+	}
+	if cfg.DailyRetentionDays > 0 {
+		cutoff := now.AddDate(0, 0, -cfg.DailyRetentionDays)
+		if err := database.DB.Where("emby_server_id = ? AND resolution = ? AND bucket_start < ?", serverID, MetricResolutionDay, cutoff).
+			Delete(&models.ServerMetricRollup{}).Error; err != nil {
+			return fmt.Errorf("清理汇总数据失败: %w", err)
+		}
+	}
+	if cfg.RawRetentionDays > 0 {
+		cutoff := now.AddDate(0, 0, -cfg.RawRetentionDays)
+		if err := database.DB.Where("emby_server_id = ? AND created_at < ?", serverID, cutoff).
+			Delete(&models.ConnectionLog{}).Error; err != nil {
+			return fmt.Errorf("清理连接日志失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// retentionCutoff 计算清理的截止时间，不超过下一级汇总已覆盖的时间；返回零值表示不清理
+func retentionCutoff(now time.Time, days int, rolledUpTo time.Time) time.Time {
+	if days <= 0 || rolledUpTo.IsZero() {
+		return time.Time{}
+	}
+	cutoff := now.AddDate(0, 0, -days)
+	if rolledUpTo.Before(cutoff) {
+		cutoff = rolledUpTo
+	}
+	return cutoff
+}
+
+// watermark 返回汇总已覆盖到的时间（最后一个时间段的结束时间），没有汇总时返回零值
+func (s *ServerMetricsService) watermark(serverID uint, resolution string) (time.Time, error) {
+	var last models.ServerMetricRollup
+	result := database.DB.Where("emby_server_id = ? AND resolution = ?", serverID, resolution).
+		Order("bucket_start DESC").Limit(1).Find(&last)
+	if result.Error != nil {
+		return time.Time{}, fmt.Errorf("查询汇总数据失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return time.Time{}, nil
+	}
+	return last.BucketStart.Add(metricResolutionDurations[resolution]), nil
+}
+
+// samplePoint 把原始采样转换为数据点
+func samplePoint(sample *models.ServerMetric) MetricPoint {
+	point := MetricPoint{Time: sample.CheckedAt, Samples: 1}
+	if sample.Up {
+		point.UpSamples = 1
+		point.LatencySum = int64(sample.ResponseTime)
+		point.LatencyMin = sample.ResponseTime
+		point.LatencyMax = sample.ResponseTime
+	}
+	return point
+}
+
+// rollupPoint 把汇总记录转换为数据点
+func rollupPoint(rollup *models.ServerMetricRollup) MetricPoint {
+	return MetricPoint{
+		Time:       rollup.BucketStart,
+		Samples:    rollup.Samples,
+		UpSamples:  rollup.UpSamples,
+		LatencySum: rollup.LatencySum,
+		LatencyMin: rollup.LatencyMin,
+		LatencyMax: rollup.LatencyMax,
+	}
+}
+
+// applyRollupPoint 把数据点写回汇总记录
+func applyRollupPoint(rollup *models.ServerMetricRollup, point MetricPoint) {
+	rollup.Samples = point.Samples
+	rollup.UpSamples = point.UpSamples
+	rollup.LatencySum = point.LatencySum
+	rollup.LatencyMin = point.LatencyMin
+	rollup.LatencyMax = point.LatencyMax
+}
@@ -3,27 +3,55 @@ package services
 import (
 	"context"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/metrics"
 	"github.com/emby-client-go/backend/internal/models"
-	"github.com/emby-client-go/backend/pkg/emby"
 	"gorm.io/gorm"
 )
 
-type ServerService struct{}
+// 服务器认证方式
+const (
+	AuthTypeAPIKey   = "api_key"
+	AuthTypePassword = "password"
+)
+
+type ServerService struct {
+	mediaService   *MediaService
+	auditService   *AuditService
+	metricsService *ServerMetricsService
+	alertService   *AlertService
+}
 
 func NewServerService() *ServerService {
-	return &ServerService{}
+	return &ServerService{
+		mediaService:   NewMediaService(),
+		auditService:   NewAuditService(),
+		metricsService: NewServerMetricsService(),
+		alertService:   NewAlertService(),
+	}
 }
 
-// CreateServer 创建服务器
-func (s *ServerService) CreateServer(server *models.EmbyServer, userID uint) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+// CreateServer 创建服务器，操作者成为服务器所有者
+func (s *ServerService) CreateServer(actor AuditActor, server *models.EmbyServer) error {
+	userID := actor.UserID
+
+	ctx, cancel := context.WithTimeout(context.Background(), serverStatusTimeout(server))
 	defer cancel()
 
+	// 使用用户名密码登录获取访问令牌
+	if server.AuthType == AuthTypePassword {
+		if err := authenticateServer(ctx, server); err != nil {
+			return fmt.Errorf("登录Emby失败: %w", err)
+		}
+	} else {
+		server.AuthType = AuthTypeAPIKey
+	}
+
 	// 测试连接
-	client := emby.NewClient(server.URL, server.APIKey)
+	client := newEmbyClient(server)
 	info, duration, err := client.GetServerStatus(ctx)
 	if err != nil {
 		return fmt.Errorf("连接测试失败: %w", err)
@@ -41,54 +69,130 @@ func (s *ServerService) CreateServer(server *models.EmbyServer, userID uint) err
 		return fmt.Errorf("创建服务器失败: %w", err)
 	}
 
-	// 关联用户
-	if err := database.DB.Model(server).Association("Users").Append(&models.User{ID: userID}); err != nil {
+	// 关联用户，创建者为服务器所有者
+	if err := database.DB.Create(&models.UserEmbyServer{
+		UserID:       userID,
+		EmbyServerID: server.ID,
+		Role:         ServerRoleOwner,
+	}).Error; err != nil {
 		return fmt.Errorf("关联用户失败: %w", err)
 	}
 
 	// 记录连接日志
-	log := models.ConnectionLog{
+	recordConnectionLog(&models.ConnectionLog{
 		EmbyServerID: server.ID,
-		UserID:       userID,
-		Action:       "connect",
+		UserID:       &userID,
+		Action:       ConnectionActionConnect,
 		Message:      fmt.Sprintf("服务器连接成功，版本: %s", info.Version),
 		ResponseTime: int(duration.Milliseconds()),
-		Status:       "success",
-	}
-	database.DB.Create(&log)
+		Status:       ConnectionStatusSuccess,
+	})
 
+	s.auditService.Record(actor, AuditActionServerCreate, AuditTargetServer, server.ID, nil, serverAuditSnapshot(server))
 	return nil
 }
 
+// applyConnectionUpdates 将更新中与连接相关的字段应用到server，用于保存前测试连接
+func applyConnectionUpdates(server *models.EmbyServer, updates map[string]interface{}) {
+	if url, ok := updates["url"].(string); ok {
+		server.URL = url
+	}
+	if apiKey, ok := updates["api_key"].(models.EncryptedString); ok {
+		server.APIKey = apiKey
+	}
+	if username, ok := updates["emby_username"].(string); ok {
+		server.EmbyUsername = username
+	}
+	if password, ok := updates["emby_password"].(models.EncryptedString); ok {
+		server.EmbyPassword = password
+	}
+	if timeout, ok := updates["timeout"].(*int); ok {
+		server.Timeout = timeout
+	}
+	if retries, ok := updates["max_retries"].(*int); ok {
+		server.MaxRetries = retries
+	}
+	if authMode, ok := updates["auth_mode"].(string); ok {
+		server.AuthMode = authMode
+	}
+}
+
+// serverStatusTimeout 获取服务器状态（连接测试+系统信息两次请求）的最长等待时间
+func serverStatusTimeout(server *models.EmbyServer) time.Duration {
+	return 2 * embyClientOptions(server).MaxDuration()
+}
+
 // UpdateServer 更新服务器
-func (s *ServerService) UpdateServer(id uint, updates map[string]interface{}) error {
-	// 如果更新了URL或APIKey，需要重新测试连接
-	if url, hasURL := updates["url"].(string); hasURL {
-		if apiKey, hasKey := updates["api_key"].(string); hasKey {
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			defer cancel()
-
-			client := emby.NewClient(url, apiKey)
-			info, _, err := client.GetServerStatus(ctx)
-			if err != nil {
-				return fmt.Errorf("连接测试失败: %w", err)
+func (s *ServerService) UpdateServer(actor AuditActor, id uint, updates map[string]interface{}) error {
+	var current models.EmbyServer
+	if err := database.DB.First(&current, id).Error; err != nil {
+		return fmt.Errorf("服务器不存在: %w", err)
+	}
+
+	_, hasURL := updates["url"]
+	_, hasKey := updates["api_key"]
+	password, _ := updates["emby_password"].(models.EncryptedString)
+	hasPassword := password != ""
+
+	// 如果同时更新了URL和APIKey，或更新了登录密码，需要重新登录并测试连接
+	if (hasURL && hasKey) || hasPassword {
+		server := current
+		applyConnectionUpdates(&server, updates)
+
+		ctx, cancel := context.WithTimeout(context.Background(), serverStatusTimeout(&server))
+		defer cancel()
+
+		if hasPassword {
+			if err := authenticateServer(ctx, &server); err != nil {
+				return fmt.Errorf("登录Emby失败: %w", err)
 			}
+			updates["api_key"] = server.APIKey
+			updates["emby_user_id"] = server.EmbyUserID
+		}
 
-			// 更新版本和系统信息
-			updates["version"] = info.Version
-			updates["os"] = info.OperatingSystem
-			updates["status"] = "online"
-			now := time.Now()
-			updates["last_check"] = &now
+		client := newEmbyClient(&server)
+		info, _, err := client.GetServerStatus(ctx)
+		if err != nil {
+			return fmt.Errorf("连接测试失败: %w", err)
 		}
+
+		// 更新版本和系统信息
+		updates["version"] = info.Version
+		updates["os"] = info.OperatingSystem
+		updates["status"] = "online"
+		now := time.Now()
+		updates["last_check"] = &now
+	}
+
+	if err := database.DB.Model(&models.EmbyServer{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return err
 	}
+	InvalidateEmbyClient(id)
 
-	return database.DB.Model(&models.EmbyServer{}).Where("id = ?", id).Updates(updates).Error
+	var updated models.EmbyServer
+	if err := database.DB.First(&updated, id).Error; err == nil {
+		s.auditService.Record(actor, AuditActionServerUpdate, AuditTargetServer, id, serverAuditSnapshot(&current), serverAuditSnapshot(&updated))
+	}
+	return nil
 }
 
 // DeleteServer 删除服务器
-func (s *ServerService) DeleteServer(id uint) error {
-	return database.DB.Delete(&models.EmbyServer{}, id).Error
+func (s *ServerService) DeleteServer(actor AuditActor, id uint) error {
+	var server models.EmbyServer
+	if err := database.DB.First(&server, id).Error; err != nil {
+		return fmt.Errorf("服务器不存在: %w", err)
+	}
+	if err := database.DB.Delete(&server).Error; err != nil {
+		return err
+	}
+	InvalidateEmbyClient(id)
+	metrics.DeleteServer(id)
+	if err := s.alertService.CloseServerAlerts(id); err != nil {
+		log.Printf("结束服务器 %d 的告警失败: %v", id, err)
+	}
+
+	s.auditService.Record(actor, AuditActionServerDelete, AuditTargetServer, id, serverAuditSnapshot(&server), nil)
+	return nil
 }
 
 // GetServer 获取服务器详情
@@ -103,14 +207,17 @@ func (s *ServerService) GetServer(id uint) (*models.EmbyServer, error) {
 	return &server, nil
 }
 
-// GetServers 获取服务器列表
-func (s *ServerService) GetServers(userID uint, page, pageSize int) ([]models.EmbyServer, int64, error) {
+// GetServers 获取用户可访问的服务器列表，管理员返回所有服务器
+func (s *ServerService) GetServers(userID uint, isAdmin bool, page, pageSize int) ([]models.EmbyServer, int64, error) {
 	var servers []models.EmbyServer
 	var total int64
 
-	query := database.DB.Model(&models.EmbyServer{}).
-		Joins("JOIN user_emby_servers ON user_emby_servers.emby_server_id = emby_servers.id").
-		Where("user_emby_servers.user_id = ?", userID)
+	query := database.DB.Model(&models.EmbyServer{})
+	if !isAdmin {
+		query = query.
+			Joins("JOIN user_emby_servers ON user_emby_servers.emby_server_id = emby_servers.id").
+			Where("user_emby_servers.user_id = ?", userID)
+	}
 
 	// 统计总数
 	if err := query.Count(&total).Error; err != nil {
@@ -126,59 +233,77 @@ func (s *ServerService) GetServers(userID uint, page, pageSize int) ([]models.Em
 	return servers, total, nil
 }
 
-// TestConnection 测试服务器连接
-func (s *ServerService) TestConnection(id uint, userID uint) (time.Duration, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
+// TestConnection 测试服务器连接，userID为0时表示定时健康检查，检查结果同时记入可用率时间序列
+func (s *ServerService) TestConnection(ctx context.Context, id uint, userID uint) (time.Duration, error) {
 	server, err := s.GetServer(id)
 	if err != nil {
 		return 0, err
 	}
 
-	client := emby.NewClient(server.URL, server.APIKey)
+	ctx, cancel := context.WithTimeout(ctx, embyClientOptions(server).MaxDuration())
+	defer cancel()
+
+	client := GetEmbyClient(server)
 	duration, err := client.TestConnection(ctx)
+	// 调用方取消（如调度器停止）时不代表服务器不可用
+	if err != nil && ctx.Err() == context.Canceled {
+		return 0, err
+	}
 
 	// 记录连接日志
 	log := models.ConnectionLog{
 		EmbyServerID: server.ID,
-		UserID:       userID,
-		Action:       "connect",
+		Action:       ConnectionActionHealthCheck,
 		ResponseTime: int(duration.Milliseconds()),
 	}
+	if userID != 0 {
+		log.Action = ConnectionActionTest
+		log.UserID = &userID
+	}
 
+	now := time.Now()
+	if userID == 0 {
+		s.metricsService.RecordSample(server.ID, now, err == nil, log.ResponseTime)
+	}
 	if err != nil {
-		log.Status = "failed"
+		log.Status = ConnectionStatusFailed
 		log.Message = err.Error()
-		database.DB.Create(&log)
+		recordConnectionLog(&log)
+
+		// 更新服务器状态
+		database.DB.Model(server).Updates(map[string]interface{}{
+			"status":     "offline",
+			"last_check": &now,
+		})
+		raiseAlert(AlertEventServerOffline, server.ID, err.Error())
 		return 0, err
 	}
 
-	log.Status = "success"
+	log.Status = ConnectionStatusSuccess
 	log.Message = "连接测试成功"
-	database.DB.Create(&log)
+	recordConnectionLog(&log)
 
 	// 更新服务器状态
-	now := time.Now()
 	database.DB.Model(server).Updates(map[string]interface{}{
 		"status":     "online",
 		"last_check": &now,
 	})
+	resolveAlert(AlertEventServerOffline, server.ID)
 
 	return duration, nil
 }
 
 // SyncDevices 同步设备列表
-func (s *ServerService) SyncDevices(id uint) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
+func (s *ServerService) SyncDevices(ctx context.Context, id uint) error {
 	server, err := s.GetServer(id)
 	if err != nil {
 		return err
 	}
 
-	client := emby.NewClient(server.URL, server.APIKey)
+	ctx, cancel := context.WithTimeout(ctx, embyClientOptions(server).MaxDuration())
+	defer cancel()
+
+	client := GetEmbyClient(server)
 	devices, err := client.GetDevices(ctx)
 	if err != nil {
 		return fmt.Errorf("获取设备列表失败: %w", err)
@@ -219,56 +344,22 @@ func (s *ServerService) SyncDevices(id uint) error {
 	return nil
 }
 
-// SyncLibraries 同步媒体库列表
+// SyncLibraries 同步媒体库列表及其中的媒体项目
 func (s *ServerService) SyncLibraries(id uint) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	server, err := s.GetServer(id)
-	if err != nil {
+	if _, err := s.GetServer(id); err != nil {
 		return err
 	}
 
-	client := emby.NewClient(server.URL, server.APIKey)
-	libraries, err := client.GetLibraries(ctx)
-	if err != nil {
-		return fmt.Errorf("获取媒体库列表失败: %w", err)
-	}
-
-	// 同步媒体库到数据库
-	for _, lib := range libraries {
-		var library models.MediaLibrary
-		result := database.DB.Where("emby_server_id = ? AND name = ?", server.ID, lib.Name).First(&library)
-
-		if result.Error == gorm.ErrRecordNotFound {
-			// 创建新媒体库
-			library = models.MediaLibrary{
-				EmbyServerID:   server.ID,
-				Name:           lib.Name,
-				Type:           lib.CollectionType,
-				CollectionType: lib.CollectionType,
-				TotalItems:     lib.ItemCount,
-			}
-			database.DB.Create(&library)
-		} else {
-			// 更新媒体库信息
-			now := time.Now()
-			database.DB.Model(&library).Updates(map[string]interface{}{
-				"total_items":  lib.ItemCount,
-				"last_refresh": &now,
-			})
-		}
-	}
-
-	return nil
+	_, err := s.mediaService.SyncMediaLibraries(context.Background(), id, false)
+	return err
 }
 
-// GetConnectionLogs 获取连接日志
-func (s *ServerService) GetConnectionLogs(serverID uint, page, pageSize int) ([]models.ConnectionLog, int64, error) {
+// GetConnectionLogs 获取连接日志，最新的在前
+func (s *ServerService) GetConnectionLogs(serverID uint, q ConnectionLogQuery, page, pageSize int) ([]models.ConnectionLog, int64, error) {
 	var logs []models.ConnectionLog
 	var total int64
 
-	query := database.DB.Model(&models.ConnectionLog{}).Where("emby_server_id = ?", serverID)
+	query := applyConnectionLogQuery(database.DB.Model(&models.ConnectionLog{}).Where("emby_server_id = ?", serverID), q)
 
 	// 统计总数
 	if err := query.Count(&total).Error; err != nil {
@@ -277,10 +368,64 @@ func (s *ServerService) GetConnectionLogs(serverID uint, page, pageSize int) ([]
 
 	// 分页查询
 	offset := (page - 1) * pageSize
-	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).
 		Preload("User").Find(&logs).Error; err != nil {
 		return nil, 0, err
 	}
 
 	return logs, total, nil
 }
+
+// GetConnectionStats 统计一段时间内的可用率和延迟，可用率按主动探测（创建、测试和健康检查）的成功比例计算
+// 未指定时间范围时统计最近24小时
+func (s *ServerService) GetConnectionStats(serverID uint, from, to time.Time) (*ConnectionStats, error) {
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultConnectionStatsWindow)
+	}
+	if !from.Before(to) {
+		return nil, ErrInvalidTimeRange
+	}
+	if to.Sub(from) > maxConnectionStatsWindow {
+		return nil, ErrTimeRangeTooLarge
+	}
+	stats := &ConnectionStats{From: from, To: to}
+
+	window := func() *gorm.DB {
+		return applyConnectionLogQuery(database.DB.Model(&models.ConnectionLog{}).Where("emby_server_id = ?", serverID),
+			ConnectionLogQuery{From: from, To: to})
+	}
+	checks := func() *gorm.DB {
+		return window().Where("source = ? AND action IN ?", ConnectionSourceHTTP, connectionCheckActions)
+	}
+
+	if err := checks().Count(&stats.Checks).Error; err != nil {
+		return nil, err
+	}
+	if err := checks().Where("status = ?", ConnectionStatusSuccess).Count(&stats.SuccessfulChecks).Error; err != nil {
+		return nil, err
+	}
+	if err := window().Where("status = ?", ConnectionStatusFailed).Count(&stats.Failures).Error; err != nil {
+		return nil, err
+	}
+	if err := window().Where("source = ? AND action = ?", ConnectionSourceWebSocket, ConnectionActionDisconnect).
+		Count(&stats.Disconnects).Error; err != nil {
+		return nil, err
+	}
+
+	if stats.Checks > 0 {
+		uptime := float64(stats.SuccessfulChecks) / float64(stats.Checks) * 100
+		stats.UptimePercent = &uptime
+	}
+
+	var latencies []int
+	if err := checks().Where("status = ?", ConnectionStatusSuccess).
+		Order("response_time").Pluck("response_time", &latencies).Error; err != nil {
+		return nil, err
+	}
+	stats.Latency = newLatencyStats(latencies)
+
+	return stats, nil
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/config"
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidRefreshToken 刷新令牌无效、已过期或已吊销
+	ErrInvalidRefreshToken = errors.New("刷新令牌无效或已过期")
+	// ErrTokenRevoked 访问令牌或所属会话已吊销
+	ErrTokenRevoked = errors.New("令牌已失效，请重新登录")
+	// ErrSessionNotFound 会话不存在
+	ErrSessionNotFound = errors.New("会话不存在")
+)
+
+// AccessTokenTTL 访问令牌有效期
+func AccessTokenTTL() time.Duration {
+	if config.AppConfig != nil && config.AppConfig.JWT.ExpireTime > 0 {
+		return time.Duration(config.AppConfig.JWT.ExpireTime) * time.Second
+	}
+	return 15 * time.Minute
+}
+
+// RefreshTokenTTL 刷新令牌有效期
+func RefreshTokenTTL() time.Duration {
+	if config.AppConfig != nil && config.AppConfig.JWT.RefreshExpireTime > 0 {
+		return time.Duration(config.AppConfig.JWT.RefreshExpireTime) * time.Second
+	}
+	return 7 * 24 * time.Hour
+}
+
+// SessionClient 创建或刷新会话的客户端信息
+type SessionClient struct {
+	DeviceName string
+	UserAgent  string
+	IP         string
+}
+
+// SessionService 登录会话服务
+type SessionService struct{}
+
+// NewSessionService 创建登录会话服务
+func NewSessionService() *SessionService {
+	return &SessionService{}
+}
+
+// CreateSession 登录成功后创建会话，返回会话和刷新令牌明文
+func (s *SessionService) CreateSession(userID uint, client SessionClient) (*models.UserSession, string, error) {
+	sid, err := randomToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := &models.UserSession{
+		SID:              sid,
+		UserID:           userID,
+		RefreshTokenHash: hashToken(refreshToken),
+		DeviceName:       client.DeviceName,
+		UserAgent:        client.UserAgent,
+		IP:               client.IP,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(RefreshTokenTTL()),
+	}
+	if err := database.DB.Create(session).Error; err != nil {
+		return nil, "", fmt.Errorf("创建会话失败: %w", err)
+	}
+
+	// 顺带清理该用户早已失效的会话
+	database.DB.Where("user_id = ? AND expires_at < ?", userID, now.Add(-RefreshTokenTTL())).
+		Delete(&models.UserSession{})
+
+	return session, refreshToken, nil
+}
+
+// RotateRefreshToken 使用刷新令牌换取新的刷新令牌，旧令牌立即失效
+// 已轮换的旧令牌被再次使用时视为泄露，吊销整个会话
+func (s *SessionService) RotateRefreshToken(ctx context.Context, refreshToken string, client SessionClient) (*models.UserSession, string, error) {
+	hash := hashToken(refreshToken)
+
+	var session models.UserSession
+	err := database.DB.Where("refresh_token_hash = ?", hash).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := database.DB.Where("previous_refresh_hash = ?", hash).First(&session).Error; err == nil {
+			log.Printf("用户 %d 的会话 %d 检测到刷新令牌重复使用，已吊销", session.UserID, session.ID)
+			if err := s.revoke(ctx, &session); err != nil {
+				log.Printf("吊销会话 %d 失败: %v", session.ID, err)
+			}
+		}
+		return nil, "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("查询会话失败: %w", err)
+	}
+
+	now := time.Now()
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	newToken, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	updates := map[string]interface{}{
+		"refresh_token_hash":    hashToken(newToken),
+		"previous_refresh_hash": hash,
+		"last_used_at":          now,
+	}
+	if client.UserAgent != "" {
+		updates["user_agent"] = client.UserAgent
+	}
+	if client.IP != "" {
+		updates["ip"] = client.IP
+	}
+
+	// 条件更新，并发使用同一刷新令牌时只有一个请求成功
+	result := database.DB.Model(&models.UserSession{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, hash).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, "", fmt.Errorf("更新会话失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	if err := database.DB.First(&session, session.ID).Error; err != nil {
+		return nil, "", fmt.Errorf("查询会话失败: %w", err)
+	}
+	return &session, newToken, nil
+}
+
+// ValidateAccessToken 检查访问令牌及其所属会话是否已被吊销
+func (s *SessionService) ValidateAccessToken(ctx context.Context, jti, sid string) error {
+	if jti == "" || sid == "" {
+		// 引入会话之前签发的令牌
+		return ErrTokenRevoked
+	}
+
+	store := getRevocationStore()
+	for _, id := range []string{jti, sid} {
+		revoked, err := store.IsRevoked(ctx, id)
+		if err != nil {
+			return fmt.Errorf("查询令牌状态失败: %w", err)
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+	return nil
+}
+
+// IsTokenRevoked 检查令牌是否已吊销，用于一次性临时令牌
+func (s *SessionService) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	if jti == "" {
+		return true, nil
+	}
+	return getRevocationStore().IsRevoked(ctx, jti)
+}
+
+// RevokeAccessToken 吊销单个访问令牌
+func (s *SessionService) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return nil
+	}
+	return getRevocationStore().Revoke(ctx, jti, expiresAt)
+}
+
+// RevokeSessionBySID 按会话标识吊销会话，用于登出当前会话
+func (s *SessionService) RevokeSessionBySID(ctx context.Context, sid string) error {
+	var session models.UserSession
+	if err := database.DB.Where("s_id = ?", sid).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("查询会话失败: %w", err)
+	}
+	return s.revoke(ctx, &session)
+}
+
+// RevokeSession 吊销用户的指定会话
+func (s *SessionService) RevokeSession(ctx context.Context, userID, sessionID uint) error {
+	var session models.UserSession
+	if err := database.DB.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("查询会话失败: %w", err)
+	}
+	return s.revoke(ctx, &session)
+}
+
+// RevokeAllSessions 吊销用户的所有会话，exceptSID不为空时保留该会话
+func (s *SessionService) RevokeAllSessions(ctx context.Context, userID uint, exceptSID string) (int, error) {
+	query := database.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now())
+	if exceptSID != "" {
+		query = query.Where("s_id <> ?", exceptSID)
+	}
+
+	var sessions []models.UserSession
+	if err := query.Find(&sessions).Error; err != nil {
+		return 0, fmt.Errorf("查询会话失败: %w", err)
+	}
+
+	for i := range sessions {
+		if err := s.revoke(ctx, &sessions[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(sessions), nil
+}
+
+// ListSessions 获取用户未过期且未吊销的会话
+func (s *SessionService) ListSessions(userID uint) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	if err := database.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("查询会话失败: %w", err)
+	}
+	return sessions, nil
+}
+
+// revoke 标记会话已吊销，并将SID加入吊销列表使已签发的访问令牌失效
+func (s *SessionService) revoke(ctx context.Context, session *models.UserSession) error {
+	now := time.Now()
+	if session.RevokedAt == nil {
+		if err := database.DB.Model(session).Update("revoked_at", &now).Error; err != nil {
+			return fmt.Errorf("吊销会话失败: %w", err)
+		}
+	}
+
+	// 会话内签发的访问令牌最迟在一个有效期后过期
+	if err := getRevocationStore().Revoke(ctx, session.SID, now.Add(AccessTokenTTL())); err != nil {
+		return fmt.Errorf("写入吊销列表失败: %w", err)
+	}
+	return nil
+}
+
+// randomToken 生成URL安全的随机令牌
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成随机令牌失败: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken 计算令牌摘要，数据库中只保存摘要
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
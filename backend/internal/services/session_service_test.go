@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	service := NewSessionService()
+	user := createTestUser(t, "alice", RoleUser)
+
+	tests := []struct {
+		name string
+		// prepare 创建会话，返回本次用于刷新的令牌
+		prepare     func(t *testing.T, session *models.UserSession, token string) string
+		wantErr     error
+		wantRevoked bool
+	}{
+		{
+			name:    "有效令牌",
+			prepare: func(t *testing.T, session *models.UserSession, token string) string { return token },
+		},
+		{
+			name:    "未知令牌",
+			prepare: func(t *testing.T, session *models.UserSession, token string) string { return "unknown" },
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "已轮换的令牌被重复使用时吊销会话",
+			prepare: func(t *testing.T, session *models.UserSession, token string) string {
+				if _, _, err := service.RotateRefreshToken(ctx, token, SessionClient{}); err != nil {
+					t.Fatalf("首次刷新失败: %v", err)
+				}
+				return token
+			},
+			wantErr:     ErrInvalidRefreshToken,
+			wantRevoked: true,
+		},
+		{
+			name: "会话已过期",
+			prepare: func(t *testing.T, session *models.UserSession, token string) string {
+				database.DB.Model(session).Update("expires_at", time.Now().Add(-time.Minute))
+				return token
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "会话已吊销",
+			prepare: func(t *testing.T, session *models.UserSession, token string) string {
+				if err := service.RevokeSessionBySID(ctx, session.SID); err != nil {
+					t.Fatalf("吊销会话失败: %v", err)
+				}
+				return token
+			},
+			wantErr:     ErrInvalidRefreshToken,
+			wantRevoked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, token, err := service.CreateSession(user.ID, SessionClient{IP: "127.0.0.1"})
+			if err != nil {
+				t.Fatalf("创建会话失败: %v", err)
+			}
+
+			rotated, newToken, err := service.RotateRefreshToken(ctx, tt.prepare(t, session, token), SessionClient{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				if rotated.ID != session.ID || newToken == "" || newToken == token {
+					t.Fatalf("刷新后应返回同一会话的新令牌")
+				}
+			}
+
+			var stored models.UserSession
+			database.DB.First(&stored, session.ID)
+			if revoked := stored.RevokedAt != nil; revoked != tt.wantRevoked {
+				t.Fatalf("revoked = %v, want %v", revoked, tt.wantRevoked)
+			}
+			if tt.wantRevoked {
+				if err := service.ValidateAccessToken(ctx, "jti", session.SID); !errors.Is(err, ErrTokenRevoked) {
+					t.Fatalf("会话吊销后访问令牌仍然有效: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestRotateRefreshTokenReuseRevokesRotatedToken(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	service := NewSessionService()
+	user := createTestUser(t, "alice", RoleUser)
+
+	_, token, err := service.CreateSession(user.ID, SessionClient{})
+	if err != nil {
+		t.Fatalf("创建会话失败: %v", err)
+	}
+	_, newToken, err := service.RotateRefreshToken(ctx, token, SessionClient{})
+	if err != nil {
+		t.Fatalf("刷新失败: %v", err)
+	}
+
+	// 旧令牌被重复使用后，合法持有者的新令牌也随会话一起失效
+	if _, _, err := service.RotateRefreshToken(ctx, token, SessionClient{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("重复使用 err = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, _, err := service.RotateRefreshToken(ctx, newToken, SessionClient{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("新令牌 err = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
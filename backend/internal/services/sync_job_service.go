@@ -0,0 +1,482 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/metrics"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/pkg/websocket"
+)
+
+// 同步任务范围
+const (
+	SyncScopeServer = "server"
+	SyncScopeAll    = "all"
+)
+
+// 同步任务状态
+const (
+	SyncJobPending   = "pending"
+	SyncJobRunning   = "running"
+	SyncJobCompleted = "completed"
+	SyncJobFailed    = "failed"
+	SyncJobCancelled = "cancelled"
+)
+
+// ErrSyncJobFinished 任务已结束，无法取消
+var ErrSyncJobFinished = errors.New("任务已结束")
+
+// SyncJobService 媒体库同步任务服务
+type SyncJobService struct {
+	mediaService *MediaService
+	hub          *websocket.Hub
+	running      map[uint]*syncJobRun // 当前实例中运行的任务
+	mutex        sync.Mutex
+}
+
+// syncJobRun 运行中的同步任务状态
+type syncJobRun struct {
+	job       models.SyncJob
+	cancel    context.CancelFunc
+	servers   []uint
+	processed map[uint]int
+	totals    map[uint]int
+	finished  map[uint]bool
+	mutex     sync.Mutex
+}
+
+// NewSyncJobService 创建同步任务服务
+func NewSyncJobService(hub *websocket.Hub) *SyncJobService {
+	return &SyncJobService{
+		mediaService: NewMediaService(),
+		hub:          hub,
+		running:      make(map[uint]*syncJobRun),
+	}
+}
+
+// RecoverInterruptedJobs 将心跳超时的未完成任务标记为失败
+// 多副本部署时其他实例仍在运行的任务会持续心跳，不受影响
+func (s *SyncJobService) RecoverInterruptedJobs() error {
+	now := time.Now()
+	staleBefore := now.Add(-syncLeaseTTL)
+	result := database.DB.Model(&models.SyncJob{}).
+		Where("status IN ?", []string{SyncJobPending, SyncJobRunning}).
+		Where("(heartbeat_at IS NULL AND created_at < ?) OR heartbeat_at < ?", staleBefore, staleBefore).
+		Updates(map[string]interface{}{
+			"status":      SyncJobFailed,
+			"finished_at": &now,
+			"errors":      models.StringList{"服务重启，任务被中断"},
+		})
+	if result.Error != nil {
+		return fmt.Errorf("恢复同步任务状态失败: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("已将 %d 个中断的同步任务标记为失败", result.RowsAffected)
+	}
+	return nil
+}
+
+// StartServerSync 创建并异步执行单个服务器的同步任务
+// 服务器已在同步时返回正在运行的任务，created为false
+func (s *SyncJobService) StartServerSync(serverID, userID uint, full bool) (job *models.SyncJob, created bool, err error) {
+	job, err = s.createServerJob(serverID, userID, full)
+	if err != nil {
+		return s.runningJobFor(err)
+	}
+
+	ctx, run := s.register(job, []uint{serverID})
+	go s.run(ctx, run)
+	return job, true, nil
+}
+
+// RunServerSync 创建单个服务器的同步任务并等待其完成，供后台调度使用
+// 服务器已在同步时直接返回nil
+func (s *SyncJobService) RunServerSync(ctx context.Context, serverID uint, full bool) error {
+	job, err := s.createServerJob(serverID, 0, full)
+	if errors.Is(err, ErrServerSyncing) {
+		log.Printf("服务器 %d 正在同步，跳过本次调度: %v", serverID, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	runCtx, run := s.register(job, []uint{serverID})
+	stop := context.AfterFunc(ctx, run.cancel)
+	defer stop()
+
+	s.run(runCtx, run)
+
+	switch run.job.Status {
+	case SyncJobFailed:
+		return fmt.Errorf("同步任务 %d 失败: %s", job.ID, strings.Join(run.job.Errors, "; "))
+	case SyncJobCancelled:
+		return fmt.Errorf("同步任务 %d 已取消", job.ID)
+	}
+	return nil
+}
+
+// createServerJob 创建单服务器同步任务并获取该服务器的同步租约
+func (s *SyncJobService) createServerJob(serverID, userID uint, full bool) (*models.SyncJob, error) {
+	var server models.EmbyServer
+	if err := database.DB.First(&server, serverID).Error; err != nil {
+		return nil, fmt.Errorf("服务器不存在: %w", err)
+	}
+
+	job := &models.SyncJob{
+		EmbyServerID: serverID,
+		Scope:        SyncScopeServer,
+		Full:         full,
+		Status:       SyncJobPending,
+		CreatedBy:    userID,
+	}
+	if err := database.DB.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("创建同步任务失败: %w", err)
+	}
+
+	lease, acquired, err := acquireSyncLease(serverID, job.ID)
+	if err == nil && acquired {
+		return job, nil
+	}
+
+	// 未获取到租约，任务不会执行
+	database.DB.Delete(job)
+	if err != nil {
+		return nil, err
+	}
+	return nil, syncingError(lease)
+}
+
+// runningJobFor 服务器正在同步时查找持有租约的任务
+func (s *SyncJobService) runningJobFor(err error) (*models.SyncJob, bool, error) {
+	var syncing *syncingJobError
+	if !errors.As(err, &syncing) {
+		return nil, false, err
+	}
+
+	job, getErr := s.GetJob(syncing.jobID)
+	if getErr != nil {
+		return nil, false, err
+	}
+	return job, false, nil
+}
+
+// StartAllSync 创建并异步执行所有在线服务器的同步任务
+// allowed为nil时同步所有在线服务器，否则只同步其中在线的服务器
+func (s *SyncJobService) StartAllSync(userID uint, allowed []uint, full bool) (*models.SyncJob, error) {
+	var serverIDs []uint
+	if err := scopeServers(database.DB.Model(&models.EmbyServer{}), "id", allowed).
+		Where("status = ?", "online").
+		Pluck("id", &serverIDs).Error; err != nil {
+		return nil, fmt.Errorf("查询在线服务器失败: %w", err)
+	}
+
+	job := &models.SyncJob{
+		Scope:     SyncScopeAll,
+		Full:      full,
+		Status:    SyncJobPending,
+		CreatedBy: userID,
+	}
+	if err := database.DB.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("创建同步任务失败: %w", err)
+	}
+
+	ctx, run := s.register(job, serverIDs)
+	go s.run(ctx, run)
+	return job, nil
+}
+
+// GetJob 获取同步任务
+func (s *SyncJobService) GetJob(id uint) (*models.SyncJob, error) {
+	var job models.SyncJob
+	if err := database.DB.First(&job, id).Error; err != nil {
+		return nil, fmt.Errorf("同步任务不存在: %w", err)
+	}
+	return &job, nil
+}
+
+// ListJobs 获取最近的同步任务，userID为0时返回所有用户的任务
+func (s *SyncJobService) ListJobs(userID uint, limit int) ([]models.SyncJob, error) {
+	query := database.DB.Model(&models.SyncJob{})
+	if userID != 0 {
+		query = query.Where("created_by = ?", userID)
+	}
+
+	var jobs []models.SyncJob
+	if err := query.Order("id DESC").Limit(limit).Find(&jobs).Error; err != nil {
+		return nil, fmt.Errorf("查询同步任务失败: %w", err)
+	}
+	return jobs, nil
+}
+
+// CancelJob 取消同步任务
+func (s *SyncJobService) CancelJob(id uint) (*models.SyncJob, error) {
+	job, err := s.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != SyncJobPending && job.Status != SyncJobRunning {
+		return job, ErrSyncJobFinished
+	}
+
+	s.mutex.Lock()
+	run, ok := s.running[id]
+	s.mutex.Unlock()
+
+	if !ok {
+		// 任务不在当前实例中运行，标记为已取消，运行实例在心跳时检测到后终止
+		now := time.Now()
+		if err := database.DB.Model(job).Updates(map[string]interface{}{
+			"status":      SyncJobCancelled,
+			"finished_at": &now,
+		}).Error; err != nil {
+			return nil, fmt.Errorf("取消同步任务失败: %w", err)
+		}
+		return job, nil
+	}
+
+	run.cancel()
+	return job, nil
+}
+
+// register 登记运行中的任务
+func (s *SyncJobService) register(job *models.SyncJob, serverIDs []uint) (context.Context, *syncJobRun) {
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &syncJobRun{
+		job:       *job,
+		cancel:    cancel,
+		servers:   serverIDs,
+		processed: make(map[uint]int),
+		totals:    make(map[uint]int),
+		finished:  make(map[uint]bool),
+	}
+
+	s.mutex.Lock()
+	s.running[job.ID] = run
+	s.mutex.Unlock()
+
+	return ctx, run
+}
+
+// run 执行同步任务
+func (s *SyncJobService) run(ctx context.Context, run *syncJobRun) {
+	defer func() {
+		run.cancel()
+		s.mutex.Lock()
+		delete(s.running, run.job.ID)
+		s.mutex.Unlock()
+	}()
+
+	now := time.Now()
+	run.mutex.Lock()
+	run.job.Status = SyncJobRunning
+	run.job.StartedAt = &now
+	run.job.HeartbeatAt = &now
+	run.mutex.Unlock()
+	s.persist(run, "status", "started_at", "heartbeat_at")
+
+	go s.heartbeat(ctx, run)
+
+	var wg sync.WaitGroup
+	var failed int
+	var failMu sync.Mutex
+
+	for _, serverID := range run.servers {
+		wg.Add(1)
+		go func(serverID uint) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					s.addError(run, fmt.Sprintf("服务器 %d 同步异常: %v", serverID, r))
+					raiseAlert(AlertEventSyncFailed, serverID, fmt.Sprintf("同步异常: %v", r))
+					failMu.Lock()
+					failed++
+					failMu.Unlock()
+				}
+			}()
+
+			err := s.syncServer(ctx, run, serverID)
+			if errors.Is(err, ErrServerSyncing) {
+				// 其他任务正在同步该服务器，跳过但不计为失败
+				s.addError(run, fmt.Sprintf("服务器 %d 已跳过: %v", serverID, err))
+			} else if err != nil && ctx.Err() == nil {
+				s.addError(run, fmt.Sprintf("服务器 %d: %v", serverID, err))
+				failMu.Lock()
+				failed++
+				failMu.Unlock()
+				raiseAlert(AlertEventSyncFailed, serverID, err.Error())
+			} else if err == nil {
+				resolveAlert(AlertEventSyncFailed, serverID)
+			}
+			run.mutex.Lock()
+			run.finished[serverID] = true
+			run.mutex.Unlock()
+			s.updateProgress(run, serverID, "", -1, -1)
+		}(serverID)
+	}
+
+	wg.Wait()
+
+	finishedAt := time.Now()
+	run.mutex.Lock()
+	switch {
+	case ctx.Err() != nil:
+		run.job.Status = SyncJobCancelled
+	case failed > 0 && failed == len(run.servers):
+		run.job.Status = SyncJobFailed
+	default:
+		run.job.Status = SyncJobCompleted
+		run.job.Progress = 100
+	}
+	run.job.CurrentLibrary = ""
+	run.job.FinishedAt = &finishedAt
+	run.mutex.Unlock()
+
+	s.persist(run, "status", "progress", "current_library", "finished_at", "errors")
+	s.notify(run)
+	metrics.ObserveSyncJob(run.job.Scope, run.job.Status, finishedAt.Sub(now))
+
+	log.Printf("同步任务 %d 结束，状态: %s", run.job.ID, run.job.Status)
+}
+
+// syncServer 在持有租约的情况下同步单个服务器
+func (s *SyncJobService) syncServer(ctx context.Context, run *syncJobRun, serverID uint) error {
+	jobID := run.job.ID
+
+	// 单服务器任务在创建时已获取租约
+	if run.job.Scope != SyncScopeServer {
+		lease, acquired, err := acquireSyncLease(serverID, jobID)
+		if err != nil {
+			return err
+		}
+		if !acquired {
+			return syncingError(lease)
+		}
+	}
+	defer releaseSyncLease(serverID, jobID)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go keepSyncLease(ctx, cancel, serverID, jobID)
+
+	_, err := s.mediaService.syncMediaLibraries(ctx, serverID, run.job.Full, func(library string, done, total int) {
+		s.updateProgress(run, serverID, library, done, total)
+	})
+	return err
+}
+
+// heartbeat 定期刷新任务心跳，并检测其他实例发起的取消
+func (s *SyncJobService) heartbeat(ctx context.Context, run *syncJobRun) {
+	ticker := time.NewTicker(syncLeaseRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var status string
+			if err := database.DB.Model(&models.SyncJob{}).
+				Where("id = ?", run.job.ID).
+				Pluck("status", &status).Error; err == nil && status == SyncJobCancelled {
+				log.Printf("同步任务 %d 已被取消", run.job.ID)
+				run.cancel()
+				return
+			}
+
+			now := time.Now()
+			run.mutex.Lock()
+			run.job.HeartbeatAt = &now
+			run.mutex.Unlock()
+			s.persist(run, "heartbeat_at")
+		}
+	}
+}
+
+// updateProgress 更新任务进度并推送，done/total为-1表示仅刷新进度
+func (s *SyncJobService) updateProgress(run *syncJobRun, serverID uint, library string, done, total int) {
+	run.mutex.Lock()
+	if total >= 0 {
+		run.totals[serverID] = total
+		run.processed[serverID] = done
+	}
+	if library != "" {
+		run.job.CurrentLibrary = library
+	}
+
+	var fraction float64
+	var processed, totalLibraries int
+	for _, id := range run.servers {
+		processed += run.processed[id]
+		totalLibraries += run.totals[id]
+
+		switch {
+		case run.finished[id]:
+			fraction += 1
+		case run.totals[id] > 0:
+			fraction += float64(run.processed[id]) / float64(run.totals[id])
+		}
+	}
+
+	progress := 0.0
+	if len(run.servers) > 0 {
+		progress = math.Round(fraction/float64(len(run.servers))*1000) / 10
+	}
+	run.job.Progress = progress
+	run.job.ProcessedLibraries = processed
+	run.job.TotalLibraries = totalLibraries
+	run.mutex.Unlock()
+
+	s.persist(run, "progress", "processed_libraries", "total_libraries", "current_library")
+	s.notify(run)
+}
+
+// addError 记录任务错误
+func (s *SyncJobService) addError(run *syncJobRun, msg string) {
+	log.Printf("同步任务 %d: %s", run.job.ID, msg)
+
+	run.mutex.Lock()
+	run.job.Errors = append(run.job.Errors, msg)
+	run.mutex.Unlock()
+
+	s.persist(run, "errors")
+}
+
+// persist 将任务的指定字段写入数据库
+func (s *SyncJobService) persist(run *syncJobRun, fields ...string) {
+	run.mutex.Lock()
+	job := run.job
+	job.Errors = append(models.StringList(nil), run.job.Errors...)
+	run.mutex.Unlock()
+
+	if err := database.DB.Model(&job).Select(fields).Updates(&job).Error; err != nil {
+		log.Printf("保存同步任务 %d 状态失败: %v", job.ID, err)
+	}
+}
+
+// notify 通过WebSocket推送任务进度
+func (s *SyncJobService) notify(run *syncJobRun) {
+	if s.hub == nil {
+		return
+	}
+
+	run.mutex.Lock()
+	job := run.job
+	job.Errors = append(models.StringList(nil), run.job.Errors...)
+	run.mutex.Unlock()
+
+	serverID := ""
+	if job.EmbyServerID != 0 {
+		serverID = strconv.FormatUint(uint64(job.EmbyServerID), 10)
+	}
+	s.hub.SendSyncProgress(serverID, job.CreatedBy, job)
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	syncLeaseTTL           = 2 * time.Minute  // 租约有效期
+	syncLeaseRenewInterval = 30 * time.Second // 续约间隔，需明显小于有效期
+)
+
+// ErrServerSyncing 服务器正在由其他任务同步
+var ErrServerSyncing = errors.New("服务器正在同步中")
+
+// instanceID 当前进程的实例标识，多副本部署时用于区分租约持有者
+var instanceID = newInstanceID()
+
+// newInstanceID 生成实例标识
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// acquireSyncLease 尝试获取服务器同步租约
+// 获取失败时返回当前持有者的租约
+func acquireSyncLease(serverID, jobID uint) (*models.SyncLease, bool, error) {
+	now := time.Now()
+	lease := models.SyncLease{
+		EmbyServerID: serverID,
+		Holder:       instanceID,
+		SyncJobID:    jobID,
+		ExpiresAt:    now.Add(syncLeaseTTL),
+	}
+
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&lease)
+	if result.Error != nil {
+		return nil, false, fmt.Errorf("获取同步租约失败: %w", result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return &lease, true, nil
+	}
+
+	// 租约已存在，仅在过期时接管
+	result = database.DB.Model(&models.SyncLease{}).
+		Where("emby_server_id = ? AND expires_at < ?", serverID, now).
+		Updates(map[string]interface{}{
+			"holder":      instanceID,
+			"sync_job_id": jobID,
+			"expires_at":  lease.ExpiresAt,
+		})
+	if result.Error != nil {
+		return nil, false, fmt.Errorf("接管同步租约失败: %w", result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return &lease, true, nil
+	}
+
+	var current models.SyncLease
+	if err := database.DB.First(&current, "emby_server_id = ?", serverID).Error; err != nil {
+		return nil, false, fmt.Errorf("查询同步租约失败: %w", err)
+	}
+	return &current, false, nil
+}
+
+// renewSyncLease 续约，返回false表示租约已丢失
+func renewSyncLease(serverID, jobID uint) (bool, error) {
+	result := database.DB.Model(&models.SyncLease{}).
+		Where("emby_server_id = ? AND holder = ? AND sync_job_id = ?", serverID, instanceID, jobID).
+		Update("expires_at", time.Now().Add(syncLeaseTTL))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// releaseSyncLease 释放租约
+func releaseSyncLease(serverID, jobID uint) {
+	if err := database.DB.
+		Where("emby_server_id = ? AND holder = ? AND sync_job_id = ?", serverID, instanceID, jobID).
+		Delete(&models.SyncLease{}).Error; err != nil {
+		log.Printf("释放服务器 %d 同步租约失败: %v", serverID, err)
+	}
+}
+
+// keepSyncLease 在ctx结束前定期续约，租约丢失时调用cancel终止同步
+func keepSyncLease(ctx context.Context, cancel context.CancelFunc, serverID, jobID uint) {
+	ticker := time.NewTicker(syncLeaseRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ok, err := renewSyncLease(serverID, jobID)
+			if err != nil {
+				// 数据库暂时不可用时等待下次续约，租约有效期足以覆盖几次失败
+				log.Printf("服务器 %d 同步租约续约失败: %v", serverID, err)
+				continue
+			}
+			if !ok {
+				log.Printf("服务器 %d 同步租约已丢失，终止同步", serverID)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// syncingJobError 服务器正在由指定同步任务同步
+type syncingJobError struct {
+	jobID uint
+}
+
+func (e *syncingJobError) Error() string {
+	return fmt.Sprintf("%s（任务 %d）", ErrServerSyncing, e.jobID)
+}
+
+func (e *syncingJobError) Unwrap() error {
+	return ErrServerSyncing
+}
+
+// syncingError 构造服务器正在同步的错误
+func syncingError(lease *models.SyncLease) error {
+	if lease.SyncJobID != 0 {
+		return &syncingJobError{jobID: lease.SyncJobID}
+	}
+	return ErrServerSyncing
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 系统配置键
+const (
+	ConfigRequireTwoFactor = "security.require_two_factor" // 所有用户必须启用两步验证
+)
+
+// 系统配置分类
+const (
+	ConfigCategorySecurity = "security"
+)
+
+// SystemConfigService 系统配置服务，保存可在运行时由管理员修改的配置
+type SystemConfigService struct{}
+
+// NewSystemConfigService 创建系统配置服务
+func NewSystemConfigService() *SystemConfigService {
+	return &SystemConfigService{}
+}
+
+// Get 获取配置值，配置不存在时返回false
+func (s *SystemConfigService) Get(key string) (string, bool, error) {
+	// key在MySQL中是保留字，使用结构体条件由GORM处理引号
+	var cfg models.SystemConfig
+	err := database.DB.Where(&models.SystemConfig{Key: key}).First(&cfg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("查询系统配置失败: %w", err)
+	}
+	return cfg.Value, true, nil
+}
+
+// GetBool 获取布尔配置，配置不存在或无法解析时返回默认值
+func (s *SystemConfigService) GetBool(key string, defaultValue bool) (bool, error) {
+	value, ok, err := s.Get(key)
+	if err != nil || !ok {
+		return defaultValue, err
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue, nil
+	}
+	return b, nil
+}
+
+// GetCategory 一次获取某个分类下的全部配置
+func (s *SystemConfigService) GetCategory(category string) (map[string]string, error) {
+	var configs []models.SystemConfig
+	if err := database.DB.Where("category = ?", category).Find(&configs).Error; err != nil {
+		return nil, fmt.Errorf("查询系统配置失败: %w", err)
+	}
+	values := make(map[string]string, len(configs))
+	for _, cfg := range configs {
+		values[cfg.Key] = cfg.Value
+	}
+	return values, nil
+}
+
+// Set 写入配置值，已存在时覆盖
+func (s *SystemConfigService) Set(key, value, category, description string) error {
+	cfg := models.SystemConfig{
+		Key:         key,
+		Value:       value,
+		Category:    category,
+		Description: description,
+	}
+	if err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&cfg).Error; err != nil {
+		return fmt.Errorf("保存系统配置失败: %w", err)
+	}
+	return nil
+}
+
+// SetBool 写入布尔配置
+func (s *SystemConfigService) SetBool(key string, value bool, category, description string) error {
+	return s.Set(key, strconv.FormatBool(value), category, description)
+}
+
+// SetInt 写入整数配置
+func (s *SystemConfigService) SetInt(key string, value int, category, description string) error {
+	return s.Set(key, strconv.Itoa(value), category, description)
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/emby-client-go/backend/internal/config"
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/internal/secrets"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB 使用临时SQLite数据库初始化配置、加密密钥和内置角色
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	config.Init()
+	config.AppConfig.Database.Type = "sqlite"
+	config.AppConfig.Database.Database = filepath.Join(t.TempDir(), "test.db")
+	config.AppConfig.Log.Level = "silent"
+
+	if err := secrets.Init("", "", "", "test-secret"); err != nil {
+		t.Fatalf("初始化加密密钥失败: %v", err)
+	}
+	if err := database.Init(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	database.DB.Logger = logger.Default.LogMode(logger.Silent)
+	t.Cleanup(func() { database.Close() })
+
+	if err := NewRBACService().EnsureDefaults(); err != nil {
+		t.Fatalf("初始化角色权限失败: %v", err)
+	}
+}
+
+// createTestUser 创建测试用户
+func createTestUser(t *testing.T, username, role string) *models.User {
+	t.Helper()
+
+	user := &models.User{Username: username, Email: username + "@example.com", Password: "x", Role: role, Status: "active"}
+	if err := database.DB.Create(user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	return user
+}
+
+// createTestRole 创建拥有指定权限的自定义角色
+func createTestRole(t *testing.T, name string, permissions ...string) {
+	t.Helper()
+
+	if _, err := NewRBACService().CreateRole(RoleAdmin, name, name, permissions); err != nil {
+		t.Fatalf("创建角色 %s 失败: %v", name, err)
+	}
+}
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/config"
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount 每次生成的恢复码数量
+const recoveryCodeCount = 10
+
+var (
+	// ErrTwoFactorAlreadyEnabled 两步验证已启用
+	ErrTwoFactorAlreadyEnabled = errors.New("两步验证已启用")
+	// ErrTwoFactorNotEnabled 两步验证未启用
+	ErrTwoFactorNotEnabled = errors.New("两步验证未启用")
+	// ErrTwoFactorSetupNotStarted 尚未生成两步验证密钥
+	ErrTwoFactorSetupNotStarted = errors.New("请先生成两步验证密钥")
+	// ErrTwoFactorRequired 系统要求启用两步验证，不能关闭
+	ErrTwoFactorRequired = errors.New("系统要求启用两步验证，不能关闭")
+)
+
+// TwoFactorStatus 用户的两步验证状态
+type TwoFactorStatus struct {
+	Enabled                bool  `json:"enabled"`
+	Required               bool  `json:"required"` // 系统是否要求启用
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// TwoFactorService TOTP两步验证服务
+type TwoFactorService struct {
+	configService *SystemConfigService
+	userService   *UserService
+	policyService *SecurityPolicyService
+}
+
+// NewTwoFactorService 创建两步验证服务
+func NewTwoFactorService() *TwoFactorService {
+	return &TwoFactorService{
+		configService: NewSystemConfigService(),
+		userService:   NewUserService(),
+		policyService: NewSecurityPolicyService(),
+	}
+}
+
+// IsRequired 系统是否要求所有用户启用两步验证
+func (s *TwoFactorService) IsRequired() (bool, error) {
+	return s.configService.GetBool(ConfigRequireTwoFactor, false)
+}
+
+// SetRequired 设置是否要求所有用户启用两步验证
+func (s *TwoFactorService) SetRequired(required bool) error {
+	return s.configService.SetBool(ConfigRequireTwoFactor, required, ConfigCategorySecurity, "所有用户必须启用两步验证")
+}
+
+// GetStatus 获取用户的两步验证状态
+func (s *TwoFactorService) GetStatus(userID uint) (*TwoFactorStatus, error) {
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	required, err := s.IsRequired()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TwoFactorStatus{Enabled: user.TOTPEnabled, Required: required}
+	if user.TOTPEnabled {
+		if err := database.DB.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND used_at IS NULL", userID).
+			Count(&status.RecoveryCodesRemaining).Error; err != nil {
+			return nil, fmt.Errorf("查询恢复码失败: %w", err)
+		}
+	}
+	return status, nil
+}
+
+// BeginSetup 生成待确认的TOTP密钥，返回密钥和用于生成二维码的otpauth URI
+func (s *TwoFactorService) BeginSetup(userID uint) (string, string, error) {
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return "", "", err
+	}
+	if user.TOTPEnabled {
+		return "", "", ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", fmt.Errorf("生成两步验证密钥失败: %w", err)
+	}
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"totp_secret":       models.EncryptedString(secret),
+		"totp_last_counter": 0,
+	}).Error; err != nil {
+		return "", "", fmt.Errorf("保存两步验证密钥失败: %w", err)
+	}
+
+	return secret, utils.TOTPProvisioningURI(config.AppConfig.JWT.Issuer, user.Username, secret), nil
+}
+
+// Enable 校验验证码后启用两步验证，返回恢复码明文（只显示这一次）
+func (s *TwoFactorService) Enable(userID uint, code string) ([]string, error) {
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorSetupNotStarted
+	}
+
+	counter, ok := utils.ValidateTOTP(string(user.TOTPSecret), code, time.Now())
+	if !ok {
+		return nil, fmt.Errorf("验证码错误")
+	}
+
+	var codes []string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":      true,
+			"totp_last_counter": counter,
+		}).Error; err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("启用两步验证失败: %w", err)
+	}
+	return codes, nil
+}
+
+// Disable 校验密码和验证码后关闭两步验证
+func (s *TwoFactorService) Disable(userID uint, password, code string) error {
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+	required, err := s.IsRequired()
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrTwoFactorRequired
+	}
+	if err := s.userService.CheckPassword(user, password); err != nil {
+		return err
+	}
+	if err := s.verifyCode(user, code); err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":      false,
+			"totp_secret":       models.EncryptedString(""),
+			"totp_last_counter": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes 校验验证码后重新生成恢复码，旧恢复码全部失效
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err := s.verifyCode(user, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("生成恢复码失败: %w", err)
+	}
+	return codes, nil
+}
+
+// VerifyLogin 登录第二步，校验TOTP验证码或恢复码；错误次数计入账户和IP的登录失败次数
+func (s *TwoFactorService) VerifyLogin(userID uint, code, ip string) (*models.User, error) {
+	ctx := context.Background()
+	policy, err := s.policyService.GetPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.checkIP(ctx, ip); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		remainingTime := time.Until(*user.LockedUntil).Minutes()
+		return nil, fmt.Errorf("账户已被锁定，请在 %.0f 分钟后重试", remainingTime)
+	}
+	if user.Status != "active" {
+		return nil, fmt.Errorf("用户已被禁用")
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	if err := s.verifyCode(user, code); err != nil {
+		ipErr := policy.recordIPFailure(ctx, ip)
+		loginErr := policy.recordLoginFailure(user, "验证码错误")
+		if ipErr != nil {
+			return nil, ipErr
+		}
+		return nil, loginErr
+	}
+
+	if user.FailedLoginCount > 0 || user.LockoutCount > 0 {
+		user.FailedLoginCount = 0
+		user.LockoutCount = 0
+		database.DB.Model(user).Updates(map[string]interface{}{
+			"failed_login_count": 0,
+			"lockout_count":      0,
+		})
+	}
+	return user, nil
+}
+
+// verifyCode 校验TOTP验证码或未使用的恢复码
+func (s *TwoFactorService) verifyCode(user *models.User, code string) error {
+	code = strings.TrimSpace(code)
+
+	if counter, ok := utils.ValidateTOTP(string(user.TOTPSecret), code, time.Now()); ok {
+		// 同一时间步的验证码只能使用一次
+		result := database.DB.Model(&models.User{}).
+			Where("id = ? AND totp_last_counter < ?", user.ID, counter).
+			Update("totp_last_counter", counter)
+		if result.Error != nil {
+			return fmt.Errorf("校验验证码失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("验证码已使用，请等待下一个验证码")
+		}
+		user.TOTPLastCounter = counter
+		return nil
+	}
+
+	// 恢复码
+	now := time.Now()
+	result := database.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", &now)
+	if result.Error != nil {
+		return fmt.Errorf("校验恢复码失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("验证码错误")
+	}
+	return nil
+}
+
+// replaceRecoveryCodes 删除旧恢复码并生成新的恢复码
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := utils.GenerateTOTPSecret()
+		if err != nil {
+			return nil, err
+		}
+		// 格式 xxxxx-xxxxx，便于抄写
+		code := strings.ToLower(raw[:5] + "-" + raw[5:10])
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashToken(normalizeRecoveryCode(code)),
+		})
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空格和连字符
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
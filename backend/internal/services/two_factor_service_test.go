@@ -0,0 +1,67 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emby-client-go/backend/internal/database"
+	"github.com/emby-client-go/backend/internal/models"
+	"github.com/emby-client-go/backend/internal/utils"
+)
+
+func TestVerifyCodeRejectsReplay(t *testing.T) {
+	setupTestDB(t)
+	service := NewTwoFactorService()
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("生成TOTP密钥失败: %v", err)
+	}
+	user := createTestUser(t, "alice", RoleUser)
+	database.DB.Model(user).Updates(map[string]interface{}{
+		"totp_enabled": true,
+		"totp_secret":  models.EncryptedString(secret),
+	})
+	recovery, err := replaceRecoveryCodes(database.DB, user.ID)
+	if err != nil {
+		t.Fatalf("生成恢复码失败: %v", err)
+	}
+
+	code := func(offset int64) string {
+		c, err := utils.TOTPCode(secret, utils.TOTPCounter(time.Now())+offset)
+		if err != nil {
+			t.Fatalf("计算验证码失败: %v", err)
+		}
+		return c
+	}
+	current, previous, next := code(0), code(-1), code(1)
+
+	// 按顺序执行，每一步依赖前面已使用的验证码
+	steps := []struct {
+		name   string
+		code   string
+		wantOK bool
+	}{
+		{"当前验证码", current, true},
+		{"重复使用同一验证码", current, false},
+		{"已使用时间步之前的验证码", previous, false},
+		{"下一个时间步的验证码", next, true},
+		{"重复使用下一个时间步的验证码", next, false},
+		{"错误的验证码", "000000", false},
+		{"恢复码", recovery[0], true},
+		{"重复使用恢复码", recovery[0], false},
+		{"恢复码忽略大小写和连字符", " " + strings.ToUpper(strings.ReplaceAll(recovery[1], "-", "")) + " ", true},
+	}
+
+	for _, step := range steps {
+		var stored models.User
+		if err := database.DB.First(&stored, user.ID).Error; err != nil {
+			t.Fatalf("查询用户失败: %v", err)
+		}
+		err := service.verifyCode(&stored, step.code)
+		if ok := err == nil; ok != step.wantOK {
+			t.Fatalf("%s: err = %v, wantOK %v", step.name, err, step.wantOK)
+		}
+	}
+}
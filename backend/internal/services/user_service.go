@@ -1,9 +1,13 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
+	"github.com/emby-client-go/backend/internal/config"
 	"github.com/emby-client-go/backend/internal/database"
 	"github.com/emby-client-go/backend/internal/dto"
 	"github.com/emby-client-go/backend/internal/models"
@@ -11,10 +15,31 @@ import (
 	"gorm.io/gorm"
 )
 
-type UserService struct{}
+var (
+	// ErrInvalidPassword 密码错误
+	ErrInvalidPassword = errors.New("密码错误")
+	// ErrUserNotFound 用户不存在
+	ErrUserNotFound = errors.New("用户不存在")
+)
+
+type UserService struct {
+	ldapService    *LDAPService
+	rbacService    *RBACService
+	sessionService *SessionService
+	auditService   *AuditService
+	accountService *AccountService
+	policyService  *SecurityPolicyService
+}
 
 func NewUserService() *UserService {
-	return &UserService{}
+	return &UserService{
+		ldapService:    NewLDAPService(),
+		rbacService:    NewRBACService(),
+		sessionService: NewSessionService(),
+		auditService:   NewAuditService(),
+		accountService: NewAccountService(),
+		policyService:  NewSecurityPolicyService(),
+	}
 }
 
 // Register 用户注册
@@ -30,36 +55,65 @@ func (s *UserService) Register(req dto.RegisterRequest) (*models.User, error) {
 		return nil, fmt.Errorf("邮箱已存在")
 	}
 
-	// 哈希密码
-	hashedPassword, err := utils.HashPassword(req.Password)
+	// 按密码策略校验并哈希密码
+	hashedPassword, err := s.policyService.HashPassword(nil, req.Password)
 	if err != nil {
-		return nil, fmt.Errorf("密码加密失败: %v", err)
+		return nil, err
 	}
 
 	// 创建用户
 	user := models.User{
-		Username: req.Username,
-		Email:    req.Email,
-		Password: hashedPassword,
-		Nickname: req.Nickname,
-		Role:     "user",
-		Status:   "active",
+		Username:   req.Username,
+		Email:      req.Email,
+		Password:   hashedPassword,
+		Nickname:   req.Nickname,
+		Role:       "user",
+		Status:     "active",
+		AuthSource: AuthSourceLocal,
 	}
 
 	if err := database.DB.Create(&user).Error; err != nil {
 		return nil, fmt.Errorf("创建用户失败: %v", err)
 	}
+	s.policyService.RecordPassword(user.ID, hashedPassword)
+
+	// 验证邮件发送失败不影响注册，用户可以在登录页重新发送
+	if err := s.accountService.SendVerificationEmail(&user); err != nil {
+		log.Printf("发送用户 %s 的验证邮件失败: %v", user.Username, err)
+	}
 
 	return &user, nil
 }
 
-// Login 用户登录
-func (s *UserService) Login(req dto.LoginRequest) (*models.User, error) {
+// Login 用户登录，ip为客户端地址，同一IP失败次数过多时拒绝登录
+func (s *UserService) Login(req dto.LoginRequest, ip string) (*models.User, error) {
+	ctx := context.Background()
+	policy, err := s.policyService.GetPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.checkIP(ctx, ip); err != nil {
+		return nil, err
+	}
+
 	var user models.User
 
 	// 查找用户
 	if err := database.DB.Where("username = ? OR email = ?", req.Username, req.Username).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			// 本地不存在时尝试目录认证，首次登录自动创建账户
+			if s.ldapService.Enabled() {
+				user, err := s.loginNewDirectoryUser(req)
+				if err != nil {
+					if ipErr := policy.recordIPFailure(ctx, ip); ipErr != nil {
+						return nil, ipErr
+					}
+				}
+				return user, err
+			}
+			if err := policy.recordIPFailure(ctx, ip); err != nil {
+				return nil, err
+			}
 			return nil, fmt.Errorf("用户不存在")
 		}
 		return nil, fmt.Errorf("查询用户失败: %v", err)
@@ -71,12 +125,19 @@ func (s *UserService) Login(req dto.LoginRequest) (*models.User, error) {
 		return nil, fmt.Errorf("账户已被锁定，请在 %.0f 分钟后重试", remainingTime)
 	}
 
-	// 如果锁定时间已过，解锁账户
+	// 如果锁定时间已过，解锁账户；只恢复因锁定而停用的状态，被管理员禁用的账户保持禁用
 	if user.LockedUntil != nil && time.Now().After(*user.LockedUntil) {
+		updates := map[string]interface{}{
+			"locked_until":       nil,
+			"failed_login_count": 0,
+		}
+		if user.Status == "locked" {
+			updates["status"] = "active"
+			user.Status = "active"
+		}
 		user.LockedUntil = nil
 		user.FailedLoginCount = 0
-		user.Status = "active"
-		database.DB.Save(&user)
+		database.DB.Model(&user).Updates(updates)
 	}
 
 	// 检查用户状态
@@ -85,39 +146,91 @@ func (s *UserService) Login(req dto.LoginRequest) (*models.User, error) {
 	}
 
 	// 验证密码
-	if !utils.CheckPasswordHash(req.Password, user.Password) {
-		// 增加失败次数
-		user.FailedLoginCount++
-
-		// 如果失败次数达到5次，锁定账户15分钟
-		if user.FailedLoginCount >= 5 {
-			lockUntil := time.Now().Add(15 * time.Minute)
-			user.LockedUntil = &lockUntil
-			user.Status = "locked"
-			database.DB.Save(&user)
-			return nil, fmt.Errorf("登录失败次数过多，账户已被锁定15分钟")
+	if err := s.CheckPassword(&user, req.Password); err != nil {
+		if errors.Is(err, ErrInvalidPassword) {
+			ipErr := policy.recordIPFailure(ctx, ip)
+			loginErr := policy.recordLoginFailure(&user, "密码错误")
+			if ipErr != nil {
+				return nil, ipErr
+			}
+			return nil, loginErr
 		}
-
-		database.DB.Save(&user)
-		return nil, fmt.Errorf("密码错误，还剩 %d 次尝试机会", 5-user.FailedLoginCount)
+		return nil, err
 	}
 
-	// 登录成功，重置失败次数
+	// 密码正确，未启用两步验证时登录成功，重置失败次数；启用时在验证码通过后重置
+	// 只更新相关字段，避免用读取时的失败次数覆盖并发请求累加的结果
 	now := time.Now()
+	updates := map[string]interface{}{
+		"last_login":   &now,
+		"locked_until": nil,
+	}
 	user.LastLogin = &now
-	user.FailedLoginCount = 0
+	if !user.TOTPEnabled {
+		updates["failed_login_count"] = 0
+		updates["lockout_count"] = 0
+		user.FailedLoginCount = 0
+		user.LockoutCount = 0
+	}
 	user.LockedUntil = nil
-	database.DB.Save(&user)
+	database.DB.Model(&user).Updates(updates)
+
+	// 目录用户的邮箱由目录管理，不需要验证
+	if config.AppConfig.Account.RequireEmailVerification && !user.EmailVerified && user.AuthSource != AuthSourceLDAP {
+		return nil, ErrEmailNotVerified
+	}
 
 	return &user, nil
 }
 
+// CheckPassword 校验用户密码，目录用户通过LDAP绑定校验并同步组和角色
+func (s *UserService) CheckPassword(user *models.User, password string) error {
+	if user.AuthSource != AuthSourceLDAP {
+		if !utils.CheckPasswordHash(password, user.Password) {
+			return ErrInvalidPassword
+		}
+		return nil
+	}
+
+	entry, err := s.ldapService.AuthenticateUser(user, password)
+	if err != nil {
+		if errors.Is(err, ErrLDAPDisabled) {
+			return fmt.Errorf("目录认证未启用，请联系管理员")
+		}
+		return err
+	}
+	// 同步失败不影响登录，下次登录或定期同步时重试
+	if err := s.ldapService.SyncUser(user, entry); err != nil {
+		log.Printf("同步目录用户 %s 失败: %v", user.Username, err)
+	}
+	return nil
+}
+
+// loginNewDirectoryUser 本地不存在的用户通过目录认证登录
+func (s *UserService) loginNewDirectoryUser(req dto.LoginRequest) (*models.User, error) {
+	user, err := s.ldapService.LoginNewUser(req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, ErrLDAPUserNotFound) || errors.Is(err, ErrInvalidPassword) {
+			return nil, fmt.Errorf("用户名或密码错误")
+		}
+		return nil, err
+	}
+	if user.Status != "active" {
+		return nil, fmt.Errorf("用户已被禁用")
+	}
+
+	now := time.Now()
+	user.LastLogin = &now
+	database.DB.Model(user).Update("last_login", now)
+	return user, nil
+}
+
 // GetUserByID 根据ID获取用户
 func (s *UserService) GetUserByID(id uint) (*models.User, error) {
 	var user models.User
 	if err := database.DB.First(&user, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("用户不存在")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("查询用户失败: %v", err)
 	}
@@ -137,19 +250,89 @@ func (s *UserService) ChangePassword(userID uint, req dto.ChangePasswordRequest)
 		return err
 	}
 
+	// 目录用户的密码由LDAP管理
+	if user.AuthSource == AuthSourceLDAP {
+		return ErrDirectoryManagedUser
+	}
+
 	// 验证旧密码
 	if !utils.CheckPasswordHash(req.OldPassword, user.Password) {
 		return fmt.Errorf("原密码错误")
 	}
 
-	// 加密新密码
-	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	// 按密码策略校验并加密新密码
+	hashedPassword, err := s.policyService.HashPassword(user, req.NewPassword)
 	if err != nil {
-		return fmt.Errorf("密码加密失败: %v", err)
+		return err
 	}
 
 	// 更新密码
-	return database.DB.Model(user).Update("password", hashedPassword).Error
+	if err := database.DB.Model(user).Update("password", hashedPassword).Error; err != nil {
+		return err
+	}
+	s.policyService.RecordPassword(user.ID, hashedPassword)
+	return nil
+}
+
+// SetAuthSource 设置用户的认证来源
+// 改为目录用户时按用户名在目录中查找并关联；改回本地账户时必须设置新密码
+func (s *UserService) SetAuthSource(actor AuditActor, id uint, req dto.SetAuthSourceRequest) error {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkManageTarget(actor, user); err != nil {
+		return err
+	}
+	before := userAuditSnapshot(user)
+
+	if err := s.setAuthSource(user, req); err != nil {
+		return err
+	}
+
+	s.recordUserChange(actor, AuditActionUserAuthSource, id, before)
+	return nil
+}
+
+func (s *UserService) setAuthSource(user *models.User, req dto.SetAuthSourceRequest) error {
+	if req.AuthSource == AuthSourceLDAP {
+		entry, err := s.ldapService.LookupUser(user.Username)
+		if err != nil {
+			return err
+		}
+		if err := s.ldapService.LinkUser(user, entry); err != nil {
+			return err
+		}
+		user.AuthSource = AuthSourceLDAP
+		return s.ldapService.SyncUser(user, entry)
+	}
+
+	updates := map[string]interface{}{"auth_source": AuthSourceLocal}
+	hashedPassword := ""
+	if req.Password != "" {
+		var err error
+		hashedPassword, err = s.policyService.HashPassword(user, req.Password)
+		if err != nil {
+			return err
+		}
+		updates["password"] = hashedPassword
+	} else if user.AuthSource == AuthSourceLDAP {
+		return fmt.Errorf("目录用户改为本地账户时必须设置新密码")
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND provider = ?", user.ID, IdentityProviderLDAP).
+			Delete(&models.UserIdentity{}).Error
+	}); err != nil {
+		return err
+	}
+	if hashedPassword != "" {
+		s.policyService.RecordPassword(user.ID, hashedPassword)
+	}
+	return nil
 }
 
 // GetUsers 获取用户列表（分页）
@@ -179,7 +362,327 @@ func (s *UserService) GetUsers(page, pageSize int, search string) ([]models.User
 	return users, total, nil
 }
 
-// DeleteUser 删除用户
-func (s *UserService) DeleteUser(id uint) error {
-	return database.DB.Delete(&models.User{}, id).Error
+// CreateUser 管理员创建本地用户
+func (s *UserService) CreateUser(actor AuditActor, req dto.CreateUserRequest) (*models.User, error) {
+	role := req.Role
+	if role == "" {
+		role = RoleUser
+	}
+	exists, err := s.rbacService.RoleExists(role)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrRoleNotFound
+	}
+	if err := s.rbacService.CheckGrantRole(actor.Role, role); err != nil {
+		return nil, err
+	}
+
+	if err := checkUserUnique(0, req.Username, req.Email); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := s.policyService.HashPassword(nil, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		Username:   req.Username,
+		Email:      req.Email,
+		Password:   hashedPassword,
+		Nickname:   req.Nickname,
+		Role:       role,
+		Status:     "active",
+		AuthSource: AuthSourceLocal,
+		// 管理员创建的账户视为邮箱已验证
+		EmailVerified: true,
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		return nil, fmt.Errorf("创建用户失败: %v", err)
+	}
+	s.policyService.RecordPassword(user.ID, hashedPassword)
+
+	s.auditService.Record(actor, AuditActionUserCreate, AuditTargetUser, user.ID, nil, userAuditSnapshot(&user))
+	return &user, nil
+}
+
+// EditUser 管理员编辑用户的用户名、邮箱、昵称和邮箱验证状态
+func (s *UserService) EditUser(actor AuditActor, id uint, req dto.UpdateUserRequest) (*models.User, error) {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkManageTarget(actor, user); err != nil {
+		return nil, err
+	}
+	before := userAuditSnapshot(user)
+
+	updates := map[string]interface{}{}
+	username, email := "", ""
+	if req.Username != nil && *req.Username != user.Username {
+		username = *req.Username
+		updates["username"] = username
+	}
+	if req.Email != nil && *req.Email != user.Email {
+		email = *req.Email
+		updates["email"] = email
+	}
+	if req.Nickname != nil && *req.Nickname != user.Nickname {
+		updates["nickname"] = *req.Nickname
+	}
+	if req.EmailVerified != nil && *req.EmailVerified != user.EmailVerified {
+		updates["email_verified"] = *req.EmailVerified
+	}
+	if len(updates) == 0 {
+		return user, nil
+	}
+
+	if err := checkUserUnique(id, username, email); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Model(user).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新用户失败: %v", err)
+	}
+
+	s.auditService.Record(actor, AuditActionUserUpdate, AuditTargetUser, id, before, userAuditSnapshot(user))
+	return user, nil
+}
+
+// DisableUser 禁用用户并吊销其全部会话
+func (s *UserService) DisableUser(ctx context.Context, actor AuditActor, id uint) error {
+	if id == actor.UserID {
+		return fmt.Errorf("不能禁用自己的账户")
+	}
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkManageTarget(actor, user); err != nil {
+		return err
+	}
+	if user.Status == "inactive" {
+		return nil
+	}
+	if err := ensureOtherAdmin(user); err != nil {
+		return err
+	}
+	before := userAuditSnapshot(user)
+
+	// 同时清除登录锁定，避免锁定到期后登录时把账户恢复为启用
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"status":       "inactive",
+		"locked_until": nil,
+	}).Error; err != nil {
+		return fmt.Errorf("禁用用户失败: %v", err)
+	}
+	if _, err := s.sessionService.RevokeAllSessions(ctx, id, ""); err != nil {
+		return fmt.Errorf("用户已禁用，但吊销会话失败: %v", err)
+	}
+
+	s.auditService.Record(actor, AuditActionUserDisable, AuditTargetUser, id, before, userAuditSnapshot(user))
+	return nil
+}
+
+// EnableUser 启用用户，同时清除登录失败锁定
+func (s *UserService) EnableUser(actor AuditActor, id uint) error {
+	return s.activateUser(actor, AuditActionUserEnable, id)
+}
+
+// UnlockUser 解除登录失败锁定，清空失败次数
+func (s *UserService) UnlockUser(actor AuditActor, id uint) error {
+	return s.activateUser(actor, AuditActionUserUnlock, id)
+}
+
+// activateUser 把用户恢复为正常状态
+func (s *UserService) activateUser(actor AuditActor, action string, id uint) error {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkManageTarget(actor, user); err != nil {
+		return err
+	}
+	if action == AuditActionUserUnlock && user.Status == "inactive" {
+		return fmt.Errorf("用户已被禁用，请直接启用")
+	}
+	before := userAuditSnapshot(user)
+	before["failed_login_count"] = user.FailedLoginCount
+	before["locked_until"] = user.LockedUntil
+
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"status":             "active",
+		"failed_login_count": 0,
+		"lockout_count":      0,
+		"locked_until":       nil,
+	}).Error; err != nil {
+		return fmt.Errorf("更新用户状态失败: %v", err)
+	}
+
+	s.auditService.Record(actor, action, AuditTargetUser, id, before, userAuditSnapshot(user))
+	return nil
+}
+
+// ResetPassword 管理员重置本地用户的密码，用户的全部会话需要重新登录
+func (s *UserService) ResetPassword(ctx context.Context, actor AuditActor, id uint, password string) error {
+	if id == actor.UserID {
+		return fmt.Errorf("请通过修改密码功能修改自己的密码")
+	}
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkManageTarget(actor, user); err != nil {
+		return err
+	}
+	if user.AuthSource == AuthSourceLDAP {
+		return ErrDirectoryManagedUser
+	}
+
+	hashedPassword, err := s.policyService.HashPassword(user, password)
+	if err != nil {
+		return err
+	}
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"password":           hashedPassword,
+		"failed_login_count": 0,
+		"lockout_count":      0,
+		"locked_until":       nil,
+	}).Error; err != nil {
+		return fmt.Errorf("重置密码失败: %v", err)
+	}
+	s.policyService.RecordPassword(id, hashedPassword)
+
+	if _, err := s.sessionService.RevokeAllSessions(ctx, id, ""); err != nil {
+		return fmt.Errorf("密码已重置，但吊销会话失败: %v", err)
+	}
+
+	s.auditService.Record(actor, AuditActionUserResetPassword, AuditTargetUser, id, nil, nil)
+	return nil
+}
+
+// ChangeRole 修改用户角色
+func (s *UserService) ChangeRole(actor AuditActor, id uint, role string) error {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkManageTarget(actor, user); err != nil {
+		return err
+	}
+	before := userAuditSnapshot(user)
+	if user.Role == role {
+		return nil
+	}
+
+	if err := s.rbacService.AssignRole(actor.Role, id, role); err != nil {
+		return err
+	}
+	user.Role = role
+
+	s.auditService.Record(actor, AuditActionUserRoleChange, AuditTargetUser, id, before, userAuditSnapshot(user))
+	return nil
+}
+
+// DeleteUser 删除用户，同时吊销会话并清理令牌、外部身份和服务器共享
+func (s *UserService) DeleteUser(ctx context.Context, actor AuditActor, id uint) error {
+	if id == actor.UserID {
+		return fmt.Errorf("不能删除自己的账户")
+	}
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkManageTarget(actor, user); err != nil {
+		return err
+	}
+	if err := ensureOtherAdmin(user); err != nil {
+		return err
+	}
+	before := userAuditSnapshot(user)
+
+	if _, err := s.sessionService.RevokeAllSessions(ctx, id, ""); err != nil {
+		return fmt.Errorf("吊销会话失败: %v", err)
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{
+			&models.APIToken{},
+			&models.UserIdentity{},
+			&models.RecoveryCode{},
+			&models.UserEmbyServer{},
+		} {
+			if err := tx.Where("user_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(user).Error
+	})
+	if err != nil {
+		return fmt.Errorf("删除用户失败: %v", err)
+	}
+
+	s.auditService.Record(actor, AuditActionUserDelete, AuditTargetUser, id, before, nil)
+	return nil
+}
+
+// checkManageTarget 只能管理权限不超过自己的用户，避免通过重置密码、修改邮箱等方式接管更高权限的账户
+func (s *UserService) checkManageTarget(actor AuditActor, user *models.User) error {
+	if err := s.rbacService.CheckGrantRole(actor.Role, user.Role); err != nil {
+		if errors.Is(err, ErrPermissionNotHeld) {
+			return fmt.Errorf("%w: 不能管理拥有更多权限的用户", ErrPermissionNotHeld)
+		}
+		return err
+	}
+	return nil
+}
+
+// recordUserChange 重新读取用户并记录变更前后的快照
+func (s *UserService) recordUserChange(actor AuditActor, action string, id uint, before map[string]interface{}) {
+	var after interface{}
+	if user, err := s.GetUserByID(id); err == nil {
+		after = userAuditSnapshot(user)
+	}
+	s.auditService.Record(actor, action, AuditTargetUser, id, before, after)
+}
+
+// checkUserUnique 检查用户名和邮箱没有被其他用户使用，为空的字段不检查
+// 已删除用户仍占用唯一索引，也需要检查
+func checkUserUnique(id uint, username, email string) error {
+	var count int64
+	if username != "" {
+		if err := database.DB.Unscoped().Model(&models.User{}).Where("username = ? AND id <> ?", username, id).Count(&count).Error; err != nil {
+			return fmt.Errorf("查询用户失败: %v", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("用户名已存在")
+		}
+	}
+	if email != "" {
+		if err := database.DB.Unscoped().Model(&models.User{}).Where("email = ? AND id <> ?", email, id).Count(&count).Error; err != nil {
+			return fmt.Errorf("查询用户失败: %v", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("邮箱已存在")
+		}
+	}
+	return nil
+}
+
+// ensureOtherAdmin 禁用或删除管理员前确认还有其他可用的管理员
+func ensureOtherAdmin(user *models.User) error {
+	if user.Role != RoleAdmin {
+		return nil
+	}
+	var admins int64
+	if err := database.DB.Model(&models.User{}).
+		Where("role = ? AND status = ? AND id <> ?", RoleAdmin, "active", user.ID).
+		Count(&admins).Error; err != nil {
+		return fmt.Errorf("查询管理员失败: %w", err)
+	}
+	if admins == 0 {
+		return fmt.Errorf("不能禁用或删除最后一个可用的管理员")
+	}
+	return nil
 }
\ No newline at end of file
@@ -1,8 +1,10 @@
 package emby
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -23,11 +25,30 @@ const (
 	StatusError
 )
 
+// AuthMode API密钥的传递方式
+type AuthMode string
+
+const (
+	AuthModeHeader AuthMode = "header" // 通过X-Emby-Token/X-Emby-Authorization请求头传递（默认）
+	AuthModeQuery  AuthMode = "query"  // 通过api_key查询参数传递，用于会剥离自定义请求头的代理
+)
+
+// 客户端标识默认值
+const (
+	DefaultClientName    = "EmbyManager"
+	DefaultClientVersion = "1.0"
+)
+
 // Client Emby API客户端
 type Client struct {
 	BaseURL       string
 	APIKey        string
 	HTTPClient    *http.Client
+	authMode      AuthMode
+	clientName    string
+	deviceName    string
+	deviceID      string
+	version       string
 	mutex         sync.RWMutex
 	status        ConnectionStatus
 	lastCheck     int64 // Unix纳秒时间戳
@@ -37,19 +58,108 @@ type Client struct {
 
 	// 状态监控
 	onStatusChange func(status ConnectionStatus, err error)
+	// 每次API调用结束后回调，用于统计请求指标
+	onRequest func(result RequestResult)
+
+	// 访问令牌失效时用于获取新令牌，未设置时不自动续期
+	tokenRefresher func(ctx context.Context) (string, error)
+	refreshMutex   sync.Mutex
+}
+
+// RequestResult 一次API调用（含重试）的结果
+type RequestResult struct {
+	Method   string
+	Path     string
+	Retries  int           // 实际重试次数
+	Duration time.Duration // 包含重试和退避等待的总耗时
+	Err      error
 }
 
-// NewClient 创建新的Emby客户端
+// ErrUnauthorized 访问令牌或API密钥无效
+var ErrUnauthorized = errors.New("认证失败")
+
+// ClientOptions 客户端选项
+type ClientOptions struct {
+	Timeout    time.Duration // 单次请求超时
+	MaxRetries int           // 最大重试次数
+	RetryDelay time.Duration // 基础重试延迟，按指数退避
+	AuthMode   AuthMode      // API密钥传递方式
+	ClientName string        // X-Emby-Authorization中的Client
+	DeviceName string        // X-Emby-Authorization中的Device
+	DeviceID   string        // X-Emby-Authorization中的DeviceId
+	Version    string        // X-Emby-Authorization中的Version
+}
+
+// DefaultClientOptions 默认客户端选项
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		Timeout:    30 * time.Second,
+		MaxRetries: 3,
+		RetryDelay: time.Second,
+		AuthMode:   AuthModeHeader,
+		ClientName: DefaultClientName,
+		DeviceName: DefaultClientName,
+		DeviceID:   DefaultClientName,
+		Version:    DefaultClientVersion,
+	}
+}
+
+// MaxDuration 单次调用在全部重试后的最长耗时
+func (o ClientOptions) MaxDuration() time.Duration {
+	total := o.Timeout * time.Duration(o.MaxRetries+1)
+	for attempt := 1; attempt <= o.MaxRetries; attempt++ {
+		total += o.RetryDelay * time.Duration(1<<uint(attempt-1))
+	}
+	return total
+}
+
+// NewClient 使用默认选项创建新的Emby客户端
 func NewClient(baseURL, apiKey string) *Client {
+	return NewClientWithOptions(baseURL, apiKey, DefaultClientOptions())
+}
+
+// NewClientWithOptions 使用指定选项创建新的Emby客户端，未设置的选项使用默认值（MaxRetries为负数时使用默认值）
+func NewClientWithOptions(baseURL, apiKey string, opts ClientOptions) *Client {
+	defaults := DefaultClientOptions()
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaults.Timeout
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = defaults.MaxRetries
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = defaults.RetryDelay
+	}
+	if opts.AuthMode != AuthModeQuery {
+		opts.AuthMode = AuthModeHeader
+	}
+	if opts.ClientName == "" {
+		opts.ClientName = defaults.ClientName
+	}
+	if opts.DeviceName == "" {
+		opts.DeviceName = defaults.DeviceName
+	}
+	if opts.DeviceID == "" {
+		opts.DeviceID = defaults.DeviceID
+	}
+	if opts.Version == "" {
+		opts.Version = defaults.Version
+	}
+
 	return &Client{
 		BaseURL:        strings.TrimSuffix(baseURL, "/"),
 		APIKey:         apiKey,
 		HTTPClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: opts.Timeout,
 		},
+		authMode:       opts.AuthMode,
+		clientName:     opts.ClientName,
+		deviceName:     opts.DeviceName,
+		deviceID:       opts.DeviceID,
+		version:        opts.Version,
 		status:         StatusDisconnected,
-		maxRetries:     3,
-		baseRetryDelay: time.Second,
+		maxRetries:     int32(opts.MaxRetries),
+		baseRetryDelay: opts.RetryDelay,
 	}
 }
 
@@ -60,6 +170,34 @@ func (c *Client) SetStatusChangeCallback(callback func(ConnectionStatus, error))
 	c.onStatusChange = callback
 }
 
+// SetRequestObserver 设置API调用结束后的回调
+func (c *Client) SetRequestObserver(observer func(RequestResult)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onRequest = observer
+}
+
+// SetTokenRefresher 设置访问令牌续期函数，请求返回401时调用并重试一次
+func (c *Client) SetTokenRefresher(refresher func(ctx context.Context) (string, error)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.tokenRefresher = refresher
+}
+
+// SetAPIKey 更新API密钥或访问令牌
+func (c *Client) SetAPIKey(apiKey string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.APIKey = apiKey
+}
+
+// token 获取当前的API密钥或访问令牌
+func (c *Client) token() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.APIKey
+}
+
 // updateStatus 更新连接状态
 func (c *Client) updateStatus(status ConnectionStatus, err error) {
 	c.mutex.Lock()
@@ -89,7 +227,9 @@ func (c *Client) buildURL(path string, params map[string]string) string {
 	}
 
 	q := u.Query()
-	q.Set("api_key", c.APIKey)
+	if token := c.token(); c.authMode == AuthModeQuery && token != "" {
+		q.Set("api_key", token)
+	}
 
 	for key, value := range params {
 		q.Set(key, value)
@@ -99,6 +239,54 @@ func (c *Client) buildURL(path string, params map[string]string) string {
 	return u.String()
 }
 
+// setAuthHeaders 设置认证请求头
+func (c *Client) setAuthHeaders(req *http.Request) {
+	authorization := fmt.Sprintf(`MediaBrowser Client="%s", Device="%s", DeviceId="%s", Version="%s"`,
+		c.clientName, c.deviceName, c.deviceID, c.version)
+
+	if token := c.token(); c.authMode == AuthModeHeader && token != "" {
+		req.Header.Set("X-Emby-Token", token)
+		authorization += fmt.Sprintf(`, Token="%s"`, token)
+	}
+
+	req.Header.Set("X-Emby-Authorization", authorization)
+}
+
+// redact 将字符串中的API密钥替换为掩码
+func (c *Client) redact(s string) string {
+	token := c.token()
+	if token == "" {
+		return s
+	}
+	s = strings.ReplaceAll(s, token, "***")
+	if escaped := url.QueryEscape(token); escaped != token {
+		s = strings.ReplaceAll(s, escaped, "***")
+	}
+	return s
+}
+
+// redactedError 已脱敏的错误，保留原始错误用于errors.Is判断
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError 对错误信息中的API密钥脱敏
+func (c *Client) redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{msg: c.redact(err.Error()), err: err}
+}
+
 // SystemInfo Emby系统信息
 type SystemInfo struct {
 	ServerName         string `json:"ServerName"`
@@ -125,15 +313,89 @@ type DeviceInfo struct {
 type Library struct {
 	Name           string `json:"Name"`
 	ID             string `json:"Id"`
+	ItemID         string `json:"ItemId"` // VirtualFolders接口返回的媒体库项目ID
 	CollectionType string `json:"CollectionType"`
 	ItemCount      int    `json:"ItemCount"`
 }
 
-// doRequest 执行HTTP请求（带重试机制）
+// LibraryID 返回可用于ParentId查询的媒体库ID
+func (l Library) LibraryID() string {
+	if l.ItemID != "" {
+		return l.ItemID
+	}
+	return l.ID
+}
+
+// doRequest 执行HTTP请求（带重试机制），返回的错误中不包含API密钥
 func (c *Client) doRequest(ctx context.Context, method, path string, params map[string]string) ([]byte, error) {
+	return c.send(ctx, method, path, params, nil)
+}
+
+// doJSONRequest 以JSON请求体执行HTTP请求
+func (c *Client) doJSONRequest(ctx context.Context, method, path string, payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+	return c.send(ctx, method, path, nil, data)
+}
+
+// send 执行请求，访问令牌失效时续期后重试一次
+func (c *Client) send(ctx context.Context, method, path string, params map[string]string, payload []byte) ([]byte, error) {
+	body, err := c.doRequestWithRetry(ctx, method, path, params, payload)
+	if errors.Is(err, ErrUnauthorized) {
+		if renewErr := c.renewToken(ctx); renewErr == nil {
+			body, err = c.doRequestWithRetry(ctx, method, path, params, payload)
+		}
+	}
+	if err != nil {
+		return nil, c.redactError(err)
+	}
+	return body, nil
+}
+
+// renewToken 调用续期函数获取新的访问令牌
+func (c *Client) renewToken(ctx context.Context) error {
+	c.mutex.RLock()
+	refresher := c.tokenRefresher
+	c.mutex.RUnlock()
+	if refresher == nil {
+		return ErrUnauthorized
+	}
+
+	// 并发请求同时遇到401时只续期一次
+	oldToken := c.token()
+	c.refreshMutex.Lock()
+	defer c.refreshMutex.Unlock()
+	if c.token() != oldToken {
+		return nil
+	}
+
+	token, err := refresher(ctx)
+	if err != nil {
+		return fmt.Errorf("续期访问令牌失败: %w", err)
+	}
+	c.SetAPIKey(token)
+	return nil
+}
+
+// doRequestWithRetry 执行HTTP请求并在失败时按指数退避重试
+func (c *Client) doRequestWithRetry(ctx context.Context, method, path string, params map[string]string, payload []byte) (result []byte, err error) {
 	maxRetries := int(atomic.LoadInt32(&c.maxRetries))
 	var lastErr error
 
+	started := time.Now()
+	retries := 0
+	defer func() {
+		c.observeRequest(RequestResult{
+			Method:   method,
+			Path:     path,
+			Retries:  retries,
+			Duration: time.Since(started),
+			Err:      err,
+		})
+	}()
+
 	for attempt := 0; attempt <= maxRetries; attempt++ {
 		if attempt > 0 {
 			// 指数退避
@@ -143,14 +405,19 @@ func (c *Client) doRequest(ctx context.Context, method, path string, params map[
 				return nil, ctx.Err()
 			case <-time.After(backoffDuration):
 			}
+			retries = attempt
 		}
 
 		// 更新重试计数
 		atomic.StoreInt32(&c.retryCount, int32(attempt))
 
 		// 构建请求
+		var reqBody io.Reader
+		if payload != nil {
+			reqBody = bytes.NewReader(payload)
+		}
 		url := c.buildURL(path, params)
-		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 		if err != nil {
 			lastErr = fmt.Errorf("创建请求失败: %w", err)
 			continue
@@ -158,7 +425,11 @@ func (c *Client) doRequest(ctx context.Context, method, path string, params map[
 
 		// 设置请求头
 		req.Header.Set("Accept", "application/json")
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
 		req.Header.Set("User-Agent", "EmbyManager/1.0")
+		c.setAuthHeaders(req)
 
 		// 执行请求
 		resp, err := c.HTTPClient.Do(req)
@@ -176,27 +447,42 @@ func (c *Client) doRequest(ctx context.Context, method, path string, params map[
 			continue
 		}
 
-		// 检查状态码
-		if resp.StatusCode == http.StatusOK {
+		// 检查状态码，播放控制等命令返回204
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 			c.updateStatus(StatusConnected, nil)
 			return body, nil
 		}
 
+		// 401表示令牌失效，由调用方决定是否续期
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("%w，状态码: %d, 响应: %s", ErrUnauthorized, resp.StatusCode, string(body))
+		}
+
 		// 对于5xx错误重试，4xx错误直接返回
+		// 4xx说明服务器可以正常响应，只是请求本身有问题（如项目不存在），不改变连接状态
 		if resp.StatusCode >= 500 {
 			lastErr = fmt.Errorf("服务器错误，状态码: %d, 响应: %s", resp.StatusCode, string(body))
 			continue
-		} else {
-			c.updateStatus(StatusError, fmt.Errorf("客户端错误，状态码: %d", resp.StatusCode))
-			return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(body))
 		}
+		return nil, fmt.Errorf("请求失败，状态码: %d, 响应: %s", resp.StatusCode, string(body))
 	}
 
-	c.updateStatus(StatusError, lastErr)
+	// 网络错误或5xx重试后仍然失败才视为连接异常
+	c.updateStatus(StatusError, c.redactError(lastErr))
 	atomic.StoreInt32(&c.retryCount, 0)
 	return nil, fmt.Errorf("请求失败，已重试%d次: %w", maxRetries, lastErr)
 }
 
+// observeRequest 通知API调用结果
+func (c *Client) observeRequest(result RequestResult) {
+	c.mutex.RLock()
+	observer := c.onRequest
+	c.mutex.RUnlock()
+	if observer != nil {
+		observer(result)
+	}
+}
+
 // GetSystemInfo 获取系统信息
 func (c *Client) GetSystemInfo(ctx context.Context) (*SystemInfo, error) {
 	body, err := c.doRequest(ctx, "GET", "/System/Info", nil)
@@ -312,14 +598,68 @@ func (c *Client) HealthCheck(ctx context.Context) error {
 
 // MediaItem 媒体项目信息
 type MediaItem struct {
-	ID             string `json:"Id"`
-	Name           string `json:"Name"`
-	Type           string `json:"Type"`
-	CollectionType string `json:"CollectionType"`
-	Path           string `json:"Path"`
-	ItemCount      int    `json:"ChildCount"`
+	ID                string            `json:"Id"`
+	Name              string            `json:"Name"`
+	Type              string            `json:"Type"`
+	CollectionType    string            `json:"CollectionType"`
+	Path              string            `json:"Path"`
+	ItemCount         int               `json:"ChildCount"`
+	ParentID          string            `json:"ParentId"`
+	SeriesID          string            `json:"SeriesId"`
+	SeriesName        string            `json:"SeriesName"`
+	SeasonID          string            `json:"SeasonId"`
+	IndexNumber       *int              `json:"IndexNumber"`       // 集号（剧集）或季号（季）
+	ParentIndexNumber *int              `json:"ParentIndexNumber"` // 季号（剧集）
+	ProductionYear    *int              `json:"ProductionYear"`
+	RunTimeTicks      int64             `json:"RunTimeTicks"`
+	Container         string            `json:"Container"`
+	Width             int               `json:"Width"`
+	Height            int               `json:"Height"`
+	MediaSources      []MediaSourceInfo `json:"MediaSources"`
+	MediaStreams      []MediaStream     `json:"MediaStreams"`
+	DateLastSaved     string            `json:"DateLastSaved"`
+}
+
+// LastSavedAt 解析项目的最后保存时间，无法解析时返回零值
+func (i MediaItem) LastSavedAt() time.Time {
+	if i.DateLastSaved == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, i.DateLastSaved)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// MediaSourceInfo 媒体源信息
+type MediaSourceInfo struct {
+	ID           string        `json:"Id"`
+	Path         string        `json:"Path"`
+	Container    string        `json:"Container"`
+	Size         int64         `json:"Size"`
+	RunTimeTicks int64         `json:"RunTimeTicks"`
+	MediaStreams []MediaStream `json:"MediaStreams"`
+}
+
+// MediaStream 媒体流信息
+type MediaStream struct {
+	Index  int    `json:"Index"`
+	Type   string `json:"Type"` // Video, Audio, Subtitle
+	Codec  string `json:"Codec"`
+	Width  int    `json:"Width"`
+	Height int    `json:"Height"`
 }
 
+// LibraryItemTypes 目录同步需要抓取的项目类型
+const LibraryItemTypes = "Movie,Series,Season,Episode,Audio"
+
+// libraryItemFields 目录同步需要返回的附加字段
+const libraryItemFields = "Path,ParentId,ProductionYear,MediaSources,MediaStreams,DateLastSaved"
+
+// libraryItemSortBy 目录同步分页的排序，按ID兜底保证顺序稳定，避免翻页时跳过或重复项目
+const libraryItemSortBy = "SortName,Id"
+
 // GetMediaItems 获取媒体库项目列表
 func (c *Client) GetMediaItems(ctx context.Context, parentID string) ([]MediaItem, error) {
 	path := "/Items"
@@ -348,13 +688,31 @@ func (c *Client) GetMediaItems(ctx context.Context, parentID string) ([]MediaIte
 
 // GetLibraryItems 获取特定媒体库的所有项目（分页）
 func (c *Client) GetLibraryItems(ctx context.Context, libraryID string, startIndex, limit int) ([]MediaItem, int, error) {
+	return c.queryLibraryItems(ctx, libraryID, startIndex, limit, nil)
+}
+
+// GetChangedLibraryItems 获取媒体库中自since之后有变更的项目（分页）
+func (c *Client) GetChangedLibraryItems(ctx context.Context, libraryID string, since time.Time, startIndex, limit int) ([]MediaItem, int, error) {
+	return c.queryLibraryItems(ctx, libraryID, startIndex, limit, map[string]string{
+		"MinDateLastSaved": since.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+// queryLibraryItems 分页查询媒体库项目
+func (c *Client) queryLibraryItems(ctx context.Context, libraryID string, startIndex, limit int, extra map[string]string) ([]MediaItem, int, error) {
 	path := "/Items"
 	params := map[string]string{
-		"ParentId":   libraryID,
-		"Recursive":  "true",
-		"StartIndex": fmt.Sprintf("%d", startIndex),
-		"Limit":      fmt.Sprintf("%d", limit),
-		"Fields":     "Path",
+		"ParentId":         libraryID,
+		"Recursive":        "true",
+		"StartIndex":       fmt.Sprintf("%d", startIndex),
+		"Limit":            fmt.Sprintf("%d", limit),
+		"Fields":           libraryItemFields,
+		"IncludeItemTypes": LibraryItemTypes,
+		"SortBy":           libraryItemSortBy,
+		"SortOrder":        "Ascending",
+	}
+	for key, value := range extra {
+		params[key] = value
 	}
 
 	body, err := c.doRequest(ctx, "GET", path, params)
@@ -373,6 +731,55 @@ func (c *Client) GetLibraryItems(ctx context.Context, libraryID string, startInd
 	return response.Items, response.TotalCount, nil
 }
 
+// GetLibraryItemIDs 获取媒体库中全部项目的ID（只请求ID，用于删除对账），同时返回上游报告的项目总数
+func (c *Client) GetLibraryItemIDs(ctx context.Context, libraryID string) ([]string, int, error) {
+	const pageSize = 2000
+	var ids []string
+	total := 0
+
+	for startIndex := 0; ; {
+		params := map[string]string{
+			"ParentId":         libraryID,
+			"Recursive":        "true",
+			"StartIndex":       fmt.Sprintf("%d", startIndex),
+			"Limit":            fmt.Sprintf("%d", pageSize),
+			"IncludeItemTypes": LibraryItemTypes,
+			"SortBy":           libraryItemSortBy,
+			"SortOrder":        "Ascending",
+			"Fields":           "",
+			"EnableImages":     "false",
+			"EnableUserData":   "false",
+		}
+
+		body, err := c.doRequest(ctx, "GET", "/Items", params)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var response struct {
+			Items []struct {
+				ID string `json:"Id"`
+			} `json:"Items"`
+			TotalCount int `json:"TotalRecordCount"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, 0, fmt.Errorf("解析媒体库项目ID失败: %w", err)
+		}
+		total = response.TotalCount
+
+		for _, item := range response.Items {
+			ids = append(ids, item.ID)
+		}
+
+		startIndex += len(response.Items)
+		if len(response.Items) == 0 || startIndex >= response.TotalCount {
+			break
+		}
+	}
+
+	return ids, total, nil
+}
+
 // SessionInfo 会话信息
 type SessionInfo struct {
 	Id            string         `json:"Id"`
@@ -437,3 +844,40 @@ func (c *Client) SendSeekCommand(ctx context.Context, sessionID string, position
 	_, err := c.doRequest(ctx, "POST", path, params)
 	return err
 }
+
+// AuthenticationResult 用户名密码登录结果
+type AuthenticationResult struct {
+	User struct {
+		ID     string `json:"Id"`
+		Name   string `json:"Name"`
+		Policy struct {
+			IsAdministrator bool `json:"IsAdministrator"`
+		} `json:"Policy"`
+	} `json:"User"`
+	AccessToken string `json:"AccessToken"`
+	ServerID    string `json:"ServerId"`
+}
+
+// AuthenticateByName 使用用户名密码登录，获取访问令牌
+// 获取到的令牌可作为API密钥用于后续请求
+func (c *Client) AuthenticateByName(ctx context.Context, username, password string) (*AuthenticationResult, error) {
+	payload := map[string]string{
+		"Username": username,
+		"Pw":       password,
+	}
+
+	body, err := c.doJSONRequest(ctx, "POST", "/Users/AuthenticateByName", payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var result AuthenticationResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析登录结果失败: %w", err)
+	}
+	if result.AccessToken == "" {
+		return nil, fmt.Errorf("登录结果中缺少访问令牌")
+	}
+
+	return &result, nil
+}
@@ -3,7 +3,6 @@ package websocket
 import (
 	"encoding/json"
 	"log"
-	"net/http"
 	"sync"
 	"time"
 
@@ -12,7 +11,7 @@ import (
 
 // Message WebSocket消息
 type Message struct {
-	Type      string      `json:"type"`      // 消息类型：system, server-status, device-update, library-update
+	Type      string      `json:"type"`      // 消息类型：system, server-status, device-update, library-update, sync-progress
 	ServerID  string      `json:"server_id"` // 服务器ID（可选）
 	Data      interface{} `json:"data"`      // 消息数据
 	Timestamp time.Time   `json:"timestamp"` // 时间戳
@@ -25,7 +24,7 @@ type Client struct {
 	ServerID string              // 服务器ID（可选，用于服务器特定连接）
 	Conn     *websocket.Conn     // WebSocket连接
 	Send     chan Message         // 发送消息通道
-	Manager  *Manager            // 管理器引用
+	Manager  *Hub                // Hub引用
 	LastPing time.Time           // 最后心跳时间
 	mutex    sync.RWMutex        // 读写锁
 }
@@ -356,6 +355,11 @@ func (h *Hub) SendLibraryUpdate(serverID string, libraries interface{}) {
 	h.SendMessage("library-update", serverID, 0, libraries)
 }
 
+// SendSyncProgress 发送同步任务进度，userID为0时广播给所有客户端
+func (h *Hub) SendSyncProgress(serverID string, userID uint, progress interface{}) {
+	h.SendMessage("sync-progress", serverID, userID, progress)
+}
+
 const (
 	// 写入等待时间
 	writeWait = 10 * time.Second
@@ -1,7 +1,6 @@
 package websocket
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -30,6 +29,7 @@ type EmbyConnection struct {
 
 	// 消息处理
 	messageHandler func(serverID string, message []byte)
+	statusHandler  func(event StatusEvent)
 
 	// 并发安全
 	mutex          sync.RWMutex
@@ -67,6 +67,16 @@ func (s ConnectionStatus) String() string {
 	}
 }
 
+// StatusEvent 连接状态变化事件
+type StatusEvent struct {
+	ServerID string
+	Status   ConnectionStatus
+	Previous ConnectionStatus // 变化前的状态，Previous为Reconnecting时表示重连的结果
+	Attempt  int              // 当前重连次数
+	Latency  time.Duration    // 建立连接的耗时，仅在连接成功或失败时有值
+	Err      error
+}
+
 // Manager WebSocket连接管理器
 type Manager struct {
 	// 连接池：serverID -> EmbyConnection
@@ -84,6 +94,7 @@ type Manager struct {
 
 	// 消息处理器
 	messageHandler func(serverID string, message []byte)
+	statusHandler  func(event StatusEvent)
 }
 
 // NewManager 创建新的WebSocket管理器
@@ -103,6 +114,13 @@ func (m *Manager) SetMessageHandler(handler func(serverID string, message []byte
 	m.messageHandler = handler
 }
 
+// SetStatusHandler 设置连接状态变化处理器，用于记录连接日志
+func (m *Manager) SetStatusHandler(handler func(event StatusEvent)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.statusHandler = handler
+}
+
 // AddConnection 添加新的Emby服务器连接
 func (m *Manager) AddConnection(serverID, serverURL, apiKey string) error {
 	m.mutex.Lock()
@@ -121,9 +139,9 @@ func (m *Manager) AddConnection(serverID, serverURL, apiKey string) error {
 		Status:         Disconnected,
 		MaxReconnects:  m.maxReconnects,
 		ReconnectDelay: m.reconnectDelay,
-		stopChan:       make(chan struct{}),
 		reconnectChan:  make(chan struct{}, 1),
 		messageHandler: m.messageHandler,
+		statusHandler:  m.statusHandler,
 		manager:        m,
 	}
 
@@ -206,6 +224,14 @@ func (ec *EmbyConnection) Start() {
 		return
 	}
 	ec.Status = Connecting
+	// 每次启动使用新的停止通道，停止后可以重新启动
+	ec.stopChan = make(chan struct{})
+	stop := ec.stopChan
+	// 丢弃停止前排队的重连请求
+	select {
+	case <-ec.reconnectChan:
+	default:
+	}
 	ec.mutex.Unlock()
 
 	// 首次连接
@@ -215,7 +241,7 @@ func (ec *EmbyConnection) Start() {
 	}
 
 	// 启动重连监听
-	go ec.reconnectLoop()
+	go ec.reconnectLoop(stop)
 }
 
 // Stop 停止连接
@@ -223,7 +249,9 @@ func (ec *EmbyConnection) Stop() {
 	ec.mutex.Lock()
 	defer ec.mutex.Unlock()
 
-	close(ec.stopChan)
+	if ec.stopChan != nil && !isStopped(ec.stopChan) {
+		close(ec.stopChan)
+	}
 
 	if ec.Conn != nil {
 		ec.Conn.Close()
@@ -234,6 +262,16 @@ func (ec *EmbyConnection) Stop() {
 	log.Printf("服务器 %s 连接已停止", ec.ServerID)
 }
 
+// isStopped 停止通道是否已关闭
+func isStopped(stop chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
 // connect 建立WebSocket连接
 func (ec *EmbyConnection) connect() error {
 	// 构建WebSocket URL
@@ -247,13 +285,20 @@ func (ec *EmbyConnection) connect() error {
 		HandshakeTimeout: 10 * time.Second,
 	}
 
+	start := time.Now()
 	conn, _, err := dialer.Dial(wsURL, nil)
+	latency := time.Since(start)
+
+	ec.mutex.Lock()
+	previous := ec.Status
+	attempt := ec.ReconnectCount
+	stop := ec.stopChan
 	if err != nil {
-		ec.updateStatus(Failed)
+		ec.Status = Failed
+		ec.mutex.Unlock()
+		ec.notifyStatusChange(StatusEvent{Previous: previous, Attempt: attempt, Latency: latency, Err: err})
 		return fmt.Errorf("连接失败: %w", err)
 	}
-
-	ec.mutex.Lock()
 	ec.Conn = conn
 	ec.Status = Connected
 	ec.LastConnected = time.Now()
@@ -263,11 +308,11 @@ func (ec *EmbyConnection) connect() error {
 	log.Printf("服务器 %s WebSocket连接已建立", ec.ServerID)
 
 	// 通知前端客户端
-	ec.notifyStatusChange()
+	ec.notifyStatusChange(StatusEvent{Previous: previous, Attempt: attempt, Latency: latency})
 
 	// 启动读写循环
-	go ec.readLoop()
-	go ec.writeLoop()
+	go ec.readLoop(conn, stop)
+	go ec.writeLoop(conn, stop)
 
 	return nil
 }
@@ -297,27 +342,29 @@ func (ec *EmbyConnection) buildWebSocketURL() (string, error) {
 }
 
 // readLoop 读取消息循环
-func (ec *EmbyConnection) readLoop() {
+func (ec *EmbyConnection) readLoop(conn *websocket.Conn, stop chan struct{}) {
+	var readErr error
 	defer func() {
-		ec.handleDisconnect()
+		ec.handleDisconnect(stop, readErr)
 	}()
 
-	ec.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	ec.Conn.SetPongHandler(func(string) error {
-		ec.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 		return nil
 	})
 
 	for {
 		select {
-		case <-ec.stopChan:
+		case <-stop:
 			return
 		default:
-			_, message, err := ec.Conn.ReadMessage()
+			_, message, err := conn.ReadMessage()
 			if err != nil {
 				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 					log.Printf("服务器 %s WebSocket异常关闭: %v", ec.ServerID, err)
 				}
+				readErr = err
 				return
 			}
 
@@ -328,7 +375,7 @@ func (ec *EmbyConnection) readLoop() {
 }
 
 // writeLoop 写入消息循环
-func (ec *EmbyConnection) writeLoop() {
+func (ec *EmbyConnection) writeLoop(conn *websocket.Conn, stop chan struct{}) {
 	ticker := time.NewTicker(54 * time.Second) // Ping周期
 	defer func() {
 		ticker.Stop()
@@ -336,11 +383,11 @@ func (ec *EmbyConnection) writeLoop() {
 
 	for {
 		select {
-		case <-ec.stopChan:
+		case <-stop:
 			return
 		case <-ticker.C:
-			ec.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := ec.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				log.Printf("服务器 %s 发送Ping失败: %v", ec.ServerID, err)
 				return
 			}
@@ -369,17 +416,22 @@ func (ec *EmbyConnection) handleMessage(message []byte) {
 	}
 }
 
-// handleDisconnect 处理断开连接
-func (ec *EmbyConnection) handleDisconnect() {
+// handleDisconnect 处理断开连接，主动停止的连接不再重连
+func (ec *EmbyConnection) handleDisconnect(stop chan struct{}, err error) {
 	ec.mutex.Lock()
+	if isStopped(stop) {
+		ec.mutex.Unlock()
+		return
+	}
 	if ec.Conn != nil {
 		ec.Conn.Close()
 		ec.Conn = nil
 	}
+	previous := ec.Status
+	ec.Status = Disconnected
 	ec.mutex.Unlock()
 
-	ec.updateStatus(Disconnected)
-	ec.notifyStatusChange()
+	ec.notifyStatusChange(StatusEvent{Previous: previous, Err: err})
 
 	// 触发重连
 	ec.scheduleReconnect()
@@ -395,10 +447,10 @@ func (ec *EmbyConnection) scheduleReconnect() {
 }
 
 // reconnectLoop 重连循环
-func (ec *EmbyConnection) reconnectLoop() {
+func (ec *EmbyConnection) reconnectLoop(stop chan struct{}) {
 	for {
 		select {
-		case <-ec.stopChan:
+		case <-stop:
 			return
 		case <-ec.reconnectChan:
 			ec.attemptReconnect()
@@ -409,19 +461,30 @@ func (ec *EmbyConnection) reconnectLoop() {
 // attemptReconnect 尝试重连
 func (ec *EmbyConnection) attemptReconnect() {
 	ec.mutex.Lock()
+	if ec.Status == Connected {
+		ec.mutex.Unlock()
+		return
+	}
+	previous := ec.Status
 	if ec.ReconnectCount >= ec.MaxReconnects {
 		ec.Status = Failed
+		attempt := ec.ReconnectCount
 		ec.mutex.Unlock()
 		log.Printf("服务器 %s 重连次数已达上限 (%d)", ec.ServerID, ec.MaxReconnects)
-		ec.notifyStatusChange()
+		ec.notifyStatusChange(StatusEvent{
+			Previous: previous,
+			Attempt:  attempt,
+			Err:      fmt.Errorf("重连次数已达上限 (%d)", ec.MaxReconnects),
+		})
 		return
 	}
 	ec.ReconnectCount++
 	ec.Status = Reconnecting
+	attempt := ec.ReconnectCount
 	ec.mutex.Unlock()
 
-	log.Printf("服务器 %s 尝试重连 (第 %d 次)", ec.ServerID, ec.ReconnectCount)
-	ec.notifyStatusChange()
+	log.Printf("服务器 %s 尝试重连 (第 %d 次)", ec.ServerID, attempt)
+	ec.notifyStatusChange(StatusEvent{Previous: previous, Attempt: attempt})
 
 	// 等待一段时间后重连
 	time.Sleep(ec.ReconnectDelay)
@@ -464,22 +527,26 @@ func (ec *EmbyConnection) GetStatus() ConnectionStatus {
 	return ec.Status
 }
 
-// updateStatus 更新连接状态
-func (ec *EmbyConnection) updateStatus(status ConnectionStatus) {
-	ec.mutex.Lock()
-	ec.Status = status
-	ec.mutex.Unlock()
-}
+// notifyStatusChange 通知前端客户端和状态处理器，event中的ServerID和Status由当前连接填充
+func (ec *EmbyConnection) notifyStatusChange(event StatusEvent) {
+	ec.mutex.RLock()
+	event.ServerID = ec.ServerID
+	event.Status = ec.Status
+	lastConnected := ec.LastConnected
+	reconnectCount := ec.ReconnectCount
+	ec.mutex.RUnlock()
 
-// notifyStatusChange 通知状态变化
-func (ec *EmbyConnection) notifyStatusChange() {
 	if ec.manager != nil && ec.manager.hub != nil {
 		ec.manager.hub.SendServerStatus(ec.ServerID, map[string]interface{}{
-			"status":          ec.Status.String(),
-			"last_connected":  ec.LastConnected,
-			"reconnect_count": ec.ReconnectCount,
+			"status":          event.Status.String(),
+			"last_connected":  lastConnected,
+			"reconnect_count": reconnectCount,
 		})
 	}
+
+	if ec.statusHandler != nil {
+		ec.statusHandler(event)
+	}
 }
 
 // ResetReconnectCount 重置重连计数
@@ -0,0 +1,197 @@
+import request, { type ApiResponse } from './request'
+import type { PageResponse } from './auth'
+
+export type AlertEvent = 'server_offline' | 'sync_failed' | 'websocket_failed'
+export type AlertSeverity = 'info' | 'warning' | 'critical'
+export type AlertStatus = 'pending' | 'firing' | 'resolved'
+export type AlertChannelType = 'webhook' | 'slack' | 'email' | 'telegram'
+
+// 通知渠道，密钥只返回指纹
+export interface AlertChannel {
+  id: number
+  name: string
+  type: AlertChannelType
+  url: string
+  secret_fingerprint: string
+  chat_id: string
+  recipients: string[]
+  enabled: boolean
+  created_at: string
+  updated_at: string
+}
+
+// 告警规则
+export interface AlertRule {
+  id: number
+  name: string
+  event: AlertEvent
+  emby_server_id: number | null // 为空时适用于所有服务器
+  duration: number // 条件持续多少秒后告警，0表示立即告警
+  severity: AlertSeverity
+  repeat_interval: number // 重复通知间隔（秒），0表示不重复
+  notify_resolved: boolean
+  enabled: boolean
+  channels: AlertChannel[]
+  created_at: string
+  updated_at: string
+}
+
+// 告警
+export interface Alert {
+  id: number
+  alert_rule_id: number
+  emby_server_id: number
+  event: AlertEvent
+  severity: AlertSeverity
+  status: AlertStatus
+  message: string
+  started_at: string
+  fired_at: string | null
+  resolved_at: string | null
+  last_notified_at: string | null // 已触发但为空表示通知被静默
+  notify_count: number
+  rule?: AlertRule
+  created_at: string
+  updated_at: string
+}
+
+// 静默
+export interface AlertSilence {
+  id: number
+  alert_rule_id: number | null // 为空时匹配所有规则
+  emby_server_id: number | null // 为空时匹配所有服务器
+  starts_at: string
+  ends_at: string
+  reason: string
+  created_by: number
+  created_at: string
+}
+
+export interface AlertRuleRequest {
+  name: string
+  event: AlertEvent
+  emby_server_id?: number | null
+  duration?: number
+  severity: AlertSeverity
+  repeat_interval?: number
+  notify_resolved?: boolean
+  enabled?: boolean
+  channel_ids?: number[]
+}
+
+export interface AlertChannelRequest {
+  name: string
+  type: AlertChannelType
+  url?: string // telegram留空使用官方API地址
+  secret?: string | null // 更新时为null表示不修改
+  chat_id?: string
+  recipients?: string[]
+  enabled?: boolean
+}
+
+export interface AlertSilenceRequest {
+  alert_rule_id?: number | null
+  emby_server_id?: number | null
+  starts_at?: string // RFC3339，默认为当前时间
+  ends_at: string // RFC3339
+  reason?: string
+}
+
+export interface GetAlertsParams {
+  status?: AlertStatus
+  rule_id?: number
+  server_id?: number
+  page?: number
+  page_size?: number
+}
+
+/**
+ * 查询告警
+ */
+export function getAlerts(params: GetAlertsParams): Promise<ApiResponse<PageResponse<Alert>>> {
+  return request.get('/alerts', { params })
+}
+
+/**
+ * 获取告警规则列表
+ */
+export function getAlertRules(): Promise<ApiResponse<AlertRule[]>> {
+  return request.get('/alerts/rules')
+}
+
+/**
+ * 创建告警规则
+ */
+export function createAlertRule(data: AlertRuleRequest): Promise<ApiResponse<AlertRule>> {
+  return request.post('/alerts/rules', data)
+}
+
+/**
+ * 更新告警规则
+ */
+export function updateAlertRule(id: number, data: AlertRuleRequest): Promise<ApiResponse<AlertRule>> {
+  return request.put(`/alerts/rules/${id}`, data)
+}
+
+/**
+ * 删除告警规则
+ */
+export function deleteAlertRule(id: number): Promise<ApiResponse> {
+  return request.delete(`/alerts/rules/${id}`)
+}
+
+/**
+ * 获取通知渠道列表
+ */
+export function getAlertChannels(): Promise<ApiResponse<AlertChannel[]>> {
+  return request.get('/alerts/channels')
+}
+
+/**
+ * 创建通知渠道
+ */
+export function createAlertChannel(data: AlertChannelRequest): Promise<ApiResponse<AlertChannel>> {
+  return request.post('/alerts/channels', data)
+}
+
+/**
+ * 更新通知渠道
+ */
+export function updateAlertChannel(id: number, data: AlertChannelRequest): Promise<ApiResponse<AlertChannel>> {
+  return request.put(`/alerts/channels/${id}`, data)
+}
+
+/**
+ * 删除通知渠道
+ */
+export function deleteAlertChannel(id: number): Promise<ApiResponse> {
+  return request.delete(`/alerts/channels/${id}`)
+}
+
+/**
+ * 发送测试通知
+ */
+export function testAlertChannel(id: number): Promise<ApiResponse> {
+  return request.post(`/alerts/channels/${id}/test`)
+}
+
+/**
+ * 获取静默列表
+ */
+export function getAlertSilences(active?: boolean): Promise<ApiResponse<AlertSilence[]>> {
+  return request.get('/alerts/silences', { params: { active } })
+}
+
+/**
+ * 创建静默
+ */
+export function createAlertSilence(data: AlertSilenceRequest): Promise<ApiResponse<AlertSilence>> {
+  return request.post('/alerts/silences', data)
+}
+
+/**
+ * 删除静默
+ */
+export function deleteAlertSilence(id: number): Promise<ApiResponse> {
+  return request.delete(`/alerts/silences/${id}`)
+}
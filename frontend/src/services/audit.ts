@@ -0,0 +1,49 @@
+import request, { type ApiResponse } from './request'
+import type { PageResponse } from './auth'
+
+// 审计事件
+export interface AuditEvent {
+  id: number
+  actor_id: number | null
+  actor_name: string
+  action: string
+  target_type: string
+  target_id: string
+  before?: Record<string, any>
+  after?: Record<string, any>
+  changes?: Record<string, { before: any; after: any }> // 操作前后发生变化的字段
+  ip: string
+  user_agent: string
+  created_at: string
+}
+
+// 审计事件筛选条件，action以"."结尾时按前缀匹配，时间为RFC3339格式
+export interface AuditQuery {
+  actor_id?: number
+  actor?: string
+  action?: string
+  target_type?: string
+  target_id?: string
+  ip?: string
+  from?: string
+  to?: string
+}
+
+export interface GetAuditEventsParams extends AuditQuery {
+  page?: number
+  page_size?: number
+}
+
+/**
+ * 查询审计事件
+ */
+export function getAuditEvents(params: GetAuditEventsParams): Promise<ApiResponse<PageResponse<AuditEvent>>> {
+  return request.get('/audit', { params })
+}
+
+/**
+ * 导出审计事件
+ */
+export function exportAuditEvents(params: AuditQuery & { format?: 'csv' | 'json' }): Promise<Blob> {
+  return request.get('/audit/export', { params, responseType: 'blob', timeout: 0 }) as unknown as Promise<Blob>
+}
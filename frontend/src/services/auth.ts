@@ -22,7 +22,7 @@ export interface ChangePasswordRequest {
 
 // 刷新令牌请求参数
 export interface RefreshTokenRequest {
-  token: string
+  refresh_token: string
 }
 
 // 用户信息
@@ -33,6 +33,8 @@ export interface UserInfo {
   nickname: string
   role: string
   status: string
+  auth_source?: 'local' | 'ldap' // 目录用户不能修改密码
+  email_verified?: boolean
   last_login?: string
   created_at: string
 }
@@ -40,12 +42,21 @@ export interface UserInfo {
 // 登录响应
 export interface LoginResponse {
   token: string
+  refresh_token: string
+  expires_in: number
   user: UserInfo
+  // 需要两步验证时只返回以下字段
+  two_factor_required?: boolean
+  two_factor_setup_required?: boolean
+  two_factor_token?: string
+  recovery_codes?: string[]
 }
 
 // 刷新令牌响应
 export interface RefreshTokenResponse {
   token: string
+  refresh_token: string
+  expires_in: number
 }
 
 /**
@@ -90,6 +101,38 @@ export function changePassword(data: ChangePasswordRequest): Promise<ApiResponse
   return request.post('/user/change-password', data)
 }
 
+// 密码规则，由系统安全策略决定
+export interface PasswordPolicy {
+  password_min_length: number
+  password_require_upper: boolean
+  password_require_lower: boolean
+  password_require_digit: boolean
+  password_require_symbol: boolean
+}
+
+/**
+ * 获取密码规则
+ */
+export function getPasswordPolicy(): Promise<ApiResponse<PasswordPolicy>> {
+  return request.get('/auth/password-policy')
+}
+
+/**
+ * 按密码规则检查密码，返回错误提示，符合规则时返回空字符串
+ * 泄露密码和历史密码只能由服务端检查
+ */
+export function checkPasswordPolicy(policy: PasswordPolicy, password: string): string {
+  if ([...password].length < policy.password_min_length) {
+    return `密码长度不能少于${policy.password_min_length}位`
+  }
+  const missing: string[] = []
+  if (policy.password_require_upper && !/\p{Lu}/u.test(password)) missing.push('大写字母')
+  if (policy.password_require_lower && !/\p{Ll}/u.test(password)) missing.push('小写字母')
+  if (policy.password_require_digit && !/\p{Nd}/u.test(password)) missing.push('数字')
+  if (policy.password_require_symbol && !/[\p{P}\p{S}]/u.test(password)) missing.push('特殊字符')
+  return missing.length > 0 ? `密码必须包含${missing.join('、')}` : ''
+}
+
 /**
  * 获取用户列表（管理员）
  */
@@ -109,3 +152,57 @@ export interface PageResponse<T> {
 export function getUsers(params: GetUsersParams): Promise<ApiResponse<PageResponse<UserInfo>>> {
   return request.get('/user/list', { params })
 }
+
+/**
+ * 用户管理（管理员）
+ */
+export interface CreateUserRequest {
+  username: string
+  email: string
+  password: string
+  nickname?: string
+  role?: string
+}
+
+export interface UpdateUserRequest {
+  username?: string
+  email?: string
+  nickname?: string
+  email_verified?: boolean
+}
+
+export function getUser(id: number): Promise<ApiResponse<UserInfo>> {
+  return request.get(`/user/${id}`)
+}
+
+export function createUser(data: CreateUserRequest): Promise<ApiResponse<UserInfo>> {
+  return request.post('/user/create', data)
+}
+
+export function updateUser(id: number, data: UpdateUserRequest): Promise<ApiResponse<UserInfo>> {
+  return request.put(`/user/${id}`, data)
+}
+
+export function disableUser(id: number): Promise<ApiResponse> {
+  return request.post(`/user/${id}/disable`)
+}
+
+export function enableUser(id: number): Promise<ApiResponse> {
+  return request.post(`/user/${id}/enable`)
+}
+
+export function unlockUser(id: number): Promise<ApiResponse> {
+  return request.post(`/user/${id}/unlock`)
+}
+
+export function resetUserPassword(id: number, password: string): Promise<ApiResponse> {
+  return request.post(`/user/${id}/reset-password`, { password })
+}
+
+export function setUserRole(id: number, role: string): Promise<ApiResponse> {
+  return request.put(`/user/${id}/role`, { role })
+}
+
+export function deleteUser(id: number): Promise<ApiResponse> {
+  return request.delete(`/user/${id}`)
+}
@@ -34,9 +34,50 @@ service.interceptors.request.use(
   }
 )
 
+// 清除登录状态并跳转到登录页
+function clearAuth() {
+  localStorage.removeItem('token')
+  localStorage.removeItem('refresh_token')
+  localStorage.removeItem('user')
+  router.push('/login')
+}
+
+// 正在进行的刷新请求，并发的401只刷新一次
+let refreshing: Promise<string | null> | null = null
+
+// 使用刷新令牌换取新的访问令牌，失败返回null
+function refreshAccessToken(): Promise<string | null> {
+  const refreshToken = localStorage.getItem('refresh_token')
+  if (!refreshToken) return Promise.resolve(null)
+
+  if (!refreshing) {
+    refreshing = axios
+      .post<ApiResponse<{ token: string; refresh_token: string }>>(
+        `${service.defaults.baseURL}/auth/refresh`,
+        { refresh_token: refreshToken }
+      )
+      .then((response) => {
+        const data = response.data.data!
+        localStorage.setItem('token', data.token)
+        localStorage.setItem('refresh_token', data.refresh_token)
+        return data.token
+      })
+      .catch(() => null)
+      .finally(() => {
+        refreshing = null
+      })
+  }
+  return refreshing
+}
+
 // 响应拦截器
 service.interceptors.response.use(
   (response: AxiosResponse<ApiResponse>) => {
+    // 文件下载直接返回内容
+    if (response.config.responseType === 'blob') {
+      return response.data
+    }
+
     const res = response.data
 
     // 如果返回的状态码不是200，则认为是错误
@@ -45,9 +86,7 @@ service.interceptors.response.use(
 
       // 401: 未授权，跳转到登录页
       if (res.code === 401) {
-        localStorage.removeItem('token')
-        localStorage.removeItem('user')
-        router.push('/login')
+        clearAuth()
       }
 
       return Promise.reject(new Error(res.message || '请求失败'))
@@ -55,7 +94,23 @@ service.interceptors.response.use(
 
     return res
   },
-  (error) => {
+  async (error) => {
+    // 访问令牌过期时先尝试刷新并重试一次
+    const original = error.config
+    if (
+      error.response?.status === 401 &&
+      original &&
+      !original._retried &&
+      !original.url?.startsWith('/auth/')
+    ) {
+      original._retried = true
+      const token = await refreshAccessToken()
+      if (token) {
+        original.headers.Authorization = `Bearer ${token}`
+        return service(original)
+      }
+    }
+
     console.error('响应错误:', error)
 
     // 处理HTTP错误状态码
@@ -69,9 +124,7 @@ service.interceptors.response.use(
           break
         case 401:
           ElMessage.error('登录已过期，请重新登录')
-          localStorage.removeItem('token')
-          localStorage.removeItem('user')
-          router.push('/login')
+          clearAuth()
           break
         case 403:
           ElMessage.error('没有权限访问')
@@ -1,5 +1,6 @@
 import request from './request'
-import type { EmbyServer } from '@/types/server'
+import type { EmbyServer, ConnectionLog, ConnectionLogQuery, ConnectionStats, ServerMetrics, ServerMetricsQuery } from '@/types/server'
+import type { PageResponse } from './auth'
 
 // 服务器API服务
 
@@ -79,3 +80,24 @@ export function syncLibraries(id: number) {
     libraries: any[]
   }>(`/server/${id}/sync-libraries`)
 }
+
+/**
+ * 获取服务器连接日志
+ */
+export function getConnectionLogs(id: number, params: ConnectionLogQuery = {}) {
+  return request.get<PageResponse<ConnectionLog>>(`/server/${id}/connection-logs`, { params })
+}
+
+/**
+ * 获取服务器连接统计，未指定时间范围时统计最近24小时
+ */
+export function getConnectionStats(id: number, params: { from?: string; to?: string } = {}) {
+  return request.get<ConnectionStats>(`/server/${id}/connection-stats`, { params })
+}
+
+/**
+ * 获取服务器可用率和延迟时间序列
+ */
+export function getServerMetrics(id: number, params: ServerMetricsQuery = {}) {
+  return request.get<ServerMetrics>(`/server/${id}/metrics`, { params })
+}
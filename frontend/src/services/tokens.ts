@@ -0,0 +1,72 @@
+import request, { type ApiResponse } from './request'
+
+// 个人访问令牌
+export interface ApiToken {
+  id: number
+  name: string
+  prefix: string
+  scopes: string[]
+  expires_at: string | null
+  last_used_at: string | null
+  last_used_ip: string
+  created_at: string
+  expired: boolean
+}
+
+// 创建令牌响应，token只在创建时返回一次
+export interface CreatedApiToken extends ApiToken {
+  token: string
+}
+
+// 可授予的权限范围
+export interface TokenScope {
+  id: number
+  name: string
+  description: string
+}
+
+export interface CreateApiTokenRequest {
+  name: string
+  scopes: string[]
+  expires_in_days?: number // 0表示永不过期
+}
+
+export interface UpdateApiTokenRequest {
+  name?: string
+  scopes?: string[]
+}
+
+/**
+ * 获取个人访问令牌列表
+ */
+export function getApiTokens(): Promise<ApiResponse<ApiToken[]>> {
+  return request.get('/user/tokens')
+}
+
+/**
+ * 获取可授予的权限范围
+ */
+export function getTokenScopes(): Promise<ApiResponse<TokenScope[]>> {
+  return request.get('/user/tokens/scopes')
+}
+
+/**
+ * 创建个人访问令牌
+ */
+export function createApiToken(data: CreateApiTokenRequest): Promise<ApiResponse<CreatedApiToken>> {
+  return request.post('/user/tokens', data)
+}
+
+/**
+ * 修改个人访问令牌
+ */
+export function updateApiToken(id: number, data: UpdateApiTokenRequest): Promise<ApiResponse<ApiToken>> {
+  return request.put(`/user/tokens/${id}`, data)
+}
+
+/**
+ * 删除个人访问令牌
+ */
+export function deleteApiToken(id: number): Promise<ApiResponse> {
+  return request.delete(`/user/tokens/${id}`)
+}
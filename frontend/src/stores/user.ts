@@ -8,6 +8,7 @@ import router from '@/router'
 export const useUserStore = defineStore('user', () => {
   // 状态
   const token = ref<string>(localStorage.getItem('token') || '')
+  const refreshTokenValue = ref<string>(localStorage.getItem('refresh_token') || '')
   const userInfo = ref<UserInfo | null>(
     localStorage.getItem('user') ? JSON.parse(localStorage.getItem('user')!) : null
   )
@@ -23,11 +24,18 @@ export const useUserStore = defineStore('user', () => {
     try {
       const res = await authApi.login(loginData)
 
+      // 需要两步验证时由登录页继续完成验证
+      if (res.data!.two_factor_required) {
+        return false
+      }
+
       // 保存token和用户信息
       token.value = res.data!.token
+      refreshTokenValue.value = res.data!.refresh_token
       userInfo.value = res.data!.user
 
       localStorage.setItem('token', res.data!.token)
+      localStorage.setItem('refresh_token', res.data!.refresh_token)
       localStorage.setItem('user', JSON.stringify(res.data!.user))
 
       ElMessage.success('登录成功')
@@ -64,8 +72,10 @@ export const useUserStore = defineStore('user', () => {
     } finally {
       // 无论API调用是否成功，都清除本地数据
       token.value = ''
+      refreshTokenValue.value = ''
       userInfo.value = null
       localStorage.removeItem('token')
+      localStorage.removeItem('refresh_token')
       localStorage.removeItem('user')
 
       ElMessage.success('已退出登录')
@@ -77,14 +87,16 @@ export const useUserStore = defineStore('user', () => {
    * 刷新令牌
    */
   async function refreshToken() {
-    if (!token.value) return false
+    if (!refreshTokenValue.value) return false
 
     try {
-      const res = await authApi.refreshToken({ token: token.value })
+      const res = await authApi.refreshToken({ refresh_token: refreshTokenValue.value })
 
-      // 更新token
+      // 更新token，旧的刷新令牌已失效
       token.value = res.data!.token
+      refreshTokenValue.value = res.data!.refresh_token
       localStorage.setItem('token', res.data!.token)
+      localStorage.setItem('refresh_token', res.data!.refresh_token)
 
       return true
     } catch (error) {
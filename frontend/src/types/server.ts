@@ -45,11 +45,68 @@ export interface Device {
 
 export interface ConnectionLog {
   id: number
-  emby_server_id: number
-  user_id: number
-  action: 'connect' | 'disconnect' | 'error'
+  action: 'connect' | 'test' | 'health_check' | 'reconnect' | 'disconnect' | 'error'
+  source: 'http' | 'websocket'
   message?: string
   response_time?: number
   status: 'success' | 'failed'
+  user_id: number | null // 系统自动检测时为空
+  username: string
   created_at: string
 }
+
+export interface ConnectionLogQuery {
+  page?: number
+  page_size?: number
+  action?: ConnectionLog['action']
+  status?: ConnectionLog['status']
+  source?: ConnectionLog['source']
+  from?: string // RFC3339
+  to?: string
+}
+
+// 连接统计，可用率按创建、测试和健康检查的成功比例计算
+export interface ConnectionStats {
+  from: string
+  to: string
+  checks: number
+  successful_checks: number
+  uptime_percent: number | null
+  failures: number
+  disconnects: number
+  latency: {
+    min: number
+    avg: number
+    p50: number
+    p90: number
+    p95: number
+    p99: number
+    max: number
+  } | null
+}
+
+export interface MetricPoint {
+  time: string
+  samples: number
+  up_samples: number
+  uptime_percent: number | null
+  latency_avg: number | null
+  latency_min: number | null
+  latency_max: number | null
+}
+
+export interface ServerMetricsQuery {
+  from?: string
+  to?: string
+  step?: string
+}
+
+export interface ServerMetrics {
+  server_id: number
+  from: string
+  to: string
+  step: number
+  resolution: 'raw' | 'hour' | 'day'
+  summary: MetricPoint
+  points: MetricPoint[]
+}